@@ -0,0 +1,88 @@
+package oanda
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type accountSummaryInfo struct {
+	ID                string `json:"id"`
+	Alias             string `json:"alias"`
+	Currency          string `json:"currency"`
+	Balance           string `json:"balance"`
+	NAV               string `json:"NAV"`
+	UnrealizedPL      string `json:"unrealizedPL"`
+	PL                string `json:"pl"`
+	Financing         string `json:"financing"`
+	MarginUsed        string `json:"marginUsed"`
+	MarginAvailable   string `json:"marginAvailable"`
+	MarginRate        string `json:"marginRate"`
+	OpenTradeCount    int    `json:"openTradeCount"`
+	OpenPositionCount int    `json:"openPositionCount"`
+	PendingOrderCount int    `json:"pendingOrderCount"`
+	LastTransactionID string `json:"lastTransactionID"`
+}
+
+// AccountSummary is the summary of an account. Amounts are in the account currency.
+type AccountSummary struct {
+	ID                string
+	Alias             string
+	Currency          string
+	Balance           float64
+	NAV               float64
+	UnrealizedPL      float64
+	PL                float64
+	Financing         float64
+	MarginUsed        float64
+	MarginAvailable   float64
+	MarginRate        float64
+	OpenTradeCount    int
+	OpenPositionCount int
+	PendingOrderCount int
+	LastTransactionID TransactionID
+}
+
+func (a *accountSummaryInfo) toAccountSummary() (*AccountSummary, error) {
+	var p fieldParser
+	summary := &AccountSummary{
+		ID:                a.ID,
+		Alias:             a.Alias,
+		Currency:          a.Currency,
+		Balance:           p.amount("balance", a.Balance),
+		NAV:               p.amount("nav", a.NAV),
+		UnrealizedPL:      p.amount("unrealized pl", a.UnrealizedPL),
+		PL:                p.amount("pl", a.PL),
+		Financing:         p.amount("financing", a.Financing),
+		MarginUsed:        p.amount("margin used", a.MarginUsed),
+		MarginAvailable:   p.amount("margin available", a.MarginAvailable),
+		MarginRate:        p.amount("margin rate", a.MarginRate),
+		OpenTradeCount:    a.OpenTradeCount,
+		OpenPositionCount: a.OpenPositionCount,
+		PendingOrderCount: a.PendingOrderCount,
+		LastTransactionID: TransactionID(a.LastTransactionID),
+	}
+	return summary, p.err
+}
+
+// FetchAccountSummary fetches the summary of the account.
+func (c *Client) FetchAccountSummary() (*AccountSummary, error) {
+	body, err := c.fetchAccountSummary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account summary: %w", err)
+	}
+	var ra struct {
+		Account accountSummaryInfo `json:"account"`
+	}
+	if err := json.Unmarshal(body, &ra); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	summary, err := ra.Account.toAccountSummary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert account summary: %w", err)
+	}
+	return summary, nil
+}
+
+func (c *Client) FetchAccountSummaryJSON() ([]byte, error) {
+	return c.fetchAccountSummary()
+}
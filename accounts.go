@@ -0,0 +1,180 @@
+package oanda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// accountsKey is the key under which sub-accounts are configured as Accounts/<NAME> = <account id>.
+// Names are upper cased by the config providers.
+const accountsKey = "Accounts"
+
+// DefaultAccountName is the name of the account configured by AccountID when no Accounts/ are configured.
+const DefaultAccountName = "DEFAULT"
+
+// Account is a named OANDA account.
+type Account struct {
+	Name string
+	ID   string
+}
+
+// AccountRegistry is the set of accounts to operate on, e.g. a sub-account per strategy.
+type AccountRegistry struct {
+	accounts []Account
+}
+
+// NewAccountRegistry constructs an AccountRegistry of accounts.
+func NewAccountRegistry(accounts ...Account) *AccountRegistry {
+	r := &AccountRegistry{accounts: append([]Account(nil), accounts...)}
+	sort.Slice(r.accounts, func(i, j int) bool { return r.accounts[i].Name < r.accounts[j].Name })
+	return r
+}
+
+// LoadAccountRegistry loads the accounts configured under Accounts/ in provider,
+// which must implement ConfigLister. When none are configured the registry has
+// the account of AccountID named DefaultAccountName.
+func LoadAccountRegistry(provider ConfigProvider) (*AccountRegistry, error) {
+	lister, ok := provider.(ConfigLister)
+	if !ok {
+		return nil, fmt.Errorf("config provider %T cannot list keys", provider)
+	}
+	keys, err := lister.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config keys: %w", err)
+	}
+	var accounts []Account
+	for _, key := range keys {
+		parts := strings.Split(key, "/")
+		if len(parts) != 2 || parts[0] != strings.ToUpper(accountsKey) {
+			continue
+		}
+		id, err := provider.Value(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get config (key=%s): %w", key, err)
+		}
+		accounts = append(accounts, Account{Name: parts[1], ID: id})
+	}
+	if len(accounts) == 0 {
+		id, err := provider.Value(ConfigKeyAccountID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get account id: %w", err)
+		}
+		accounts = append(accounts, Account{Name: DefaultAccountName, ID: id})
+	}
+	return NewAccountRegistry(accounts...), nil
+}
+
+// Accounts returns the accounts sorted by name.
+func (r *AccountRegistry) Accounts() []Account {
+	return append([]Account(nil), r.accounts...)
+}
+
+// Lookup returns the account whose name (case-insensitively) or id is nameOrID.
+func (r *AccountRegistry) Lookup(nameOrID string) (Account, bool) {
+	for _, a := range r.accounts {
+		if strings.EqualFold(a.Name, nameOrID) || a.ID == nameOrID {
+			return a, true
+		}
+	}
+	return Account{}, false
+}
+
+// AccountError is the error of an operation on an account.
+type AccountError struct {
+	Account Account
+	Err     error
+}
+
+func (e *AccountError) Error() string {
+	return fmt.Sprintf("account %s (id=%s): %v", e.Account.Name, e.Account.ID, e.Err)
+}
+
+func (e *AccountError) Unwrap() error {
+	return e.Err
+}
+
+// AccountErrors is the errors of the accounts an operation failed on.
+type AccountErrors []*AccountError
+
+func (e AccountErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ForEachAccount calls fn for every account with a view of client for the account, as concurrently
+// and as fast as the bulk operations of client, e.g. WithBulkConcurrency.
+// It waits for all calls and returns AccountErrors of the failed ones.
+// Accounts not started before ctx is done fail with the error of ctx.
+func (r *AccountRegistry) ForEachAccount(ctx context.Context, client *Client, fn func(ctx context.Context, account Account, client *Client) error) error {
+	results := client.fanOut(ctx, len(r.accounts), func(ctx context.Context, i int) error {
+		return fn(ctx, r.accounts[i], client.ForAccount(r.accounts[i].ID))
+	})
+	var errs AccountErrors
+	for i, err := range results {
+		if err != nil {
+			errs = append(errs, &AccountError{r.accounts[i], err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// AccountOrders is the orders of an account.
+type AccountOrders struct {
+	Account Account
+	Orders  []Order
+}
+
+// FetchOrders fetches the orders of every account.
+// On partial failure it returns the orders it fetched along with AccountErrors.
+func (r *AccountRegistry) FetchOrders(ctx context.Context, client *Client) ([]AccountOrders, error) {
+	var mu sync.Mutex
+	var result []AccountOrders
+	err := r.ForEachAccount(ctx, client, func(ctx context.Context, account Account, client *Client) error {
+		orders, err := client.FetchOrders()
+		var decodeErrs OrderDecodeErrors
+		if err != nil && !errors.As(err, &decodeErrs) {
+			return err
+		}
+		mu.Lock()
+		result = append(result, AccountOrders{account, orders})
+		mu.Unlock()
+		return err
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].Account.Name < result[j].Account.Name })
+	return result, err
+}
+
+// AccountTrades is the open trades of an account.
+type AccountTrades struct {
+	Account Account
+	Trades  []Trade
+}
+
+// FetchOpenTrades fetches the open trades of every account.
+// On partial failure it returns the trades it fetched along with AccountErrors.
+func (r *AccountRegistry) FetchOpenTrades(ctx context.Context, client *Client) ([]AccountTrades, error) {
+	var mu sync.Mutex
+	var result []AccountTrades
+	err := r.ForEachAccount(ctx, client, func(ctx context.Context, account Account, client *Client) error {
+		trades, err := client.FetchOpenTrades()
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		result = append(result, AccountTrades{account, trades})
+		mu.Unlock()
+		return nil
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].Account.Name < result[j].Account.Name })
+	return result, err
+}
@@ -0,0 +1,149 @@
+package oanda
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadAccountRegistry(t *testing.T) {
+	provider := NewChainProvider(
+		MapProvider{"Accounts/scalper": "001-001-1-002", ConfigKeyAccountID: "001-001-1-001"},
+		MapProvider{"Accounts/SWING": "001-001-1-003", "Units/USD_JPY": "1000"},
+	)
+	registry, err := LoadAccountRegistry(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Account{{"SCALPER", "001-001-1-002"}, {"SWING", "001-001-1-003"}}
+	got := registry.Accounts()
+	if len(got) != len(want) {
+		t.Fatalf("accounts = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("accounts = %+v, want %+v", got, want)
+		}
+	}
+	if a, ok := registry.Lookup("scalper"); !ok || a.ID != "001-001-1-002" {
+		t.Errorf("lookup by name = %+v, %t", a, ok)
+	}
+	if a, ok := registry.Lookup("001-001-1-003"); !ok || a.Name != "SWING" {
+		t.Errorf("lookup by id = %+v, %t", a, ok)
+	}
+	if _, ok := registry.Lookup("001-001-1-001"); ok {
+		t.Error("AccountID is in the registry of configured accounts")
+	}
+
+	registry, err = LoadAccountRegistry(MapProvider{ConfigKeyAccountID: "001-001-1-001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.Accounts(); len(got) != 1 || got[0] != (Account{DefaultAccountName, "001-001-1-001"}) {
+		t.Errorf("accounts without Accounts/ = %+v, want AccountID as %s", got, DefaultAccountName)
+	}
+	if _, err := LoadAccountRegistry(MapProvider{}); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("err = %v, want ErrConfigNotFound without any account", err)
+	}
+}
+
+func TestForAccountSharesTransportAndLimiter(t *testing.T) {
+	httpClient := &http.Client{}
+	client := NewClient("001-001-1-001", "key", EnvironmentPractice, WithHTTPClient(httpClient), WithRateLimit(10))
+	view := client.ForAccount("001-001-1-002")
+	if view.AccountID() != "001-001-1-002" || client.AccountID() != "001-001-1-001" {
+		t.Errorf("account ids = %s and %s", client.AccountID(), view.AccountID())
+	}
+	if view.client != client.client || view.limiter == nil || view.limiter != client.limiter {
+		t.Error("the view does not share the HTTP client and the rate limiter")
+	}
+	if got := view.requiredHeaders.Get("Authorization"); got != client.requiredHeaders.Get("Authorization") {
+		t.Errorf("authorization = %q", got)
+	}
+	if view.instruments == client.instruments {
+		t.Error("the view shares the instrument cache of another account")
+	}
+}
+
+func testRegistry() *AccountRegistry {
+	return NewAccountRegistry(
+		Account{"A", "001-001-1-001"}, Account{"B", "001-001-1-002"}, Account{"C", "001-001-1-003"},
+		Account{"D", "001-001-1-004"}, Account{"E", "001-001-1-005"}, Account{"F", "001-001-1-006"},
+	)
+}
+
+func TestForEachAccountBoundsConcurrency(t *testing.T) {
+	client := NewClient("001-001-1-001", "key", EnvironmentPractice, WithBulkConcurrency(2), WithRateLimit(1000))
+	var mu sync.Mutex
+	running, peak := 0, 0
+	seen := map[string]bool{}
+	err := testRegistry().ForEachAccount(context.Background(), client, func(ctx context.Context, account Account, c *Client) error {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		seen[c.AccountID()] = account.ID == c.AccountID()
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("%d accounts ran at once, want at most 2", peak)
+	}
+	for _, a := range testRegistry().Accounts() {
+		if !seen[a.ID] {
+			t.Errorf("account %s was not called with its view", a.Name)
+		}
+	}
+}
+
+func TestForEachAccountAggregatesErrors(t *testing.T) {
+	client := NewClient("001-001-1-001", "key", EnvironmentPractice, WithBulkConcurrency(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failed := errors.New("failed")
+	var mu sync.Mutex
+	called := map[string]bool{}
+	err := testRegistry().ForEachAccount(ctx, client, func(ctx context.Context, account Account, c *Client) error {
+		mu.Lock()
+		called[account.Name] = true
+		mu.Unlock()
+		if account.Name == "A" {
+			cancel()
+			return failed
+		}
+		return nil
+	})
+	var errs AccountErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v, want AccountErrors", err)
+	}
+	if len(errs) != 6-len(called)+1 {
+		t.Errorf("%d errors for %d accounts called, want the failed and the not started ones: %v", len(errs), len(called), err)
+	}
+	for i, e := range errs {
+		if i > 0 && errs[i-1].Account.Name >= e.Account.Name {
+			t.Errorf("errors are not in order of account names: %v", err)
+		}
+		switch {
+		case e.Account.Name == "A":
+			if !errors.Is(e, failed) {
+				t.Errorf("error of A = %v", e)
+			}
+		case called[e.Account.Name]:
+			t.Errorf("account %s failed after it was called: %v", e.Account.Name, e)
+		case !errors.Is(e, context.Canceled):
+			t.Errorf("error of %s = %v, want the error of ctx", e.Account.Name, e)
+		}
+	}
+}
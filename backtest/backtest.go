@@ -0,0 +1,150 @@
+// Package backtest replays historical candles and order book snapshots of OANDA API
+// into a strategy which trades on an oanda.PaperBroker on a simulated clock.
+//
+//	cache, err := backtest.NewCandleCache(client, "./candles")
+//	store, err := orderbook.OpenStore("./orderbooks")
+//	bt := &backtest.Backtest{
+//		Instrument:  oanda.InstrumentUSDJPY,
+//		Granularity: "M5",
+//		From:        from,
+//		To:          to,
+//		Paper:       oanda.PaperConfig{Balance: 1000000},
+//		Candles:     cache,
+//		OrderBooks:  orderbook.NewCollector(client, store),
+//	}
+//	result, err := bt.Run(ctx, strategy)
+package backtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/orderbook"
+)
+
+// Event is what a strategy sees when a candle closes.
+type Event struct {
+	// Time is the close time of the candle, the time of the simulated clock.
+	Time   time.Time
+	Candle oanda.Candle
+	// Price is the close price of the candle.
+	Price oanda.ClientPrice
+	// OrderBook is the latest order book snapshot at or before Time. It is nil when there is none.
+	OrderBook *oanda.OrderBook
+}
+
+// Strategy is called every time a candle closes and places orders through broker.
+// An error stops the backtest.
+type Strategy func(e Event, broker oanda.Broker) error
+
+// Backtest is a backtest of a strategy on an instrument over [From, To).
+type Backtest struct {
+	Instrument  oanda.Instrument
+	Granularity string
+	From        time.Time
+	To          time.Time
+	Paper       oanda.PaperConfig
+	Candles     *CandleCache
+	// OrderBooks backfills and provides order book snapshots. Events have no order book when it is nil.
+	OrderBooks *orderbook.Collector
+}
+
+// Run replays the candles into strategy and returns the result.
+//
+// Within a candle the paper broker sees the open, then the low and high, in the order
+// which makes the shorter path to the close, and then the close,
+// so that pending orders fill inside the candle. Strategy is called at the close.
+func (bt *Backtest) Run(ctx context.Context, strategy Strategy) (*Result, error) {
+	d, err := oanda.GranularityDuration(bt.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := bt.Candles.Candles(bt.Instrument, bt.Granularity, bt.From, bt.To)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load candles: %w", err)
+	}
+	if len(candles) == 0 {
+		return nil, errors.New("no candles in the period")
+	}
+	books, err := bt.orderBooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load order books: %w", err)
+	}
+
+	broker := oanda.NewPaperBroker(bt.Paper)
+	result := &Result{}
+	if err := result.record(broker, candles[0].Time); err != nil {
+		return nil, err
+	}
+	var book *oanda.OrderBook
+	for _, c := range candles {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, p := range path(bt.Instrument, c, d) {
+			broker.Update(p)
+		}
+		end := c.Time.Add(d)
+		for len(books) > 0 && !books[0].Time.After(end) {
+			book, books = books[0], books[1:]
+		}
+		if err := result.record(broker, end); err != nil {
+			return nil, err
+		}
+		price, _ := broker.Price(bt.Instrument)
+		e := Event{Time: end, Candle: c, Price: price, OrderBook: book}
+		if err := strategy(e, broker); err != nil {
+			return nil, fmt.Errorf("strategy failed at %s: %w", end.Format(time.RFC3339), err)
+		}
+	}
+	if err := result.record(broker, candles[len(candles)-1].Time.Add(d)); err != nil {
+		return nil, err
+	}
+	result.Trades = broker.Trades()
+	result.Fills = broker.Fills()
+	result.summarize(bt.To.Sub(bt.From))
+	return result, nil
+}
+
+// orderBooks backfills the snapshots of the period, including the one before From, and returns them.
+func (bt *Backtest) orderBooks(ctx context.Context) ([]*oanda.OrderBook, error) {
+	if bt.OrderBooks == nil {
+		return nil, nil
+	}
+	from := bt.From.Add(-orderbook.SnapshotInterval)
+	instruments := []oanda.Instrument{bt.Instrument}
+	if err := bt.OrderBooks.Backfill(ctx, instruments, from, bt.To); err != nil {
+		return nil, err
+	}
+	return bt.OrderBooks.Store.Range(bt.Instrument, from, bt.To)
+}
+
+// path returns the prices the paper broker sees within candle c.
+func path(instrument oanda.Instrument, c oanda.Candle, d time.Duration) []oanda.ClientPrice {
+	bid, ask := c.Bid, c.Ask
+	if bid == nil {
+		bid = c.Mid
+	}
+	if ask == nil {
+		ask = c.Mid
+	}
+	if bid == nil || ask == nil {
+		return nil
+	}
+	price := func(t time.Time, b, a oanda.Price) oanda.ClientPrice {
+		return oanda.ClientPrice{Instrument: instrument, Time: t, Tradeable: true, Bid: b, Ask: a, CloseoutBid: b, CloseoutAsk: a}
+	}
+	open := price(c.Time, bid.O, ask.O)
+	low := price(c.Time.Add(d/3), bid.L, ask.L)
+	high := price(c.Time.Add(d/3), bid.H, ask.H)
+	last := price(c.Time.Add(d), bid.C, ask.C)
+	if bid.C >= bid.O {
+		high.Time = c.Time.Add(2 * d / 3)
+		return []oanda.ClientPrice{open, low, high, last}
+	}
+	low.Time = c.Time.Add(2 * d / 3)
+	return []oanda.ClientPrice{open, high, low, last}
+}
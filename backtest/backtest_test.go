@@ -0,0 +1,178 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+	"github.com/yuki-inoue-eng/oanda-api-client/orderbook"
+)
+
+// noFetcher fails every fetch, so that a backtest runs only on what is cached.
+type noFetcher struct{}
+
+func (noFetcher) FetchCandles(oanda.Instrument, oanda.CandlesQuery) ([]oanda.Candle, error) {
+	return nil, errors.New("candles are not cached")
+}
+
+// bookFetcher returns a snapshot taken exactly at the requested time.
+type bookFetcher struct{}
+
+func (bookFetcher) FetchOrderBook(instrument oanda.Instrument, dateTime *time.Time) (*oanda.OrderBook, error) {
+	return &oanda.OrderBook{Instrument: instrument, Time: *dateTime, Price: 105, BucketWidth: "0.05"}, nil
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "backtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func candle(at time.Time, o, h, l, c oanda.Price) oanda.Candle {
+	return oanda.Candle{Time: at, Complete: true, Mid: &oanda.OHLC{O: o, H: h, L: l, C: c}}
+}
+
+// cachedCandles writes candles of M5 USD_JPY on their day to a cache in a temporary directory.
+func cachedCandles(t *testing.T, candles ...oanda.Candle) *CandleCache {
+	t.Helper()
+	dir := tempDir(t)
+	day := candles[0].Time.Format("2006-01-02")
+	if err := writeCandles(filepath.Join(dir, string(oanda.InstrumentUSDJPY), "M5", day+util.DayFileSuffix), candles); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := NewCandleCache(noFetcher{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cache
+}
+
+func TestPath(t *testing.T) {
+	start := time.Date(2020, 9, 18, 10, 0, 0, 0, time.UTC)
+	d := 3 * time.Minute
+	tests := []struct {
+		name   string
+		candle oanda.Candle
+		prices []oanda.Price
+	}{
+		{"bullish candle goes low first", candle(start, 105, 105.2, 104.9, 105.1), []oanda.Price{105, 104.9, 105.2, 105.1}},
+		{"bearish candle goes high first", candle(start, 105.1, 105.3, 104.8, 104.9), []oanda.Price{105.1, 105.3, 104.8, 104.9}},
+	}
+	for _, tt := range tests {
+		got := path(oanda.InstrumentUSDJPY, tt.candle, d)
+		if len(got) != 4 {
+			t.Fatalf("%s: path = %+v", tt.name, got)
+		}
+		for i, p := range got {
+			if want := start.Add(time.Duration(i) * time.Minute); p.Bid != tt.prices[i] || p.Ask != tt.prices[i] || !p.Time.Equal(want) {
+				t.Errorf("%s: price %d = %v/%v at %s, want %v at %s", tt.name, i, p.Bid, p.Ask, p.Time, tt.prices[i], want)
+			}
+		}
+	}
+
+	bidAsk := oanda.Candle{Time: start, Bid: &oanda.OHLC{O: 105, H: 105.2, L: 104.9, C: 105.1}, Ask: &oanda.OHLC{O: 105.01, H: 105.21, L: 104.91, C: 105.11}}
+	if got := path(oanda.InstrumentUSDJPY, bidAsk, d); got[1].Bid != 104.9 || got[1].Ask != 104.91 {
+		t.Errorf("low of bid and ask = %v/%v", got[1].Bid, got[1].Ask)
+	}
+	if got := path(oanda.InstrumentUSDJPY, oanda.Candle{Time: start}, d); got != nil {
+		t.Errorf("path of a candle without prices = %+v", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	from := time.Date(2020, 9, 18, 9, 50, 0, 0, time.UTC)
+	m5 := 5 * time.Minute
+	candles := cachedCandles(t,
+		candle(from, 105, 105.2, 104.9, 105.1),
+		// bearish, so the low at 09:58:20 fills the limit order placed at the close of the first candle.
+		candle(from.Add(m5), 105.1, 105.3, 104.8, 104.9),
+		candle(from.Add(2*m5), 104.9, 105.05, 104.85, 105),
+	)
+	store, err := orderbook.OpenStore(tempDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := orderbook.NewCollector(bookFetcher{}, store)
+	collector.RequestInterval = 0
+	bt := &Backtest{
+		Instrument:  oanda.InstrumentUSDJPY,
+		Granularity: "M5",
+		From:        from,
+		To:          from.Add(3 * m5),
+		Paper:       oanda.PaperConfig{Balance: 1000000},
+		Candles:     candles,
+		OrderBooks:  collector,
+	}
+
+	var events []Event
+	var openTrades [][]oanda.Trade
+	result, err := bt.Run(context.Background(), func(e Event, broker oanda.Broker) error {
+		events = append(events, e)
+		trades, err := broker.FetchOpenTrades()
+		if err != nil {
+			return err
+		}
+		openTrades = append(openTrades, trades)
+		if len(events) == 1 {
+			for _, price := range []oanda.Price{104.85, 104.5} {
+				if err := broker.CreateOrder(oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentUSDJPY, Units: 100, Price: price,
+					TimeInForce: oanda.TimeInForceGTC}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("%d events, want one per candle", len(events))
+	}
+	// order books are visible from their time: 09:40 before 10:00, and 10:00 at and after it.
+	wantBooks := []time.Time{from.Add(-10 * time.Minute), from.Add(10 * time.Minute), from.Add(10 * time.Minute)}
+	for i, e := range events {
+		if want := from.Add(time.Duration(i+1) * m5); !e.Time.Equal(want) || e.Price.Bid != e.Candle.Mid.C {
+			t.Errorf("event %d at %s with price %v, want at %s with the close", i, e.Time, e.Price.Bid, want)
+		}
+		if e.OrderBook == nil || !e.OrderBook.Time.Equal(wantBooks[i]) {
+			t.Errorf("event %d at %s has order book %+v, want the one of %s", i, e.Time, e.OrderBook, wantBooks[i])
+		}
+	}
+
+	if len(openTrades[0]) != 0 || len(openTrades[1]) != 1 || len(openTrades[2]) != 1 {
+		t.Fatalf("open trades = %+v, want the limit order filled within the second candle", openTrades)
+	}
+	trade := openTrades[1][0]
+	if trade.Price > 104.85 || !trade.OpenTime.Equal(from.Add(m5+2*m5/3)) {
+		t.Errorf("trade opened at %v on %s, want at most 104.85 on the low of the second candle", trade.Price, trade.OpenTime)
+	}
+	if len(result.Trades) != 1 || len(result.Fills) != 1 {
+		t.Errorf("trades = %+v, fills = %+v, want only the filled limit order", result.Trades, result.Fills)
+	}
+
+	if len(result.Equity) != 4 {
+		t.Fatalf("equity = %+v, want the start and every close", result.Equity)
+	}
+	for i, p := range result.Equity {
+		if want := from.Add(time.Duration(i) * m5); !p.Time.Equal(want) || p.Balance != 1000000 {
+			t.Errorf("equity point %d = %+v, want at %s with the balance unchanged", i, p, want)
+		}
+	}
+	last := result.Equity[3]
+	if want := 1000000 + 100*float64(105-trade.Price); math.Abs(last.NAV-want) > 1e-6 || math.Abs(result.PL-(want-1000000)) > 1e-6 {
+		t.Errorf("last NAV = %v and PL = %v, want NAV %v with the unrealized P/L of the trade", last.NAV, result.PL, want)
+	}
+}
@@ -0,0 +1,174 @@
+package backtest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+// CandleFetcher fetches candles. *oanda.Client implements it.
+type CandleFetcher interface {
+	FetchCandles(instrument oanda.Instrument, query oanda.CandlesQuery) ([]oanda.Candle, error)
+}
+
+// CandleCache fetches complete candles with bid and ask prices and keeps them on local disk.
+//
+// Candles are cached as one gzip compressed JSON Lines file per instrument, granularity and UTC day:
+//
+//	<dir>/<INSTRUMENT>/<GRANULARITY>/<YYYY-MM-DD>.jsonl.gz
+//
+// Only days which have ended are cached, so a cached day is never fetched again.
+type CandleCache struct {
+	fetcher CandleFetcher
+	dir     string
+	now     func() time.Time
+}
+
+// NewCandleCache constructs a CandleCache in dir, creating the directory if it does not exist.
+func NewCandleCache(fetcher CandleFetcher, dir string) (*CandleCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &CandleCache{fetcher: fetcher, dir: dir, now: time.Now}, nil
+}
+
+// Candles returns the complete candles of instrument in granularity which start in [from, to), in chronological order.
+func (c *CandleCache) Candles(instrument oanda.Instrument, granularity string, from, to time.Time) ([]oanda.Candle, error) {
+	d, err := oanda.GranularityDuration(granularity)
+	if err != nil {
+		return nil, err
+	}
+	var candles []oanda.Candle
+	for day := util.TruncateDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		cs, err := c.day(instrument, granularity, d, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, candle := range cs {
+			if !candle.Time.Before(from) && candle.Time.Before(to) {
+				candles = append(candles, candle)
+			}
+		}
+	}
+	return candles, nil
+}
+
+// day returns the candles of the UTC day from the cache, fetching and caching them if they are not cached.
+func (c *CandleCache) day(instrument oanda.Instrument, granularity string, d time.Duration, day time.Time) ([]oanda.Candle, error) {
+	path := filepath.Join(c.dir, string(instrument), granularity, day.Format("2006-01-02")+util.DayFileSuffix)
+	candles, err := readCandles(path)
+	if err == nil {
+		return candles, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	end := day.AddDate(0, 0, 1)
+	now := c.now()
+	if now.Before(day) {
+		return nil, nil
+	}
+	for cursor := day; cursor.Before(end) && cursor.Before(now); {
+		from := cursor
+		cs, err := c.fetcher.FetchCandles(instrument, oanda.CandlesQuery{
+			Granularity: granularity,
+			Price:       "BA",
+			From:        &from,
+			Count:       oanda.MaxCandleCount,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(cs) == 0 {
+			break
+		}
+		for _, candle := range cs {
+			if candle.Complete && candle.Time.Before(end) {
+				candles = append(candles, candle)
+			}
+		}
+		cursor = cs[len(cs)-1].Time.Add(d)
+		if len(cs) < oanda.MaxCandleCount {
+			break
+		}
+	}
+	if end.After(now) {
+		return candles, nil
+	}
+	if err := writeCandles(path, candles); err != nil {
+		return nil, err
+	}
+	return candles, nil
+}
+
+func readCandles(path string) ([]oanda.Candle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer util.SafeClose(f)
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var candles []oanda.Candle
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		var candle oanda.Candle
+		if err := json.Unmarshal(scanner.Bytes(), &candle); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal a line of %s: %w", path, err)
+		}
+		candles = append(candles, candle)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return candles, nil
+}
+
+// writeCandles writes candles to a temporary file and renames it to path,
+// so that an interrupted write never leaves a partial day in the cache.
+func writeCandles(path string, candles []oanda.Candle) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+	zw := gzip.NewWriter(f)
+	for _, candle := range candles {
+		line, err := json.Marshal(candle)
+		if err != nil {
+			util.SafeClose(f)
+			return fmt.Errorf("failed to marshal candle: %w", err)
+		}
+		if _, err := zw.Write(append(line, '\n')); err != nil {
+			util.SafeClose(f)
+			return fmt.Errorf("failed to write %s: %w", tmp, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		util.SafeClose(f)
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename %s: %w", tmp, err)
+	}
+	return nil
+}
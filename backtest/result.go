@@ -0,0 +1,103 @@
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// EquityPoint is the balance and net asset value of the account at a time.
+type EquityPoint struct {
+	Time    time.Time
+	Balance float64
+	NAV     float64
+}
+
+// Result is the result of a backtest.
+type Result struct {
+	// Equity is the equity curve, recorded at every candle close.
+	Equity []EquityPoint
+	// Trades are all trades in the order opened, including those still open at the end.
+	Trades []oanda.Trade
+	Fills  []oanda.OrderFill
+	// PL is the change of NAV over the backtest.
+	PL float64
+	// MaxDrawdown is the largest fall of NAV from a preceding peak, in the account currency.
+	MaxDrawdown float64
+	// MaxDrawdownPercent is MaxDrawdown relative to the peak, in percent.
+	MaxDrawdownPercent float64
+	// Sharpe is the annualized Sharpe ratio of the returns of NAV between equity points, with a risk free rate of 0.
+	Sharpe float64
+	// WinRate is the ratio of closed trades with positive realized P/L. It is 0 without closed trades.
+	WinRate float64
+}
+
+func (r *Result) record(broker *oanda.PaperBroker, t time.Time) error {
+	s, err := broker.FetchAccountSummary()
+	if err != nil {
+		return err
+	}
+	if n := len(r.Equity); n > 0 && r.Equity[n-1].Time.Equal(t) {
+		r.Equity[n-1] = EquityPoint{t, s.Balance, s.NAV}
+		return nil
+	}
+	r.Equity = append(r.Equity, EquityPoint{t, s.Balance, s.NAV})
+	return nil
+}
+
+// summarize calculates the statistics from the equity curve and trades of a backtest over period.
+func (r *Result) summarize(period time.Duration) {
+	if len(r.Equity) == 0 {
+		return
+	}
+	r.PL = r.Equity[len(r.Equity)-1].NAV - r.Equity[0].NAV
+
+	peak := r.Equity[0].NAV
+	for _, p := range r.Equity {
+		peak = math.Max(peak, p.NAV)
+		if dd := peak - p.NAV; dd > r.MaxDrawdown {
+			r.MaxDrawdown = dd
+			if peak > 0 {
+				r.MaxDrawdownPercent = dd / peak * 100
+			}
+		}
+	}
+
+	var returns []float64
+	for i := 1; i < len(r.Equity); i++ {
+		if prev := r.Equity[i-1].NAV; prev > 0 {
+			returns = append(returns, r.Equity[i].NAV/prev-1)
+		}
+	}
+	if len(returns) > 1 && period > 0 {
+		var mean float64
+		for _, ret := range returns {
+			mean += ret
+		}
+		mean /= float64(len(returns))
+		var variance float64
+		for _, ret := range returns {
+			variance += (ret - mean) * (ret - mean)
+		}
+		std := math.Sqrt(variance / float64(len(returns)-1))
+		if std > 0 {
+			perYear := float64(len(returns)) / (period.Hours() / (365 * 24))
+			r.Sharpe = mean / std * math.Sqrt(perYear)
+		}
+	}
+
+	var closed, wins int
+	for _, t := range r.Trades {
+		if t.State != "CLOSED" {
+			continue
+		}
+		closed++
+		if t.RealizedPL > 0 {
+			wins++
+		}
+	}
+	if closed > 0 {
+		r.WinRate = float64(wins) / float64(closed)
+	}
+}
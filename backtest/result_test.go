@@ -0,0 +1,79 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+func equity(navs ...float64) []EquityPoint {
+	start := time.Date(2020, 9, 14, 0, 0, 0, 0, time.UTC)
+	var points []EquityPoint
+	for i, nav := range navs {
+		points = append(points, EquityPoint{Time: start.Add(time.Duration(i) * 24 * time.Hour), Balance: nav, NAV: nav})
+	}
+	return points
+}
+
+func TestSummarizeDrawdown(t *testing.T) {
+	r := &Result{Equity: equity(100, 110, 99, 120, 90, 95)}
+	r.summarize(5 * 24 * time.Hour)
+	if r.PL != -5 {
+		t.Errorf("PL = %v, want -5", r.PL)
+	}
+	if r.MaxDrawdown != 30 {
+		t.Errorf("MaxDrawdown = %v, want 30", r.MaxDrawdown)
+	}
+	if r.MaxDrawdownPercent != 25 {
+		t.Errorf("MaxDrawdownPercent = %v, want 25", r.MaxDrawdownPercent)
+	}
+
+	rising := &Result{Equity: equity(100, 101, 102)}
+	rising.summarize(2 * 24 * time.Hour)
+	if rising.MaxDrawdown != 0 || rising.MaxDrawdownPercent != 0 {
+		t.Errorf("drawdown of a rising curve = %v (%v%%)", rising.MaxDrawdown, rising.MaxDrawdownPercent)
+	}
+}
+
+func TestSummarizeSharpe(t *testing.T) {
+	// daily returns of 2% and 1%: mean 1.5%, sample standard deviation 0.7071%, 365 returns a year.
+	r := &Result{Equity: equity(100, 102, 103.02)}
+	r.summarize(2 * 24 * time.Hour)
+	if want := 40.52776825831888; math.Abs(r.Sharpe-want) > 1e-6 {
+		t.Errorf("Sharpe = %v, want %v", r.Sharpe, want)
+	}
+
+	flat := &Result{Equity: equity(100, 100, 100)}
+	flat.summarize(2 * 24 * time.Hour)
+	if flat.Sharpe != 0 {
+		t.Errorf("Sharpe of a flat curve = %v, want 0", flat.Sharpe)
+	}
+	single := &Result{Equity: equity(100, 110)}
+	single.summarize(24 * time.Hour)
+	if single.Sharpe != 0 {
+		t.Errorf("Sharpe of a single return = %v, want 0", single.Sharpe)
+	}
+}
+
+func TestSummarizeWinRate(t *testing.T) {
+	r := &Result{
+		Equity: equity(100),
+		Trades: []oanda.Trade{
+			{State: "CLOSED", RealizedPL: 10},
+			{State: "CLOSED", RealizedPL: -5},
+			{State: "CLOSED", RealizedPL: 0},
+			{State: "OPEN", RealizedPL: 3},
+		},
+	}
+	r.summarize(time.Hour)
+	if want := 1.0 / 3; r.WinRate != want {
+		t.Errorf("WinRate = %v, want %v", r.WinRate, want)
+	}
+	empty := &Result{}
+	empty.summarize(time.Hour)
+	if empty.PL != 0 || empty.Sharpe != 0 || empty.WinRate != 0 {
+		t.Errorf("summary of an empty result = %+v", empty)
+	}
+}
@@ -0,0 +1,85 @@
+package oanda
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Bracket returns entry with a take profit takeProfit pips and a stop loss stopLoss pips away from the entry
+// attached on fill. The stop loss is a trailing stop loss when trailing is true. A zero distance attaches none.
+//
+// The prices are computed from Price of entry and rounded to the precision of the instrument.
+// A MARKET entry has no price, so they are computed from current, the current price of the instrument:
+// the ask for a buy and the bid for a sell. current is not used for other entries.
+func Bracket(details InstrumentDetails, current ClientPrice, entry Order, takeProfit, stopLoss Pips, trailing bool) (Order, error) {
+	if entry.Instrument != details.Name {
+		return entry, fmt.Errorf("details of %s do not apply to an order of %s", details.Name, entry.Instrument)
+	}
+	if entry.Units == 0 {
+		return entry, errors.New("units of a bracket entry must not be 0")
+	}
+	if takeProfit < 0 || stopLoss < 0 {
+		return entry, fmt.Errorf("invalid take profit %g or stop loss %g pips", takeProfit, stopLoss)
+	}
+	side := Price(1)
+	if entry.Units < 0 {
+		side = -1
+	}
+	price := entry.Price
+	if entry.Type == OrderTypeMarket {
+		if current.Instrument != entry.Instrument {
+			return entry, fmt.Errorf("price of %s does not apply to an order of %s", current.Instrument, entry.Instrument)
+		}
+		price = current.Ask
+		if side < 0 {
+			price = current.Bid
+		}
+	}
+	if price == 0 {
+		return entry, fmt.Errorf("price of a %s bracket entry must be set", entry.Type)
+	}
+	if takeProfit > 0 {
+		distance := details.RoundPrice(details.PipsToPrice(takeProfit))
+		entry.TakeProfitOnFill = &OnFill{Price: details.RoundPrice(price + side*distance), TimeInForce: TimeInForceGTC}
+	}
+	if stopLoss > 0 {
+		distance := details.RoundPrice(details.PipsToPrice(stopLoss))
+		if trailing {
+			// OANDA API only accepts a distance for a trailing stop loss.
+			entry.TrailingStopLossOnFill = &OnFill{Distance: distance, TimeInForce: TimeInForceGTC}
+		} else {
+			entry.StopLossOnFill = &OnFill{Price: details.RoundPrice(price - side*distance), TimeInForce: TimeInForceGTC}
+		}
+	}
+	return entry, nil
+}
+
+// BracketOrder returns entry with a take profit and a stop loss attached on fill as Bracket does,
+// looking up the pip location and the precision of the instrument and, for a MARKET entry, the current price.
+func (c *Client) BracketOrder(entry Order, takeProfit, stopLoss Pips, trailing bool) (Order, error) {
+	details, err := c.LookupInstrument(entry.Instrument)
+	if err != nil {
+		return entry, err
+	}
+	var current ClientPrice
+	if entry.Type == OrderTypeMarket {
+		prices, err := c.FetchPricing(entry.Instrument)
+		if err != nil {
+			return entry, err
+		}
+		if len(prices) == 0 {
+			return entry, fmt.Errorf("no price of %s", entry.Instrument)
+		}
+		current = prices[0]
+	}
+	return Bracket(details, current, entry, takeProfit, stopLoss, trailing)
+}
+
+// PlaceBracket creates entry with a take profit and a stop loss attached on fill as BracketOrder returns it.
+func (c *Client) PlaceBracket(entry Order, takeProfit, stopLoss Pips, trailing bool) error {
+	order, err := c.BracketOrder(entry, takeProfit, stopLoss, trailing)
+	if err != nil {
+		return err
+	}
+	return c.CreateOrder(order)
+}
@@ -0,0 +1,77 @@
+package oanda_test
+
+import (
+	"testing"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+var usdJPY = oanda.InstrumentDetails{Name: oanda.InstrumentUSDJPY, PipLocation: -2, DisplayPrecision: 3, MinimumTradeSize: 1}
+
+func TestBracket(t *testing.T) {
+	current := oanda.ClientPrice{Instrument: oanda.InstrumentUSDJPY, Bid: 105.001, Ask: 105.009}
+	tests := []struct {
+		name         string
+		entry        oanda.Order
+		trailing     bool
+		takeProfit   oanda.Price
+		stopLoss     oanda.Price
+		trailingStop oanda.Price
+	}{
+		{"buy limit from its price", oanda.Order{Type: oanda.OrderTypeLimit, Units: 100, Price: 104.5}, false, 104.7, 104.4, 0},
+		{"sell stop from its price", oanda.Order{Type: oanda.OrderTypeStop, Units: -100, Price: 104.5}, false, 104.3, 104.6, 0},
+		{"market buy from the ask", oanda.Order{Type: oanda.OrderTypeMarket, Units: 100}, false, 105.209, 104.909, 0},
+		{"market sell from the bid", oanda.Order{Type: oanda.OrderTypeMarket, Units: -100}, false, 104.801, 105.101, 0},
+		{"trailing stop as a distance", oanda.Order{Type: oanda.OrderTypeMarket, Units: 100}, true, 105.209, 0, 0.1},
+	}
+	for _, tt := range tests {
+		tt.entry.Instrument = oanda.InstrumentUSDJPY
+		got, err := oanda.Bracket(usdJPY, current, tt.entry, 20, 10, tt.trailing)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.TakeProfitOnFill == nil || got.TakeProfitOnFill.Price != tt.takeProfit || got.TakeProfitOnFill.Distance != 0 {
+			t.Errorf("%s: take profit = %+v, want at %v", tt.name, got.TakeProfitOnFill, tt.takeProfit)
+		}
+		if tt.trailing {
+			if got.StopLossOnFill != nil || got.TrailingStopLossOnFill == nil || got.TrailingStopLossOnFill.Distance != tt.trailingStop {
+				t.Errorf("%s: stop loss = %+v, trailing = %+v", tt.name, got.StopLossOnFill, got.TrailingStopLossOnFill)
+			}
+		} else if got.StopLossOnFill == nil || got.StopLossOnFill.Price != tt.stopLoss || got.StopLossOnFill.Distance != 0 {
+			t.Errorf("%s: stop loss = %+v, want at %v", tt.name, got.StopLossOnFill, tt.stopLoss)
+		}
+	}
+
+	market := oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 100}
+	if _, err := oanda.Bracket(usdJPY, oanda.ClientPrice{}, market, 20, 10, false); err == nil {
+		t.Error("bracket of a market entry without the current price succeeds")
+	}
+	limit := oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentUSDJPY, Units: 100}
+	if _, err := oanda.Bracket(usdJPY, current, limit, 20, 10, false); err == nil {
+		t.Error("bracket of a limit entry without a price succeeds")
+	}
+}
+
+func TestPlaceBracketOfMarketEntry(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	entry := oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: -100, TimeInForce: oanda.TimeInForceFOK}
+	if err := client.PlaceBracket(entry, 20, 10, false); err != nil {
+		t.Fatal(err)
+	}
+	orders, err := client.FetchOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := map[oanda.OrderType]oanda.Price{}
+	for _, o := range orders {
+		prices[o.Type] = o.Price
+	}
+	if len(orders) != 2 || prices[oanda.OrderTypeTakeProfit] != 104.801 || prices[oanda.OrderTypeStopLoss] != 105.101 {
+		t.Errorf("orders = %+v, want a take profit at 104.801 and a stop loss at 105.101", orders)
+	}
+}
@@ -0,0 +1,23 @@
+package oanda
+
+// Broker is the operations on an account which bots use.
+// *Client implements it against OANDA API and *PaperBroker against a simulated account,
+// so a strategy written against Broker runs unchanged on practice, live or simulation.
+type Broker interface {
+	FetchOrders() ([]Order, error)
+	CreateOrder(order Order) error
+	UpdateOrder(order Order) error
+	CancelOrder(orderID OrderID) error
+	FetchOpenTrades() ([]Trade, error)
+	CloseOpenTrade(id TradeID) error
+	FetchOpenPositions() ([]Position, error)
+}
+
+var (
+	_ Broker = (*Client)(nil)
+	_ Broker = (*PaperBroker)(nil)
+	_ Broker = (*RiskManager)(nil)
+
+	_ RiskBroker = (*Client)(nil)
+	_ RiskBroker = (*PaperBroker)(nil)
+)
@@ -0,0 +1,262 @@
+package oanda
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBulkConcurrency is the default number of concurrent requests of bulk operations.
+const DefaultBulkConcurrency = 4
+
+// DefaultBulkRateLimit is the number of requests per second bulk operations send
+// when the client has no rate limit of WithRateLimit.
+const DefaultBulkRateLimit = 20
+
+// WithBulkConcurrency sets the number of concurrent requests of bulk operations such as CancelOrders.
+func WithBulkConcurrency(n int) Option {
+	return func(c *Client) {
+		c.bulkConcurrency = n
+	}
+}
+
+// Filter selects orders and trades for bulk operations. Zero fields match everything.
+type Filter struct {
+	Instruments []Instrument
+	// Types applies only to orders.
+	Types []OrderType
+	// Side is decided by the sign of units. Orders without units, e.g. TAKE_PROFIT, have no side
+	// and never match a Side.
+	Side Side
+	// Tag must equal the tag of the client extensions.
+	Tag string
+	// Comment must be contained in the comment of the client extensions.
+	Comment string
+	// OlderThan is the minimum age by the create time of an order or the open time of a trade.
+	OlderThan time.Duration
+}
+
+// MatchOrder reports whether o matches f at now.
+func (f *Filter) MatchOrder(o *Order, now time.Time) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			found = found || t == o.Type
+		}
+		if !found {
+			return false
+		}
+	}
+	return f.match(o.Instrument, o.Units, o.ClientExtensions, o.CreateTime, now)
+}
+
+// MatchTrade reports whether t matches f at now.
+func (f *Filter) MatchTrade(t *Trade, now time.Time) bool {
+	units := t.CurrentUnits
+	if units == 0 {
+		units = t.InitialUnits
+	}
+	return f.match(t.Instrument, units, t.ClientExtensions, t.OpenTime, now)
+}
+
+func (f *Filter) match(instrument Instrument, units Unit, ext *ClientExtensions, created *time.Time, now time.Time) bool {
+	if len(f.Instruments) > 0 {
+		found := false
+		for _, i := range f.Instruments {
+			found = found || i == instrument
+		}
+		if !found {
+			return false
+		}
+	}
+	switch f.Side {
+	case SideBuy:
+		if units <= 0 {
+			return false
+		}
+	case SideSell:
+		if units >= 0 {
+			return false
+		}
+	}
+	if f.Tag != "" && (ext == nil || ext.Tag != f.Tag) {
+		return false
+	}
+	if f.Comment != "" && (ext == nil || !strings.Contains(ext.Comment, f.Comment)) {
+		return false
+	}
+	if f.OlderThan > 0 && (created == nil || now.Sub(*created) < f.OlderThan) {
+		return false
+	}
+	return true
+}
+
+// BulkResult is the result of an operation on an order or a trade.
+type BulkResult struct {
+	ID         string
+	Instrument Instrument
+	Err        error
+}
+
+// BulkReport is the results of a bulk operation in the order of the targets.
+type BulkReport struct {
+	Results []BulkResult
+}
+
+// Failed returns the results of the failed operations.
+func (r *BulkReport) Failed() []BulkResult {
+	var failed []BulkResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Err returns an error describing the failed operations, or nil when all succeeded.
+func (r *BulkReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, f := range failed {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", f.ID, f.Err))
+	}
+	return fmt.Errorf("%d of %d failed: %s", len(failed), len(r.Results), strings.Join(msgs, "; "))
+}
+
+// MatchingOrders returns the pending orders matching filter.
+// Orders which are only partly decoded are matched by their decoded fields.
+func (c *Client) MatchingOrders(filter Filter) ([]Order, error) {
+	orders, err := c.FetchOrders()
+	partial, err := partlyDecodedOrders(err)
+	if err != nil {
+		return nil, err
+	}
+	orders = append(orders, partial...)
+	now := time.Now()
+	var matched []Order
+	for i := range orders {
+		if filter.MatchOrder(&orders[i], now) {
+			matched = append(matched, orders[i])
+		}
+	}
+	return matched, nil
+}
+
+// MatchingTrades returns the open trades matching filter.
+func (c *Client) MatchingTrades(filter Filter) ([]Trade, error) {
+	trades, err := c.FetchOpenTrades()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var matched []Trade
+	for i := range trades {
+		if filter.MatchTrade(&trades[i], now) {
+			matched = append(matched, trades[i])
+		}
+	}
+	return matched, nil
+}
+
+// CancelOrders cancels every pending order matching filter.
+// It returns an error only when it fails to fetch the orders; failures to cancel are reported per order.
+func (c *Client) CancelOrders(ctx context.Context, filter Filter) (*BulkReport, error) {
+	orders, err := c.MatchingOrders(filter)
+	if err != nil {
+		return nil, err
+	}
+	return c.CancelOrderList(ctx, orders), nil
+}
+
+// CancelOrderList cancels orders, e.g. the ones of MatchingOrders a user confirmed, by their ids.
+// Failures to cancel are reported per order.
+func (c *Client) CancelOrderList(ctx context.Context, orders []Order) *BulkReport {
+	targets := make([]BulkResult, len(orders))
+	for i, o := range orders {
+		targets[i] = BulkResult{ID: string(o.ID), Instrument: o.Instrument}
+	}
+	return c.bulk(ctx, targets, func(ctx context.Context, id string) error {
+		return c.cancelOrderContext(ctx, OrderID(id))
+	})
+}
+
+// CloseTrades closes every open trade matching filter.
+// It returns an error only when it fails to fetch the trades; failures to close are reported per trade.
+func (c *Client) CloseTrades(ctx context.Context, filter Filter) (*BulkReport, error) {
+	trades, err := c.MatchingTrades(filter)
+	if err != nil {
+		return nil, err
+	}
+	return c.CloseTradeList(ctx, trades), nil
+}
+
+// CloseTradeList closes trades, e.g. the ones of MatchingTrades a user confirmed, by their ids.
+// Failures to close are reported per trade.
+func (c *Client) CloseTradeList(ctx context.Context, trades []Trade) *BulkReport {
+	targets := make([]BulkResult, len(trades))
+	for i, t := range trades {
+		targets[i] = BulkResult{ID: string(t.ID), Instrument: t.Instrument}
+	}
+	return c.bulk(ctx, targets, func(ctx context.Context, id string) error {
+		return c.closeOpenTrade(ctx, TradeID(id))
+	})
+}
+
+// bulk runs op for every target with fanOut.
+func (c *Client) bulk(ctx context.Context, targets []BulkResult, op func(ctx context.Context, id string) error) *BulkReport {
+	errs := c.fanOut(ctx, len(targets), func(ctx context.Context, i int) error {
+		return op(ctx, targets[i].ID)
+	})
+	for i := range targets {
+		targets[i].Err = errs[i]
+	}
+	return &BulkReport{Results: targets}
+}
+
+// fanOut runs op for 0 to n-1 with bounded concurrency, no faster than DefaultBulkRateLimit unless the client
+// has its own rate limit, and returns their errors by index. Calls not started before ctx is done fail with
+// the error of ctx.
+func (c *Client) fanOut(ctx context.Context, n int, op func(ctx context.Context, i int) error) []error {
+	concurrency := c.bulkConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+	var limiter *rateLimiter
+	if c.limiter == nil {
+		limiter = newRateLimiter(DefaultBulkRateLimit)
+	}
+	errs := make([]error, n)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			errs[i] = err
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+		if err := limiter.wait(ctx); err != nil {
+			<-sem
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = op(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
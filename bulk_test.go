@@ -0,0 +1,137 @@
+package oanda_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+func createLimitOrders(t *testing.T, client *oanda.Client, tag string, prices ...oanda.Price) {
+	t.Helper()
+	for _, price := range prices {
+		err := client.CreateOrder(oanda.Order{
+			Type:             oanda.OrderTypeLimit,
+			Instrument:       oanda.InstrumentUSDJPY,
+			Units:            100,
+			Price:            price,
+			TimeInForce:      oanda.TimeInForceGTC,
+			ClientExtensions: &oanda.ClientExtensions{Tag: tag},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCancelOrderListCancelsOnlyTheGivenOrders(t *testing.T) {
+	s := oandatest.NewServer()
+	defer s.Close()
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	createLimitOrders(t, client, "grid", 104, 103)
+
+	orders, err := client.MatchingOrders(oanda.Filter{Tag: "grid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("matched %d orders, want 2", len(orders))
+	}
+	// an order matching the filter is created after the orders are confirmed.
+	createLimitOrders(t, client, "grid", 102)
+
+	report := client.CancelOrderList(context.Background(), orders)
+	if err := report.Err(); err != nil {
+		t.Fatal(err)
+	}
+	left, err := client.MatchingOrders(oanda.Filter{Tag: "grid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 1 || left[0].Price != 102 {
+		t.Errorf("left orders = %+v, want the one created after the confirmation", left)
+	}
+}
+
+func TestCancelOrdersReportsFailuresPerOrder(t *testing.T) {
+	s := oandatest.NewServer()
+	defer s.Close()
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	createLimitOrders(t, client, "", 104, 103, 102)
+
+	s.Inject(oandatest.Fault{Method: http.MethodPut, Path: "/cancel", Status: http.StatusServiceUnavailable, Times: 1})
+	report, err := client.CancelOrders(context.Background(), oanda.Filter{Instruments: []oanda.Instrument{oanda.InstrumentUSDJPY}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 3 || len(report.Failed()) != 1 {
+		t.Fatalf("results = %+v, want 1 of 3 failed", report.Results)
+	}
+	if err := report.Err(); err == nil || !strings.Contains(err.Error(), "1 of 3 failed") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestCloseTradeListStopsWhenCanceled(t *testing.T) {
+	s := oandatest.NewServer()
+	defer s.Close()
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	for i := 0; i < 2; i++ {
+		if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 100, TimeInForce: oanda.TimeInForceFOK}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trades, err := client.MatchingTrades(oanda.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 {
+		t.Fatalf("matched %d trades, want 2", len(trades))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := client.CloseTradeList(ctx, trades)
+	for _, r := range report.Results {
+		if r.Err != context.Canceled {
+			t.Errorf("result of %s = %v, want context.Canceled", r.ID, r.Err)
+		}
+	}
+	if open, _ := client.FetchOpenTrades(); len(open) != 2 {
+		t.Errorf("%d open trades after a canceled close, want 2", len(open))
+	}
+
+	if err := client.CloseTradeList(context.Background(), trades).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if open, _ := client.FetchOpenTrades(); len(open) != 0 {
+		t.Errorf("%d open trades left", len(open))
+	}
+}
+
+func TestBulkIsRateLimitedByDefault(t *testing.T) {
+	s := oandatest.NewServer()
+	defer s.Close()
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient(oanda.WithBulkConcurrency(10))
+	createLimitOrders(t, client, "", 104, 103, 102, 101)
+	orders, err := client.MatchingOrders(oanda.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := client.CancelOrderList(context.Background(), orders).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed, min := time.Since(start), 3*time.Second/oanda.DefaultBulkRateLimit; elapsed < min {
+		t.Errorf("canceled 4 orders in %s, want at least %s", elapsed, min)
+	}
+}
@@ -0,0 +1,200 @@
+package oanda
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxCandleCount is the most candles OANDA API returns for a request.
+const MaxCandleCount = 5000
+
+var granularityDurations = map[string]time.Duration{
+	"S5":  5 * time.Second,
+	"S10": 10 * time.Second,
+	"S15": 15 * time.Second,
+	"S30": 30 * time.Second,
+	"M1":  time.Minute,
+	"M2":  2 * time.Minute,
+	"M4":  4 * time.Minute,
+	"M5":  5 * time.Minute,
+	"M10": 10 * time.Minute,
+	"M15": 15 * time.Minute,
+	"M30": 30 * time.Minute,
+	"H1":  time.Hour,
+	"H2":  2 * time.Hour,
+	"H3":  3 * time.Hour,
+	"H4":  4 * time.Hour,
+	"H6":  6 * time.Hour,
+	"H8":  8 * time.Hour,
+	"H12": 12 * time.Hour,
+	"D":   24 * time.Hour,
+	"W":   7 * 24 * time.Hour,
+}
+
+// GranularityDuration returns the duration of a candle of granularity, e.g. time.Minute for "M1".
+// Monthly candles have no fixed duration and are an error.
+func GranularityDuration(granularity string) (time.Duration, error) {
+	d, ok := granularityDurations[granularity]
+	if !ok {
+		return 0, fmt.Errorf("unsupported granularity: %q", granularity)
+	}
+	return d, nil
+}
+
+type candlestickData struct {
+	O string `json:"o"`
+	H string `json:"h"`
+	L string `json:"l"`
+	C string `json:"c"`
+}
+
+type candlestick struct {
+	Time     time.Time        `json:"time"`
+	Volume   int              `json:"volume"`
+	Complete bool             `json:"complete"`
+	Mid      *candlestickData `json:"mid,omitempty"`
+	Bid      *candlestickData `json:"bid,omitempty"`
+	Ask      *candlestickData `json:"ask,omitempty"`
+}
+
+type receivedCandles struct {
+	Candles []candlestick `json:"candles"`
+}
+
+// OHLC is the open, high, low and close prices of a candle.
+type OHLC struct {
+	O, H, L, C Price
+}
+
+// Candle is a candlestick of an instrument. Mid, Bid and Ask are set as requested by CandlesQuery.Price.
+type Candle struct {
+	Time     time.Time
+	Volume   int
+	Complete bool
+	Mid      *OHLC
+	Bid      *OHLC
+	Ask      *OHLC
+}
+
+// CandlesQuery is the query of FetchCandles.
+type CandlesQuery struct {
+	// Granularity is e.g. "M1" or "H1". OANDA API defaults to "S5".
+	Granularity string
+	// Price is the components of the prices to fetch, any of "M", "B" and "A". OANDA API defaults to "M".
+	Price string
+	From  *time.Time
+	To    *time.Time
+	// Count is the number of candles, up to MaxCandleCount. It cannot be set with both From and To.
+	Count int
+}
+
+func (q CandlesQuery) values() url.Values {
+	v := url.Values{}
+	if q.Granularity != "" {
+		v.Set("granularity", q.Granularity)
+	}
+	if q.Price != "" {
+		v.Set("price", q.Price)
+	}
+	if q.From != nil {
+		v.Set("from", q.From.UTC().Format(time.RFC3339Nano))
+	}
+	if q.To != nil {
+		v.Set("to", q.To.UTC().Format(time.RFC3339Nano))
+	}
+	if q.Count != 0 {
+		v.Set("count", strconv.Itoa(q.Count))
+	}
+	return v
+}
+
+func (d *candlestickData) toOHLC(p *fieldParser) *OHLC {
+	if d == nil {
+		return nil
+	}
+	return &OHLC{
+		O: p.price("open", d.O),
+		H: p.price("high", d.H),
+		L: p.price("low", d.L),
+		C: p.price("close", d.C),
+	}
+}
+
+func fromOHLC(o *OHLC) *candlestickData {
+	if o == nil {
+		return nil
+	}
+	return &candlestickData{
+		O: formatDecimal(o.O),
+		H: formatDecimal(o.H),
+		L: formatDecimal(o.L),
+		C: formatDecimal(o.C),
+	}
+}
+
+func (c *candlestick) toCandle() (Candle, error) {
+	var p fieldParser
+	candle := Candle{
+		Time:     c.Time,
+		Volume:   c.Volume,
+		Complete: c.Complete,
+		Mid:      c.Mid.toOHLC(&p),
+		Bid:      c.Bid.toOHLC(&p),
+		Ask:      c.Ask.toOHLC(&p),
+	}
+	return candle, p.err
+}
+
+// MarshalJSON encodes the candle in the format of OANDA API.
+func (c Candle) MarshalJSON() ([]byte, error) {
+	return json.Marshal(candlestick{
+		Time:     c.Time,
+		Volume:   c.Volume,
+		Complete: c.Complete,
+		Mid:      fromOHLC(c.Mid),
+		Bid:      fromOHLC(c.Bid),
+		Ask:      fromOHLC(c.Ask),
+	})
+}
+
+// UnmarshalJSON decodes a candlestick object of OANDA API.
+func (c *Candle) UnmarshalJSON(data []byte) error {
+	var cs candlestick
+	if err := json.Unmarshal(data, &cs); err != nil {
+		return err
+	}
+	candle, err := cs.toCandle()
+	if err != nil {
+		return err
+	}
+	*c = candle
+	return nil
+}
+
+// FetchCandles fetches candles of instrument in chronological order.
+func (c *Client) FetchCandles(instrument Instrument, query CandlesQuery) ([]Candle, error) {
+	body, err := c.fetchCandles(instrument, query.values())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch candles: %w", err)
+	}
+	var rc receivedCandles
+	if err := json.Unmarshal(body, &rc); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	candles := make([]Candle, 0, len(rc.Candles))
+	for _, cs := range rc.Candles {
+		candle, err := cs.toCandle()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert candle of %s: %w", cs.Time, err)
+		}
+		candles = append(candles, candle)
+	}
+	return candles, nil
+}
+
+func (c *Client) FetchCandlesJSON(instrument Instrument, query CandlesQuery) ([]byte, error) {
+	return c.fetchCandles(instrument, query.values())
+}
@@ -0,0 +1,295 @@
+// Package cassette records HTTP interactions with OANDA API to fixture files and replays them,
+// for deterministic tests based on real payloads.
+//
+//	rec, err := cassette.New("testdata/orders.json", cassette.ModeReplay)
+//	client := oanda.NewClient(accountID, apiKey, "Practice", oanda.WithHTTPClient(rec.Client()))
+//	orders, err := client.FetchOrders()
+//
+// Recorded interactions never contain request headers, so the Authorization header is not written,
+// and OANDA account IDs are replaced with RedactedAccountID everywhere.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+// Mode is the mode of a Recorder.
+type Mode int
+
+const (
+	// ModeReplay replays interactions from the cassette and fails requests which do not match any.
+	ModeReplay Mode = iota
+	// ModeRecord sends requests and records the interactions, overwriting the cassette on Save.
+	ModeRecord
+	// ModeReplayOrRecord replays when the cassette exists and records otherwise.
+	ModeReplayOrRecord
+)
+
+// RedactedAccountID replaces OANDA account IDs in recorded interactions.
+const RedactedAccountID = "000-000-0000000-000"
+
+var accountIDPattern = regexp.MustCompile(`\b\d{3}-\d{3}-\d{5,}-\d{3}\b`)
+
+// ErrNoInteraction is returned in replay mode for a request which matches no recorded interaction.
+var ErrNoInteraction = errors.New("no recorded interaction matches the request")
+
+// Interaction is a recorded pair of a request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is a recorded request.
+type Request struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+type cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper which records or replays interactions.
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+	redact    []redaction
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+type redaction struct {
+	secret, replacement string
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithTransport makes the recorder send requests with transport in record mode instead of http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// WithRedaction replaces secret with replacement in recorded interactions and in requests to replay.
+func WithRedaction(secret, replacement string) Option {
+	return func(r *Recorder) {
+		r.redact = append(r.redact, redaction{secret, replacement})
+	}
+}
+
+// New returns a recorder of the cassette file at path. In replay mode the file is loaded.
+func New(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, transport: http.DefaultTransport}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.mode == ModeReplayOrRecord {
+		r.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+	if r.mode == ModeReplay {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+		var c cassette
+		if err := json.Unmarshal(b, &c); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal cassette %s: %w", path, err)
+		}
+		r.interactions = c.Interactions
+		r.used = make([]bool, len(c.Interactions))
+	}
+	return r, nil
+}
+
+// Mode returns the mode the recorder works in.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns an HTTP client which uses the recorder as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip records or replays req.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		util.SafeClose(req.Body)
+		body = b
+	}
+	recorded := Request{
+		Method: req.Method,
+		Path:   r.redactString(req.URL.Path),
+		Query:  r.redactString(canonicalQuery(req.URL.RawQuery)),
+		Body:   r.redactString(string(body)),
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	defer util.SafeClose(resp.Body)
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	header := http.Header{}
+	for k, vs := range resp.Header {
+		if k == "Content-Length" {
+			continue // redaction may change the length
+		}
+		for _, v := range vs {
+			header.Add(k, r.redactString(v))
+		}
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     header,
+			Body:       r.redactString(string(respBody)),
+		},
+	})
+	r.mu.Unlock()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// replay returns the response of the first unused interaction which matches the request.
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, in := range r.interactions {
+		if r.used[i] || !matches(in.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			StatusCode:    in.Response.StatusCode,
+			Status:        in.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(strings.NewReader(in.Response.Body)),
+			ContentLength: int64(len(in.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s?%s", ErrNoInteraction, recorded.Method, recorded.Path, recorded.Query)
+}
+
+// Unused returns the interactions which have not been replayed, or all interactions in record mode.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, in := range r.interactions {
+		if i >= len(r.used) || !r.used[i] {
+			unused = append(unused, in)
+		}
+	}
+	return unused
+}
+
+// Save writes the recorded interactions to the cassette. It does nothing in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode == ModeReplay {
+		return nil
+	}
+	r.mu.Lock()
+	b, err := json.MarshalIndent(cassette{r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("failed to create cassette directory: %w", err)
+	}
+	if err := ioutil.WriteFile(r.path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) redactString(s string) string {
+	for _, rd := range r.redact {
+		if rd.secret != "" {
+			s = strings.ReplaceAll(s, rd.secret, rd.replacement)
+		}
+	}
+	return accountIDPattern.ReplaceAllString(s, RedactedAccountID)
+}
+
+func matches(recorded, req Request) bool {
+	return recorded.Method == req.Method &&
+		recorded.Path == req.Path &&
+		canonicalQuery(recorded.Query) == req.Query &&
+		sameBody(recorded.Body, req.Body)
+}
+
+// canonicalQuery sorts the parameters of a query so that their order does not matter.
+func canonicalQuery(rawQuery string) string {
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	return q.Encode()
+}
+
+// sameBody compares bodies as JSON when both are JSON, and as strings otherwise.
+func sameBody(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
@@ -0,0 +1,80 @@
+package cassette
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordRedactsAndReplays(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"account":{"id":"101-009-1234567-001","alias":"secret-alias"}}`))
+	}))
+	defer s.Close()
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "account.json")
+
+	rec, err := New(path, ModeReplayOrRecord, WithRedaction("secret-alias", "ALIAS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode() != ModeRecord {
+		t.Fatalf("mode = %v, want ModeRecord without a cassette", rec.Mode())
+	}
+	req, _ := http.NewRequest(http.MethodGet, s.URL+"/v3/accounts/101-009-1234567-001/summary?b=2&a=1", nil)
+	req.Header.Set("Authorization", "Bearer api-key")
+	resp, err := rec.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "101-009-1234567-001") {
+		t.Errorf("recorded response is redacted for the caller: %s", body)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"101-009-1234567-001", "secret-alias", "api-key"} {
+		if strings.Contains(string(saved), secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, saved)
+		}
+	}
+
+	replay, err := New(path, ModeReplayOrRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Mode() != ModeReplay {
+		t.Fatalf("mode = %v, want ModeReplay with a cassette", replay.Mode())
+	}
+	resp, err = replay.Client().Get("https://api-fxpractice.oanda.com/v3/accounts/101-009-7654321-001/summary?a=1&b=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if want := `{"account":{"id":"` + RedactedAccountID + `","alias":"ALIAS"}}`; string(body) != want {
+		t.Errorf("replayed body = %s, want %s", body, want)
+	}
+	if len(replay.Unused()) != 0 {
+		t.Error("the interaction is left unused")
+	}
+	if _, err := replay.Client().Get("https://api-fxpractice.oanda.com/v3/accounts/101-009-7654321-001/summary?a=1&b=2"); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("err = %v, want ErrNoInteraction for a used interaction", err)
+	}
+}
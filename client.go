@@ -2,12 +2,18 @@ package oanda
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
 )
 
 const authorizationPrefix = "Bearer "
@@ -17,74 +23,188 @@ type Client struct {
 	accountID       string
 	client          *http.Client
 	endpoint        string
+	streamEndpoint  string
 	requiredHeaders http.Header
+	limiter         *rateLimiter
+	dryRun          io.Writer
+	bulkConcurrency int
+	instruments     *instrumentCache
+}
+
+// Option configures optional behavior of Client.
+type Option func(*Client)
+
+// WithRateLimit limits the number of requests the client sends per second.
+// OANDA API allows up to 120 requests per second per client.
+func WithRateLimit(requestsPerSecond float64) Option {
+	return func(c *Client) {
+		c.limiter = newRateLimiter(requestsPerSecond)
+	}
+}
+
+// WithEndpoint makes the client send requests, including streaming ones, to endpoint,
+// e.g. the URL of an oandatest.Server, instead of the OANDA API of the environment.
+func WithEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.endpoint = strings.TrimSuffix(endpoint, "/")
+		c.streamEndpoint = c.endpoint
+	}
+}
+
+// WithHTTPClient makes the client send requests with client, e.g. one with a cassette.Recorder as its transport.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.client = client
+	}
+}
+
+// ErrDryRun is returned by requests which would change the account when the client is in dry run mode.
+var ErrDryRun = errors.New("dry run: request was not sent")
+
+// WithDryRun makes the client write the method, URL and body of every request which would change
+// the account to w instead of sending it. Such requests fail with ErrDryRun; GET requests are sent as usual.
+func WithDryRun(w io.Writer) Option {
+	return func(c *Client) {
+		c.dryRun = w
+	}
+}
+
+// APIError is returned when OANDA API responds with an unexpected status code.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("HTTP %s: %s", e.Status, e.Body)
 }
 
 // NewClient constructs OANDA API client objects.
-func NewClient(accountID, apiKey string, environment string) *Client {
+func NewClient(accountID, apiKey string, environment string, opts ...Option) *Client {
 	requiredHeaders := http.Header{}
 	requiredHeaders.Add("Authorization", authorizationPrefix+apiKey)
 	requiredHeaders.Add("Content-Type", "application/json")
-	var endpoint string
+	requiredHeaders.Add("Accept-Datetime-Format", "RFC3339")
+	var endpoint, streamEndpoint string
 	if environment == "Trade" {
 		endpoint = "https://api-fxtrade.oanda.com"
+		streamEndpoint = "https://stream-fxtrade.oanda.com"
 	} else {
 		endpoint = "https://api-fxpractice.oanda.com"
+		streamEndpoint = "https://stream-fxpractice.oanda.com"
 	}
-	return &Client{
+	c := &Client{
 		accountID:       accountID,
 		client:          &http.Client{},
 		endpoint:        endpoint,
+		streamEndpoint:  streamEndpoint,
 		requiredHeaders: requiredHeaders,
+		instruments:     &instrumentCache{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-func (c *Client) fetchOpenTrades() ([]byte, error) {
-	req, err := http.NewRequest(http.MethodGet, c.endpoint+"/v3/accounts/"+c.accountID+"/openTrades", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build request: %v", err)
+// AccountID returns the id of the account the client operates on.
+func (c *Client) AccountID() string {
+	return c.accountID
+}
+
+// ForAccount returns a view of the client which operates on the account id.
+// The view shares the HTTP transport, the rate limiter and the credentials with c.
+func (c *Client) ForAccount(id string) *Client {
+	view := *c
+	view.accountID = id
+	view.instruments = &instrumentCache{}
+	return &view
+}
+
+// do sends req and returns the response body when the response has the expected status code.
+func (c *Client) do(req *http.Request, expectedStatus int) ([]byte, error) {
+	req.Header = c.requiredHeaders.Clone()
+	if c.dryRun != nil && req.Method != http.MethodGet {
+		return nil, c.writeDryRun(req)
+	}
+	if err := c.limiter.wait(req.Context()); err != nil {
+		return nil, err
 	}
-	c.requiredHeaders.Add("Accept-Datetime-Format", "RFC3339")
-	req.Header = c.requiredHeaders
-	req.URL.RawQuery = req.URL.Query().Encode()
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch response: %v", err)
+		return nil, fmt.Errorf("failed to fetch response: %w", err)
 	}
-	defer safeClose(resp.Body)
+	defer util.SafeClose(resp.Body)
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP %s: failed to read response body: %v", resp.Status, err)
+		return nil, fmt.Errorf("HTTP %s: failed to read response body: %w", resp.Status, err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %s: %s", resp.Status, body)
+	if resp.StatusCode != expectedStatus {
+		return nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
 	}
 	return body, nil
 }
 
-func (c *Client) reduceTradeSize(id tradeID, body []byte) error {
-	req, err := http.NewRequest(
-		http.MethodPut,
-		c.endpoint+"/v3/accounts/"+c.accountID+"/trades/"+string(id)+"/close",
-		bytes.NewReader(body),
-	)
-	if err != nil {
-		return fmt.Errorf("failed to build request: %v", err)
+// openStream sends req and returns the response whose body is the stream.
+func (c *Client) openStream(req *http.Request) (*http.Response, error) {
+	req.Header = c.requiredHeaders.Clone()
+	if err := c.limiter.wait(req.Context()); err != nil {
+		return nil, err
 	}
-	req.Header = c.requiredHeaders
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to fetch response: %v", err)
+		return nil, fmt.Errorf("failed to fetch response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer util.SafeClose(resp.Body)
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
 	}
-	defer safeClose(resp.Body)
-	respBody, err := ioutil.ReadAll(resp.Body)
+	return resp, nil
+}
+
+func (c *Client) writeDryRun(req *http.Request) error {
+	fmt.Fprintf(c.dryRun, "%s %s\n", req.Method, req.URL)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+		defer util.SafeClose(body)
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+		var indented bytes.Buffer
+		if json.Indent(&indented, b, "", "  ") == nil {
+			b = indented.Bytes()
+		}
+		fmt.Fprintf(c.dryRun, "%s\n", b)
+	}
+	return ErrDryRun
+}
+
+func (c *Client) fetchOpenTrades() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, c.endpoint+"/v3/accounts/"+c.accountID+"/openTrades", nil)
 	if err != nil {
-		return fmt.Errorf("HTTP %s: failed to read response body: %v", resp.Status, err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %s: %s", resp.Status, respBody)
+	return c.do(req, http.StatusOK)
+}
+
+func (c *Client) reduceTradeSize(ctx context.Context, id TradeID, body []byte) error {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPut,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/trades/"+string(id)+"/close",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
 	}
-	return nil
+	_, err = c.do(req, http.StatusOK)
+	return err
 }
 
 func (c *Client) fetchOrders() ([]byte, error) {
@@ -94,141 +214,205 @@ func (c *Client) fetchOrders() ([]byte, error) {
 		nil,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build request: %v", err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	c.requiredHeaders.Add("Accept-Datetime-Format", "RFC3339")
-	req.Header = c.requiredHeaders
-	req.URL.RawQuery = req.URL.Query().Encode()
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch response: %v", err)
-	}
-	defer safeClose(resp.Body)
-	body, err := ioutil.ReadAll(resp.Body)
+	return c.do(req, http.StatusOK)
+}
+
+func (c *Client) fetchOrder(orderID OrderID) ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/orders/"+string(orderID),
+		nil,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP %s: failed to read response body: %v", resp.Status, err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %s: %s", resp.Status, body)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	return body, nil
+	return c.do(req, http.StatusOK)
 }
 
-func (c *Client) updateOrder(orderID orderID, body []byte) error {
+func (c *Client) updateOrder(orderID OrderID, body []byte) ([]byte, error) {
 	req, err := http.NewRequest(
 		http.MethodPut,
 		c.endpoint+"/v3/accounts/"+c.accountID+"/orders/"+string(orderID),
 		bytes.NewReader(body),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to build request: %v", err)
-	}
-	c.requiredHeaders.Add("Accept-Datetime-Format", "RFC3339")
-	req.Header = c.requiredHeaders
-	req.URL.RawQuery = req.URL.Query().Encode()
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to fetch response: %v", err)
-	}
-	defer safeClose(resp.Body)
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("HTTP %s: failed to read response body: %v", resp.Status, err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("HTTP %s: %s", resp.Status, respBody)
-	}
-	return nil
+	return c.do(req, http.StatusCreated)
 }
 
-func (c *Client) createOrder(body []byte) error {
+func (c *Client) createOrder(body []byte) ([]byte, error) {
 	req, err := http.NewRequest(
 		http.MethodPost,
 		c.endpoint+"/v3/accounts/"+c.accountID+"/orders",
 		bytes.NewReader(body),
 	)
 	if err != nil {
-		return fmt.Errorf("failed to build request: %v", err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	c.requiredHeaders.Add("Accept-Datetime-Format", "RFC3339")
-	req.Header = c.requiredHeaders
-	req.URL.RawQuery = req.URL.Query().Encode()
-	resp, err := c.client.Do(req)
+	return c.do(req, http.StatusCreated)
+}
+
+func (c *Client) cancelOrder(ctx context.Context, orderID OrderID) error {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPut,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/orders/"+string(orderID)+"/cancel",
+		nil,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to fetch response: %v", err)
+		return fmt.Errorf("failed to build request: %w", err)
 	}
-	defer safeClose(resp.Body)
-	respBody, err := ioutil.ReadAll(resp.Body)
+	_, err = c.do(req, http.StatusOK)
+	return err
+}
+
+func (c *Client) fetchOrderBook(instrument Instrument, dateTime *time.Time) ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		c.endpoint+"/v3/instruments/"+string(instrument)+"/orderBook",
+		nil,
+	)
 	if err != nil {
-		return fmt.Errorf("HTTP %s: failed to read response body: %v", resp.Status, err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("HTTP %s: %s", resp.Status, respBody)
+	if dateTime != nil {
+		req.URL.RawQuery = url.Values{"time": {dateTime.UTC().Format(time.RFC3339Nano)}}.Encode()
 	}
-	return nil
+	return c.do(req, http.StatusOK)
 }
 
-func (c *Client) cancelOrder(orderID orderID) error {
+func (c *Client) fetchCandles(instrument Instrument, query url.Values) ([]byte, error) {
 	req, err := http.NewRequest(
-		http.MethodPut,
-		c.endpoint+"/v3/accounts/"+c.accountID+"/orders/"+string(orderID)+"/cancel",
+		http.MethodGet,
+		c.endpoint+"/v3/instruments/"+string(instrument)+"/candles",
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to build request: %v", err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	c.requiredHeaders.Add("Accept-Datetime-Format", "RFC3339")
-	req.Header = c.requiredHeaders
-	req.URL.RawQuery = req.URL.Query().Encode()
-	resp, err := c.client.Do(req)
+	req.URL.RawQuery = query.Encode()
+	return c.do(req, http.StatusOK)
+}
+
+func (c *Client) fetchPricing(instruments []Instrument, includeHomeConversions bool) ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/pricing",
+		nil,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to fetch response: %v", err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	var names []string
+	for _, i := range instruments {
+		names = append(names, string(i))
 	}
-	defer safeClose(resp.Body)
-	respBody, err := ioutil.ReadAll(resp.Body)
+	query := url.Values{"instruments": {strings.Join(names, ",")}}
+	if includeHomeConversions {
+		query.Set("includeHomeConversions", "true")
+	}
+	req.URL.RawQuery = query.Encode()
+	return c.do(req, http.StatusOK)
+}
+
+func (c *Client) fetchInstruments(instruments []Instrument) ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/instruments",
+		nil,
+	)
 	if err != nil {
-		return fmt.Errorf("HTTP %s: failed to read response body: %v", resp.Status, err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("HTTP %s: %s", resp.Status, respBody)
+	if len(instruments) > 0 {
+		var names []string
+		for _, i := range instruments {
+			names = append(names, string(i))
+		}
+		req.URL.RawQuery = url.Values{"instruments": {strings.Join(names, ",")}}.Encode()
 	}
-	return nil
+	return c.do(req, http.StatusOK)
 }
 
-func (c *Client) fetchOrderBook(instrument instrument, dateTime *time.Time) ([]byte, error) {
-	url := c.endpoint + "/v3/instruments/" + string(instrument) + "/orderBook"
-	if dateTime != nil {
-		url = c.endpoint + "/v3/instruments/" + string(instrument) + "/orderBook?time=" + dateTime.UTC().Format(time.RFC3339Nano)
+func (c *Client) streamPricing(ctx context.Context, instruments []Instrument) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		c.streamEndpoint+"/v3/accounts/"+c.accountID+"/pricing/stream",
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	req, err := http.NewRequest(
+	var names []string
+	for _, i := range instruments {
+		names = append(names, string(i))
+	}
+	req.URL.RawQuery = url.Values{"instruments": {strings.Join(names, ",")}}.Encode()
+	return c.openStream(req)
+}
+
+func (c *Client) streamTransactions(ctx context.Context) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
 		http.MethodGet,
-		url,
+		c.streamEndpoint+"/v3/accounts/"+c.accountID+"/transactions/stream",
 		nil,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build request: %v", err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	c.requiredHeaders.Add("Accept-Datetime-Format", "RFC3339")
-	req.Header = c.requiredHeaders
-	resp, err := c.client.Do(req)
+	return c.openStream(req)
+}
+
+func (c *Client) fetchTransactionsSinceID(id TransactionID) ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/transactions/sinceid",
+		nil,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch response: %v", err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	defer safeClose(resp.Body)
-	body, err := ioutil.ReadAll(resp.Body)
+	req.URL.RawQuery = url.Values{"id": {string(id)}}.Encode()
+	return c.do(req, http.StatusOK)
+}
+
+func (c *Client) fetchAccountSummary() ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/summary",
+		nil,
+	)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP %s: failed to read response body: %v", resp.Status, err)
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %s: %s", resp.Status, body)
+	return c.do(req, http.StatusOK)
+}
+
+func (c *Client) fetchOpenPositions() ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodGet,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/openPositions",
+		nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
-	return body, nil
+	return c.do(req, http.StatusOK)
 }
 
-func safeClose(closer io.Closer) {
-	if closer != nil {
-		if err := closer.Close(); err != nil {
-			log.Printf("failed to close: %v", err)
-		}
+func (c *Client) closePosition(instrument Instrument, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(
+		http.MethodPut,
+		c.endpoint+"/v3/accounts/"+c.accountID+"/positions/"+string(instrument)+"/close",
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
 	}
+	return c.do(req, http.StatusOK)
 }
@@ -0,0 +1,69 @@
+package main
+
+func runPricing(a *app, args []string) error {
+	fs := a.newFlagSet("pricing")
+	var out outputFlags
+	out.register(fs)
+	instruments := fs.String("instruments", "USD_JPY,EUR_USD,EUR_JPY", "comma separated instruments")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	ins := parseInstruments(*instruments)
+	if len(ins) == 0 {
+		return usagef("--instruments must not be empty")
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	prices, err := client.FetchPricing(ins...)
+	if err != nil {
+		return err
+	}
+	return a.render(&out, view{prices, prices, priceColumns})
+}
+
+func runAccount(a *app, args []string) error {
+	fs := a.newFlagSet("account")
+	var out outputFlags
+	out.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	summary, err := client.FetchAccountSummary()
+	if err != nil {
+		return err
+	}
+	return a.render(&out, view{summary, summary, accountColumns})
+}
+
+func runPositions(a *app, args []string) error {
+	fs := a.newFlagSet("positions")
+	var out outputFlags
+	out.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	positions, err := client.FetchOpenPositions()
+	if err != nil {
+		return err
+	}
+	return a.render(&out, view{positions, positions, positionColumns})
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// filterFlags are the flags of oanda.Filter.
+type filterFlags struct {
+	instruments string
+	types       string
+	side        string
+	tag         string
+	comment     string
+	olderThan   time.Duration
+}
+
+func (f *filterFlags) register(fs *flag.FlagSet, orders bool) {
+	fs.StringVar(&f.instruments, "instrument", "", "comma separated instruments")
+	if orders {
+		fs.StringVar(&f.types, "type", "", "comma separated order types")
+	}
+	fs.StringVar(&f.side, "side", "", "buy or sell")
+	fs.StringVar(&f.tag, "tag", "", "client extensions tag")
+	fs.StringVar(&f.comment, "comment", "", "text contained in the client extensions comment")
+	fs.DurationVar(&f.olderThan, "older-than", 0, "minimum age, e.g. 30m")
+}
+
+func (f *filterFlags) filter() (oanda.Filter, error) {
+	filter := oanda.Filter{
+		Instruments: parseInstruments(f.instruments),
+		Side:        oanda.Side(strings.ToLower(f.side)),
+		Tag:         f.tag,
+		Comment:     f.comment,
+		OlderThan:   f.olderThan,
+	}
+	for _, t := range strings.Split(f.types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			filter.Types = append(filter.Types, oanda.OrderType(strings.ToUpper(t)))
+		}
+	}
+	if filter.Side != "" && filter.Side != oanda.SideBuy && filter.Side != oanda.SideSell {
+		return filter, usagef("--side must be buy or sell")
+	}
+	return filter, nil
+}
+
+func resultColumn(header string, value func(r oanda.BulkResult) string) column {
+	return column{header, func(r interface{}) string { return value(r.(oanda.BulkResult)) }}
+}
+
+var resultColumns = []column{
+	resultColumn("ID", func(r oanda.BulkResult) string { return r.ID }),
+	resultColumn("INSTRUMENT", func(r oanda.BulkResult) string { return string(r.Instrument) }),
+	resultColumn("RESULT", func(r oanda.BulkResult) string {
+		switch {
+		case r.Err == nil:
+			return "ok"
+		case errors.Is(r.Err, oanda.ErrDryRun):
+			return "dry run"
+		}
+		return r.Err.Error()
+	}),
+}
+
+// reportBulk writes report and fails when an operation other than a dry run failed.
+func (a *app) reportBulk(out *outputFlags, report *oanda.BulkReport) error {
+	if err := a.render(out, view{report.Results, report.Results, resultColumns}); err != nil {
+		return err
+	}
+	var failed []string
+	for _, r := range report.Failed() {
+		if !errors.Is(r.Err, oanda.ErrDryRun) {
+			failed = append(failed, r.ID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d failed: %s", len(failed), len(report.Results), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// interruptible returns a context of parent which is also canceled on SIGINT.
+func interruptible(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		select {
+		case <-ch:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(ch)
+	}()
+	return ctx, cancel
+}
+
+func runOrdersCancelAll(a *app, args []string) error {
+	fs := a.newFlagSet("orders cancel-all")
+	var f filterFlags
+	f.register(fs, true)
+	var g guardFlags
+	g.register(fs)
+	var out outputFlags
+	out.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	filter, err := f.filter()
+	if err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	orders, err := client.MatchingOrders(filter)
+	if err != nil {
+		return err
+	}
+	if len(orders) == 0 {
+		fmt.Fprintln(a.stderr, "no orders match")
+		return nil
+	}
+	if err := a.guard(&g, fmt.Sprintf("cancel %d orders", len(orders))); err != nil {
+		return err
+	}
+	if client, err = a.getClient(); err != nil {
+		return err
+	}
+	ctx, cancel := interruptible(a.ctx)
+	defer cancel()
+	return a.reportBulk(&out, client.CancelOrderList(ctx, orders))
+}
+
+func runTradesCloseAll(a *app, args []string) error {
+	fs := a.newFlagSet("trades close-all")
+	var f filterFlags
+	f.register(fs, false)
+	var g guardFlags
+	g.register(fs)
+	var out outputFlags
+	out.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	filter, err := f.filter()
+	if err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	trades, err := client.MatchingTrades(filter)
+	if err != nil {
+		return err
+	}
+	if len(trades) == 0 {
+		fmt.Fprintln(a.stderr, "no trades match")
+		return nil
+	}
+	if err := a.guard(&g, fmt.Sprintf("close %d trades", len(trades))); err != nil {
+		return err
+	}
+	if client, err = a.getClient(); err != nil {
+		return err
+	}
+	ctx, cancel := interruptible(a.ctx)
+	defer cancel()
+	return a.reportBulk(&out, client.CloseTradeList(ctx, trades))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/orderbook"
+)
+
+func orderColumn(header string, value func(o oanda.Order) string) column {
+	return column{header, func(r interface{}) string { return value(r.(oanda.Order)) }}
+}
+
+var orderColumns = []column{
+	orderColumn("ID", func(o oanda.Order) string { return string(o.ID) }),
+	orderColumn("TYPE", func(o oanda.Order) string { return string(o.Type) }),
+	orderColumn("STATE", func(o oanda.Order) string { return o.State }),
+	orderColumn("INSTRUMENT", func(o oanda.Order) string { return string(o.Instrument) }),
+	orderColumn("UNITS", func(o oanda.Order) string {
+		if o.Units == 0 {
+			return ""
+		}
+		return strconv.Itoa(int(o.Units))
+	}),
+	orderColumn("PRICE", func(o oanda.Order) string { return formatPrice(o.Price) }),
+	orderColumn("DISTANCE", func(o oanda.Order) string { return formatPrice(o.Distance) }),
+	orderColumn("TRADE", func(o oanda.Order) string { return string(o.TradeID) }),
+	orderColumn("TIF", func(o oanda.Order) string { return string(o.TimeInForce) }),
+	orderColumn("GTD", func(o oanda.Order) string { return formatTime(o.GtdTime) }),
+	orderColumn("CREATED", func(o oanda.Order) string { return formatTime(o.CreateTime) }),
+}
+
+func tradeColumn(header string, value func(t oanda.Trade) string) column {
+	return column{header, func(r interface{}) string { return value(r.(oanda.Trade)) }}
+}
+
+var tradeColumns = []column{
+	tradeColumn("ID", func(t oanda.Trade) string { return string(t.ID) }),
+	tradeColumn("INSTRUMENT", func(t oanda.Trade) string { return string(t.Instrument) }),
+	tradeColumn("UNITS", func(t oanda.Trade) string { return strconv.Itoa(int(t.CurrentUnits)) }),
+	tradeColumn("PRICE", func(t oanda.Trade) string { return formatPrice(t.Price) }),
+	tradeColumn("UNREALIZED_PL", func(t oanda.Trade) string { return formatAmount(t.UnrealizedPL) }),
+	tradeColumn("REALIZED_PL", func(t oanda.Trade) string { return formatAmount(t.RealizedPL) }),
+	tradeColumn("OPENED", func(t oanda.Trade) string { return formatTime(t.OpenTime) }),
+}
+
+func positionColumn(header string, value func(p oanda.Position) string) column {
+	return column{header, func(r interface{}) string { return value(r.(oanda.Position)) }}
+}
+
+var positionColumns = []column{
+	positionColumn("INSTRUMENT", func(p oanda.Position) string { return string(p.Instrument) }),
+	positionColumn("LONG_UNITS", func(p oanda.Position) string { return strconv.Itoa(int(p.Long.Units)) }),
+	positionColumn("LONG_AVG", func(p oanda.Position) string { return formatPrice(p.Long.AveragePrice) }),
+	positionColumn("SHORT_UNITS", func(p oanda.Position) string { return strconv.Itoa(int(p.Short.Units)) }),
+	positionColumn("SHORT_AVG", func(p oanda.Position) string { return formatPrice(p.Short.AveragePrice) }),
+	positionColumn("UNREALIZED_PL", func(p oanda.Position) string { return formatAmount(p.UnrealizedPL) }),
+	positionColumn("PL", func(p oanda.Position) string { return formatAmount(p.PL) }),
+}
+
+func priceColumn(header string, value func(p oanda.ClientPrice) string) column {
+	return column{header, func(r interface{}) string { return value(r.(oanda.ClientPrice)) }}
+}
+
+var priceColumns = []column{
+	priceColumn("INSTRUMENT", func(p oanda.ClientPrice) string { return string(p.Instrument) }),
+	priceColumn("BID", func(p oanda.ClientPrice) string { return formatPrice(p.Bid) }),
+	priceColumn("ASK", func(p oanda.ClientPrice) string { return formatPrice(p.Ask) }),
+	priceColumn("SPREAD", func(p oanda.ClientPrice) string { return strconv.FormatFloat(float64(p.Spread()), 'f', 5, 64) }),
+	priceColumn("TRADEABLE", func(p oanda.ClientPrice) string { return strconv.FormatBool(p.Tradeable) }),
+	priceColumn("TIME", func(p oanda.ClientPrice) string { return formatTime(&p.Time) }),
+}
+
+func accountColumn(header string, value func(s *oanda.AccountSummary) string) column {
+	return column{header, func(r interface{}) string { return value(r.(*oanda.AccountSummary)) }}
+}
+
+var accountColumns = []column{
+	accountColumn("ID", func(s *oanda.AccountSummary) string { return s.ID }),
+	accountColumn("ALIAS", func(s *oanda.AccountSummary) string { return s.Alias }),
+	accountColumn("CURRENCY", func(s *oanda.AccountSummary) string { return s.Currency }),
+	accountColumn("BALANCE", func(s *oanda.AccountSummary) string { return formatAmount(s.Balance) }),
+	accountColumn("NAV", func(s *oanda.AccountSummary) string { return formatAmount(s.NAV) }),
+	accountColumn("UNREALIZED_PL", func(s *oanda.AccountSummary) string { return formatAmount(s.UnrealizedPL) }),
+	accountColumn("MARGIN_USED", func(s *oanda.AccountSummary) string { return formatAmount(s.MarginUsed) }),
+	accountColumn("MARGIN_AVAILABLE", func(s *oanda.AccountSummary) string { return formatAmount(s.MarginAvailable) }),
+	accountColumn("TRADES", func(s *oanda.AccountSummary) string { return strconv.Itoa(s.OpenTradeCount) }),
+	accountColumn("ORDERS", func(s *oanda.AccountSummary) string { return strconv.Itoa(s.PendingOrderCount) }),
+}
+
+func bucketColumn(header string, value func(b oanda.OrderBookBucket) string) column {
+	return column{header, func(r interface{}) string { return value(r.(oanda.OrderBookBucket)) }}
+}
+
+var bucketColumns = []column{
+	bucketColumn("PRICE", func(b oanda.OrderBookBucket) string { return formatPrice(b.Price) }),
+	bucketColumn("LONG%", func(b oanda.OrderBookBucket) string { return formatPercent(b.LongCountPercent) }),
+	bucketColumn("SHORT%", func(b oanda.OrderBookBucket) string { return formatPercent(b.ShortCountPercent) }),
+}
+
+// vopBucket is a bucket in the vicinity of a price.
+type vopBucket struct {
+	Side string // "lower" or "higher"
+	oanda.OrderBookBucket
+}
+
+func vopColumn(header string, value func(b vopBucket) string) column {
+	return column{header, func(r interface{}) string { return value(r.(vopBucket)) }}
+}
+
+var vopColumns = []column{
+	vopColumn("SIDE", func(b vopBucket) string { return b.Side }),
+	vopColumn("PRICE", func(b vopBucket) string { return formatPrice(b.Price) }),
+	vopColumn("LONG%", func(b vopBucket) string { return formatPercent(b.LongCountPercent) }),
+	vopColumn("SHORT%", func(b vopBucket) string { return formatPercent(b.ShortCountPercent) }),
+}
+
+func deltaColumn(header string, value func(d orderbook.BucketDelta) string) column {
+	return column{header, func(r interface{}) string { return value(r.(orderbook.BucketDelta)) }}
+}
+
+var deltaColumns = []column{
+	deltaColumn("PRICE", func(d orderbook.BucketDelta) string { return formatPrice(d.Price) }),
+	deltaColumn("LONG%", func(d orderbook.BucketDelta) string { return formatPercent(d.LongAfter) }),
+	deltaColumn("LONG_DELTA", func(d orderbook.BucketDelta) string { return strconv.FormatFloat(d.LongDelta(), 'f', 4, 64) }),
+	deltaColumn("SHORT%", func(d orderbook.BucketDelta) string { return formatPercent(d.ShortAfter) }),
+	deltaColumn("SHORT_DELTA", func(d orderbook.BucketDelta) string { return strconv.FormatFloat(d.ShortDelta(), 'f', 4, 64) }),
+	deltaColumn("STATUS", func(d orderbook.BucketDelta) string {
+		switch {
+		case d.New:
+			return "new"
+		case d.Vanished:
+			return "vanished"
+		}
+		return ""
+	}),
+}
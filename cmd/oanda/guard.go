@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// configKeyAllowLiveTrading is the config key which allows mutating commands on the Trade environment
+// without --live.
+const configKeyAllowLiveTrading = "AllowLiveTrading"
+
+// configKeyDefaultMaxUnits is the config key of the max units of an order of an instrument
+// without instrument settings.
+const configKeyDefaultMaxUnits = "DefaultMaxUnits"
+
+const (
+	// defaultMaxPriceDeviation is how far the prices of an order may be from the current price.
+	defaultMaxPriceDeviation = 0.05
+	// defaultMaxUnits is the max units of an order of an instrument without instrument settings
+	// unless DefaultMaxUnits is set.
+	defaultMaxUnits = 10000
+)
+
+// refusedError is returned when a mutating command is blocked or not confirmed.
+type refusedError struct {
+	msg string
+}
+
+func (e *refusedError) Error() string {
+	return e.msg
+}
+
+// guardFlags are the flags of every mutating command.
+type guardFlags struct {
+	dryRun bool
+	yes    bool
+}
+
+func (g *guardFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&g.dryRun, "dry-run", false, "validate and print the request without sending it")
+	fs.BoolVar(&g.yes, "yes", false, "do not ask for confirmation")
+}
+
+// guard must be called by a mutating command after validating its arguments,
+// and the command must get the client after it. It blocks the Trade environment unless live trading is allowed, puts the client in dry run mode
+// for --dry-run, and otherwise asks to confirm action unless --yes is given.
+func (a *app) guard(g *guardFlags, action string) error {
+	env, err := a.environment()
+	if err != nil {
+		return err
+	}
+	if g.dryRun {
+		a.dryRun = true
+		a.client = nil // construct the client again in dry run mode
+		return nil
+	}
+	if env == oanda.EnvironmentTrade {
+		allowed, err := a.liveTradingAllowed()
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return &refusedError{"live trading is blocked: pass --live or set " + configKeyAllowLiveTrading + " to true"}
+		}
+	}
+	if g.yes {
+		return nil
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stderr, "%s on account %s (%s)? [y/N] ", action, client.AccountID(), env)
+	answer, err := bufio.NewReader(a.stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(a.stderr)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return &refusedError{"aborted"}
+}
+
+// environment returns the resolved environment, Practice or Trade.
+func (a *app) environment() (string, error) {
+	provider, err := a.configProvider()
+	if err != nil {
+		return "", err
+	}
+	env, err := provider.Value(oanda.ConfigKeyEnvironment)
+	if errors.Is(err, oanda.ErrConfigNotFound) {
+		return oanda.EnvironmentPractice, nil
+	}
+	return env, err
+}
+
+func (a *app) liveTradingAllowed() (bool, error) {
+	if a.live {
+		return true, nil
+	}
+	provider, err := a.configProvider()
+	if err != nil {
+		return false, err
+	}
+	v, err := provider.Value(configKeyAllowLiveTrading)
+	if errors.Is(err, oanda.ErrConfigNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	allowed, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %w", configKeyAllowLiveTrading, err)
+	}
+	return allowed, nil
+}
+
+// riskManager returns client behind a risk manager which limits the units of every instrument
+// in the instrument settings to its MaxUnits, those of other instruments to DefaultMaxUnits,
+// and the prices of orders to defaultMaxPriceDeviation.
+func (a *app) riskManager(client *oanda.Client) (*oanda.RiskManager, error) {
+	limits := oanda.RiskLimits{
+		MaxUnits:          map[oanda.Instrument]oanda.Unit{},
+		DefaultMaxUnits:   defaultMaxUnits,
+		MaxPriceDeviation: defaultMaxPriceDeviation,
+	}
+	provider, err := a.configProvider()
+	if err != nil {
+		return nil, err
+	}
+	v, err := provider.Value(configKeyDefaultMaxUnits)
+	if err != nil && !errors.Is(err, oanda.ErrConfigNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		units, err := strconv.Atoi(v)
+		if err != nil || units <= 0 {
+			return nil, fmt.Errorf("invalid %s: %q is not a positive number of units", configKeyDefaultMaxUnits, v)
+		}
+		limits.DefaultMaxUnits = oanda.Unit(units)
+	}
+	if _, ok := provider.(oanda.ConfigLister); ok {
+		settings, err := oanda.LoadInstrumentSettings(provider)
+		if err != nil {
+			return nil, err
+		}
+		for instrument, s := range settings {
+			limits.MaxUnits[instrument] = s.MaxUnits
+		}
+	}
+	return oanda.NewRiskManager(client, limits), nil
+}
+
+// mutated reports the result of a mutating request; a request skipped by --dry-run is a success.
+func (a *app) mutated(err error, done string) error {
+	if errors.Is(err, oanda.ErrDryRun) {
+		fmt.Fprintln(a.stdout, "dry run: request was not sent")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, done)
+	return nil
+}
@@ -0,0 +1,251 @@
+// Command oanda operates an OANDA account through OANDA API.
+//
+// Usage:
+//
+//	oanda [--env Practice|Trade] [--account NAME|ID] <command> [<subcommand>] [flags]
+//
+// Configuration is read by oanda.DefaultConfigProvider. --account takes either
+// an account name of Accounts/<NAME> in the configuration or a raw account id.
+// Endpoint in the configuration, e.g. OANDA_ENDPOINT, replaces the endpoint of the environment.
+//
+// Commands which change the account accept --dry-run, which prints the request instead of sending it,
+// and ask for confirmation unless --yes is given. They are blocked on the Trade environment
+// unless --live is given or AllowLiveTrading is true in the configuration.
+//
+// Exit codes are 0 on success, 1 when an operation fails, 2 on usage errors
+// and 3 when a command is blocked or not confirmed.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// configKeyEndpoint is the config key of the endpoint which replaces the one of the environment.
+const configKeyEndpoint = "Endpoint"
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+	exitRefused = 3
+)
+
+// usageError is an error caused by invalid arguments.
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+type command struct {
+	name    string // e.g. "orders list"
+	summary string
+	run     func(a *app, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{"orders list", "list pending orders", runOrdersList},
+		{"orders get", "show an order", runOrdersGet},
+		{"orders create", "create an order", runOrdersCreate},
+		{"orders update", "replace a pending order", runOrdersUpdate},
+		{"orders cancel", "cancel a pending order", runOrdersCancel},
+		{"orders cancel-all", "cancel pending orders matching filters", runOrdersCancelAll},
+		{"trades list", "list open trades", runTradesList},
+		{"trades close", "close an open trade", runTradesClose},
+		{"trades close-all", "close open trades matching filters", runTradesCloseAll},
+		{"orderbook get", "show an order book", runOrderBookGet},
+		{"orderbook vop", "show buckets in the vicinity of a price", runOrderBookVOP},
+		{"orderbook diff", "show how an order book changed since the previous snapshot", runOrderBookDiff},
+		{"orderbook collect", "backfill order book snapshots into a local store", runOrderBookCollect},
+		{"pricing", "show current prices", runPricing},
+		{"account", "show the account summary", runAccount},
+		{"positions", "list open positions", runPositions},
+		{"report", "sync the trade journal and report P/L by day, instrument or tag", runReport},
+		{"size", "compute the units of an order from the account risk", runSize},
+		{"panic", "cancel all orders, close all positions and confirm the account is flat", runPanic},
+	}
+}
+
+// app holds the global flags and the client shared by commands.
+type app struct {
+	// ctx is the context of the command, which is also canceled on interrupt for long running commands.
+	ctx     context.Context
+	env     string
+	account string
+	live    bool
+	dryRun  bool
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+
+	provider oanda.ConfigProvider
+	client   *oanda.Client
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	return runContext(context.Background(), args, stdin, stdout, stderr)
+}
+
+// runContext runs the command of args until it finishes or ctx is done.
+func runContext(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{ctx: ctx, stdin: stdin, stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet("oanda", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&a.env, "env", "", "environment, Practice or Trade (default $ENVIRONMENT or Practice)")
+	fs.StringVar(&a.account, "account", "", "account name or id (default AccountID of the configuration)")
+	fs.BoolVar(&a.live, "live", false, "allow commands which change the account on the Trade environment")
+	fs.Usage = func() { a.usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if a.env == "" {
+		a.env = os.Getenv("ENVIRONMENT")
+	}
+
+	cmd, rest, ok := findCommand(fs.Args())
+	if !ok {
+		a.usage(fs)
+		return exitUsage
+	}
+	err := cmd.run(a, rest)
+	var uerr *usageError
+	var rerr *refusedError
+	switch {
+	case err == nil:
+		return exitOK
+	case err == flag.ErrHelp:
+		return exitOK
+	case errors.As(err, &uerr):
+		fmt.Fprintf(stderr, "oanda %s: %v\n", cmd.name, err)
+		return exitUsage
+	case errors.As(err, &rerr):
+		fmt.Fprintf(stderr, "oanda %s: %v\n", cmd.name, err)
+		return exitRefused
+	default:
+		fmt.Fprintf(stderr, "oanda %s: %v\n", cmd.name, err)
+		return exitFailure
+	}
+}
+
+func findCommand(args []string) (command, []string, bool) {
+	for _, n := range []int{2, 1} {
+		if len(args) < n {
+			continue
+		}
+		name := strings.Join(args[:n], " ")
+		for _, c := range commands() {
+			if c.name == name {
+				return c, args[n:], true
+			}
+		}
+	}
+	return command{}, nil, false
+}
+
+func (a *app) usage(fs *flag.FlagSet) {
+	fmt.Fprintln(a.stderr, "usage: oanda [global flags] <command> [<subcommand>] [flags]")
+	fmt.Fprintln(a.stderr, "\nglobal flags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(a.stderr, "\ncommands:")
+	cmds := commands()
+	sort.SliceStable(cmds, func(i, j int) bool { return strings.Fields(cmds[i].name)[0] < strings.Fields(cmds[j].name)[0] })
+	for _, c := range cmds {
+		fmt.Fprintf(a.stderr, "  %-20s %s\n", c.name, c.summary)
+	}
+}
+
+// newFlagSet returns the flag set of cmd, which reports errors to stderr.
+func (a *app) newFlagSet(cmd string) *flag.FlagSet {
+	fs := flag.NewFlagSet("oanda "+cmd, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	return fs
+}
+
+// parse parses args and converts parse failures to usage errors.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return &usageError{err.Error()}
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return nil
+}
+
+// configProvider returns the configuration, in which --env and --account take precedence.
+func (a *app) configProvider() (oanda.ConfigProvider, error) {
+	if a.provider != nil {
+		return a.provider, nil
+	}
+	provider, err := oanda.DefaultConfigProvider(a.env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	overrides := oanda.MapProvider{}
+	if a.env != "" {
+		overrides[oanda.ConfigKeyEnvironment] = a.env
+	}
+	if a.account != "" {
+		id := a.account
+		if registry, err := oanda.LoadAccountRegistry(provider); err == nil {
+			if account, ok := registry.Lookup(a.account); ok {
+				id = account.ID
+			}
+		}
+		overrides[oanda.ConfigKeyAccountID] = id
+	}
+	a.provider = oanda.NewChainProvider(overrides, provider)
+	return a.provider, nil
+}
+
+func (a *app) getClient() (*oanda.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	provider, err := a.configProvider()
+	if err != nil {
+		return nil, err
+	}
+	var opts []oanda.Option
+	endpoint, err := provider.Value(configKeyEndpoint)
+	if err == nil {
+		opts = append(opts, oanda.WithEndpoint(endpoint))
+	} else if !errors.Is(err, oanda.ErrConfigNotFound) {
+		return nil, fmt.Errorf("failed to get endpoint: %w", err)
+	}
+	if a.dryRun {
+		opts = append(opts, oanda.WithDryRun(a.stdout))
+	}
+	client, err := oanda.NewClientFromConfig(provider, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct client: %w", err)
+	}
+	a.client = client
+	return client, nil
+}
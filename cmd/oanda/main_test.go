@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+// newTestServer starts a fake server and configures the command to use it until the test ends.
+func newTestServer(t *testing.T) *oandatest.Server {
+	t.Helper()
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	setenv(t, map[string]string{
+		"OANDA_ENDPOINT":    s.URL,
+		"OANDA_APIKEY":      oandatest.DefaultAPIKey,
+		"OANDA_ACCOUNTID":   oandatest.DefaultAccountID,
+		"OANDA_ENVIRONMENT": oanda.EnvironmentPractice,
+		"ENVIRONMENT":       "",
+		"AWS_PROFILE":       "",
+		"AWS_ACCESS_KEY_ID": "",
+	})
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "oanda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+// setenv sets or, for empty values, unsets environment variables until the test ends.
+func setenv(t *testing.T, vars map[string]string) {
+	for key, value := range vars {
+		old, ok := os.LookupEnv(key)
+		if value == "" {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, value)
+		}
+		key := key
+		t.Cleanup(func() {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		})
+	}
+}
+
+func runCommand(stdin string, args ...string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = run(args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+// orderRequests returns the bodies of the orders posted to s.
+func orderRequests(t *testing.T, s *oandatest.Server) []map[string]interface{} {
+	t.Helper()
+	var orders []map[string]interface{}
+	for _, r := range s.Requests() {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.Path, "/orders") {
+			continue
+		}
+		var body struct {
+			Order map[string]interface{} `json:"order"`
+		}
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			t.Fatal(err)
+		}
+		orders = append(orders, body.Order)
+	}
+	return orders
+}
+
+func TestRunUsage(t *testing.T) {
+	newTestServer(t)
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"unknown"}, exitUsage},
+		{[]string{"--help"}, exitOK},
+		{[]string{"orders", "create", "--units", "1"}, exitUsage},
+		{[]string{"orders", "create", "--instrument", "USD_JPY"}, exitUsage},
+		{[]string{"orders", "create", "--instrument", "USD_JPY", "--units", "1", "--type", "LIMIT"}, exitUsage},
+		{[]string{"orders", "cancel"}, exitUsage},
+		{[]string{"orders", "list", "--output", "xml"}, exitUsage},
+		{[]string{"orders", "list", "extra"}, exitUsage},
+	}
+	for _, tt := range tests {
+		if code, _, stderr := runCommand("", tt.args...); code != tt.code {
+			t.Errorf("oanda %s exited with %d, want %d: %s", strings.Join(tt.args, " "), code, tt.code, stderr)
+		}
+	}
+}
+
+func TestRunOrdersCreateOmitsDefaults(t *testing.T) {
+	s := newTestServer(t)
+	code, stdout, stderr := runCommand("", "orders", "create", "--yes", "--instrument", "usd_jpy", "--units", "100")
+	if code != exitOK {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "order created") {
+		t.Errorf("stdout = %q", stdout)
+	}
+	code, _, stderr = runCommand("", "orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "-100",
+		"--type", "LIMIT", "--price", "106", "--trigger-condition", "bid", "--position-fill", "reduce_first")
+	if code != exitOK {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+
+	orders := orderRequests(t, s)
+	if len(orders) != 2 {
+		t.Fatalf("posted %d orders, want 2", len(orders))
+	}
+	market := orders[0]
+	if market["type"] != "MARKET" || market["instrument"] != "USD_JPY" || market["timeInForce"] != "FOK" {
+		t.Errorf("market order = %v", market)
+	}
+	for _, key := range []string{"triggerCondition", "positionFill", "price"} {
+		if _, ok := market[key]; ok {
+			t.Errorf("market order has %s: %v", key, market)
+		}
+	}
+	limit := orders[1]
+	if limit["triggerCondition"] != "BID" || limit["positionFill"] != "REDUCE_FIRST" || limit["timeInForce"] != "GTC" {
+		t.Errorf("limit order = %v", limit)
+	}
+}
+
+func TestRunOrdersCreateConfirmation(t *testing.T) {
+	s := newTestServer(t)
+	args := []string{"orders", "create", "--instrument", "USD_JPY", "--units", "100"}
+	if code, _, stderr := runCommand("n\n", args...); code != exitRefused {
+		t.Errorf("declined order exited with %d: %s", code, stderr)
+	}
+	if code, _, stderr := runCommand("", args...); code != exitRefused {
+		t.Errorf("order without an answer exited with %d: %s", code, stderr)
+	}
+	if n := len(orderRequests(t, s)); n != 0 {
+		t.Fatalf("posted %d orders without confirmation", n)
+	}
+	code, _, stderr := runCommand("y\n", args...)
+	if code != exitOK {
+		t.Fatalf("confirmed order exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, oandatest.DefaultAccountID) {
+		t.Errorf("prompt %q does not name the account", stderr)
+	}
+	if n := len(orderRequests(t, s)); n != 1 {
+		t.Errorf("posted %d orders, want 1", n)
+	}
+}
+
+func TestRunOrdersCreateDryRun(t *testing.T) {
+	s := newTestServer(t)
+	code, stdout, stderr := runCommand("", "orders", "create", "--dry-run", "--instrument", "USD_JPY", "--units", "100")
+	if code != exitOK {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "POST "+s.URL) || !strings.Contains(stdout, `"units": 100`) {
+		t.Errorf("stdout = %q", stdout)
+	}
+	if n := len(orderRequests(t, s)); n != 0 {
+		t.Errorf("dry run posted %d orders", n)
+	}
+}
+
+func TestRunOrdersCreateDefaultMaxUnits(t *testing.T) {
+	s := newTestServer(t)
+	args := []string{"orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "20000"}
+	if code, _, stderr := runCommand("", args...); code != exitFailure || !strings.Contains(stderr, string(oanda.RiskRuleMaxUnits)) {
+		t.Errorf("exited with %d, want a rejection by %s: %s", code, oanda.RiskRuleMaxUnits, stderr)
+	}
+	if n := len(orderRequests(t, s)); n != 0 {
+		t.Fatalf("posted %d orders above the default max units", n)
+	}
+	setenv(t, map[string]string{"OANDA_DEFAULTMAXUNITS": "50000"})
+	if code, _, stderr := runCommand("", args...); code != exitOK {
+		t.Errorf("exited with %d with a configured DefaultMaxUnits: %s", code, stderr)
+	}
+	setenv(t, map[string]string{"OANDA_DEFAULTMAXUNITS": "0"})
+	if code, _, _ := runCommand("", args...); code != exitFailure {
+		t.Errorf("exited with %d, want %d for DefaultMaxUnits 0", code, exitFailure)
+	}
+}
+
+func TestRunBlocksLiveTrading(t *testing.T) {
+	s := newTestServer(t)
+	args := []string{"--env", oanda.EnvironmentTrade, "orders", "cancel", "--yes", "--id", "1"}
+	setenv(t, map[string]string{"OANDA_ENVIRONMENT": ""})
+	if code, _, stderr := runCommand("", args...); code != exitRefused {
+		t.Errorf("exited with %d, want %d: %s", code, exitRefused, stderr)
+	}
+	if n := len(s.Requests()); n != 0 {
+		t.Errorf("sent %d requests on the Trade environment", n)
+	}
+}
+
+func TestRunEnvFlagOverridesConfigFile(t *testing.T) {
+	s := newTestServer(t)
+	setenv(t, map[string]string{"OANDA_ENVIRONMENT": ""})
+	if err := ioutil.WriteFile(".env", []byte("OANDA_ENVIRONMENT="+oanda.EnvironmentPractice+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, stderr := runCommand("", "--env", oanda.EnvironmentTrade, "orders", "cancel", "--yes", "--id", "1"); code != exitRefused {
+		t.Errorf("--env Trade with Practice in .env exited with %d, want %d: %s", code, exitRefused, stderr)
+	}
+	if n := len(s.Requests()); n != 0 {
+		t.Errorf("sent %d requests on the Trade environment", n)
+	}
+
+	if err := ioutil.WriteFile(".env", []byte("OANDA_ENVIRONMENT="+oanda.EnvironmentTrade+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"--env", oanda.EnvironmentPractice, "orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "100"}
+	if code, _, stderr := runCommand("", args...); code != exitOK {
+		t.Errorf("--env Practice with Trade in .env exited with %d: %s", code, stderr)
+	}
+}
+
+func TestRunOrdersList(t *testing.T) {
+	s := newTestServer(t)
+	if code, _, stderr := runCommand("", "orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "100",
+		"--type", "LIMIT", "--price", "104", "--tag", "test"); code != exitOK {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+	code, stdout, stderr := runCommand("", "orders", "list", "--output", "jsonl")
+	if code != exitOK {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 1 {
+		t.Fatalf("listed %d orders: %s", len(lines), stdout)
+	}
+	if !strings.Contains(stdout, "LIMIT") || !strings.Contains(stdout, "USD_JPY") {
+		t.Errorf("stdout = %q", stdout)
+	}
+
+	s.Inject(oandatest.Fault{Path: "/orders", Status: http.StatusServiceUnavailable})
+	if code, _, _ := runCommand("", "orders", "list"); code != exitFailure {
+		t.Errorf("failed request exited with %d, want %d", code, exitFailure)
+	}
+}
+
+func TestRunJSONOutputIsCamelCase(t *testing.T) {
+	newTestServer(t)
+	for _, args := range [][]string{
+		{"orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "100", "--type", "LIMIT", "--price", "104"},
+		{"orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "100", "--tp", "106"},
+	} {
+		if code, _, stderr := runCommand("", args...); code != exitOK {
+			t.Fatalf("exited with %d: %s", code, stderr)
+		}
+	}
+
+	_, stdout, _ := runCommand("", "orders", "list", "--output", "json")
+	var orders []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &orders); err != nil {
+		t.Fatalf("failed to decode %s: %v", stdout, err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("listed %d orders, want the limit and the take profit order", len(orders))
+	}
+	for _, key := range []string{"id", "type", "state", "timeInForce", "createTime"} {
+		if _, ok := orders[0][key]; !ok {
+			t.Errorf("order has no %s: %v", key, orders[0])
+		}
+	}
+
+	_, stdout, _ = runCommand("", "trades", "list", "--output", "json")
+	var trades []map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &trades); err != nil {
+		t.Fatalf("failed to decode %s: %v", stdout, err)
+	}
+	if len(trades) != 1 {
+		t.Fatalf("listed %d trades, want 1", len(trades))
+	}
+	for _, key := range []string{"id", "instrument", "price", "currentUnits", "unrealizedPL", "takeProfitOrderID"} {
+		if _, ok := trades[0][key]; !ok {
+			t.Errorf("trade has no %s: %v", key, trades[0])
+		}
+	}
+	if _, ok := trades[0]["CurrentUnits"]; ok {
+		t.Errorf("trade has Go field names: %v", trades[0])
+	}
+}
+
+func TestRunOrdersCancelAll(t *testing.T) {
+	s := newTestServer(t)
+	for _, tag := range []string{"grid", "grid", "manual"} {
+		if code, _, stderr := runCommand("", "orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "100",
+			"--type", "LIMIT", "--price", "104", "--tag", tag); code != exitOK {
+			t.Fatalf("exited with %d: %s", code, stderr)
+		}
+	}
+	code, stdout, stderr := runCommand("y\n", "orders", "cancel-all", "--tag", "grid", "--output", "csv")
+	if code != exitOK {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, "cancel 2 orders") {
+		t.Errorf("prompt = %q", stderr)
+	}
+	if n := strings.Count(stdout, ",ok"); n != 2 {
+		t.Errorf("stdout = %q, want 2 canceled orders", stdout)
+	}
+	cancels := 0
+	for _, r := range s.Requests() {
+		if strings.HasSuffix(r.Path, "/cancel") {
+			cancels++
+		}
+	}
+	if cancels != 2 {
+		t.Errorf("sent %d cancel requests, want 2", cancels)
+	}
+	if code, _, stderr := runCommand("", "orders", "cancel-all", "--tag", "grid"); code != exitOK || !strings.Contains(stderr, "no orders match") {
+		t.Errorf("exited with %d: %s", code, stderr)
+	}
+}
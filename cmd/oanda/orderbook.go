@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/orderbook"
+)
+
+func runOrderBookGet(a *app, args []string) error {
+	fs := a.newFlagSet("orderbook get")
+	var out outputFlags
+	out.register(fs)
+	instrument := fs.String("instrument", string(oanda.InstrumentUSDJPY), "instrument")
+	at := fs.String("time", "", "time of the snapshot as RFC3339 (default latest)")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	var dateTime *time.Time
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return usagef("invalid --time: %v", err)
+		}
+		dateTime = &t
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	book, err := client.FetchOrderBook(oanda.Instrument(strings.ToUpper(*instrument)), dateTime)
+	if err != nil {
+		return err
+	}
+	return a.render(&out, view{book, book.Buckets, bucketColumns})
+}
+
+func runOrderBookVOP(a *app, args []string) error {
+	fs := a.newFlagSet("orderbook vop")
+	var out outputFlags
+	out.register(fs)
+	instrument := fs.String("instrument", string(oanda.InstrumentUSDJPY), "instrument")
+	price := fs.Float64("price", 0, "price of interest (default the price of the order book)")
+	n := fs.Int("n", 3, "number of buckets on each side of the price")
+	var w watchFlags
+	w.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	if err := w.validate(&out); err != nil {
+		return err
+	}
+	if *n <= 0 {
+		return usagef("--n must be positive")
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	return a.show(&w, &out, func() (snapshot, error) {
+		book, err := client.FetchOrderBook(oanda.Instrument(strings.ToUpper(*instrument)), nil)
+		if err != nil {
+			return snapshot{}, err
+		}
+		p := book.Price
+		if *price != 0 {
+			p = oanda.Price(*price)
+		}
+		lower, higher, err := book.ExtractBucketVicinityOfPrice(p, *n)
+		if err != nil {
+			return snapshot{}, err
+		}
+		var buckets []vopBucket
+		for i := len(lower) - 1; i >= 0; i-- {
+			buckets = append(buckets, vopBucket{"lower", lower[i]})
+		}
+		for _, b := range higher {
+			buckets = append(buckets, vopBucket{"higher", b})
+		}
+		footer := []string{fmt.Sprintf("%s  PRICE %s  BOOK %s", book.Instrument, formatPrice(book.Price), formatTime(&book.Time))}
+		return snapshot{view{buckets, buckets, vopColumns}, footer}, nil
+	}, nil)
+}
+
+func runOrderBookDiff(a *app, args []string) error {
+	fs := a.newFlagSet("orderbook diff")
+	var out outputFlags
+	out.register(fs)
+	instrument := fs.String("instrument", string(oanda.InstrumentUSDJPY), "instrument")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	ins := oanda.Instrument(strings.ToUpper(*instrument))
+	latest, err := client.FetchOrderBook(ins, nil)
+	if err != nil {
+		return err
+	}
+	previousTime := latest.Time.Add(-orderbook.SnapshotInterval)
+	previous, err := client.FetchOrderBook(ins, &previousTime)
+	if err != nil {
+		return fmt.Errorf("failed to fetch previous order book: %w", err)
+	}
+	diff, err := orderbook.Diff(previous, latest)
+	if err != nil {
+		return err
+	}
+	return a.render(&out, view{diff, diff.Deltas, deltaColumns})
+}
+
+func runOrderBookCollect(a *app, args []string) error {
+	fs := a.newFlagSet("orderbook collect")
+	dir := fs.String("dir", "orderbooks", "directory of the snapshot store")
+	instruments := fs.String("instruments", string(oanda.InstrumentUSDJPY), "comma separated instruments")
+	from := fs.String("from", "", "start of the range as RFC3339 or a duration before now, e.g. 24h (required)")
+	to := fs.String("to", "", "end of the range as RFC3339 (default now)")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return usagef("--from is required")
+	}
+	f, err := time.Parse(time.RFC3339, *from)
+	if err != nil {
+		d, derr := time.ParseDuration(*from)
+		if derr != nil {
+			return usagef("invalid --from: %v", err)
+		}
+		f = time.Now().Add(-d)
+	}
+	t := time.Now()
+	if *to != "" {
+		if t, err = time.Parse(time.RFC3339, *to); err != nil {
+			return usagef("invalid --to: %v", err)
+		}
+	}
+	store, err := orderbook.OpenStore(*dir)
+	if err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	return orderbook.NewCollector(client, store).Backfill(context.Background(), parseInstruments(*instruments), f, t)
+}
+
+func parseInstruments(s string) []oanda.Instrument {
+	var instruments []oanda.Instrument
+	for _, i := range strings.Split(s, ",") {
+		if i = strings.TrimSpace(i); i != "" {
+			instruments = append(instruments, oanda.Instrument(strings.ToUpper(i)))
+		}
+	}
+	return instruments
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// orderFlags are the flags of the fields of an order.
+type orderFlags struct {
+	instrument       string
+	units            int
+	orderType        string
+	price            float64
+	priceBound       float64
+	distance         float64
+	tradeID          string
+	timeInForce      string
+	gtd              string
+	positionFill     string
+	triggerCondition string
+	takeProfit       float64
+	stopLoss         float64
+	stopLossDistance float64
+	takeProfitPips   float64
+	stopLossPips     float64
+	trailing         bool
+	clientID         string
+	tag              string
+	comment          string
+}
+
+func (f *orderFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.instrument, "instrument", "", "instrument, e.g. USD_JPY")
+	fs.IntVar(&f.units, "units", 0, "units, negative to sell")
+	fs.StringVar(&f.orderType, "type", string(oanda.OrderTypeMarket), "order type, e.g. MARKET, LIMIT, STOP, MARKET_IF_TOUCHED, TAKE_PROFIT, STOP_LOSS, TRAILING_STOP_LOSS")
+	fs.Float64Var(&f.price, "price", 0, "price of the order")
+	fs.Float64Var(&f.priceBound, "price-bound", 0, "worst price the order may be filled at")
+	fs.Float64Var(&f.distance, "distance", 0, "price distance of a STOP_LOSS or TRAILING_STOP_LOSS order")
+	fs.StringVar(&f.tradeID, "trade-id", "", "trade of a TAKE_PROFIT, STOP_LOSS or TRAILING_STOP_LOSS order")
+	fs.StringVar(&f.timeInForce, "tif", "", "time in force, GTC, GTD, GFD, FOK or IOC (default FOK for MARKET, GTD with --gtd, otherwise GTC)")
+	fs.StringVar(&f.gtd, "gtd", "", "good till date as RFC3339 or a duration from now, e.g. 48h")
+	fs.StringVar(&f.positionFill, "position-fill", "", "position fill, DEFAULT, OPEN_ONLY, REDUCE_FIRST or REDUCE_ONLY (default DEFAULT, omitted unless set)")
+	fs.StringVar(&f.triggerCondition, "trigger-condition", "", "trigger condition, DEFAULT, INVERSE, BID, ASK or MID (default DEFAULT, omitted unless set)")
+	fs.Float64Var(&f.takeProfit, "tp", 0, "take profit on fill price")
+	fs.Float64Var(&f.stopLoss, "sl", 0, "stop loss on fill price")
+	fs.Float64Var(&f.stopLossDistance, "sl-distance", 0, "stop loss on fill price distance")
+	fs.Float64Var(&f.takeProfitPips, "tp-pips", 0, "take profit on fill in pips from the price of the order, or the current price for MARKET")
+	fs.Float64Var(&f.stopLossPips, "sl-pips", 0, "stop loss on fill in pips from the price of the order, or the current price for MARKET")
+	fs.BoolVar(&f.trailing, "trailing", false, "make the stop loss of --sl-pips a trailing stop loss")
+	fs.StringVar(&f.clientID, "client-id", "", "client extensions id")
+	fs.StringVar(&f.tag, "tag", "", "client extensions tag")
+	fs.StringVar(&f.comment, "comment", "", "client extensions comment")
+}
+
+// order validates the flags and builds the order.
+func (f *orderFlags) order() (oanda.Order, error) {
+	o := oanda.Order{
+		Type:             oanda.OrderType(strings.ToUpper(f.orderType)),
+		Instrument:       oanda.Instrument(strings.ToUpper(f.instrument)),
+		Units:            oanda.Unit(f.units),
+		Price:            oanda.Price(f.price),
+		PriceBound:       oanda.Price(f.priceBound),
+		Distance:         oanda.Price(f.distance),
+		TradeID:          oanda.TradeID(f.tradeID),
+		PositionFill:     strings.ToUpper(f.positionFill),
+		TriggerCondition: strings.ToUpper(f.triggerCondition),
+		TimeInForce:      oanda.TimeInForce(strings.ToUpper(f.timeInForce)),
+	}
+	attached := o.Type == oanda.OrderTypeTakeProfit || o.Type == oanda.OrderTypeStopLoss ||
+		o.Type == oanda.OrderTypeGuaranteedStopLoss || o.Type == oanda.OrderTypeTrailingStopLoss
+	if attached {
+		if o.TradeID == "" {
+			return o, usagef("--trade-id is required for %s orders", o.Type)
+		}
+		// orders attached to a trade have neither instrument, units nor position fill.
+		o.Instrument, o.Units, o.PositionFill = "", 0, ""
+	} else {
+		if o.Instrument == "" {
+			return o, usagef("--instrument is required")
+		}
+		if o.Units == 0 {
+			return o, usagef("--units must not be 0")
+		}
+	}
+	if o.Type != oanda.OrderTypeMarket && o.Type != oanda.OrderTypeTrailingStopLoss && o.Price == 0 && o.Distance == 0 {
+		return o, usagef("--price is required for %s orders", o.Type)
+	}
+	if f.gtd != "" {
+		gtd, err := parseTime(f.gtd)
+		if err != nil {
+			return o, usagef("invalid --gtd: %v", err)
+		}
+		o.GtdTime = &gtd
+		if o.TimeInForce == "" {
+			o.TimeInForce = oanda.TimeInForceGTD
+		}
+	}
+	if o.TimeInForce == "" {
+		o.TimeInForce = oanda.TimeInForceGTC
+		if o.Type == oanda.OrderTypeMarket {
+			o.TimeInForce = oanda.TimeInForceFOK
+		}
+	}
+	if o.TimeInForce == oanda.TimeInForceGTD && o.GtdTime == nil {
+		return o, usagef("--gtd is required for GTD orders")
+	}
+	if f.takeProfit != 0 {
+		o.TakeProfitOnFill = &oanda.OnFill{Price: oanda.Price(f.takeProfit), TimeInForce: oanda.TimeInForceGTC}
+	}
+	if f.stopLoss != 0 || f.stopLossDistance != 0 {
+		o.StopLossOnFill = &oanda.OnFill{Price: oanda.Price(f.stopLoss), Distance: oanda.Price(f.stopLossDistance), TimeInForce: oanda.TimeInForceGTC}
+	}
+	if (f.takeProfitPips != 0 || f.stopLossPips != 0) && (o.TakeProfitOnFill != nil || o.StopLossOnFill != nil) {
+		return o, usagef("--tp-pips and --sl-pips cannot be combined with --tp, --sl and --sl-distance")
+	}
+	if f.trailing && f.stopLossPips == 0 {
+		return o, usagef("--trailing requires --sl-pips")
+	}
+	if f.takeProfitPips < 0 || f.stopLossPips < 0 {
+		return o, usagef("--tp-pips and --sl-pips must not be negative")
+	}
+	if f.clientID != "" || f.tag != "" || f.comment != "" {
+		o.ClientExtensions = &oanda.ClientExtensions{ID: f.clientID, Tag: f.tag, Comment: f.comment}
+	}
+	return o, nil
+}
+
+// parseTime parses s as RFC3339 or as a duration from now.
+func parseTime(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(d).UTC(), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func runOrdersList(a *app, args []string) error {
+	fs := a.newFlagSet("orders list")
+	var out outputFlags
+	out.register(fs)
+	var w watchFlags
+	w.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	if err := w.validate(&out); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	return a.show(&w, &out, func() (snapshot, error) {
+		orders, err := client.FetchOrders()
+		var decodeErrs oanda.OrderDecodeErrors
+		if errors.As(err, &decodeErrs) {
+			fmt.Fprintf(a.stderr, "warning: %v\n", err)
+		} else if err != nil {
+			return snapshot{}, err
+		}
+		s := snapshot{view: view{orders, orders, orderColumns}}
+		if w.watch {
+			footer, err := accountFooter(client)
+			if err != nil {
+				return snapshot{}, err
+			}
+			s.footer = append([]string{fmt.Sprintf("%d pending orders", len(orders))}, footer...)
+		}
+		return s, nil
+	}, transactionEvents(client))
+}
+
+func runOrdersGet(a *app, args []string) error {
+	fs := a.newFlagSet("orders get")
+	var out outputFlags
+	out.register(fs)
+	id := fs.String("id", "", "order id (required)")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	order, err := client.FetchOrder(oanda.OrderID(*id))
+	if err != nil {
+		return err
+	}
+	return a.render(&out, view{order, *order, orderColumns})
+}
+
+func runOrdersCreate(a *app, args []string) error {
+	fs := a.newFlagSet("orders create")
+	var f orderFlags
+	f.register(fs)
+	var g guardFlags
+	g.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	order, err := f.order()
+	if err != nil {
+		return err
+	}
+	if err := a.guard(&g, fmt.Sprintf("create %s order of %d %s", order.Type, order.Units, order.Instrument)); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	if f.takeProfitPips != 0 || f.stopLossPips != 0 {
+		order, err = client.BracketOrder(order, oanda.Pips(f.takeProfitPips), oanda.Pips(f.stopLossPips), f.trailing)
+		if err != nil {
+			return err
+		}
+	}
+	risk, err := a.riskManager(client)
+	if err != nil {
+		return err
+	}
+	return a.mutated(risk.CreateOrder(order), "order created")
+}
+
+func runOrdersUpdate(a *app, args []string) error {
+	fs := a.newFlagSet("orders update")
+	id := fs.String("id", "", "id of the order to replace (required)")
+	var f orderFlags
+	f.register(fs)
+	var g guardFlags
+	g.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	order, err := f.order()
+	if err != nil {
+		return err
+	}
+	order.ID = oanda.OrderID(*id)
+	if err := a.guard(&g, fmt.Sprintf("replace order %s", *id)); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	risk, err := a.riskManager(client)
+	if err != nil {
+		return err
+	}
+	return a.mutated(risk.UpdateOrder(order), fmt.Sprintf("order %s updated", *id))
+}
+
+func runOrdersCancel(a *app, args []string) error {
+	fs := a.newFlagSet("orders cancel")
+	id := fs.String("id", "", "order id (required)")
+	var g guardFlags
+	g.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := a.guard(&g, fmt.Sprintf("cancel order %s", *id)); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	return a.mutated(client.CancelOrder(oanda.OrderID(*id)), fmt.Sprintf("order %s canceled", *id))
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+const (
+	formatTable       = "table"
+	formatJSON        = "json"
+	formatJSONCompact = "json-compact"
+	formatJSONL       = "jsonl"
+	formatCSV         = "csv"
+	formatTemplate    = "template"
+)
+
+var formats = []string{formatTable, formatJSON, formatJSONCompact, formatJSONL, formatCSV, formatTemplate}
+
+// column is a column of table and CSV output.
+type column struct {
+	header string
+	value  func(record interface{}) string
+}
+
+// outputFlags are the flags which select the output format of list and get commands.
+type outputFlags struct {
+	format   string
+	template string
+}
+
+func (o *outputFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.format, "output", formatTable, "output format, one of "+strings.Join(formats, ", "))
+	fs.StringVar(&o.template, "template", "", "Go text/template executed for every record; implies --output template")
+}
+
+func (o *outputFlags) validate() error {
+	if o.template != "" {
+		o.format = formatTemplate
+	}
+	for _, f := range formats {
+		if o.format == f {
+			if f == formatTemplate && o.template == "" {
+				return usagef("--template is required for --output template")
+			}
+			return nil
+		}
+	}
+	return usagef("unknown --output %q, want one of %s", o.format, strings.Join(formats, ", "))
+}
+
+// view is what a command outputs: value as a whole for JSON,
+// and records, a slice of typed models, row by row for the other formats.
+type view struct {
+	value   interface{}
+	records interface{}
+	columns []column
+}
+
+func (a *app) render(o *outputFlags, v view) error {
+	records := toRecords(v.records)
+	switch o.format {
+	case formatJSON:
+		e := json.NewEncoder(a.stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(v.value)
+	case formatJSONCompact:
+		return json.NewEncoder(a.stdout).Encode(v.value)
+	case formatJSONL:
+		e := json.NewEncoder(a.stdout)
+		for _, r := range records {
+			if err := e.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatCSV:
+		w := csv.NewWriter(a.stdout)
+		if err := w.Write(headers(v.columns)); err != nil {
+			return err
+		}
+		for _, r := range records {
+			if err := w.Write(row(v.columns, r)); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		return w.Error()
+	case formatTemplate:
+		t, err := template.New("output").Parse(o.template)
+		if err != nil {
+			return usagef("invalid --template: %v", err)
+		}
+		for _, r := range records {
+			if err := t.Execute(a.stdout, r); err != nil {
+				return err
+			}
+			fmt.Fprintln(a.stdout)
+		}
+		return nil
+	default:
+		return writeTable(a.stdout, v.columns, records)
+	}
+}
+
+func writeTable(out io.Writer, columns []column, records []interface{}) error {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers(columns), "\t"))
+	for _, r := range records {
+		fmt.Fprintln(w, strings.Join(row(columns, r), "\t"))
+	}
+	return w.Flush()
+}
+
+func headers(columns []column) []string {
+	var hs []string
+	for _, c := range columns {
+		hs = append(hs, c.header)
+	}
+	return hs
+}
+
+func row(columns []column, record interface{}) []string {
+	var values []string
+	for _, c := range columns {
+		values = append(values, c.value(record))
+	}
+	return values
+}
+
+// toRecords converts a slice, or a single value, to records.
+func toRecords(records interface{}) []interface{} {
+	if records == nil {
+		return nil
+	}
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice {
+		return []interface{}{records}
+	}
+	rs := make([]interface{}, v.Len())
+	for i := range rs {
+		rs[i] = v.Index(i).Interface()
+	}
+	return rs
+}
+
+func formatPrice(p oanda.Price) string {
+	if p == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(p), 'f', -1, 64)
+}
+
+func formatAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+func formatPercent(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
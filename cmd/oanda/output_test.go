@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+// assertGolden compares got with testdata/golden/<name>, or writes it when -update is given.
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+func testOrders() []oanda.Order {
+	created := time.Date(2020, 9, 18, 10, 20, 0, 0, time.UTC)
+	gtd := created.Add(48 * time.Hour)
+	return []oanda.Order{
+		{ID: "12", Type: oanda.OrderTypeLimit, State: "PENDING", Instrument: oanda.InstrumentUSDJPY, Units: 100, Price: 104.5,
+			TimeInForce: oanda.TimeInForceGTD, GtdTime: &gtd, CreateTime: &created, ClientExtensions: &oanda.ClientExtensions{Tag: "s1"}},
+		{ID: "14", Type: oanda.OrderTypeTakeProfit, State: "PENDING", Price: 106, TradeID: "13",
+			TimeInForce: oanda.TimeInForceGTC, CreateTime: &created},
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	orders := testOrders()
+	account := &oanda.AccountSummary{ID: "001-001-1-001", Alias: `Main, "scalper"`, Currency: "JPY", Balance: 1000000,
+		NAV: 1000123.5, UnrealizedPL: 123.5, MarginAvailable: 996000, OpenTradeCount: 1, PendingOrderCount: 2}
+	tests := []struct {
+		golden string
+		flags  outputFlags
+		view   view
+	}{
+		{"orders.table.txt", outputFlags{format: formatTable}, view{orders, orders, orderColumns}},
+		{"orders.csv", outputFlags{format: formatCSV}, view{orders, orders, orderColumns}},
+		{"orders.jsonl", outputFlags{format: formatJSONL}, view{orders, orders, orderColumns}},
+		{"orders.template.txt", outputFlags{template: `{{.ID}} {{.Type}} {{.Instrument}} {{.Units}}@{{.Price}}{{with .ClientExtensions}} tag={{.Tag}}{{end}}`},
+			view{orders, orders, orderColumns}},
+		// the alias has a comma and quotes to be quoted.
+		{"account.csv", outputFlags{format: formatCSV}, view{account, account, accountColumns}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		a := &app{stdout: &out}
+		if err := tt.flags.validate(); err != nil {
+			t.Fatalf("%s: %v", tt.golden, err)
+		}
+		if err := a.render(&tt.flags, tt.view); err != nil {
+			t.Fatalf("%s: %v", tt.golden, err)
+		}
+		assertGolden(t, tt.golden, out.Bytes())
+	}
+}
+
+func TestRunTemplateErrors(t *testing.T) {
+	newTestServer(t)
+	if code, _, stderr := runCommand("", "orders", "create", "--yes", "--instrument", "USD_JPY", "--units", "100",
+		"--type", "LIMIT", "--price", "104"); code != exitOK {
+		t.Fatalf("exited with %d: %s", code, stderr)
+	}
+	tests := []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{"--template", "{{.ID"}, exitUsage, "invalid --template"},
+		{[]string{"--output", "template"}, exitUsage, "--template is required"},
+		{[]string{"--template", "{{.NoSuchField}}"}, exitFailure, "NoSuchField"},
+		{[]string{"--output", "xml"}, exitUsage, "unknown --output"},
+	}
+	for _, tt := range tests {
+		code, _, stderr := runCommand("", append([]string{"orders", "list"}, tt.args...)...)
+		if code != tt.code || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%v exited with %d and %q, want %d and %q", tt.args, code, stderr, tt.code, tt.stderr)
+		}
+	}
+}
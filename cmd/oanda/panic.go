@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+func fillColumn(header string, value func(f oanda.OrderFill) string) column {
+	return column{header, func(r interface{}) string { return value(r.(oanda.OrderFill)) }}
+}
+
+var fillColumns = []column{
+	fillColumn("TRANSACTION", func(f oanda.OrderFill) string { return string(f.TransactionID) }),
+	fillColumn("INSTRUMENT", func(f oanda.OrderFill) string { return string(f.Instrument) }),
+	fillColumn("UNITS", func(f oanda.OrderFill) string { return strconv.Itoa(int(f.Units)) }),
+	fillColumn("PRICE", func(f oanda.OrderFill) string { return formatPrice(f.Price) }),
+	fillColumn("PL", func(f oanda.OrderFill) string { return formatAmount(f.PL) }),
+	fillColumn("TIME", func(f oanda.OrderFill) string { return formatTime(&f.Time) }),
+}
+
+func runPanic(a *app, args []string) error {
+	fs := a.newFlagSet("panic")
+	marker := fs.String("marker", "", "file the progress is persisted to (default oanda/panic-<account>.json in the user config directory)")
+	reset := fs.Bool("reset", false, "discard the marker of an interrupted run instead of resuming it")
+	var g guardFlags
+	g.register(fs)
+	var out outputFlags
+	out.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	if err := a.guard(&g, "cancel all orders and close all positions"); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	if *marker == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return fmt.Errorf("failed to locate user config directory: %w", err)
+		}
+		*marker = filepath.Join(dir, "oanda", "panic-"+client.AccountID()+".json")
+	}
+	if *reset && !a.dryRun {
+		if err := os.Remove(*marker); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove marker: %w", err)
+		}
+	}
+
+	ctx, cancel := interruptible(a.ctx)
+	defer cancel()
+	report, panicErr := oanda.NewKillSwitch(client, *marker).Panic(ctx)
+	if report == nil {
+		return panicErr
+	}
+	if err := a.render(&out, view{report, report.ClosedPositions, fillColumns}); err != nil {
+		return err
+	}
+	if report.Resumed {
+		fmt.Fprintf(a.stderr, "resumed the panic started at %s\n", report.StartedAt.Format("2006-01-02T15:04:05Z07:00"))
+	}
+	fmt.Fprintf(a.stderr, "canceled %d orders, closed %d positions, realized pl %s, flat: %t\n",
+		len(report.CanceledOrders), len(report.ClosedPositions), formatAmount(report.RealizedPL()), report.Flat)
+	if errors.Is(panicErr, oanda.ErrDryRun) {
+		return nil
+	}
+	return panicErr
+}
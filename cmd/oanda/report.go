@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/journal"
+)
+
+func reportColumn(header string, value func(r journal.ReportRow) string) column {
+	return column{header, func(r interface{}) string { return value(r.(journal.ReportRow)) }}
+}
+
+var reportColumns = []column{
+	reportColumn("KEY", func(r journal.ReportRow) string { return r.Key }),
+	reportColumn("REALIZED_PL", func(r journal.ReportRow) string { return formatAmount(r.RealizedPL) }),
+	reportColumn("UNREALIZED_PL", func(r journal.ReportRow) string { return formatAmount(r.UnrealizedPL) }),
+	reportColumn("FINANCING", func(r journal.ReportRow) string { return formatAmount(r.Financing) }),
+	reportColumn("FEES", func(r journal.ReportRow) string { return formatAmount(r.Fees) }),
+	reportColumn("NET", func(r journal.ReportRow) string { return formatAmount(r.Net) }),
+	reportColumn("CLOSES", func(r journal.ReportRow) string { return strconv.Itoa(r.Closes) }),
+}
+
+func runReport(a *app, args []string) error {
+	fs := a.newFlagSet("report")
+	var out outputFlags
+	out.register(fs)
+	db := fs.String("db", "journal.db", "SQLite database of the trade journal")
+	by := fs.String("by", string(journal.ByDay), "group by day, instrument or tag")
+	from := fs.String("from", "", "start of the range as RFC3339 or a duration before now, e.g. 720h (default the first transaction)")
+	to := fs.String("to", "", "end of the range as RFC3339 (default now)")
+	offline := fs.Bool("offline", false, "report from the journal without syncing transactions and fetching open trades")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	group := journal.GroupBy(*by)
+	if group != journal.ByDay && group != journal.ByInstrument && group != journal.ByTag {
+		return usagef("unknown --by %q, want day, instrument or tag", *by)
+	}
+	var f, t time.Time
+	var err error
+	if *from != "" {
+		if f, err = time.Parse(time.RFC3339, *from); err != nil {
+			d, derr := time.ParseDuration(*from)
+			if derr != nil {
+				return usagef("invalid --from: %v", err)
+			}
+			f = time.Now().Add(-d)
+		}
+	}
+	if *to != "" {
+		if t, err = time.Parse(time.RFC3339, *to); err != nil {
+			return usagef("invalid --to: %v", err)
+		}
+	}
+	j, err := journal.Open(*db)
+	if err != nil {
+		return err
+	}
+	defer j.Close()
+	var open []oanda.Trade
+	if !*offline {
+		client, err := a.getClient()
+		if err != nil {
+			return err
+		}
+		if err := j.Sync(client); err != nil {
+			return err
+		}
+		if open, err = client.FetchOpenTrades(); err != nil {
+			return err
+		}
+	}
+	report, err := j.Report(group, f, t, open)
+	if err != nil {
+		return err
+	}
+	return a.render(&out, view{report, report, reportColumns})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+func runSize(a *app, args []string) error {
+	fs := a.newFlagSet("size")
+	instrument := fs.String("instrument", "", "instrument, e.g. USD_JPY (required)")
+	risk := fs.Float64("risk", 1, "percent of NAV to lose at the stop")
+	stop := fs.Float64("stop", 0, "stop distance in pips")
+	notional := fs.Float64("notional", 0, "value of the position in the account currency, instead of --risk")
+	granularity := fs.String("atr", "", "granularity of candles to size by average true range instead of --stop, e.g. H1")
+	period := fs.Int("period", 14, "period of the average true range")
+	multiplier := fs.Float64("multiplier", 2, "stop distance in average true ranges")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *instrument == "" {
+		return usagef("--instrument is required")
+	}
+	if *notional == 0 && *granularity == "" && *stop <= 0 {
+		return usagef("one of --stop, --atr and --notional is required")
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	sizer := oanda.NewSizer(client)
+	var units oanda.Unit
+	switch {
+	case *notional != 0:
+		units, err = sizer.FixedNotional(oanda.Instrument(*instrument), *notional)
+	case *granularity != "":
+		units, err = sizer.Volatility(oanda.Instrument(*instrument), *risk, *granularity, *period, *multiplier)
+	default:
+		units, err = sizer.FixedFractional(oanda.Instrument(*instrument), *risk, oanda.Pips(*stop))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(a.stdout, units)
+	return nil
+}
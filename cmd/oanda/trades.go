@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+func runTradesList(a *app, args []string) error {
+	fs := a.newFlagSet("trades list")
+	var out outputFlags
+	out.register(fs)
+	var w watchFlags
+	w.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := out.validate(); err != nil {
+		return err
+	}
+	if err := w.validate(&out); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	return a.show(&w, &out, func() (snapshot, error) {
+		trades, err := client.FetchOpenTrades()
+		if err != nil {
+			return snapshot{}, err
+		}
+		s := snapshot{view: view{trades, trades, tradeColumns}}
+		if w.watch {
+			footer, err := accountFooter(client)
+			if err != nil {
+				return snapshot{}, err
+			}
+			var unrealized float64
+			for _, t := range trades {
+				unrealized += t.UnrealizedPL
+			}
+			s.footer = append([]string{fmt.Sprintf("%d open trades  UNREALIZED_PL %s", len(trades), formatAmount(unrealized))}, footer...)
+		}
+		return s, nil
+	}, transactionEvents(client))
+}
+
+func runTradesClose(a *app, args []string) error {
+	fs := a.newFlagSet("trades close")
+	id := fs.String("id", "", "trade id (required)")
+	var g guardFlags
+	g.register(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *id == "" {
+		return usagef("--id is required")
+	}
+	if err := a.guard(&g, fmt.Sprintf("close trade %s", *id)); err != nil {
+		return err
+	}
+	client, err := a.getClient()
+	if err != nil {
+		return err
+	}
+	return a.mutated(client.CloseOpenTrade(oanda.TradeID(*id)), fmt.Sprintf("trade %s closed", *id))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+const (
+	ansiClear     = "\x1b[H\x1b[2J"
+	ansiHighlight = "\x1b[7m"
+	ansiReset     = "\x1b[0m"
+
+	defaultWatchInterval = 5 * time.Second
+)
+
+// watchFlags are the flags of list commands which can redraw their table in place.
+type watchFlags struct {
+	watch    bool
+	interval time.Duration
+}
+
+func (w *watchFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&w.watch, "watch", false, "redraw the table at --interval, and on transactions where available, until interrupted, highlighting changed rows")
+	fs.DurationVar(&w.interval, "interval", defaultWatchInterval, "interval of --watch")
+}
+
+func (w *watchFlags) validate(o *outputFlags) error {
+	if !w.watch {
+		return nil
+	}
+	if o.format != formatTable {
+		return usagef("--watch works only with --output %s", formatTable)
+	}
+	if w.interval <= 0 {
+		return usagef("--interval must be positive")
+	}
+	return nil
+}
+
+// snapshot is a view with footer lines, which are shown below the table in watch mode.
+type snapshot struct {
+	view
+	footer []string
+}
+
+// events calls changed whenever what a watched table shows may have changed, until ctx is done or it fails.
+type events func(ctx context.Context, changed func()) error
+
+// transactionEvents are the transactions of the account of client.
+func transactionEvents(client *oanda.Client) events {
+	return func(ctx context.Context, changed func()) error {
+		return client.StreamTransactions(ctx, func(oanda.Transaction) error {
+			changed()
+			return nil
+		})
+	}
+}
+
+// show renders the snapshot load returns once, or redraws it in watch mode at the interval
+// and, when on is not nil, on its events.
+func (a *app) show(w *watchFlags, o *outputFlags, load func() (snapshot, error), on events) error {
+	if !w.watch {
+		s, err := load()
+		if err != nil {
+			return err
+		}
+		return a.render(o, s.view)
+	}
+
+	ctx, cancel := interruptible(a.ctx)
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	changed := make(chan struct{}, 1)
+	failed := make(chan error, 1)
+	if on != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			follow(ctx, on, changed, failed)
+		}()
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	var previous *frame
+	for {
+		s, err := load()
+		if err != nil {
+			fmt.Fprintf(a.stderr, "%s: %v\n", time.Now().Format("15:04:05"), err)
+		} else {
+			previous, err = a.redraw(w, s, previous)
+			if err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-changed:
+		case err := <-failed:
+			fmt.Fprintf(a.stderr, "%s: %v\n", time.Now().Format("15:04:05"), err)
+		}
+	}
+}
+
+// follow signals changed on the events of on, reconnecting after failures, which it sends to failed,
+// until ctx is done.
+func follow(ctx context.Context, on events, changed chan<- struct{}, failed chan<- error) {
+	for {
+		err := on(ctx, func() {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case failed <- fmt.Errorf("failed to follow events, retrying in %s: %w", oanda.DefaultReconnectInterval, err):
+		default:
+		}
+		if util.SleepContext(ctx, oanda.DefaultReconnectInterval) != nil {
+			return
+		}
+	}
+}
+
+// frame is the rows of a table drawn in watch mode and the rows highlighted in it.
+type frame struct {
+	rows        map[string]bool
+	highlighted map[string]bool
+}
+
+// redraw clears the screen and draws s, highlighting the rows which are not in the previous frame.
+// When the rows are the same as the previous frame, e.g. on a redraw by an event which changed nothing shown,
+// the same rows stay highlighted. It returns the frame drawn.
+func (a *app) redraw(w *watchFlags, s snapshot, previous *frame) (*frame, error) {
+	var buf bytes.Buffer
+	if err := writeTable(&buf, s.columns, toRecords(s.records)); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	keys := make([]string, len(lines))
+	current := &frame{rows: make(map[string]bool, len(lines)), highlighted: map[string]bool{}}
+	for i, line := range lines[1:] {
+		keys[i+1] = strings.Join(strings.Fields(line), " ")
+		current.rows[keys[i+1]] = true
+	}
+	if previous != nil {
+		if sameRows(current.rows, previous.rows) {
+			current.highlighted = previous.highlighted
+		} else {
+			for key := range current.rows {
+				if !previous.rows[key] {
+					current.highlighted[key] = true
+				}
+			}
+		}
+	}
+
+	var screen strings.Builder
+	screen.WriteString(ansiClear)
+	fmt.Fprintf(&screen, "Every %s: %s\n\n", w.interval, time.Now().Format("2006-01-02 15:04:05"))
+	for i, line := range lines {
+		if i > 0 && current.highlighted[keys[i]] {
+			line = ansiHighlight + line + ansiReset
+		}
+		screen.WriteString(line + "\n")
+	}
+	if len(s.footer) > 0 {
+		screen.WriteString("\n")
+		for _, f := range s.footer {
+			screen.WriteString(f + "\n")
+		}
+	}
+	_, err := fmt.Fprint(a.stdout, screen.String())
+	return current, err
+}
+
+func sameRows(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if !b[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// accountFooter summarizes the profit and loss of the account.
+func accountFooter(client *oanda.Client) ([]string, error) {
+	summary, err := client.FetchAccountSummary()
+	if err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("NAV %s  UNREALIZED_PL %s  PL %s  MARGIN_USED %s  MARGIN_AVAILABLE %s",
+		formatAmount(summary.NAV), formatAmount(summary.UnrealizedPL), formatAmount(summary.PL),
+		formatAmount(summary.MarginUsed), formatAmount(summary.MarginAvailable))}, nil
+}
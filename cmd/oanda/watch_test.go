@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// syncBuffer is a buffer which a running command writes to while the test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// watchCommand runs a watching command until the test ends and returns its output.
+func watchCommand(t *testing.T, args ...string) (stdout, stderr *syncBuffer) {
+	t.Helper()
+	stdout, stderr = &syncBuffer{}, &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan int, 1)
+	go func() {
+		done <- runContext(ctx, args, strings.NewReader(""), stdout, stderr)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if code := <-done; code != exitOK {
+			t.Errorf("exited with %d: %s", code, stderr)
+		}
+	})
+	return stdout, stderr
+}
+
+// waitFrame waits until the last frame drawn satisfies done, and returns the frames drawn.
+func waitFrame(t *testing.T, stdout *syncBuffer, done func(frame string) bool) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		frames := strings.Split(stdout.String(), ansiClear)[1:]
+		if len(frames) > 0 && done(frames[len(frames)-1]) {
+			return frames
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("frames = %q", frames)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunTradesListWatchRedrawsOnTransactions(t *testing.T) {
+	s := newTestServer(t)
+	client := s.NewClient()
+	if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 100, TimeInForce: oanda.TimeInForceFOK}); err != nil {
+		t.Fatal(err)
+	}
+	// the interval is too long to redraw in the test, so redraws are by the transaction stream.
+	stdout, _ := watchCommand(t, "trades", "list", "--watch", "--interval", "1h")
+	frames := waitFrame(t, stdout, func(frame string) bool { return strings.Contains(frame, "1 open trades") })
+	if strings.Contains(frames[0], ansiHighlight) {
+		t.Errorf("first frame highlights rows: %q", frames[0])
+	}
+	for _, want := range []string{"UNREALIZED_PL", "NAV ", "MARGIN_AVAILABLE"} {
+		if !strings.Contains(frames[0], want) {
+			t.Errorf("first frame has no %q: %q", want, frames[0])
+		}
+	}
+
+	if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: -50, TimeInForce: oanda.TimeInForceFOK,
+		PositionFill: "OPEN_ONLY"}); err != nil {
+		t.Fatal(err)
+	}
+	frames = waitFrame(t, stdout, func(frame string) bool { return strings.Contains(frame, "2 open trades") })
+	last := frames[len(frames)-1]
+	var highlighted []string
+	for _, line := range strings.Split(last, "\n") {
+		if strings.HasPrefix(line, ansiHighlight) {
+			highlighted = append(highlighted, line)
+		}
+	}
+	if len(highlighted) != 1 || !strings.Contains(highlighted[0], "-50") {
+		t.Errorf("highlighted rows = %q, want only the new trade", highlighted)
+	}
+}
+
+func TestRunOrdersListWatchRedrawsAtInterval(t *testing.T) {
+	s := newTestServer(t)
+	client := s.NewClient()
+	stdout, _ := watchCommand(t, "orders", "list", "--watch", "--interval", "10ms")
+	waitFrame(t, stdout, func(frame string) bool { return strings.Contains(frame, "0 pending orders") })
+	if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentUSDJPY, Units: 100, Price: 104.5,
+		TimeInForce: oanda.TimeInForceGTC}); err != nil {
+		t.Fatal(err)
+	}
+	frames := waitFrame(t, stdout, func(frame string) bool { return strings.Contains(frame, "1 pending orders") })
+	if last := frames[len(frames)-1]; !strings.Contains(last, ansiHighlight) || !strings.Contains(last, "104.5") {
+		t.Errorf("last frame = %q, want the new order highlighted", last)
+	}
+}
+
+func TestRunWatchFlags(t *testing.T) {
+	newTestServer(t)
+	tests := []struct {
+		args   []string
+		stderr string
+	}{
+		{[]string{"trades", "list", "--watch", "--output", "json"}, "--watch works only with --output table"},
+		{[]string{"orders", "list", "--watch", "--interval", "0s"}, "--interval must be positive"},
+	}
+	for _, tt := range tests {
+		if code, _, stderr := runCommand("", tt.args...); code != exitUsage || !strings.Contains(stderr, tt.stderr) {
+			t.Errorf("%v exited with %d and %q, want %d and %q", tt.args, code, stderr, exitUsage, tt.stderr)
+		}
+	}
+
+	// without --watch the table is rendered once, without a footer.
+	code, stdout, stderr := runCommand("", "trades", "list")
+	if code != exitOK || !strings.HasPrefix(stdout, "ID") || strings.Contains(stdout, "NAV") || strings.Contains(stdout, ansiClear) {
+		t.Errorf("exited with %d: %q %s", code, stdout, stderr)
+	}
+}
@@ -0,0 +1,485 @@
+package oanda
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrConfigNotFound is returned by a ConfigProvider which has no value for a key.
+var ErrConfigNotFound = errors.New("config not found")
+
+const (
+	// ConfigKeyAPIKey is the key of the api key of OANDA API.
+	ConfigKeyAPIKey = "APIKey"
+	// ConfigKeyAccountID is the key of the oanda account.
+	ConfigKeyAccountID = "AccountID"
+	// ConfigKeyEnvironment is the key of the environment, Practice or Trade.
+	ConfigKeyEnvironment = "Environment"
+
+	// EnvironmentPractice is the environment of practice accounts, the default.
+	EnvironmentPractice = "Practice"
+	// EnvironmentTrade is the environment of live trading accounts.
+	EnvironmentTrade = "Trade"
+
+	// DefaultEnvPrefix is the prefix of environment variables read by EnvProvider.
+	DefaultEnvPrefix = "OANDA"
+)
+
+// ConfigProvider provides configuration values.
+//
+// Keys are slash separated paths relative to the environment, such as "APIKey" or "Units/USD_JPY",
+// and are compared case-insensitively. A provider returns an error wrapping ErrConfigNotFound
+// when it has no value for a key.
+type ConfigProvider interface {
+	Value(key string) (string, error)
+}
+
+// ConfigLister is implemented by providers which can list the keys they have values for.
+// Listed keys are upper cased. Every provider of this package implements it.
+type ConfigLister interface {
+	Keys() ([]string, error)
+}
+
+// NewClientFromConfig constructs OANDA API client objects from the account id, api key and environment
+// provided by provider. The environment defaults to Practice.
+func NewClientFromConfig(provider ConfigProvider, opts ...Option) (*Client, error) {
+	accountID, err := provider.Value(ConfigKeyAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account id: %w", err)
+	}
+	apiKey, err := provider.Value(ConfigKeyAPIKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	environment, err := provider.Value(ConfigKeyEnvironment)
+	if errors.Is(err, ErrConfigNotFound) {
+		environment = EnvironmentPractice
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get environment: %w", err)
+	}
+	if environment != EnvironmentPractice && environment != EnvironmentTrade {
+		return nil, fmt.Errorf("unknown environment: %q", environment)
+	}
+	return NewClient(accountID, apiKey, environment, opts...), nil
+}
+
+// DefaultConfigProvider returns the chain of the providers the bundled commands read configuration from:
+// environment variables prefixed with OANDA_, then the files .env and oanda.yaml in the working directory
+// if they exist, then AWS SSM parameter store under /Oanda/<environment>/ when AWS credentials
+// are configured by AWS_PROFILE or AWS_ACCESS_KEY_ID.
+// The environment is the given one, or when it is empty the one configured by the environment variables or
+// the files, e.g. OANDA_ENVIRONMENT. It selects both the parameters in SSM and the endpoint of the client.
+func DefaultConfigProvider(environment string) (ConfigProvider, error) {
+	providers := []ConfigProvider{NewEnvProvider(DefaultEnvPrefix)}
+	for _, path := range []string{".env", "oanda.yaml"} {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		p, err := LoadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	if environment == "" {
+		configured, err := NewChainProvider(providers...).Value(ConfigKeyEnvironment)
+		switch {
+		case err == nil:
+			environment = configured
+		case !errors.Is(err, ErrConfigNotFound):
+			return nil, fmt.Errorf("failed to get environment: %w", err)
+		}
+	}
+	if environment == "" {
+		environment = EnvironmentPractice
+	}
+	if os.Getenv("AWS_PROFILE") != "" || os.Getenv("AWS_ACCESS_KEY_ID") != "" {
+		p, err := NewSSMProvider(environment)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	return NewChainProvider(append([]ConfigProvider{MapProvider{ConfigKeyEnvironment: environment}}, providers...)...), nil
+}
+
+func normalizeConfigKey(key string) string {
+	return strings.ToUpper(strings.Trim(key, "/"))
+}
+
+func notFound(key string) error {
+	return fmt.Errorf("%w (key=%s)", ErrConfigNotFound, key)
+}
+
+// MapProvider provides the values of a map.
+type MapProvider map[string]string
+
+func (m MapProvider) Value(key string) (string, error) {
+	for k, v := range m {
+		if normalizeConfigKey(k) == normalizeConfigKey(key) {
+			return v, nil
+		}
+	}
+	return "", notFound(key)
+}
+
+func (m MapProvider) Keys() ([]string, error) {
+	var keys []string
+	for k := range m {
+		keys = append(keys, normalizeConfigKey(k))
+	}
+	return keys, nil
+}
+
+// EnvProvider provides values of environment variables.
+// The variable of a key is the prefix and the upper cased key joined by "_",
+// where "/" in the key is replaced by "__", e.g. OANDA_APIKEY or OANDA_UNITS__USD_JPY.
+type EnvProvider struct {
+	prefix string
+}
+
+// NewEnvProvider constructs an EnvProvider reading variables with prefix.
+func NewEnvProvider(prefix string) *EnvProvider {
+	return &EnvProvider{prefix: prefix}
+}
+
+func (p *EnvProvider) Value(key string) (string, error) {
+	if v, ok := os.LookupEnv(envName(p.prefix, key)); ok {
+		return v, nil
+	}
+	return "", notFound(key)
+}
+
+func (p *EnvProvider) Keys() ([]string, error) {
+	var keys []string
+	for _, kv := range os.Environ() {
+		name := kv[:strings.Index(kv, "=")]
+		if p.prefix != "" {
+			if !strings.HasPrefix(name, p.prefix+"_") {
+				continue
+			}
+			name = strings.TrimPrefix(name, p.prefix+"_")
+		}
+		keys = append(keys, normalizeConfigKey(strings.Replace(name, "__", "/", -1)))
+	}
+	return keys, nil
+}
+
+func envName(prefix, key string) string {
+	name := strings.Replace(normalizeConfigKey(key), "/", "__", -1)
+	if prefix == "" {
+		return name
+	}
+	return prefix + "_" + name
+}
+
+// FileProvider provides values loaded from a dotenv or YAML file.
+type FileProvider struct {
+	values map[string]string
+}
+
+// LoadConfigFile loads a YAML file when path ends with .yaml or .yml, and a dotenv file otherwise.
+//
+// A dotenv file has lines of NAME=value named like the variables of EnvProvider with DefaultEnvPrefix.
+// A YAML file has the keys as nested maps, e.g.
+//
+//	APIKey: xxx
+//	Units:
+//	  USD_JPY: 1000
+func LoadConfigFile(path string) (*FileProvider, error) {
+	var values map[string]string
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		values, err = loadYAML(path)
+	default:
+		values, err = loadDotenv(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config file %s: %w", path, err)
+	}
+	return &FileProvider{values: values}, nil
+}
+
+func (p *FileProvider) Value(key string) (string, error) {
+	if v, ok := p.values[normalizeConfigKey(key)]; ok {
+		return v, nil
+	}
+	return "", notFound(key)
+}
+
+func (p *FileProvider) Keys() ([]string, error) {
+	return mapKeys(p.values), nil
+}
+
+func loadDotenv(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer util.SafeClose(f)
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: missing '='", n)
+		}
+		name := strings.TrimSpace(line[:i])
+		if !strings.HasPrefix(name, DefaultEnvPrefix+"_") {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		key := strings.Replace(strings.TrimPrefix(name, DefaultEnvPrefix+"_"), "__", "/", -1)
+		values[normalizeConfigKey(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func loadYAML(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	values := map[string]string{}
+	flattenYAML("", doc, values)
+	return values, nil
+}
+
+func flattenYAML(prefix string, node interface{}, values map[string]string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, v := range n {
+			flattenYAML(prefix+"/"+k, v, values)
+		}
+	case map[interface{}]interface{}:
+		for k, v := range n {
+			flattenYAML(prefix+"/"+fmt.Sprint(k), v, values)
+		}
+	case nil:
+	default:
+		values[normalizeConfigKey(prefix)] = fmt.Sprint(n)
+	}
+}
+
+// DefaultSSMCacheTTL is how long SSMProvider constructed by NewSSMProvider caches parameters.
+const DefaultSSMCacheTTL = 5 * time.Minute
+
+const (
+	// ssmMinBackoff and ssmMaxBackoff bound how long SSMProvider waits after a failed load before it loads again.
+	ssmMinBackoff = time.Second
+	ssmMaxBackoff = time.Minute
+)
+
+// SSMProvider provides values of AWS SSM parameter store under /Oanda/<environment>/.
+//
+// All parameters under the path are loaded at once, decrypting SecureString parameters,
+// and cached until the TTL passes or Refresh is called. After a failed load the error is returned,
+// or the expired values while there are any, without loading again until a backoff passes,
+// which doubles on every failure up to a minute.
+type SSMProvider struct {
+	client      ssmiface.SSMAPI
+	environment string
+	ttl         time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	values   map[string]string
+	loadedAt time.Time
+	err      error // error of the last load, if it failed
+	retryAt  time.Time
+	backoff  time.Duration
+}
+
+// NewSSMProvider constructs an SSMProvider for environment caching parameters for DefaultSSMCacheTTL.
+// The region and credentials are resolved by the AWS SDK from the shared config and environment variables
+// unless they are given by configs.
+func NewSSMProvider(environment string, configs ...*aws.Config) (*SSMProvider, error) {
+	config := aws.NewConfig()
+	config.MergeIn(configs...)
+	s, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aws session: %w", err)
+	}
+	return NewSSMProviderWithClient(ssm.New(s), environment, DefaultSSMCacheTTL), nil
+}
+
+// NewSSMProviderWithClient constructs an SSMProvider for environment using client.
+// Cached parameters expire after ttl; they never expire when ttl is 0.
+func NewSSMProviderWithClient(client ssmiface.SSMAPI, environment string, ttl time.Duration) *SSMProvider {
+	return &SSMProvider{client: client, environment: environment, ttl: ttl, now: time.Now}
+}
+
+func (p *SSMProvider) Value(key string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.loadIfExpired(); err != nil {
+		return "", err
+	}
+	if v, ok := p.values[normalizeConfigKey(key)]; ok {
+		return v, nil
+	}
+	return "", notFound(key)
+}
+
+func (p *SSMProvider) Keys() ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.loadIfExpired(); err != nil {
+		return nil, err
+	}
+	return mapKeys(p.values), nil
+}
+
+// Refresh reloads all parameters regardless of the TTL.
+func (p *SSMProvider) Refresh() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.load()
+}
+
+func (p *SSMProvider) path() string {
+	return strings.Replace(prefix, envPlaceholder, p.environment, 1) + "/"
+}
+
+func (p *SSMProvider) loadIfExpired() error {
+	if p.values != nil && (p.ttl <= 0 || p.now().Sub(p.loadedAt) < p.ttl) {
+		return nil
+	}
+	if p.err != nil && p.now().Before(p.retryAt) {
+		if p.values != nil {
+			return nil
+		}
+		return p.err
+	}
+	if err := p.load(); err != nil && p.values == nil {
+		return err
+	}
+	return nil
+}
+
+func (p *SSMProvider) load() error {
+	path := p.path()
+	values := map[string]string{}
+	err := p.client.GetParametersByPathPages(&ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	}, func(output *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		for _, param := range output.Parameters {
+			if param.Name == nil || param.Value == nil {
+				continue
+			}
+			values[normalizeConfigKey(strings.TrimPrefix(*param.Name, path))] = *param.Value
+		}
+		return true
+	})
+	if err != nil {
+		p.backoff *= 2
+		if p.backoff < ssmMinBackoff {
+			p.backoff = ssmMinBackoff
+		}
+		if p.backoff > ssmMaxBackoff {
+			p.backoff = ssmMaxBackoff
+		}
+		p.err = fmt.Errorf("failed to get parameters by path (path=%s): %w", path, err)
+		p.retryAt = p.now().Add(p.backoff)
+		return p.err
+	}
+	p.values = values
+	p.loadedAt = p.now()
+	p.err, p.backoff = nil, 0
+	return nil
+}
+
+// ChainProvider provides the value of the first of its providers which has one.
+// A provider failing with an error other than ErrConfigNotFound is skipped,
+// and the error is returned only when no other provider has the value.
+type ChainProvider struct {
+	providers []ConfigProvider
+}
+
+// NewChainProvider constructs a ChainProvider which consults providers in order.
+func NewChainProvider(providers ...ConfigProvider) *ChainProvider {
+	return &ChainProvider{providers: providers}
+}
+
+func (p *ChainProvider) Value(key string) (string, error) {
+	var errs []string
+	for _, provider := range p.providers {
+		v, err := provider.Value(key)
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, ErrConfigNotFound) {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return "", fmt.Errorf("failed to get config (key=%s): %s", key, strings.Join(errs, "; "))
+	}
+	return "", notFound(key)
+}
+
+// Keys returns the keys of every provider which implements ConfigLister.
+func (p *ChainProvider) Keys() ([]string, error) {
+	seen := map[string]bool{}
+	var keys []string
+	for _, provider := range p.providers {
+		lister, ok := provider.(ConfigLister)
+		if !ok {
+			continue
+		}
+		ks, err := lister.Keys()
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range ks {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func mapKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,251 @@
+package oanda
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+func TestDefaultConfigProviderEnvironment(t *testing.T) {
+	for _, key := range []string{"AWS_PROFILE", "AWS_ACCESS_KEY_ID", "OANDA_ENVIRONMENT"} {
+		if v, ok := os.LookupEnv(key); ok {
+			defer os.Setenv(key, v)
+			os.Unsetenv(key)
+		}
+	}
+	chdirTemp(t)
+
+	tests := []struct {
+		given, variable, file, want string
+	}{
+		{"", "", "", EnvironmentPractice},
+		{EnvironmentTrade, "", "", EnvironmentTrade},
+		{"", EnvironmentTrade, "", EnvironmentTrade},
+		{"", "", EnvironmentTrade, EnvironmentTrade},
+		// an explicit environment, e.g. of --env, wins over the configured one.
+		{EnvironmentPractice, EnvironmentTrade, "", EnvironmentPractice},
+		{EnvironmentTrade, "", EnvironmentPractice, EnvironmentTrade},
+		{EnvironmentPractice, "", EnvironmentTrade, EnvironmentPractice},
+	}
+	for _, tt := range tests {
+		if tt.variable != "" {
+			os.Setenv("OANDA_ENVIRONMENT", tt.variable)
+		} else {
+			os.Unsetenv("OANDA_ENVIRONMENT")
+		}
+		os.Remove(".env")
+		if tt.file != "" {
+			if err := ioutil.WriteFile(".env", []byte("OANDA_ENVIRONMENT="+tt.file+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+		provider, err := DefaultConfigProvider(tt.given)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := provider.Value(ConfigKeyEnvironment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("given %q, OANDA_ENVIRONMENT=%q and .env %q: environment = %q, want %q", tt.given, tt.variable, tt.file, got, tt.want)
+		}
+	}
+	os.Unsetenv("OANDA_ENVIRONMENT")
+
+	// the parameters in SSM are those of the same environment as the endpoint.
+	if err := ioutil.WriteFile(".env", []byte("OANDA_ENVIRONMENT="+EnvironmentPractice+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("AWS_ACCESS_KEY_ID", "test")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	provider, err := DefaultConfigProvider(EnvironmentTrade)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ssmEnvironments []string
+	for _, p := range provider.(*ChainProvider).providers {
+		if s, ok := p.(*SSMProvider); ok {
+			ssmEnvironments = append(ssmEnvironments, s.environment)
+		}
+	}
+	if len(ssmEnvironments) != 1 || ssmEnvironments[0] != EnvironmentTrade {
+		t.Errorf("SSM environments = %v, want %s over the Practice of .env", ssmEnvironments, EnvironmentTrade)
+	}
+}
+
+func TestFetchValueWithoutEnvironment(t *testing.T) {
+	defer func(e string) { env = e }(env)
+	env = ""
+	if _, err := ParamOandaAPIKey.FetchValue(); err == nil {
+		t.Error("FetchValue succeeds without ENVIRONMENT")
+	}
+}
+
+// chdirTemp changes the working directory to a new temporary directory until the test ends.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "oanda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+}
+
+// fakeSSM serves parameters two per page and fails while err is set.
+type fakeSSM struct {
+	ssmiface.SSMAPI
+	params map[string]string
+	err    error
+	calls  int
+}
+
+func (f *fakeSSM) GetParametersByPathPages(input *ssm.GetParametersByPathInput, fn func(*ssm.GetParametersByPathOutput, bool) bool) error {
+	f.calls++
+	if f.err != nil {
+		return f.err
+	}
+	if !aws.BoolValue(input.WithDecryption) || !aws.BoolValue(input.Recursive) {
+		return errors.New("parameters must be loaded recursively with decryption")
+	}
+	var names []string
+	for name := range f.params {
+		if strings.HasPrefix(name, aws.StringValue(input.Path)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names); i += 2 {
+		end := i + 2
+		if end > len(names) {
+			end = len(names)
+		}
+		output := &ssm.GetParametersByPathOutput{}
+		for _, name := range names[i:end] {
+			output.Parameters = append(output.Parameters, &ssm.Parameter{Name: aws.String(name), Value: aws.String(f.params[name])})
+		}
+		if !fn(output, i+2 >= len(names)) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestSSMProviderLoadsEveryPage(t *testing.T) {
+	fake := &fakeSSM{params: map[string]string{
+		"/Oanda/Practice/APIKey":                       "practice-key",
+		"/Oanda/Practice/AccountID":                    "101-001",
+		"/Oanda/Practice/Units/USD_JPY":                "1000",
+		"/Oanda/Practice/Instruments/EUR_USD/MaxUnits": "5000",
+		"/Oanda/Practice/Accounts/SCALP":               "101-002",
+		"/Oanda/Trade/APIKey":                          "trade-key",
+	}}
+	p := NewSSMProviderWithClient(fake, EnvironmentPractice, time.Minute)
+	keys, err := p.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 5 {
+		t.Errorf("keys = %v, want the 5 keys of Practice", keys)
+	}
+	for key, want := range map[string]string{"APIKey": "practice-key", "units/usd_jpy": "1000", "Accounts/SCALP": "101-002"} {
+		if got, err := p.Value(key); err != nil || got != want {
+			t.Errorf("Value(%s) = %q, %v, want %q", key, got, err, want)
+		}
+	}
+	if _, err := p.Value("Missing"); !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("err = %v, want ErrConfigNotFound", err)
+	}
+	if fake.calls != 1 {
+		t.Errorf("loaded %d times, want 1", fake.calls)
+	}
+}
+
+func TestSSMProviderTTLAndRefresh(t *testing.T) {
+	fake := &fakeSSM{params: map[string]string{"/Oanda/Practice/APIKey": "old"}}
+	now := time.Date(2020, 9, 18, 0, 0, 0, 0, time.UTC)
+	p := NewSSMProviderWithClient(fake, EnvironmentPractice, time.Minute)
+	p.now = func() time.Time { return now }
+
+	if v, _ := p.Value("APIKey"); v != "old" {
+		t.Fatalf("value = %q", v)
+	}
+	fake.params["/Oanda/Practice/APIKey"] = "new"
+	now = now.Add(59 * time.Second)
+	if v, _ := p.Value("APIKey"); v != "old" {
+		t.Errorf("value within TTL = %q, want the cached one", v)
+	}
+	now = now.Add(time.Second)
+	if v, _ := p.Value("APIKey"); v != "new" {
+		t.Errorf("value after TTL = %q, want the reloaded one", v)
+	}
+	fake.params["/Oanda/Practice/APIKey"] = "newer"
+	if err := p.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := p.Value("APIKey"); v != "newer" {
+		t.Errorf("value after Refresh = %q", v)
+	}
+	if fake.calls != 3 {
+		t.Errorf("loaded %d times, want 3", fake.calls)
+	}
+}
+
+func TestSSMProviderBacksOffAfterFailure(t *testing.T) {
+	fake := &fakeSSM{params: map[string]string{"/Oanda/Practice/APIKey": "key"}, err: errors.New("throttled")}
+	now := time.Date(2020, 9, 18, 0, 0, 0, 0, time.UTC)
+	p := NewSSMProviderWithClient(fake, EnvironmentPractice, time.Minute)
+	p.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		if _, err := p.Value("APIKey"); err == nil {
+			t.Fatal("value is provided while SSM fails")
+		}
+	}
+	if fake.calls != 1 {
+		t.Fatalf("loaded %d times within the backoff, want 1", fake.calls)
+	}
+	now = now.Add(ssmMinBackoff)
+	p.Value("APIKey")
+	now = now.Add(ssmMinBackoff)
+	p.Value("APIKey")
+	if fake.calls != 2 {
+		t.Fatalf("loaded %d times, want 2 since the backoff doubled", fake.calls)
+	}
+
+	fake.err = nil
+	now = now.Add(ssmMaxBackoff)
+	if v, err := p.Value("APIKey"); err != nil || v != "key" {
+		t.Fatalf("value after recovery = %q, %v", v, err)
+	}
+
+	// expired values are served while SSM is down.
+	fake.err = errors.New("unavailable")
+	now = now.Add(time.Minute)
+	if v, err := p.Value("APIKey"); err != nil || v != "key" {
+		t.Errorf("value while SSM is down = %q, %v, want the expired one", v, err)
+	}
+	calls := fake.calls
+	p.Value("APIKey")
+	if fake.calls != calls {
+		t.Error("loaded again within the backoff")
+	}
+}
@@ -2,4 +2,8 @@ module github.com/yuki-inoue-eng/oanda-api-client
 
 go 1.14
 
-require github.com/aws/aws-sdk-go v1.34.26
+require (
+	github.com/aws/aws-sdk-go v1.34.26
+	github.com/mattn/go-sqlite3 v1.14.16
+	gopkg.in/yaml.v2 v2.4.0
+)
@@ -0,0 +1,159 @@
+package oanda
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// instrumentsKey is the key under which InstrumentSettings are configured:
+//
+//	Instruments/<INSTRUMENT>/DefaultUnits   units of an order (required)
+//	Instruments/<INSTRUMENT>/MaxUnits       max units of an order (defaults to DefaultUnits)
+//	Instruments/<INSTRUMENT>/TakeProfitPips default take profit distance (0 or unset for none)
+//	Instruments/<INSTRUMENT>/StopLossPips   default stop loss distance (0 or unset for none)
+//	Instruments/<INSTRUMENT>/Enabled        whether to trade the instrument (defaults to true)
+const instrumentsKey = "Instruments"
+
+var instrumentNamePattern = regexp.MustCompile(`^[A-Z0-9]+_[A-Z0-9]+$`)
+
+// InstrumentSettings is the trading parameters of an instrument.
+type InstrumentSettings struct {
+	Instrument     Instrument
+	DefaultUnits   Unit
+	MaxUnits       Unit
+	TakeProfitPips Pips
+	StopLossPips   Pips
+	Enabled        bool
+}
+
+// InstrumentSettingsMap is InstrumentSettings by instrument.
+type InstrumentSettingsMap map[Instrument]InstrumentSettings
+
+// LoadInstrumentSettings loads the settings of every instrument configured under Instruments/ in provider,
+// which must implement ConfigLister. It reports all invalid or unknown settings at once.
+func LoadInstrumentSettings(provider ConfigProvider) (InstrumentSettingsMap, error) {
+	lister, ok := provider.(ConfigLister)
+	if !ok {
+		return nil, fmt.Errorf("config provider %T cannot list keys", provider)
+	}
+	keys, err := lister.Keys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config keys: %w", err)
+	}
+	fields := map[Instrument]map[string]string{}
+	var errs []string
+	for _, key := range keys {
+		parts := strings.Split(key, "/")
+		if parts[0] != strings.ToUpper(instrumentsKey) {
+			continue
+		}
+		if len(parts) != 3 {
+			errs = append(errs, fmt.Sprintf("%s: want %s/<INSTRUMENT>/<FIELD>", key, instrumentsKey))
+			continue
+		}
+		instrument := Instrument(parts[1])
+		if fields[instrument] == nil {
+			fields[instrument] = map[string]string{}
+		}
+		v, err := provider.Value(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get config (key=%s): %w", key, err)
+		}
+		fields[instrument][parts[2]] = v
+	}
+
+	settings := InstrumentSettingsMap{}
+	for instrument, f := range fields {
+		s, fieldErrs := parseInstrumentSettings(instrument, f)
+		for _, e := range fieldErrs {
+			errs = append(errs, fmt.Sprintf("%s/%s: %s", instrumentsKey, instrument, e))
+		}
+		settings[instrument] = s
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("invalid instrument settings: %s", strings.Join(errs, "; "))
+	}
+	return settings, nil
+}
+
+func parseInstrumentSettings(instrument Instrument, fields map[string]string) (InstrumentSettings, []string) {
+	s := InstrumentSettings{Instrument: instrument, Enabled: true}
+	var errs []string
+	if !instrumentNamePattern.MatchString(string(instrument)) {
+		errs = append(errs, "invalid instrument name")
+	}
+	hasMaxUnits := false
+	for name, v := range fields {
+		var err error
+		switch name {
+		case "DEFAULTUNITS":
+			s.DefaultUnits, err = parseUnits(v)
+		case "MAXUNITS":
+			s.MaxUnits, err = parseUnits(v)
+			hasMaxUnits = true
+		case "TAKEPROFITPIPS":
+			s.TakeProfitPips, err = parsePips(v)
+		case "STOPLOSSPIPS":
+			s.StopLossPips, err = parsePips(v)
+		case "ENABLED":
+			s.Enabled, err = strconv.ParseBool(v)
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if _, ok := fields["DEFAULTUNITS"]; !ok {
+		errs = append(errs, "DefaultUnits is required")
+	}
+	if !hasMaxUnits {
+		s.MaxUnits = s.DefaultUnits
+	}
+	if s.DefaultUnits > s.MaxUnits {
+		errs = append(errs, fmt.Sprintf("DefaultUnits %d exceeds MaxUnits %d", s.DefaultUnits, s.MaxUnits))
+	}
+	return s, errs
+}
+
+func parseUnits(s string) (Unit, error) {
+	u, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if u <= 0 {
+		return 0, fmt.Errorf("must be positive: %d", u)
+	}
+	return Unit(u), nil
+}
+
+func parsePips(s string) (Pips, error) {
+	p, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(p) || math.IsInf(p, 0) {
+		return 0, fmt.Errorf("must be finite: %v", p)
+	}
+	if p < 0 {
+		return 0, fmt.Errorf("must not be negative: %v", p)
+	}
+	return Pips(p), nil
+}
+
+// Enabled returns the settings of enabled instruments sorted by instrument.
+func (m InstrumentSettingsMap) Enabled() []InstrumentSettings {
+	var settings []InstrumentSettings
+	for _, s := range m {
+		if s.Enabled {
+			settings = append(settings, s)
+		}
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Instrument < settings[j].Instrument })
+	return settings
+}
@@ -0,0 +1,57 @@
+package oanda
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadInstrumentSettings(t *testing.T) {
+	settings, err := LoadInstrumentSettings(MapProvider{
+		"Instruments/USD_JPY/DefaultUnits":   "1000",
+		"Instruments/USD_JPY/MaxUnits":       "5000",
+		"Instruments/USD_JPY/TakeProfitPips": "12.5",
+		"Instruments/EUR_USD/DefaultUnits":   "2000",
+		"Instruments/EUR_USD/Enabled":        "false",
+		"APIKey":                             "key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := InstrumentSettings{Instrument: InstrumentUSDJPY, DefaultUnits: 1000, MaxUnits: 5000, TakeProfitPips: 12.5, Enabled: true}
+	if got := settings[InstrumentUSDJPY]; got != want {
+		t.Errorf("USD_JPY settings = %+v, want %+v", got, want)
+	}
+	if got := settings[InstrumentEURUSD]; got.MaxUnits != 2000 || got.Enabled {
+		t.Errorf("EUR_USD settings = %+v", got)
+	}
+	if enabled := settings.Enabled(); len(enabled) != 1 || enabled[0].Instrument != InstrumentUSDJPY {
+		t.Errorf("enabled = %+v", enabled)
+	}
+}
+
+func TestLoadInstrumentSettingsRejectsInvalidValues(t *testing.T) {
+	for _, v := range []string{"NaN", "nan", "Inf", "+Inf", "-Inf", "-1", "x"} {
+		_, err := LoadInstrumentSettings(MapProvider{
+			"Instruments/USD_JPY/DefaultUnits": "1000",
+			"Instruments/USD_JPY/StopLossPips": v,
+		})
+		if err == nil || !strings.Contains(err.Error(), "STOPLOSSPIPS") {
+			t.Errorf("StopLossPips %q: err = %v", v, err)
+		}
+	}
+	_, err := LoadInstrumentSettings(MapProvider{
+		"Instruments/usd-jpy/DefaultUnits": "1000",
+		"Instruments/EUR_USD/MaxUnits":     "10",
+		"Instruments/EUR_JPY/DefaultUnits": "100",
+		"Instruments/EUR_JPY/MaxUnits":     "10",
+		"Instruments/EUR_JPY/Color":        "red",
+	})
+	if err == nil {
+		t.Fatal("invalid settings are accepted")
+	}
+	for _, want := range []string{"invalid instrument name", "DefaultUnits is required", "exceeds MaxUnits", "unknown field"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to report %q", err, want)
+		}
+	}
+}
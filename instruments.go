@@ -0,0 +1,207 @@
+package oanda
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+type instrumentInfo struct {
+	Name                string `json:"name"`
+	Type                string `json:"type"`
+	DisplayName         string `json:"displayName"`
+	PipLocation         int    `json:"pipLocation"`
+	DisplayPrecision    int    `json:"displayPrecision"`
+	TradeUnitsPrecision int    `json:"tradeUnitsPrecision"`
+	MinimumTradeSize    string `json:"minimumTradeSize"`
+	MaximumOrderUnits   string `json:"maximumOrderUnits"`
+	MarginRate          string `json:"marginRate"`
+}
+
+type receivedInstruments struct {
+	Instruments []instrumentInfo `json:"instruments"`
+}
+
+// InstrumentDetails is the trading details of an instrument for the account.
+type InstrumentDetails struct {
+	Name        Instrument
+	Type        string // CURRENCY, CFD or METAL
+	DisplayName string
+	// PipLocation is the exponent of a pip, e.g. -2 for USD_JPY.
+	PipLocation      int
+	DisplayPrecision int
+	// TradeUnitsPrecision is the number of decimal places of units. Unit is an integer,
+	// so units are never finer than 1.
+	TradeUnitsPrecision int
+	MinimumTradeSize    float64
+	MaximumOrderUnits   float64
+	MarginRate          float64
+}
+
+func (i *instrumentInfo) toInstrumentDetails() (InstrumentDetails, error) {
+	var p fieldParser
+	details := InstrumentDetails{
+		Name:                Instrument(i.Name),
+		Type:                i.Type,
+		DisplayName:         i.DisplayName,
+		PipLocation:         i.PipLocation,
+		DisplayPrecision:    i.DisplayPrecision,
+		TradeUnitsPrecision: i.TradeUnitsPrecision,
+		MinimumTradeSize:    p.amount("minimum trade size", i.MinimumTradeSize),
+		MaximumOrderUnits:   p.amount("maximum order units", i.MaximumOrderUnits),
+		MarginRate:          p.amount("margin rate", i.MarginRate),
+	}
+	return details, p.err
+}
+
+// PipsToPrice returns the price distance of pips in the instrument.
+func (d *InstrumentDetails) PipsToPrice(pips Pips) Price {
+	return Price(float64(pips) * math.Pow10(d.PipLocation))
+}
+
+// RoundPrice rounds p to DisplayPrecision, the precision OANDA API accepts for prices of the instrument.
+func (d *InstrumentDetails) RoundPrice(p Price) Price {
+	scale := math.Pow10(d.DisplayPrecision)
+	return Price(math.Round(float64(p)*scale) / scale)
+}
+
+// Currencies returns the base and quote currencies of the instrument, e.g. USD and JPY of USD_JPY.
+// Both are empty when the name is not of the form BASE_QUOTE.
+func (d *InstrumentDetails) Currencies() (base, quote string) {
+	parts := strings.SplitN(string(d.Name), "_", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
+
+// maxUnits is the most units RoundUnits returns, which fit in a Unit on every platform.
+const maxUnits = math.MaxInt32
+
+// RoundUnits rounds units toward zero to TradeUnitsPrecision, capped at MaximumOrderUnits.
+// It returns ErrBelowMinimumSize when the result is smaller than MinimumTradeSize,
+// and ErrAboveMaximumSize when there is no MaximumOrderUnits and the result does not fit in a Unit.
+func (d *InstrumentDetails) RoundUnits(units float64) (Unit, error) {
+	if math.IsNaN(units) {
+		return 0, fmt.Errorf("invalid units %g of %s", units, d.Name)
+	}
+	step := math.Pow10(-d.TradeUnitsPrecision)
+	if step < 1 {
+		step = 1
+	}
+	rounded := math.Trunc(units/step) * step
+	if d.MaximumOrderUnits > 0 && math.Abs(rounded) > d.MaximumOrderUnits {
+		rounded = math.Copysign(math.Trunc(d.MaximumOrderUnits/step)*step, units)
+	}
+	if math.Abs(rounded) > maxUnits {
+		return 0, fmt.Errorf("%w: %g units of %s", ErrAboveMaximumSize, units, d.Name)
+	}
+	if rounded == 0 || math.Abs(rounded) < d.MinimumTradeSize {
+		return 0, fmt.Errorf("%w: %.2f units of %s, minimum %g", ErrBelowMinimumSize, units, d.Name, d.MinimumTradeSize)
+	}
+	return Unit(rounded), nil
+}
+
+// FetchInstruments fetches the details of instruments, or of every instrument tradeable by the account when none is given.
+func (c *Client) FetchInstruments(instruments ...Instrument) ([]InstrumentDetails, error) {
+	body, err := c.fetchInstruments(instruments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch instruments: %w", err)
+	}
+	var ri receivedInstruments
+	if err := json.Unmarshal(body, &ri); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	var details []InstrumentDetails
+	for _, i := range ri.Instruments {
+		d, err := i.toInstrumentDetails()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert instrument %s: %w", i.Name, err)
+		}
+		details = append(details, d)
+	}
+	return details, nil
+}
+
+// instrumentCache is the instrument details a client has looked up.
+type instrumentCache struct {
+	mu      sync.Mutex
+	details map[Instrument]InstrumentDetails
+}
+
+// LookupInstrument returns the details of instrument, fetching them on the first lookup.
+func (c *Client) LookupInstrument(instrument Instrument) (InstrumentDetails, error) {
+	cache := c.instruments
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if d, ok := cache.details[instrument]; ok {
+		return d, nil
+	}
+	details, err := c.FetchInstruments(instrument)
+	if err != nil {
+		return InstrumentDetails{}, err
+	}
+	if cache.details == nil {
+		cache.details = map[Instrument]InstrumentDetails{}
+	}
+	for _, d := range details {
+		cache.details[d.Name] = d
+	}
+	d, ok := cache.details[instrument]
+	if !ok {
+		return InstrumentDetails{}, fmt.Errorf("no details of instrument %s", instrument)
+	}
+	return d, nil
+}
+
+func (c *Client) FetchInstrumentsJSON(instruments ...Instrument) ([]byte, error) {
+	return c.fetchInstruments(instruments)
+}
+
+type homeConversionInfo struct {
+	Currency      string `json:"currency"`
+	AccountGain   string `json:"accountGain"`
+	AccountLoss   string `json:"accountLoss"`
+	PositionValue string `json:"positionValue"`
+}
+
+// HomeConversion is the factors which convert amounts in a currency to the account currency.
+type HomeConversion struct {
+	Currency string
+	// AccountGain converts a profit.
+	AccountGain float64
+	// AccountLoss converts a loss.
+	AccountLoss float64
+	// PositionValue converts the value of a position.
+	PositionValue float64
+}
+
+// FetchHomeConversions fetches the conversion factors of the currencies of instruments to the account currency.
+func (c *Client) FetchHomeConversions(instruments ...Instrument) (map[string]HomeConversion, error) {
+	body, err := c.fetchPricing(instruments, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pricing: %w", err)
+	}
+	var rp struct {
+		HomeConversions []homeConversionInfo `json:"homeConversions"`
+	}
+	if err := json.Unmarshal(body, &rp); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	conversions := map[string]HomeConversion{}
+	for _, h := range rp.HomeConversions {
+		var p fieldParser
+		conversions[h.Currency] = HomeConversion{
+			Currency:      h.Currency,
+			AccountGain:   p.amount("account gain", h.AccountGain),
+			AccountLoss:   p.amount("account loss", h.AccountLoss),
+			PositionValue: p.amount("position value", h.PositionValue),
+		}
+		if p.err != nil {
+			return nil, fmt.Errorf("failed to convert home conversion of %s: %w", h.Currency, p.err)
+		}
+	}
+	return conversions, nil
+}
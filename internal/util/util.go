@@ -0,0 +1,42 @@
+// Package util implements small helpers shared by the packages of this module.
+package util
+
+import (
+	"context"
+	"io"
+	"log"
+	"time"
+)
+
+// DayFileSuffix is the suffix of the gzip compressed JSON Lines files of a UTC day, e.g. 2020-09-18.jsonl.gz.
+const DayFileSuffix = ".jsonl.gz"
+
+// SafeClose closes closer and logs the error, for deferred closes whose error cannot be returned.
+func SafeClose(closer io.Closer) {
+	if closer != nil {
+		if err := closer.Close(); err != nil {
+			log.Printf("failed to close: %v", err)
+		}
+	}
+}
+
+// TruncateDay returns the start of the UTC day of t.
+func TruncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// SleepContext sleeps for d or until ctx is done, in which case it returns the error of ctx.
+func SleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,403 @@
+// Package journal keeps the transactions of an account in a local SQLite database
+// and reports profit and loss from them.
+package journal
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+// timeLayout is a fixed width UTC layout, so that times stored as text sort and compare in order.
+const timeLayout = "2006-01-02T15:04:05.000000000Z"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS transactions (
+	id   INTEGER PRIMARY KEY,
+	time TEXT NOT NULL,
+	type TEXT NOT NULL,
+	raw  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS orders (
+	id          INTEGER PRIMARY KEY,
+	type        TEXT NOT NULL,
+	instrument  TEXT NOT NULL,
+	units       INTEGER NOT NULL,
+	price       REAL NOT NULL,
+	trade_id    TEXT NOT NULL,
+	client_id   TEXT NOT NULL,
+	tag         TEXT NOT NULL,
+	state       TEXT NOT NULL,
+	reason      TEXT NOT NULL,
+	create_time TEXT NOT NULL,
+	close_time  TEXT
+);
+CREATE TABLE IF NOT EXISTS fills (
+	transaction_id INTEGER PRIMARY KEY,
+	order_id       TEXT NOT NULL,
+	instrument     TEXT NOT NULL,
+	tag            TEXT NOT NULL,
+	units          INTEGER NOT NULL,
+	price          REAL NOT NULL,
+	pl             REAL NOT NULL,
+	financing      REAL NOT NULL,
+	commission     REAL NOT NULL,
+	fee            REAL NOT NULL,
+	reason         TEXT NOT NULL,
+	time           TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS trades (
+	id          INTEGER PRIMARY KEY,
+	instrument  TEXT NOT NULL,
+	tag         TEXT NOT NULL,
+	units       INTEGER NOT NULL,
+	price       REAL NOT NULL,
+	state       TEXT NOT NULL,
+	realized_pl REAL NOT NULL DEFAULT 0,
+	financing   REAL NOT NULL DEFAULT 0,
+	open_time   TEXT NOT NULL,
+	close_time  TEXT
+);
+CREATE TABLE IF NOT EXISTS realizations (
+	transaction_id INTEGER NOT NULL,
+	trade_id       INTEGER NOT NULL,
+	units          INTEGER NOT NULL,
+	price          REAL NOT NULL,
+	realized_pl    REAL NOT NULL,
+	financing      REAL NOT NULL,
+	closed         INTEGER NOT NULL,
+	time           TEXT NOT NULL,
+	PRIMARY KEY (transaction_id, trade_id)
+);
+CREATE TABLE IF NOT EXISTS financing (
+	transaction_id INTEGER NOT NULL,
+	trade_id       TEXT NOT NULL,
+	instrument     TEXT NOT NULL,
+	amount         REAL NOT NULL,
+	time           TEXT NOT NULL,
+	PRIMARY KEY (transaction_id, trade_id, instrument)
+);
+`
+
+// Journal is a SQLite database of the transactions of an account.
+//
+// Every transaction is stored as OANDA API sent it, and orders, fills, trades, the realized profit and loss
+// of trades and daily financing are derived from them into tables of the same names. The strategy tag of a
+// trade is the client extensions tag of the order which opened it.
+type Journal struct {
+	db *sql.DB
+}
+
+// Open opens the journal at path, creating the database and its tables if they do not exist.
+func Open(path string) (*Journal, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create journal tables: %w", err)
+	}
+	return &Journal{db: db}, nil
+}
+
+// Close closes the database.
+func (j *Journal) Close() error {
+	return j.db.Close()
+}
+
+// LastTransactionID returns the id of the last transaction recorded, or "0" when none is.
+func (j *Journal) LastTransactionID() (oanda.TransactionID, error) {
+	var id int64
+	if err := j.db.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM transactions`).Scan(&id); err != nil {
+		return "", fmt.Errorf("failed to query last transaction id: %w", err)
+	}
+	return oanda.TransactionID(strconv.FormatInt(id, 10)), nil
+}
+
+// Sync records the transactions of the account of client after the last one recorded.
+func (j *Journal) Sync(client *oanda.Client) error {
+	since, err := j.LastTransactionID()
+	if err != nil {
+		return err
+	}
+	for {
+		transactions, last, err := client.FetchTransactionsSince(since)
+		if err != nil {
+			return err
+		}
+		for _, t := range transactions {
+			if err := j.Record(t); err != nil {
+				return err
+			}
+		}
+		if len(transactions) == 0 || transactions[len(transactions)-1].ID == last {
+			return nil
+		}
+		since = transactions[len(transactions)-1].ID
+	}
+}
+
+// Follow records the transactions of the account of client as they are streamed until ctx is done.
+// It syncs whenever the stream is opened and whenever a streamed transaction does not follow the last one
+// recorded, so that transactions while the stream was broken or being opened are not missed.
+func (j *Journal) Follow(ctx context.Context, client *oanda.Client) error {
+	for {
+		err := j.Sync(client)
+		if err == nil {
+			err = client.StreamTransactions(ctx, func(t oanda.Transaction) error {
+				return j.follow(client, t)
+			})
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("failed to follow transactions, retrying in %s: %v", oanda.DefaultReconnectInterval, err)
+		if err := util.SleepContext(ctx, oanda.DefaultReconnectInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// follow records a streamed transaction, syncing first when transactions before it are missing.
+func (j *Journal) follow(client *oanda.Client, t oanda.Transaction) error {
+	id, err := strconv.ParseInt(string(t.ID), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid transaction id %q: %w", t.ID, err)
+	}
+	last, err := j.LastTransactionID()
+	if err != nil {
+		return err
+	}
+	if n, _ := strconv.ParseInt(string(last), 10, 64); id > n+1 {
+		if err := j.Sync(client); err != nil {
+			return err
+		}
+	}
+	return j.Record(t)
+}
+
+// rawTransaction is the fields of a transaction which oanda.Transaction does not have.
+type rawTransaction struct {
+	Commission             string                  `json:"commission"`
+	GuaranteedExecutionFee string                  `json:"guaranteedExecutionFee"`
+	ClientExtensions       *oanda.ClientExtensions `json:"clientExtensions"`
+	PositionFinancings     []struct {
+		Instrument          string `json:"instrument"`
+		Financing           string `json:"financing"`
+		OpenTradeFinancings []struct {
+			TradeID   string `json:"tradeID"`
+			Financing string `json:"financing"`
+		} `json:"openTradeFinancings"`
+	} `json:"positionFinancings"`
+}
+
+// Record records t. A transaction already recorded is ignored.
+func (j *Journal) Record(t oanda.Transaction) error {
+	id, err := strconv.ParseInt(string(t.ID), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid transaction id %q: %w", t.ID, err)
+	}
+	var raw rawTransaction
+	if len(t.Raw) > 0 {
+		if err := json.Unmarshal(t.Raw, &raw); err != nil {
+			return fmt.Errorf("failed to json unmarshal transaction %s: %w", t.ID, err)
+		}
+	}
+	tx, err := j.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin journal transaction: %w", err)
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT OR IGNORE INTO transactions (id, time, type, raw) VALUES (?, ?, ?, ?)`,
+		id, formatTime(t.Time), t.Type, string(t.Raw))
+	if err != nil {
+		return fmt.Errorf("failed to record transaction %s: %w", t.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	r := recorder{tx: tx, t: t, raw: raw}
+	switch {
+	case t.Type == "ORDER_FILL":
+		err = r.fill()
+	case t.Type == "ORDER_CANCEL":
+		err = r.exec(`UPDATE orders SET state = 'CANCELLED', reason = ?, close_time = ? WHERE id = ?`,
+			t.Reason, formatTime(t.Time), string(t.OrderID))
+	case t.Type == "DAILY_FINANCING":
+		err = r.financing()
+	case strings.HasSuffix(t.Type, "_ORDER"):
+		err = r.order()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record transaction %s: %w", t.ID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit journal transaction: %w", err)
+	}
+	return nil
+}
+
+// recorder derives the rows of a transaction within a database transaction.
+type recorder struct {
+	tx  *sql.Tx
+	t   oanda.Transaction
+	raw rawTransaction
+}
+
+func (r *recorder) exec(query string, args ...interface{}) error {
+	_, err := r.tx.Exec(query, args...)
+	return err
+}
+
+// order records the order a transaction creates, e.g. LIMIT_ORDER.
+func (r *recorder) order() error {
+	var clientID, tag string
+	if ext := r.raw.ClientExtensions; ext != nil {
+		clientID, tag = ext.ID, ext.Tag
+	}
+	instrument := string(r.t.Instrument)
+	if r.t.TradeID != "" {
+		// orders attached to a trade belong to its instrument and strategy.
+		var tradeInstrument, tradeTag string
+		err := r.tx.QueryRow(`SELECT instrument, tag FROM trades WHERE id = ?`, string(r.t.TradeID)).Scan(&tradeInstrument, &tradeTag)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if instrument == "" {
+			instrument = tradeInstrument
+		}
+		if tag == "" {
+			tag = tradeTag
+		}
+	}
+	return r.exec(`INSERT OR IGNORE INTO orders
+		(id, type, instrument, units, price, trade_id, client_id, tag, state, reason, create_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'PENDING', ?, ?)`,
+		string(r.t.ID), strings.TrimSuffix(r.t.Type, "_ORDER"), instrument, int(r.t.Units), float64(r.t.Price),
+		string(r.t.TradeID), clientID, tag, r.t.Reason, formatTime(r.t.Time))
+}
+
+// fill records an order fill, the trade it opened and the trades it reduced or closed.
+func (r *recorder) fill() error {
+	t := r.t
+	var tag string
+	err := r.tx.QueryRow(`SELECT tag FROM orders WHERE id = ?`, string(t.OrderID)).Scan(&tag)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if tag == "" {
+		tag, err = r.closedTradeTag()
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.exec(`UPDATE orders SET state = 'FILLED', close_time = ? WHERE id = ?`, formatTime(t.Time), string(t.OrderID)); err != nil {
+		return err
+	}
+	if err := r.exec(`INSERT INTO fills
+		(transaction_id, order_id, instrument, tag, units, price, pl, financing, commission, fee, reason, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		string(t.ID), string(t.OrderID), string(t.Instrument), tag, int(t.Units), float64(t.Price), t.PL, t.Financing,
+		parseAmount(r.raw.Commission), parseAmount(r.raw.GuaranteedExecutionFee), t.Reason, formatTime(t.Time)); err != nil {
+		return err
+	}
+	if o := t.TradeOpened; o != nil {
+		if err := r.exec(`INSERT OR IGNORE INTO trades (id, instrument, tag, units, price, state, open_time)
+			VALUES (?, ?, ?, ?, ?, 'OPEN', ?)`,
+			string(o.TradeID), string(t.Instrument), tag, int(o.Units), float64(o.Price), formatTime(t.Time)); err != nil {
+			return err
+		}
+	}
+	changes := t.TradesClosed
+	if t.TradeReduced != nil {
+		changes = append(changes, *t.TradeReduced)
+	}
+	for i, c := range changes {
+		closed := i < len(t.TradesClosed)
+		if err := r.exec(`INSERT OR IGNORE INTO realizations
+			(transaction_id, trade_id, units, price, realized_pl, financing, closed, time)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			string(t.ID), string(c.TradeID), int(c.Units), float64(c.Price), c.RealizedPL, c.Financing, closed, formatTime(t.Time)); err != nil {
+			return err
+		}
+		if err := r.exec(`UPDATE trades SET realized_pl = realized_pl + ?, financing = financing + ? WHERE id = ?`,
+			c.RealizedPL, c.Financing, string(c.TradeID)); err != nil {
+			return err
+		}
+		if closed {
+			if err := r.exec(`UPDATE trades SET state = 'CLOSED', close_time = ? WHERE id = ?`, formatTime(t.Time), string(c.TradeID)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// closedTradeTag returns the tag of a trade a fill closed or reduced, for fills of orders attached to trades
+// or of close requests, which have no tag of their own.
+func (r *recorder) closedTradeTag() (string, error) {
+	var ids []oanda.TradeID
+	for _, c := range r.t.TradesClosed {
+		ids = append(ids, c.TradeID)
+	}
+	if r.t.TradeReduced != nil {
+		ids = append(ids, r.t.TradeReduced.TradeID)
+	}
+	for _, id := range ids {
+		var tag string
+		err := r.tx.QueryRow(`SELECT tag FROM trades WHERE id = ?`, string(id)).Scan(&tag)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return tag, nil
+	}
+	return "", nil
+}
+
+// financing records daily financing per trade, or per position when the trades are not itemized.
+func (r *recorder) financing() error {
+	for _, p := range r.raw.PositionFinancings {
+		if len(p.OpenTradeFinancings) == 0 {
+			if err := r.exec(`INSERT OR IGNORE INTO financing (transaction_id, trade_id, instrument, amount, time) VALUES (?, '', ?, ?, ?)`,
+				string(r.t.ID), p.Instrument, parseAmount(p.Financing), formatTime(r.t.Time)); err != nil {
+				return err
+			}
+		}
+		for _, f := range p.OpenTradeFinancings {
+			amount := parseAmount(f.Financing)
+			if err := r.exec(`INSERT OR IGNORE INTO financing (transaction_id, trade_id, instrument, amount, time) VALUES (?, ?, ?, ?, ?)`,
+				string(r.t.ID), f.TradeID, p.Instrument, amount, formatTime(r.t.Time)); err != nil {
+				return err
+			}
+			if err := r.exec(`UPDATE trades SET financing = financing + ? WHERE id = ?`, amount, f.TradeID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayout)
+}
+
+// parseAmount parses an optional decimal of OANDA API, in which an empty string is zero.
+func parseAmount(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
@@ -0,0 +1,171 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+func openMemory(t *testing.T) *Journal {
+	t.Helper()
+	j, err := Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { j.Close() })
+	return j
+}
+
+func marketOrder(units oanda.Unit, tag string) oanda.Order {
+	return oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: units, TimeInForce: oanda.TimeInForceFOK,
+		ClientExtensions: &oanda.ClientExtensions{Tag: tag}, TradeClientExtensions: &oanda.ClientExtensions{Tag: tag}}
+}
+
+func count(t *testing.T, j *Journal, table string) int {
+	t.Helper()
+	var n int
+	if err := j.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestRecordAndReport(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	if err := client.CreateOrder(marketOrder(100, "s1")); err != nil {
+		t.Fatal(err)
+	}
+	trades, err := client.FetchOpenTrades()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetPrice(oanda.InstrumentUSDJPY, 106.001, 106.009)
+	if err := client.CloseOpenTrade(trades[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateOrder(marketOrder(-100, "s2")); err != nil {
+		t.Fatal(err)
+	}
+
+	j := openMemory(t)
+	if err := j.Sync(client); err != nil {
+		t.Fatal(err)
+	}
+	// recording again changes nothing.
+	if err := j.Sync(client); err != nil {
+		t.Fatal(err)
+	}
+	transactions, last, err := client.FetchTransactionsSince("0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range transactions {
+		if err := j.Record(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := j.LastTransactionID(); err != nil || got != last {
+		t.Fatalf("last transaction id = %s, %v, want %s", got, err, last)
+	}
+	if n := count(t, j, "fills"); n != 3 {
+		t.Errorf("%d fills recorded, want 3", n)
+	}
+	if n := count(t, j, "realizations"); n != 1 {
+		t.Errorf("%d realizations recorded, want 1", n)
+	}
+
+	open, err := client.FetchOpenTrades()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(map[string]interface{}{
+		"positionFinancings": []map[string]interface{}{{
+			"instrument":          "USD_JPY",
+			"financing":           "-1.5",
+			"openTradeFinancings": []map[string]string{{"tradeID": string(open[0].ID), "financing": "-1.5"}},
+		}},
+	})
+	if err := j.Record(oanda.Transaction{ID: "1000", Type: "DAILY_FINANCING", Time: time.Now(), Raw: raw}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := j.Report(ByTag, time.Time{}, time.Time{}, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report) != 2 || report[0].Key != "s1" || report[1].Key != "s2" {
+		t.Fatalf("report = %+v, want rows of s1 and s2", report)
+	}
+	if r := report[0]; math.Abs(r.RealizedPL-99.2) > 1e-6 || r.Closes != 1 || r.UnrealizedPL != 0 || math.Abs(r.Net-99.2) > 1e-6 {
+		t.Errorf("s1 = %+v, want 99.2 realized by a close", r)
+	}
+	if r := report[1]; r.RealizedPL != 0 || r.Financing != -1.5 || r.UnrealizedPL != open[0].UnrealizedPL || r.Net != -1.5 {
+		t.Errorf("s2 = %+v, want the financing and the unrealized P/L of the open trade", r)
+	}
+
+	byInstrument, err := j.Report(ByInstrument, time.Time{}, time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byInstrument) != 1 || byInstrument[0].Key != "USD_JPY" || math.Abs(byInstrument[0].Net-97.7) > 1e-6 {
+		t.Errorf("report by instrument = %+v, want a net of 97.7 for USD_JPY", byInstrument)
+	}
+	future, err := j.Report(ByDay, time.Now().Add(time.Hour), time.Time{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(future) != 0 {
+		t.Errorf("report from an hour later = %+v, want none", future)
+	}
+	if _, err := j.Report("week", time.Time{}, time.Time{}, nil); err == nil {
+		t.Error("report by an unknown grouping succeeds")
+	}
+}
+
+func TestFollowSyncsMissedTransactions(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	// transactions made while the stream is being opened are not streamed.
+	s.Inject(oandatest.Fault{Path: "/transactions/stream", Latency: 300 * time.Millisecond, Times: 1})
+
+	j := openMemory(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = j.Follow(ctx, client)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	if err := client.CreateOrder(marketOrder(100, "missed")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if err := client.CreateOrder(marketOrder(100, "streamed")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for count(t, j, "fills") != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d fills recorded, want the missed and the streamed", count(t, j, "fills"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,166 @@
+package journal
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// GroupBy is the key rows of a report are grouped by.
+type GroupBy string
+
+const (
+	// ByDay groups by the UTC day, e.g. 2020-09-18.
+	ByDay GroupBy = "day"
+	// ByInstrument groups by instrument, e.g. USD_JPY.
+	ByInstrument GroupBy = "instrument"
+	// ByTag groups by the strategy tag of the trades, which is empty for trades opened without one.
+	ByTag GroupBy = "tag"
+)
+
+// ReportRow is the profit and loss of a group in the account currency.
+type ReportRow struct {
+	Key          string
+	RealizedPL   float64
+	UnrealizedPL float64
+	// Financing is the financing paid or received, negative when paid.
+	Financing float64
+	// Fees is the commissions and guaranteed execution fees charged.
+	Fees float64
+	// Net is RealizedPL plus Financing minus Fees.
+	Net float64
+	// Closes is the number of trades closed or reduced.
+	Closes int
+}
+
+// events is every realization, financing and fee as a row of (time, instrument, tag, pl, financing, fees, closes).
+const events = `
+SELECT r.time AS time, COALESCE(t.instrument, '') AS instrument, COALESCE(t.tag, '') AS tag,
+	r.realized_pl AS pl, r.financing AS financing, 0 AS fees, 1 AS closes
+FROM realizations r LEFT JOIN trades t ON t.id = r.trade_id
+UNION ALL
+SELECT f.time, f.instrument, COALESCE(t.tag, ''), 0, f.amount, 0, 0
+FROM financing f LEFT JOIN trades t ON f.trade_id != '' AND t.id = f.trade_id
+UNION ALL
+SELECT time, instrument, tag, 0, 0, commission + fee, 0
+FROM fills WHERE commission != 0 OR fee != 0
+`
+
+var groupKeys = map[GroupBy]string{
+	ByDay:        "substr(time, 1, 10)",
+	ByInstrument: "instrument",
+	ByTag:        "tag",
+}
+
+// Report returns the profit and loss between from and to grouped by by, in order of the key.
+// A zero from or to leaves the range open. Open trades, e.g. from Client.FetchOpenTrades, add their
+// unrealized profit and loss; by day, it belongs to the current day.
+func (j *Journal) Report(by GroupBy, from, to time.Time, open []oanda.Trade) ([]ReportRow, error) {
+	key, ok := groupKeys[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown grouping %q", by)
+	}
+	lower, upper := "", "9999"
+	if !from.IsZero() {
+		lower = formatTime(from)
+	}
+	if !to.IsZero() {
+		upper = formatTime(to)
+	}
+	rows, err := j.db.Query(`
+		SELECT `+key+` AS key, SUM(pl), SUM(financing), SUM(fees), SUM(closes)
+		FROM (`+events+`)
+		WHERE time >= ? AND time < ?
+		GROUP BY key`, lower, upper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report: %w", err)
+	}
+	defer rows.Close()
+	groups := map[string]*ReportRow{}
+	for rows.Next() {
+		var r ReportRow
+		if err := rows.Scan(&r.Key, &r.RealizedPL, &r.Financing, &r.Fees, &r.Closes); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		groups[r.Key] = &r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query report: %w", err)
+	}
+
+	for _, t := range open {
+		var k string
+		switch by {
+		case ByDay:
+			k = time.Now().UTC().Format("2006-01-02")
+		case ByInstrument:
+			k = string(t.Instrument)
+		case ByTag:
+			k, err = j.tradeTag(t)
+			if err != nil {
+				return nil, err
+			}
+		}
+		r, ok := groups[k]
+		if !ok {
+			r = &ReportRow{Key: k}
+			groups[k] = r
+		}
+		r.UnrealizedPL += t.UnrealizedPL
+	}
+
+	report := make([]ReportRow, 0, len(groups))
+	for _, r := range groups {
+		r.Net = r.RealizedPL + r.Financing - r.Fees
+		report = append(report, *r)
+	}
+	sort.Slice(report, func(i, k int) bool { return report[i].Key < report[k].Key })
+	return report, nil
+}
+
+// tradeTag returns the tag the journal recorded for t, or the tag of its client extensions.
+func (j *Journal) tradeTag(t oanda.Trade) (string, error) {
+	var tag string
+	err := j.db.QueryRow(`SELECT tag FROM trades WHERE id = ?`, string(t.ID)).Scan(&tag)
+	if err == nil && tag != "" {
+		return tag, nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to query trade %s: %w", t.ID, err)
+	}
+	if t.ClientExtensions != nil {
+		return t.ClientExtensions.Tag, nil
+	}
+	return "", nil
+}
+
+// WriteCSV writes report as CSV with a header row.
+func WriteCSV(w io.Writer, by GroupBy, report []ReportRow) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{{string(by), "realized_pl", "unrealized_pl", "financing", "fees", "net", "closes"}}
+	for _, r := range report {
+		records = append(records, []string{
+			r.Key,
+			formatAmount(r.RealizedPL),
+			formatAmount(r.UnrealizedPL),
+			formatAmount(r.Financing),
+			formatAmount(r.Fees),
+			formatAmount(r.Net),
+			strconv.Itoa(r.Closes),
+		})
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+func formatAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
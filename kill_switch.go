@@ -0,0 +1,234 @@
+package oanda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+const (
+	// DefaultPanicRounds is the default number of rounds KillSwitch tries to make the account flat.
+	DefaultPanicRounds = 5
+	// DefaultPanicRetryInterval is the default interval between rounds of KillSwitch.
+	DefaultPanicRetryInterval = 2 * time.Second
+)
+
+// ErrNotFlat is returned by KillSwitch.Panic when the account still has pending orders
+// or open positions after all rounds.
+var ErrNotFlat = errors.New("account is not flat")
+
+// PanicReport is the progress and the result of KillSwitch.Panic.
+type PanicReport struct {
+	AccountID       string
+	StartedAt       time.Time
+	FinishedAt      *time.Time
+	CanceledOrders  []OrderID
+	ClosedPositions []OrderFill
+	// Flat is true when no pending order nor open position was left.
+	Flat bool
+	// Resumed is true when the report was resumed from the marker of an earlier run.
+	Resumed bool
+}
+
+// RealizedPL returns the sum of the profit and loss realized by closing positions.
+func (r *PanicReport) RealizedPL() float64 {
+	var pl float64
+	for _, f := range r.ClosedPositions {
+		pl += f.PL
+	}
+	return pl
+}
+
+// KillSwitch cancels all pending orders and closes all open positions of an account.
+//
+// Its progress is persisted to a marker file after every step, so a run which was interrupted
+// or failed resumes the same report. A run after a finished one starts a new report.
+// Every step works on freshly fetched orders and positions, so nothing is canceled or closed twice.
+type KillSwitch struct {
+	Client *Client
+	// MarkerPath is the file the progress is persisted to. Empty disables persistence.
+	MarkerPath string
+	// Rounds is the number of times to cancel, close and confirm before giving up.
+	Rounds int
+	// RetryInterval is the interval between rounds.
+	RetryInterval time.Duration
+}
+
+// NewKillSwitch constructs a KillSwitch with the default rounds and retry interval.
+func NewKillSwitch(client *Client, markerPath string) *KillSwitch {
+	return &KillSwitch{
+		Client:        client,
+		MarkerPath:    markerPath,
+		Rounds:        DefaultPanicRounds,
+		RetryInterval: DefaultPanicRetryInterval,
+	}
+}
+
+// Panic makes the account flat and confirms it by fetching orders and positions again.
+// It returns ErrNotFlat along with the report when the account is not flat after all rounds.
+// In dry run mode it prints the requests of a single round, persists nothing and returns ErrDryRun.
+func (k *KillSwitch) Panic(ctx context.Context) (*PanicReport, error) {
+	dryRun := k.Client.dryRun != nil
+	report, err := k.loadMarker()
+	if err != nil {
+		return nil, err
+	}
+	if report == nil || report.FinishedAt != nil {
+		report = &PanicReport{AccountID: k.Client.AccountID(), StartedAt: time.Now().UTC()}
+	}
+	rounds := k.Rounds
+	if rounds <= 0 || dryRun {
+		rounds = 1
+	}
+
+	for round := 0; round < rounds; round++ {
+		if round > 0 {
+			if err := util.SleepContext(ctx, k.RetryInterval); err != nil {
+				return report, err
+			}
+		}
+		if err := k.round(ctx, report, dryRun); err != nil {
+			if dryRun || ctx.Err() != nil {
+				return report, err
+			}
+			log.Printf("panic round %d failed: %v", round+1, err)
+			continue
+		}
+		if dryRun {
+			return report, ErrDryRun
+		}
+		flat, err := k.flat()
+		if err != nil {
+			log.Printf("failed to confirm flat state: %v", err)
+			continue
+		}
+		if flat {
+			now := time.Now().UTC()
+			report.Flat = true
+			report.FinishedAt = &now
+			return report, k.saveMarker(report)
+		}
+	}
+	return report, ErrNotFlat
+}
+
+// round cancels the pending orders and closes the open positions once.
+func (k *KillSwitch) round(ctx context.Context, report *PanicReport, dryRun bool) error {
+	canceled, err := k.Client.CancelOrders(ctx, Filter{})
+	if err != nil {
+		return fmt.Errorf("failed to cancel orders: %w", err)
+	}
+	var errs []error
+	for _, r := range canceled.Results {
+		switch {
+		case r.Err == nil:
+			report.CanceledOrders = append(report.CanceledOrders, OrderID(r.ID))
+		case !errors.Is(r.Err, ErrDryRun):
+			errs = append(errs, fmt.Errorf("failed to cancel order %s: %w", r.ID, r.Err))
+		}
+	}
+	if !dryRun {
+		if err := k.saveMarker(report); err != nil {
+			return err
+		}
+	}
+
+	positions, err := k.Client.FetchOpenPositions()
+	if err != nil {
+		return fmt.Errorf("failed to fetch open positions: %w", err)
+	}
+	for _, p := range positions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fills, err := k.Client.ClosePosition(p.Instrument, p.Long.Units != 0, p.Short.Units != 0)
+		if errors.Is(err, ErrDryRun) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if len(fills) == 0 {
+			continue
+		}
+		report.ClosedPositions = append(report.ClosedPositions, fills...)
+		if err := k.saveMarker(report); err != nil {
+			return err
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d operations failed: %v", len(errs), errs)
+	}
+	return nil
+}
+
+func (k *KillSwitch) flat() (bool, error) {
+	orders, err := k.Client.FetchOrders()
+	partial, err := partlyDecodedOrders(err)
+	if err != nil {
+		return false, err
+	}
+	positions, err := k.Client.FetchOpenPositions()
+	if err != nil {
+		return false, err
+	}
+	return len(orders)+len(partial) == 0 && len(positions) == 0, nil
+}
+
+func (k *KillSwitch) loadMarker() (*PanicReport, error) {
+	if k.MarkerPath == "" {
+		return nil, nil
+	}
+	b, err := ioutil.ReadFile(k.MarkerPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read panic marker: %w", err)
+	}
+	var report PanicReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal panic marker %s: %w", k.MarkerPath, err)
+	}
+	if report.AccountID != k.Client.AccountID() {
+		return nil, fmt.Errorf("panic marker %s is of account %s", k.MarkerPath, report.AccountID)
+	}
+	report.Resumed = true
+	return &report, nil
+}
+
+// saveMarker writes report to the marker atomically.
+func (k *KillSwitch) saveMarker(report *PanicReport) error {
+	if k.MarkerPath == "" || k.Client.dryRun != nil {
+		return nil
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal panic marker: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(k.MarkerPath), 0700); err != nil {
+		return fmt.Errorf("failed to create panic marker directory: %w", err)
+	}
+	tmp := k.MarkerPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to write panic marker: %w", err)
+	}
+	if err := os.Rename(tmp, k.MarkerPath); err != nil {
+		return fmt.Errorf("failed to write panic marker: %w", err)
+	}
+	return nil
+}
+
+// Panic cancels all pending orders and closes all open positions of the account
+// with a KillSwitch which does not persist its progress.
+func (c *Client) Panic(ctx context.Context) (*PanicReport, error) {
+	return NewKillSwitch(c, "").Panic(ctx)
+}
@@ -0,0 +1,95 @@
+package oanda_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+func newKillSwitch(t *testing.T) (*oanda.Client, *oanda.KillSwitch) {
+	t.Helper()
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	dir, err := ioutil.TempDir("", "panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	client := s.NewClient()
+	k := oanda.NewKillSwitch(client, filepath.Join(dir, "panic.json"))
+	k.RetryInterval = 10 * time.Millisecond
+	return client, k
+}
+
+// openExposure opens a position and places a pending order.
+func openExposure(t *testing.T, client *oanda.Client) {
+	t.Helper()
+	for _, o := range []oanda.Order{
+		{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 100, TimeInForce: oanda.TimeInForceFOK},
+		limitOrder(100, 104.5),
+	} {
+		if err := client.CreateOrder(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKillSwitchRunsAgainAfterFinishing(t *testing.T) {
+	client, k := newKillSwitch(t)
+	openExposure(t, client)
+	first, err := k.Panic(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Flat || first.FinishedAt == nil || first.Resumed || len(first.CanceledOrders) != 1 || len(first.ClosedPositions) != 1 {
+		t.Fatalf("first report = %+v", first)
+	}
+
+	// a later emergency acts on the account again instead of returning the finished report.
+	openExposure(t, client)
+	second, err := k.Panic(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !second.Flat || second.Resumed || len(second.CanceledOrders) != 1 || len(second.ClosedPositions) != 1 {
+		t.Errorf("second report = %+v, want a new report of the second exposure", second)
+	}
+	if second.StartedAt.Before(*first.FinishedAt) {
+		t.Errorf("second report started at %s, before the first finished at %s", second.StartedAt, *first.FinishedAt)
+	}
+	if orders, _ := client.FetchOrders(); len(orders) != 0 {
+		t.Errorf("orders = %+v, want none", orders)
+	}
+	if positions, _ := client.FetchOpenPositions(); len(positions) != 0 {
+		t.Errorf("positions = %+v, want none", positions)
+	}
+}
+
+func TestKillSwitchResumesUnfinishedRun(t *testing.T) {
+	client, k := newKillSwitch(t)
+	openExposure(t, client)
+	started := time.Date(2020, 9, 18, 10, 20, 0, 0, time.UTC)
+	unfinished := oanda.PanicReport{AccountID: client.AccountID(), StartedAt: started, CanceledOrders: []oanda.OrderID{"1"}}
+	b, err := json.Marshal(unfinished)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(k.MarkerPath, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	report, err := k.Panic(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Resumed || !report.StartedAt.Equal(started) || !report.Flat || len(report.CanceledOrders) != 2 || len(report.ClosedPositions) != 1 {
+		t.Errorf("report = %+v, want the unfinished report resumed and finished", report)
+	}
+}
@@ -0,0 +1,572 @@
+package oandatest
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type transaction map[string]interface{}
+
+func (t transaction) id() string {
+	return t["id"].(string)
+}
+
+type account struct {
+	id          string
+	currency    string
+	balance     float64
+	pl          float64
+	marginRate  float64
+	createdTime time.Time
+
+	orders       []*order
+	trades       []*trade
+	positionPL   map[string]*sidePL
+	transactions []transaction
+	lastID       int
+	batchID      string
+}
+
+type sidePL struct {
+	long, short float64
+}
+
+func newAccount(id, currency string, balance float64, now time.Time) *account {
+	a := &account{
+		id:          id,
+		currency:    currency,
+		marginRate:  DefaultMarginRate,
+		createdTime: now,
+		positionPL:  map[string]*sidePL{},
+	}
+	create := a.newTransaction("CREATE", now)
+	create["divisionID"] = 1
+	create["homeCurrency"] = currency
+	create["accountNumber"] = 1
+	a.batchID = ""
+	if balance != 0 {
+		a.balance = balance
+		transfer := a.newTransaction("TRANSFER_FUNDS", now)
+		transfer["amount"] = formatAmount(balance)
+		transfer["fundingReason"] = "ACCOUNT_TRANSFER"
+		transfer["accountBalance"] = formatAmount(balance)
+		a.batchID = ""
+	}
+	return a
+}
+
+// newTransaction appends a transaction of the current batch. The first transaction of a batch starts it.
+func (a *account) newTransaction(typ string, now time.Time) transaction {
+	a.lastID++
+	id := strconv.Itoa(a.lastID)
+	if a.batchID == "" {
+		a.batchID = id
+	}
+	t := transaction{"id": id, "time": now, "type": typ, "accountID": a.id, "batchID": a.batchID, "userID": 1}
+	a.transactions = append(a.transactions, t)
+	return t
+}
+
+func (a *account) lastTransactionID() string {
+	return strconv.Itoa(a.lastID)
+}
+
+// batch returns the ids of the transactions of the current batch.
+func (a *account) batch() []string {
+	var ids []string
+	for i := len(a.transactions) - 1; i >= 0 && a.transactions[i]["batchID"] == a.batchID; i-- {
+		ids = append([]string{a.transactions[i].id()}, ids...)
+	}
+	return ids
+}
+
+func (a *account) findOrder(specifier string) *order {
+	for _, o := range a.orders {
+		if o.id == specifier || (strings.HasPrefix(specifier, "@") && o.clientExtensions != nil && o.clientExtensions.ID == specifier[1:]) {
+			return o
+		}
+	}
+	return nil
+}
+
+func (a *account) findTrade(specifier string) *trade {
+	for _, t := range a.trades {
+		if t.id == specifier || (strings.HasPrefix(specifier, "@") && t.clientExtensions != nil && t.clientExtensions.ID == specifier[1:]) {
+			return t
+		}
+	}
+	return nil
+}
+
+func (a *account) openTrades(instrument string) []*trade {
+	var trades []*trade
+	for _, t := range a.trades {
+		if t.state == "OPEN" && (instrument == "" || t.instrument == instrument) {
+			trades = append(trades, t)
+		}
+	}
+	return trades
+}
+
+func (a *account) pendingOrders() []*order {
+	var orders []*order
+	for _, o := range a.orders {
+		if o.state == "PENDING" {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+// unrealized returns the unrealized profit and loss and the margin used of the open trades.
+func (a *account) unrealized(m *market) (pl, marginUsed float64) {
+	for _, t := range a.openTrades("") {
+		pl += t.unrealizedPL(m)
+		marginUsed += t.marginUsed(m, a.marginRate)
+	}
+	return pl, marginUsed
+}
+
+func (a *account) marginAvailable(m *market) float64 {
+	pl, used := a.unrealized(m)
+	return math.Max(0, a.balance+pl-used)
+}
+
+func (a *account) summary(m *market) map[string]interface{} {
+	unrealizedPL, marginUsed := a.unrealized(m)
+	nav := a.balance + unrealizedPL
+	positions := 0
+	for _, p := range a.positions(m) {
+		if p.open() {
+			positions++
+		}
+	}
+	closeoutPercent := 0.0
+	if nav > 0 {
+		closeoutPercent = marginUsed / 2 / nav
+	}
+	return map[string]interface{}{
+		"id":                         a.id,
+		"alias":                      "Primary",
+		"currency":                   a.currency,
+		"balance":                    formatAmount(a.balance),
+		"createdByUserID":            1,
+		"createdTime":                a.createdTime,
+		"pl":                         formatAmount(a.pl),
+		"resettablePL":               formatAmount(a.pl),
+		"financing":                  formatAmount(0),
+		"commission":                 formatAmount(0),
+		"guaranteedExecutionFees":    formatAmount(0),
+		"marginRate":                 strconv.FormatFloat(a.marginRate, 'f', -1, 64),
+		"openTradeCount":             len(a.openTrades("")),
+		"openPositionCount":          positions,
+		"pendingOrderCount":          len(a.pendingOrders()),
+		"hedgingEnabled":             false,
+		"unrealizedPL":               formatAmount(unrealizedPL),
+		"NAV":                        formatAmount(nav),
+		"marginUsed":                 formatAmount(marginUsed),
+		"marginAvailable":            formatAmount(math.Max(0, nav-marginUsed)),
+		"positionValue":              formatAmount(marginUsed / a.marginRate),
+		"marginCloseoutUnrealizedPL": formatAmount(unrealizedPL),
+		"marginCloseoutNAV":          formatAmount(nav),
+		"marginCloseoutMarginUsed":   formatAmount(marginUsed / 2),
+		"marginCloseoutPercent":      strconv.FormatFloat(closeoutPercent, 'f', 5, 64),
+		"withdrawalLimit":            formatAmount(math.Max(0, nav-marginUsed)),
+		"lastTransactionID":          a.lastTransactionID(),
+	}
+}
+
+func (a *account) details(m *market) map[string]interface{} {
+	d := a.summary(m)
+	var orders, trades, positions []map[string]interface{}
+	for _, o := range a.pendingOrders() {
+		orders = append(orders, o.view())
+	}
+	for _, t := range a.openTrades("") {
+		trades = append(trades, a.tradeView(t, m))
+	}
+	for _, p := range a.positions(m) {
+		positions = append(positions, p.view())
+	}
+	d["orders"] = nonNil(orders)
+	d["trades"] = nonNil(trades)
+	d["positions"] = nonNil(positions)
+	return d
+}
+
+// clientExtensions is the client extensions of orders and trades.
+type clientExtensions struct {
+	ID      string `json:"id,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// details is the take profit, stop loss or trailing stop loss details of an order filled.
+type details struct {
+	Price       number     `json:"price,omitempty"`
+	Distance    number     `json:"distance,omitempty"`
+	TimeInForce string     `json:"timeInForce,omitempty"`
+	GtdTime     *time.Time `json:"gtdTime,omitempty"`
+}
+
+func (d *details) view(instrument string) map[string]interface{} {
+	v := map[string]interface{}{"timeInForce": d.TimeInForce}
+	if v["timeInForce"] == "" {
+		v["timeInForce"] = "GTC"
+	}
+	if p := d.Price.float(); p != 0 {
+		v["price"] = formatPrice(instrument, p)
+	}
+	if dist := d.Distance.float(); dist != 0 {
+		v["distance"] = formatPrice(instrument, dist)
+	}
+	if d.GtdTime != nil {
+		v["gtdTime"] = d.GtdTime.UTC()
+	}
+	return v
+}
+
+type order struct {
+	id                     string
+	typ                    string
+	instrument             string
+	units                  float64
+	price                  float64
+	priceBound             float64
+	distance               float64
+	trailingStopValue      float64
+	timeInForce            string
+	gtdTime                *time.Time
+	positionFill           string
+	triggerCondition       string
+	tradeID                string
+	clientTradeID          string
+	clientExtensions       *clientExtensions
+	tradeClientExtensions  *clientExtensions
+	takeProfitOnFill       *details
+	stopLossOnFill         *details
+	trailingStopLossOnFill *details
+	// touchAbove is whether a MARKET_IF_TOUCHED order triggers when the price rises to its price.
+	touchAbove bool
+
+	state                   string
+	createTime              time.Time
+	fillingTransactionID    string
+	filledTime              *time.Time
+	tradeOpenedID           string
+	tradeReducedID          string
+	tradeClosedIDs          []string
+	cancellingTransactionID string
+	cancelledTime           *time.Time
+	replacesOrderID         string
+	replacedByOrderID       string
+}
+
+func (o *order) dependent() bool {
+	return o.typ == "TAKE_PROFIT" || o.typ == "STOP_LOSS" || o.typ == "TRAILING_STOP_LOSS"
+}
+
+// spec returns the fields of the order its create transaction and its view have in common.
+func (o *order) spec() map[string]interface{} {
+	v := map[string]interface{}{"type": o.typ, "timeInForce": o.timeInForce, "triggerCondition": o.triggerCondition}
+	if o.dependent() {
+		v["tradeID"] = o.tradeID
+		if o.clientTradeID != "" {
+			v["clientTradeID"] = o.clientTradeID
+		}
+	} else {
+		v["instrument"] = o.instrument
+		v["units"] = formatUnits(o.units)
+		v["positionFill"] = o.positionFill
+		if o.typ == "MARKET" {
+			delete(v, "triggerCondition")
+		}
+	}
+	if o.price != 0 {
+		v["price"] = formatPrice(o.instrument, o.price)
+	}
+	if o.priceBound != 0 {
+		v["priceBound"] = formatPrice(o.instrument, o.priceBound)
+	}
+	if o.distance != 0 {
+		v["distance"] = formatPrice(o.instrument, o.distance)
+	}
+	if o.gtdTime != nil {
+		v["gtdTime"] = o.gtdTime.UTC()
+	}
+	if o.clientExtensions != nil {
+		v["clientExtensions"] = o.clientExtensions
+	}
+	if o.tradeClientExtensions != nil {
+		v["tradeClientExtensions"] = o.tradeClientExtensions
+	}
+	if o.takeProfitOnFill != nil {
+		v["takeProfitOnFill"] = o.takeProfitOnFill.view(o.instrument)
+	}
+	if o.stopLossOnFill != nil {
+		v["stopLossOnFill"] = o.stopLossOnFill.view(o.instrument)
+	}
+	if o.trailingStopLossOnFill != nil {
+		v["trailingStopLossOnFill"] = o.trailingStopLossOnFill.view(o.instrument)
+	}
+	return v
+}
+
+func (o *order) view() map[string]interface{} {
+	v := o.spec()
+	v["id"] = o.id
+	v["state"] = o.state
+	v["createTime"] = o.createTime
+	if o.typ == "TRAILING_STOP_LOSS" && o.trailingStopValue != 0 {
+		v["trailingStopValue"] = formatPrice(o.instrument, o.trailingStopValue)
+	}
+	if o.replacesOrderID != "" {
+		v["replacesOrderID"] = o.replacesOrderID
+	}
+	if o.replacedByOrderID != "" {
+		v["replacedByOrderID"] = o.replacedByOrderID
+	}
+	switch o.state {
+	case "FILLED":
+		v["fillingTransactionID"] = o.fillingTransactionID
+		v["filledTime"] = o.filledTime
+		if o.tradeOpenedID != "" {
+			v["tradeOpenedID"] = o.tradeOpenedID
+		}
+		if o.tradeReducedID != "" {
+			v["tradeReducedID"] = o.tradeReducedID
+		}
+		if len(o.tradeClosedIDs) > 0 {
+			v["tradeClosedIDs"] = o.tradeClosedIDs
+		}
+	case "CANCELLED":
+		v["cancellingTransactionID"] = o.cancellingTransactionID
+		v["cancelledTime"] = o.cancelledTime
+	}
+	return v
+}
+
+type trade struct {
+	id               string
+	instrument       string
+	price            float64
+	openTime         time.Time
+	state            string
+	initialUnits     float64
+	currentUnits     float64
+	realizedPL       float64
+	closeValue       float64
+	closedUnits      float64
+	closeTime        *time.Time
+	closingIDs       []string
+	clientExtensions *clientExtensions
+
+	takeProfitOrder       *order
+	stopLossOrder         *order
+	trailingStopLossOrder *order
+}
+
+func (t *trade) long() bool {
+	return t.currentUnits > 0 || (t.currentUnits == 0 && t.initialUnits > 0)
+}
+
+// closePrice returns the price the trade closes at: the bid for a long trade and the ask for a short one.
+func (t *trade) closePrice(m *market) (float64, bool) {
+	q, ok := m.quote(t.instrument)
+	if !ok {
+		return 0, false
+	}
+	if t.long() {
+		return float64(q.Bid), true
+	}
+	return float64(q.Ask), true
+}
+
+func (t *trade) unrealizedPL(m *market) float64 {
+	if t.state != "OPEN" {
+		return 0
+	}
+	p, ok := t.closePrice(m)
+	if !ok {
+		return 0
+	}
+	return (p - t.price) * t.currentUnits
+}
+
+func (t *trade) marginUsed(m *market, marginRate float64) float64 {
+	if t.state != "OPEN" {
+		return 0
+	}
+	price := t.price
+	if q, ok := m.quote(t.instrument); ok {
+		price = q.mid()
+	}
+	return math.Abs(t.currentUnits) * price * marginRate
+}
+
+func (t *trade) dependents() []*order {
+	var orders []*order
+	for _, o := range []*order{t.takeProfitOrder, t.stopLossOrder, t.trailingStopLossOrder} {
+		if o != nil {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+func (a *account) tradeView(t *trade, m *market) map[string]interface{} {
+	v := map[string]interface{}{
+		"id":                    t.id,
+		"instrument":            t.instrument,
+		"price":                 formatPrice(t.instrument, t.price),
+		"openTime":              t.openTime,
+		"state":                 t.state,
+		"initialUnits":          formatUnits(t.initialUnits),
+		"initialMarginRequired": formatAmount(math.Abs(t.initialUnits) * t.price * a.marginRate),
+		"currentUnits":          formatUnits(t.currentUnits),
+		"realizedPL":            formatAmount(t.realizedPL),
+		"financing":             formatAmount(0),
+		"dividendAdjustment":    formatAmount(0),
+	}
+	if t.state == "OPEN" {
+		v["unrealizedPL"] = formatAmount(t.unrealizedPL(m))
+		v["marginUsed"] = formatAmount(t.marginUsed(m, a.marginRate))
+	} else {
+		v["closeTime"] = t.closeTime
+		v["closingTransactionIDs"] = t.closingIDs
+	}
+	if t.closedUnits != 0 {
+		v["averageClosePrice"] = formatPrice(t.instrument, t.closeValue/t.closedUnits)
+	}
+	if t.clientExtensions != nil {
+		v["clientExtensions"] = t.clientExtensions
+	}
+	if t.takeProfitOrder != nil {
+		v["takeProfitOrder"] = t.takeProfitOrder.view()
+	}
+	if t.stopLossOrder != nil {
+		v["stopLossOrder"] = t.stopLossOrder.view()
+	}
+	if t.trailingStopLossOrder != nil {
+		v["trailingStopLossOrder"] = t.trailingStopLossOrder.view()
+	}
+	return v
+}
+
+type positionSide struct {
+	units        float64
+	value        float64
+	tradeIDs     []string
+	pl           float64
+	unrealizedPL float64
+}
+
+func (s *positionSide) view(instrument string) map[string]interface{} {
+	v := map[string]interface{}{
+		"units":        formatUnits(s.units),
+		"pl":           formatAmount(s.pl),
+		"resettablePL": formatAmount(s.pl),
+		"unrealizedPL": formatAmount(s.unrealizedPL),
+		"financing":    formatAmount(0),
+	}
+	if s.units != 0 {
+		v["averagePrice"] = formatPrice(instrument, s.value/s.units)
+		v["tradeIDs"] = s.tradeIDs
+	}
+	return v
+}
+
+type position struct {
+	instrument  string
+	marginUsed  float64
+	long, short positionSide
+}
+
+func (p *position) open() bool {
+	return p.long.units != 0 || p.short.units != 0
+}
+
+func (p *position) view() map[string]interface{} {
+	return map[string]interface{}{
+		"instrument":   p.instrument,
+		"pl":           formatAmount(p.long.pl + p.short.pl),
+		"resettablePL": formatAmount(p.long.pl + p.short.pl),
+		"unrealizedPL": formatAmount(p.long.unrealizedPL + p.short.unrealizedPL),
+		"marginUsed":   formatAmount(p.marginUsed),
+		"financing":    formatAmount(0),
+		"commission":   formatAmount(0),
+		"long":         p.long.view(p.instrument),
+		"short":        p.short.view(p.instrument),
+	}
+}
+
+// positions returns the positions of every instrument the account has traded, in the order first traded.
+func (a *account) positions(m *market) []*position {
+	var positions []*position
+	byInstrument := map[string]*position{}
+	for _, t := range a.trades {
+		p, ok := byInstrument[t.instrument]
+		if !ok {
+			p = &position{instrument: t.instrument}
+			if pl := a.positionPL[t.instrument]; pl != nil {
+				p.long.pl, p.short.pl = pl.long, pl.short
+			}
+			byInstrument[t.instrument] = p
+			positions = append(positions, p)
+		}
+		if t.state != "OPEN" {
+			continue
+		}
+		side := &p.short
+		if t.long() {
+			side = &p.long
+		}
+		side.units += t.currentUnits
+		side.value += t.currentUnits * t.price
+		side.tradeIDs = append(side.tradeIDs, t.id)
+		side.unrealizedPL += t.unrealizedPL(m)
+		p.marginUsed += t.marginUsed(m, a.marginRate)
+	}
+	return positions
+}
+
+// number is a decimal number which is either a JSON string or a JSON number.
+type number string
+
+func (n *number) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*n = number(s)
+		return nil
+	}
+	var f json.Number
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	*n = number(f)
+	return nil
+}
+
+// float returns the number, or 0 when it is empty or invalid.
+func (n number) float() float64 {
+	f, _ := strconv.ParseFloat(string(n), 64)
+	return f
+}
+
+func (n number) valid() bool {
+	_, err := strconv.ParseFloat(string(n), 64)
+	return n == "" || err == nil
+}
+
+func nonNil(vs []map[string]interface{}) []map[string]interface{} {
+	if vs == nil {
+		return []map[string]interface{}{}
+	}
+	return vs
+}
@@ -0,0 +1,906 @@
+package oandatest
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exchange executes the orders of an account against the market.
+type exchange struct {
+	a   *account
+	m   *market
+	now time.Time
+}
+
+// evaluate expires, triggers and fills the pending orders of the account until nothing changes.
+func (a *account) evaluate(now time.Time, m *market) {
+	x := &exchange{a: a, m: m, now: now}
+	for pass := 0; pass < 10; pass++ {
+		changed := false
+		for _, o := range a.pendingOrders() {
+			if o.state != "PENDING" {
+				continue
+			}
+			if o.timeInForce == "GTD" && o.gtdTime != nil && !now.Before(*o.gtdTime) {
+				x.cancel(o, "TIME_IN_FORCE_EXPIRED")
+				changed = true
+				continue
+			}
+			if x.triggered(o) {
+				x.execute(o)
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// orderRequest is the order of a request to create or replace an order.
+type orderRequest struct {
+	Type                   string            `json:"type"`
+	Instrument             string            `json:"instrument"`
+	Units                  number            `json:"units"`
+	Price                  number            `json:"price"`
+	PriceBound             number            `json:"priceBound"`
+	Distance               number            `json:"distance"`
+	TimeInForce            string            `json:"timeInForce"`
+	GtdTime                *time.Time        `json:"gtdTime"`
+	PositionFill           string            `json:"positionFill"`
+	TriggerCondition       string            `json:"triggerCondition"`
+	TradeID                string            `json:"tradeID"`
+	ClientTradeID          string            `json:"clientTradeID"`
+	ClientExtensions       *clientExtensions `json:"clientExtensions"`
+	TradeClientExtensions  *clientExtensions `json:"tradeClientExtensions"`
+	TakeProfitOnFill       *details          `json:"takeProfitOnFill"`
+	StopLossOnFill         *details          `json:"stopLossOnFill"`
+	TrailingStopLossOnFill *details          `json:"trailingStopLossOnFill"`
+}
+
+var orderTypes = map[string]bool{
+	"MARKET": true, "LIMIT": true, "STOP": true, "MARKET_IF_TOUCHED": true,
+	"TAKE_PROFIT": true, "STOP_LOSS": true, "TRAILING_STOP_LOSS": true,
+}
+
+// newOrder validates r and returns the order it specifies, or the reason to reject it.
+// replacing is the order r replaces, if any.
+func (x *exchange) newOrder(r *orderRequest, replacing *order) (*order, string) {
+	if !orderTypes[r.Type] {
+		return nil, "INVALID_ORDER_TYPE"
+	}
+	for _, n := range []number{r.Units, r.Price, r.PriceBound, r.Distance} {
+		if !n.valid() {
+			return nil, "INVALID_DECIMAL"
+		}
+	}
+	o := &order{
+		typ:                    r.Type,
+		instrument:             r.Instrument,
+		units:                  r.Units.float(),
+		price:                  r.Price.float(),
+		priceBound:             r.PriceBound.float(),
+		distance:               r.Distance.float(),
+		timeInForce:            r.TimeInForce,
+		gtdTime:                r.GtdTime,
+		positionFill:           r.PositionFill,
+		triggerCondition:       r.TriggerCondition,
+		tradeID:                r.TradeID,
+		clientTradeID:          r.ClientTradeID,
+		clientExtensions:       r.ClientExtensions,
+		tradeClientExtensions:  r.TradeClientExtensions,
+		takeProfitOnFill:       r.TakeProfitOnFill,
+		stopLossOnFill:         r.StopLossOnFill,
+		trailingStopLossOnFill: r.TrailingStopLossOnFill,
+	}
+	if o.positionFill == "" {
+		o.positionFill = "DEFAULT"
+	}
+	if o.triggerCondition == "" {
+		o.triggerCondition = "DEFAULT"
+	}
+	if o.timeInForce == "" {
+		o.timeInForce = "GTC"
+		if o.typ == "MARKET" {
+			o.timeInForce = "FOK"
+		}
+	}
+	switch {
+	case o.typ == "MARKET" && o.timeInForce != "FOK" && o.timeInForce != "IOC":
+		return nil, "TIME_IN_FORCE_INVALID"
+	case o.timeInForce == "GTD" && o.gtdTime == nil:
+		return nil, "TIME_IN_FORCE_GTD_TIMESTAMP_MISSING"
+	case o.timeInForce == "GTD" && !o.gtdTime.After(x.now):
+		return nil, "TIME_IN_FORCE_GTD_TIMESTAMP_IN_PAST"
+	}
+
+	if o.dependent() {
+		t := x.a.findTrade(o.tradeID)
+		if t == nil && o.clientTradeID != "" {
+			t = x.a.findTrade("@" + o.clientTradeID)
+		}
+		if t == nil || t.state != "OPEN" {
+			return nil, "TRADE_DOESNT_EXIST"
+		}
+		o.tradeID = t.id
+		o.instrument = t.instrument
+		if existing := t.dependent(o.typ); existing != nil && existing != replacing {
+			return nil, o.typ + "_ORDER_ALREADY_EXISTS"
+		}
+		switch {
+		case o.typ == "TRAILING_STOP_LOSS" && o.distance <= 0:
+			return nil, "TRAILING_STOP_LOSS_ORDER_DISTANCE_MISSING"
+		case o.typ == "STOP_LOSS" && o.price <= 0 && o.distance <= 0:
+			return nil, "STOP_LOSS_ORDER_PRICE_MISSING"
+		case o.typ == "TAKE_PROFIT" && o.price <= 0:
+			return nil, "TAKE_PROFIT_ORDER_PRICE_MISSING"
+		}
+		if o.typ == "STOP_LOSS" && o.price <= 0 {
+			p, _ := t.closePrice(x.m)
+			o.price = p - math.Copysign(o.distance, t.currentUnits)
+		}
+		return o, ""
+	}
+
+	if _, ok := x.m.quote(o.instrument); !ok {
+		return nil, "INSTRUMENT_NOT_TRADEABLE"
+	}
+	if o.units == 0 {
+		return nil, "UNITS_MISSING"
+	}
+	if o.typ != "MARKET" && o.price <= 0 {
+		return nil, "PRICE_MISSING"
+	}
+	switch o.positionFill {
+	case "DEFAULT", "OPEN_ONLY", "REDUCE_FIRST", "REDUCE_ONLY":
+	default:
+		return nil, "POSITION_FILL_INVALID"
+	}
+	if o.typ == "MARKET_IF_TOUCHED" {
+		o.touchAbove = o.price > x.price(o.instrument, o.units > 0, o.triggerCondition)
+	}
+	return o, ""
+}
+
+func (t *trade) dependent(typ string) *order {
+	switch typ {
+	case "TAKE_PROFIT":
+		return t.takeProfitOrder
+	case "STOP_LOSS":
+		return t.stopLossOrder
+	case "TRAILING_STOP_LOSS":
+		return t.trailingStopLossOrder
+	}
+	return nil
+}
+
+func (t *trade) setDependent(o *order) {
+	switch o.typ {
+	case "TAKE_PROFIT":
+		t.takeProfitOrder = o
+	case "STOP_LOSS":
+		t.stopLossOrder = o
+	case "TRAILING_STOP_LOSS":
+		t.trailingStopLossOrder = o
+	}
+}
+
+// price returns the price an order of the side triggers on: the ask for buying and the bid for selling,
+// unless triggerCondition says otherwise.
+func (x *exchange) price(instrument string, buy bool, triggerCondition string) float64 {
+	q, _ := x.m.quote(instrument)
+	switch triggerCondition {
+	case "MID":
+		return q.mid()
+	case "BID":
+		return float64(q.Bid)
+	case "ASK":
+		return float64(q.Ask)
+	case "INVERSE":
+		buy = !buy
+	}
+	if buy {
+		return float64(q.Ask)
+	}
+	return float64(q.Bid)
+}
+
+// create records the creation of the order and adds it to the account as pending.
+func (x *exchange) create(o *order, reason string) transaction {
+	create := x.a.newTransaction(o.typ+"_ORDER", x.now)
+	for k, v := range o.spec() {
+		create[k] = v
+	}
+	create["type"] = o.typ + "_ORDER"
+	create["reason"] = reason
+	o.id = create.id()
+	o.state = "PENDING"
+	o.createTime = x.now
+	x.a.orders = append(x.a.orders, o)
+	return create
+}
+
+// submit creates the order and executes it when it is a market order.
+func (x *exchange) submit(o *order, reason string) transaction {
+	create := x.create(o, reason)
+	if o.replacesOrderID != "" {
+		create["replacesOrderID"] = o.replacesOrderID
+	}
+	if o.dependent() {
+		t := x.a.findTrade(o.tradeID)
+		t.setDependent(o)
+		if o.typ == "TRAILING_STOP_LOSS" {
+			p, _ := t.closePrice(x.m)
+			o.trailingStopValue = p - math.Copysign(o.distance, t.currentUnits)
+		}
+	}
+	if o.typ == "MARKET" {
+		x.execute(o)
+	}
+	return create
+}
+
+func (x *exchange) triggered(o *order) bool {
+	if o.dependent() {
+		t := x.a.findTrade(o.tradeID)
+		if t == nil || t.state != "OPEN" {
+			return false
+		}
+		long := t.currentUnits > 0
+		p := x.price(o.instrument, !long, o.triggerCondition)
+		switch o.typ {
+		case "TAKE_PROFIT":
+			return (long && p >= o.price) || (!long && p <= o.price)
+		case "STOP_LOSS":
+			return (long && p <= o.price) || (!long && p >= o.price)
+		case "TRAILING_STOP_LOSS":
+			if long {
+				o.trailingStopValue = math.Max(o.trailingStopValue, p-o.distance)
+				return p <= o.trailingStopValue
+			}
+			o.trailingStopValue = math.Min(o.trailingStopValue, p+o.distance)
+			return p >= o.trailingStopValue
+		}
+		return false
+	}
+	buy := o.units > 0
+	p := x.price(o.instrument, buy, o.triggerCondition)
+	switch o.typ {
+	case "LIMIT":
+		return (buy && p <= o.price) || (!buy && p >= o.price)
+	case "STOP":
+		return (buy && p >= o.price) || (!buy && p <= o.price)
+	case "MARKET_IF_TOUCHED":
+		return (o.touchAbove && p >= o.price) || (!o.touchAbove && p <= o.price)
+	}
+	return false
+}
+
+// execute fills the order at the current price, or cancels it when it cannot be filled.
+func (x *exchange) execute(o *order) {
+	if o.dependent() {
+		t := x.a.findTrade(o.tradeID)
+		x.fill(o, -t.currentUnits, []*trade{t}, o.typ+"_ORDER")
+		return
+	}
+	var targets []*trade
+	if o.positionFill != "OPEN_ONLY" {
+		for _, t := range x.a.openTrades(o.instrument) {
+			if (t.currentUnits > 0) != (o.units > 0) {
+				targets = append(targets, t)
+			}
+		}
+	}
+	if o.positionFill == "REDUCE_ONLY" && len(targets) == 0 {
+		x.cancel(o, "REDUCE_ONLY_NOTHING_TO_REDUCE")
+		return
+	}
+	x.fill(o, o.units, targets, o.typ+"_ORDER")
+}
+
+// fill fills units of the order at the current price. It closes or reduces targets first in first out
+// and opens a trade of the remaining units unless the order only reduces.
+func (x *exchange) fill(o *order, units float64, targets []*trade, reason string) transaction {
+	q, _ := x.m.quote(o.instrument)
+	buy := units > 0
+	price := float64(q.Bid)
+	if buy {
+		price = float64(q.Ask)
+	}
+	if o.priceBound != 0 && ((buy && price > o.priceBound) || (!buy && price < o.priceBound)) {
+		return x.cancel(o, "BOUNDS_VIOLATION")
+	}
+
+	type closing struct {
+		t     *trade
+		units float64
+	}
+	var closings []closing
+	remaining := units
+	for _, t := range targets {
+		if remaining == 0 {
+			break
+		}
+		u := math.Copysign(math.Min(math.Abs(remaining), math.Abs(t.currentUnits)), remaining)
+		closings = append(closings, closing{t, u})
+		remaining -= u
+	}
+	if o.dependent() || o.positionFill == "REDUCE_ONLY" {
+		units -= remaining
+		remaining = 0
+	}
+	if remaining != 0 && math.Abs(remaining)*price*x.a.marginRate > x.a.marginAvailable(x.m) {
+		return x.cancel(o, "INSUFFICIENT_MARGIN")
+	}
+
+	fill := x.a.newTransaction("ORDER_FILL", x.now)
+	fill["orderID"] = o.id
+	if o.clientExtensions != nil && o.clientExtensions.ID != "" {
+		fill["clientOrderID"] = o.clientExtensions.ID
+	}
+	fill["instrument"] = o.instrument
+	fill["units"] = formatUnits(units)
+	fill["price"] = formatPrice(o.instrument, price)
+	fill["reason"] = reason
+	fill["fullVWAP"] = formatPrice(o.instrument, price)
+	fill["fullPrice"] = map[string]interface{}{
+		"bids":        []map[string]interface{}{{"price": formatPrice(o.instrument, float64(q.Bid)), "liquidity": 10000000}},
+		"asks":        []map[string]interface{}{{"price": formatPrice(o.instrument, float64(q.Ask)), "liquidity": 10000000}},
+		"closeoutBid": formatPrice(o.instrument, float64(q.Bid)),
+		"closeoutAsk": formatPrice(o.instrument, float64(q.Ask)),
+	}
+	fill["financing"] = formatAmount(0)
+	fill["commission"] = formatAmount(0)
+	fill["guaranteedExecutionFee"] = formatAmount(0)
+	fill["halfSpreadCost"] = formatAmount(math.Abs(units) * (float64(q.Ask) - float64(q.Bid)) / 2)
+
+	var pl float64
+	var closed []map[string]interface{}
+	var closedTrades []*trade
+	for _, c := range closings {
+		t := c.t
+		tradePL := (price - t.price) * -c.units
+		pl += tradePL
+		t.currentUnits += c.units
+		t.realizedPL += tradePL
+		t.closeValue += price * -c.units
+		t.closedUnits += -c.units
+		side := x.a.positionPL[t.instrument]
+		if side == nil {
+			side = &sidePL{}
+			x.a.positionPL[t.instrument] = side
+		}
+		if t.long() {
+			side.long += tradePL
+		} else {
+			side.short += tradePL
+		}
+		entry := map[string]interface{}{
+			"tradeID":                t.id,
+			"units":                  formatUnits(c.units),
+			"price":                  formatPrice(t.instrument, price),
+			"realizedPL":             formatAmount(tradePL),
+			"financing":              formatAmount(0),
+			"guaranteedExecutionFee": formatAmount(0),
+			"halfSpreadCost":         formatAmount(math.Abs(c.units) * (float64(q.Ask) - float64(q.Bid)) / 2),
+		}
+		if t.clientExtensions != nil && t.clientExtensions.ID != "" {
+			entry["clientTradeID"] = t.clientExtensions.ID
+		}
+		if t.currentUnits == 0 {
+			t.state = "CLOSED"
+			closeTime := x.now
+			t.closeTime = &closeTime
+			t.closingIDs = append(t.closingIDs, fill.id())
+			closed = append(closed, entry)
+			closedTrades = append(closedTrades, t)
+			o.tradeClosedIDs = append(o.tradeClosedIDs, t.id)
+		} else {
+			fill["tradeReduced"] = entry
+			o.tradeReducedID = t.id
+		}
+	}
+	if len(closed) > 0 {
+		fill["tradesClosed"] = closed
+	}
+	x.a.balance += pl
+	x.a.pl += pl
+	fill["pl"] = formatAmount(pl)
+	fill["accountBalance"] = formatAmount(x.a.balance)
+
+	filledTime := x.now
+	o.state = "FILLED"
+	o.fillingTransactionID = fill.id()
+	o.filledTime = &filledTime
+
+	var opened *trade
+	if remaining != 0 {
+		opened = &trade{
+			id:               fill.id(),
+			instrument:       o.instrument,
+			price:            price,
+			openTime:         x.now,
+			state:            "OPEN",
+			initialUnits:     remaining,
+			currentUnits:     remaining,
+			clientExtensions: o.tradeClientExtensions,
+		}
+		x.a.trades = append(x.a.trades, opened)
+		o.tradeOpenedID = opened.id
+		tradeOpened := map[string]interface{}{
+			"tradeID":                opened.id,
+			"units":                  formatUnits(remaining),
+			"price":                  formatPrice(o.instrument, price),
+			"guaranteedExecutionFee": formatAmount(0),
+			"halfSpreadCost":         formatAmount(math.Abs(remaining) * (float64(q.Ask) - float64(q.Bid)) / 2),
+			"initialMarginRequired":  formatAmount(math.Abs(remaining) * price * x.a.marginRate),
+		}
+		if opened.clientExtensions != nil {
+			tradeOpened["clientExtensions"] = opened.clientExtensions
+		}
+		fill["tradeOpened"] = tradeOpened
+	}
+
+	for _, t := range closedTrades {
+		for _, d := range t.dependents() {
+			if d != o && d.state == "PENDING" {
+				x.cancel(d, "LINKED_TRADE_CLOSED")
+			}
+		}
+	}
+	if opened != nil {
+		x.attachOnFill(o, opened)
+	}
+	return fill
+}
+
+// attachOnFill creates the dependent orders o specifies for the trade it opened.
+func (x *exchange) attachOnFill(o *order, t *trade) {
+	for _, d := range []struct {
+		typ     string
+		details *details
+	}{
+		{"TAKE_PROFIT", o.takeProfitOnFill},
+		{"STOP_LOSS", o.stopLossOnFill},
+		{"TRAILING_STOP_LOSS", o.trailingStopLossOnFill},
+	} {
+		if d.details == nil {
+			continue
+		}
+		dependent := &order{
+			typ:              d.typ,
+			instrument:       t.instrument,
+			tradeID:          t.id,
+			price:            d.details.Price.float(),
+			distance:         d.details.Distance.float(),
+			timeInForce:      d.details.TimeInForce,
+			gtdTime:          d.details.GtdTime,
+			triggerCondition: "DEFAULT",
+		}
+		if dependent.timeInForce == "" {
+			dependent.timeInForce = "GTC"
+		}
+		if d.typ == "STOP_LOSS" && dependent.price == 0 {
+			dependent.price = t.price - math.Copysign(dependent.distance, t.currentUnits)
+		}
+		if d.typ == "TAKE_PROFIT" && dependent.price == 0 {
+			dependent.price = t.price + math.Copysign(dependent.distance, t.currentUnits)
+		}
+		x.submit(dependent, "ON_FILL")
+	}
+}
+
+func (x *exchange) cancel(o *order, reason string) transaction {
+	t := x.a.newTransaction("ORDER_CANCEL", x.now)
+	t["orderID"] = o.id
+	t["reason"] = reason
+	if o.clientExtensions != nil && o.clientExtensions.ID != "" {
+		t["clientOrderID"] = o.clientExtensions.ID
+	}
+	cancelledTime := x.now
+	o.state = "CANCELLED"
+	o.cancellingTransactionID = t.id()
+	o.cancelledTime = &cancelledTime
+	if o.dependent() {
+		if tr := x.a.findTrade(o.tradeID); tr != nil && tr.dependent(o.typ) == o {
+			tr.clearDependent(o.typ)
+		}
+	}
+	return t
+}
+
+func (t *trade) clearDependent(typ string) {
+	switch typ {
+	case "TAKE_PROFIT":
+		t.takeProfitOrder = nil
+	case "STOP_LOSS":
+		t.stopLossOrder = nil
+	case "TRAILING_STOP_LOSS":
+		t.trailingStopLossOrder = nil
+	}
+}
+
+// reject records the rejection of a request to create an order and returns the response.
+func (x *exchange) reject(r *orderRequest, reason string) (int, interface{}) {
+	typ := r.Type
+	if !orderTypes[typ] {
+		typ = "MARKET"
+	}
+	t := x.a.newTransaction(typ+"_ORDER_REJECT", x.now)
+	t["instrument"] = r.Instrument
+	t["units"] = string(r.Units)
+	t["rejectReason"] = reason
+	return http.StatusBadRequest, map[string]interface{}{
+		"orderRejectTransaction": t,
+		"relatedTransactionIDs":  x.a.batch(),
+		"lastTransactionID":      x.a.lastTransactionID(),
+		"errorCode":              reason,
+		"errorMessage":           "The order request was rejected: " + reason,
+	}
+}
+
+// response returns the response of a batch of transactions, keyed by the role of each transaction.
+func (x *exchange) response(txs map[string]transaction) map[string]interface{} {
+	resp := map[string]interface{}{
+		"relatedTransactionIDs": x.a.batch(),
+		"lastTransactionID":     x.a.lastTransactionID(),
+	}
+	for k, t := range txs {
+		if t != nil {
+			resp[k] = t
+		}
+	}
+	return resp
+}
+
+// outcome returns the fill or the cancel transaction of the order in the current batch.
+func (x *exchange) outcome(o *order) (fill, cancel transaction) {
+	for _, id := range x.a.batch() {
+		t := x.a.transactions[mustAtoi(id)-1]
+		if t["orderID"] != o.id {
+			continue
+		}
+		switch t["type"] {
+		case "ORDER_FILL":
+			fill = t
+		case "ORDER_CANCEL":
+			cancel = t
+		}
+	}
+	return fill, cancel
+}
+
+func (x *exchange) routeOrders(method string, rest []string, q values, body []byte) (int, interface{}) {
+	switch {
+	case len(rest) == 1 && method == http.MethodGet:
+		return x.listOrders(rest[0] == "pendingOrders", q)
+	case len(rest) == 1 && rest[0] == "orders" && method == http.MethodPost:
+		return x.createOrder(body)
+	case len(rest) == 2 && method == http.MethodGet:
+		o := x.a.findOrder(rest[1])
+		if o == nil {
+			return orderNotFound(rest[1])
+		}
+		return http.StatusOK, map[string]interface{}{"order": o.view(), "lastTransactionID": x.a.lastTransactionID()}
+	case len(rest) == 2 && method == http.MethodPut:
+		return x.replaceOrder(rest[1], body)
+	case len(rest) == 3 && rest[2] == "cancel" && method == http.MethodPut:
+		o := x.a.findOrder(rest[1])
+		if o == nil || o.state != "PENDING" {
+			return orderNotFound(rest[1])
+		}
+		return http.StatusOK, x.response(map[string]transaction{"orderCancelTransaction": x.cancel(o, "CLIENT_REQUEST")})
+	}
+	return notFound()
+}
+
+func orderNotFound(specifier string) (int, interface{}) {
+	return http.StatusNotFound, errorBody("ORDER_DOESNT_EXIST", "The order "+specifier+" does not exist")
+}
+
+func (x *exchange) listOrders(pending bool, q values) (int, interface{}) {
+	state := q.get("state")
+	if state == "" || pending {
+		state = "PENDING"
+	}
+	count, status, resp := countParam(q)
+	if status != 0 {
+		return status, resp
+	}
+	if pending {
+		count = math.MaxInt32
+	}
+	ids := idsParam(q)
+	var orders []map[string]interface{}
+	for i := len(x.a.orders) - 1; i >= 0 && len(orders) < count; i-- {
+		o := x.a.orders[i]
+		if (state != "ALL" && o.state != state) ||
+			(q.get("instrument") != "" && o.instrument != q.get("instrument")) ||
+			(ids != nil && !ids[o.id]) ||
+			(q.get("beforeID") != "" && mustAtoi(o.id) >= mustAtoi(q.get("beforeID"))) {
+			continue
+		}
+		orders = append(orders, o.view())
+	}
+	return http.StatusOK, map[string]interface{}{"orders": nonNil(orders), "lastTransactionID": x.a.lastTransactionID()}
+}
+
+func decodeOrderRequest(body []byte) (*orderRequest, error) {
+	var req struct {
+		Order *orderRequest `json:"order"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Order == nil {
+		return nil, errMissingOrder
+	}
+	return req.Order, nil
+}
+
+var errMissingOrder = errors.New("order is missing")
+
+func (x *exchange) createOrder(body []byte) (int, interface{}) {
+	r, err := decodeOrderRequest(body)
+	if err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid JSON: "+err.Error())
+	}
+	o, reason := x.newOrder(r, nil)
+	if reason != "" {
+		return x.reject(r, reason)
+	}
+	create := x.submit(o, "CLIENT_ORDER")
+	x.a.evaluate(x.now, x.m)
+	fill, cancel := x.outcome(o)
+	return http.StatusCreated, x.response(map[string]transaction{
+		"orderCreateTransaction": create,
+		"orderFillTransaction":   fill,
+		"orderCancelTransaction": cancel,
+	})
+}
+
+func (x *exchange) replaceOrder(specifier string, body []byte) (int, interface{}) {
+	old := x.a.findOrder(specifier)
+	if old == nil || old.state != "PENDING" {
+		return orderNotFound(specifier)
+	}
+	r, err := decodeOrderRequest(body)
+	if err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid JSON: "+err.Error())
+	}
+	o, reason := x.newOrder(r, old)
+	if reason == "" && o.typ != old.typ {
+		reason = "REPLACING_ORDER_INVALID"
+	}
+	if reason != "" {
+		return x.reject(r, reason)
+	}
+	cancel := x.cancel(old, "CLIENT_REQUEST_REPLACED")
+	o.replacesOrderID = old.id
+	create := x.submit(o, "REPLACEMENT")
+	old.replacedByOrderID = o.id
+	x.a.evaluate(x.now, x.m)
+	fill, replacingCancel := x.outcome(o)
+	return http.StatusCreated, x.response(map[string]transaction{
+		"orderCancelTransaction":          cancel,
+		"orderCreateTransaction":          create,
+		"orderFillTransaction":            fill,
+		"replacingOrderCancelTransaction": replacingCancel,
+	})
+}
+
+func (x *exchange) routeTrades(method string, rest []string, q values, body []byte) (int, interface{}) {
+	switch {
+	case len(rest) == 1 && method == http.MethodGet:
+		return x.listTrades(rest[0] == "openTrades", q)
+	case len(rest) == 2 && method == http.MethodGet:
+		t := x.a.findTrade(rest[1])
+		if t == nil {
+			return tradeNotFound(rest[1])
+		}
+		return http.StatusOK, map[string]interface{}{"trade": x.a.tradeView(t, x.m), "lastTransactionID": x.a.lastTransactionID()}
+	case len(rest) == 3 && rest[2] == "close" && method == http.MethodPut:
+		return x.closeTrade(rest[1], body)
+	}
+	return notFound()
+}
+
+func tradeNotFound(specifier string) (int, interface{}) {
+	return http.StatusNotFound, errorBody("TRADE_DOESNT_EXIST", "The trade "+specifier+" does not exist")
+}
+
+func (x *exchange) listTrades(open bool, q values) (int, interface{}) {
+	state := q.get("state")
+	if state == "" || open {
+		state = "OPEN"
+	}
+	count, status, resp := countParam(q)
+	if status != 0 {
+		return status, resp
+	}
+	if open {
+		count = math.MaxInt32
+	}
+	ids := idsParam(q)
+	var trades []map[string]interface{}
+	for i := len(x.a.trades) - 1; i >= 0 && len(trades) < count; i-- {
+		t := x.a.trades[i]
+		if (state != "ALL" && t.state != state) ||
+			(q.get("instrument") != "" && t.instrument != q.get("instrument")) ||
+			(ids != nil && !ids[t.id]) ||
+			(q.get("beforeID") != "" && mustAtoi(t.id) >= mustAtoi(q.get("beforeID"))) {
+			continue
+		}
+		trades = append(trades, x.a.tradeView(t, x.m))
+	}
+	return http.StatusOK, map[string]interface{}{"trades": nonNil(trades), "lastTransactionID": x.a.lastTransactionID()}
+}
+
+func (x *exchange) closeTrade(specifier string, body []byte) (int, interface{}) {
+	t := x.a.findTrade(specifier)
+	if t == nil || t.state != "OPEN" {
+		return tradeNotFound(specifier)
+	}
+	var req struct {
+		Units string `json:"units"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return http.StatusBadRequest, errorBody("", "Invalid JSON: "+err.Error())
+		}
+	}
+	units := math.Abs(t.currentUnits)
+	if req.Units != "" && req.Units != "ALL" {
+		u, err := strconv.ParseFloat(req.Units, 64)
+		if err != nil || u <= 0 {
+			return http.StatusBadRequest, errorBody("CLOSE_TRADE_UNITS_INVALID", "Invalid value specified for 'units'")
+		}
+		if u > units {
+			return http.StatusBadRequest, errorBody("CLOSE_TRADE_UNITS_EXCEED_TRADE_SIZE", "The units exceed the size of the trade")
+		}
+		units = u
+	}
+	units = -math.Copysign(units, t.currentUnits)
+	o := &order{typ: "MARKET", instrument: t.instrument, units: units, timeInForce: "FOK", positionFill: "REDUCE_ONLY", tradeID: t.id}
+	create := x.create(o, "TRADE_CLOSE")
+	create["tradeClose"] = map[string]interface{}{"tradeID": t.id, "units": formatCloseUnits(req.Units)}
+	fill := x.fill(o, units, []*trade{t}, "MARKET_ORDER_TRADE_CLOSE")
+	return http.StatusOK, x.response(map[string]transaction{
+		"orderCreateTransaction": create,
+		"orderFillTransaction":   fill,
+	})
+}
+
+func formatCloseUnits(units string) string {
+	if units == "" {
+		return "ALL"
+	}
+	return units
+}
+
+func (x *exchange) routePositions(method string, rest []string, body []byte) (int, interface{}) {
+	switch {
+	case len(rest) == 1 && method == http.MethodGet:
+		var positions []map[string]interface{}
+		for _, p := range x.a.positions(x.m) {
+			if rest[0] == "openPositions" && !p.open() {
+				continue
+			}
+			positions = append(positions, p.view())
+		}
+		return http.StatusOK, map[string]interface{}{"positions": nonNil(positions), "lastTransactionID": x.a.lastTransactionID()}
+	case len(rest) == 2 && rest[0] == "positions" && method == http.MethodGet:
+		p := x.position(rest[1])
+		return http.StatusOK, map[string]interface{}{"position": p.view(), "lastTransactionID": x.a.lastTransactionID()}
+	case len(rest) == 3 && rest[0] == "positions" && rest[2] == "close" && method == http.MethodPut:
+		return x.closePosition(rest[1], body)
+	}
+	return notFound()
+}
+
+func (x *exchange) position(instrument string) *position {
+	for _, p := range x.a.positions(x.m) {
+		if p.instrument == instrument {
+			return p
+		}
+	}
+	return &position{instrument: instrument}
+}
+
+func (x *exchange) closePosition(instrument string, body []byte) (int, interface{}) {
+	var req struct {
+		LongUnits  string `json:"longUnits"`
+		ShortUnits string `json:"shortUnits"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid JSON: "+err.Error())
+	}
+	if _, ok := x.m.quote(instrument); !ok {
+		return http.StatusBadRequest, errorBody("INSTRUMENT_NOT_TRADEABLE", "Invalid value specified for 'instrument'")
+	}
+	txs := map[string]transaction{}
+	closed := false
+	for _, side := range []struct {
+		key   string
+		units string
+		long  bool
+	}{{"long", req.LongUnits, true}, {"short", req.ShortUnits, false}} {
+		if side.units == "" || side.units == "NONE" {
+			continue
+		}
+		var trades []*trade
+		var open float64
+		for _, t := range x.a.openTrades(instrument) {
+			if (t.currentUnits > 0) == side.long {
+				trades = append(trades, t)
+				open += t.currentUnits
+			}
+		}
+		units := open
+		if side.units != "ALL" {
+			u, err := strconv.ParseFloat(side.units, 64)
+			if err != nil || u <= 0 {
+				return http.StatusBadRequest, errorBody("CLOSEOUT_POSITION_UNITS_INVALID", "Invalid value specified for '"+side.key+"Units'")
+			}
+			units = math.Copysign(u, open)
+		}
+		if open == 0 || math.Abs(units) > math.Abs(open) {
+			reject := x.a.newTransaction("MARKET_ORDER_REJECT", x.now)
+			reject["instrument"] = instrument
+			reject["rejectReason"] = "CLOSEOUT_POSITION_DOESNT_EXIST"
+			resp := x.response(map[string]transaction{side.key + "OrderRejectTransaction": reject})
+			resp["errorCode"] = "CLOSEOUT_POSITION_DOESNT_EXIST"
+			resp["errorMessage"] = "The Position requested to be closed out does not exist"
+			return http.StatusBadRequest, resp
+		}
+		o := &order{typ: "MARKET", instrument: instrument, units: -units, timeInForce: "FOK", positionFill: "REDUCE_ONLY"}
+		create := x.create(o, "POSITION_CLOSEOUT")
+		create[side.key+"PositionCloseout"] = map[string]interface{}{"instrument": instrument, "units": side.units}
+		txs[side.key+"OrderCreateTransaction"] = create
+		fill := x.fill(o, -units, trades, "MARKET_ORDER_POSITION_CLOSEOUT")
+		if fill["type"] == "ORDER_FILL" {
+			txs[side.key+"OrderFillTransaction"] = fill
+		} else {
+			txs[side.key+"OrderCancelTransaction"] = fill
+		}
+		closed = true
+	}
+	if !closed {
+		return http.StatusBadRequest, errorBody("", "Either longUnits or shortUnits must be specified")
+	}
+	return http.StatusOK, x.response(txs)
+}
+
+// countParam returns the count query parameter, or the response to an invalid one.
+func countParam(q values) (int, int, interface{}) {
+	c := q.get("count")
+	if c == "" {
+		return 50, 0, nil
+	}
+	count, err := strconv.Atoi(c)
+	if err != nil || count <= 0 || count > 500 {
+		return 0, http.StatusBadRequest, errorBody("", "Invalid value specified for 'count'")
+	}
+	return count, 0, nil
+}
+
+func idsParam(q values) map[string]bool {
+	if q.get("ids") == "" {
+		return nil
+	}
+	ids := map[string]bool{}
+	for _, id := range strings.Split(q.get("ids"), ",") {
+		ids[id] = true
+	}
+	return ids
+}
+
+func mustAtoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
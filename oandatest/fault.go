@@ -0,0 +1,59 @@
+package oandatest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Fault is a failure the server injects into matching requests.
+type Fault struct {
+	// Method matches the request method. Empty matches any method.
+	Method string
+	// Path matches requests whose path contains it. Empty matches any path.
+	Path string
+	// Times is the number of requests the fault applies to. Zero applies to every request.
+	Times int
+
+	// Latency delays the response.
+	Latency time.Duration
+	// Status responds with the status code instead of handling the request, e.g. 429 or 503.
+	Status int
+	// Malformed handles the request and truncates the JSON of its response.
+	Malformed bool
+}
+
+func (f *Fault) matches(r *http.Request) bool {
+	return (f.Method == "" || f.Method == r.Method) && strings.Contains(r.URL.Path, f.Path)
+}
+
+// Inject adds a fault. Faults are applied in the order they are added, one per request.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// takeFault returns the first fault matching r and consumes it. It is called with s.mu held.
+func (s *Server) takeFault(r *http.Request) *Fault {
+	for i, f := range s.faults {
+		if !f.matches(r) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
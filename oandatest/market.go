@@ -0,0 +1,401 @@
+package oandatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// Quote is a price of an instrument.
+type Quote struct {
+	// Time is the time of the price. Zero means the time it is set.
+	Time time.Time
+	Bid  oanda.Price
+	Ask  oanda.Price
+}
+
+func (q Quote) mid() float64 {
+	return (float64(q.Bid) + float64(q.Ask)) / 2
+}
+
+// OHLC is the open, high, low and close prices of a candle.
+type OHLC struct {
+	O, H, L, C oanda.Price
+}
+
+// Candle is a candlestick the server serves. At least one of Mid, Bid and Ask must be set;
+// a missing mid is the average of bid and ask, and a missing bid or ask is the mid.
+type Candle struct {
+	Time     time.Time
+	Volume   int
+	Complete bool
+	Mid      *OHLC
+	Bid      *OHLC
+	Ask      *OHLC
+}
+
+const (
+	defaultCandleCount = 500
+	maxCandleCount     = 5000
+)
+
+type market struct {
+	quotes  map[string]Quote
+	scripts map[string][]Quote
+	candles map[string]map[string][]Candle
+	books   map[string][]oanda.OrderBook
+}
+
+func newMarket() *market {
+	return &market{
+		quotes:  map[string]Quote{},
+		scripts: map[string][]Quote{},
+		candles: map[string]map[string][]Candle{},
+		books:   map[string][]oanda.OrderBook{},
+	}
+}
+
+func (m *market) quote(instrument string) (Quote, bool) {
+	q, ok := m.quotes[instrument]
+	return q, ok
+}
+
+// SetPrice sets the current price of instrument and fills, triggers and expires orders accordingly.
+func (s *Server) SetPrice(instrument oanda.Instrument, bid, ask oanda.Price) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setQuote(string(instrument), Quote{Bid: bid, Ask: ask})
+}
+
+// ScriptPrices queues prices of instrument. Every pricing request for the instrument, and every Step,
+// moves the current price to the next queued one. The first one is applied at once
+// when the instrument has no price yet.
+func (s *Server) ScriptPrices(instrument oanda.Instrument, quotes ...Quote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := string(instrument)
+	s.market.scripts[name] = append(s.market.scripts[name], quotes...)
+	if _, ok := s.market.quotes[name]; !ok {
+		s.advance(name)
+	}
+}
+
+// Step moves every scripted instrument to its next price.
+// It returns false when no script has prices left.
+func (s *Server) Step() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	advanced := false
+	for name := range s.market.scripts {
+		if s.advance(name) {
+			advanced = true
+		}
+	}
+	return advanced
+}
+
+// Price returns the current price of instrument.
+func (s *Server) Price(instrument oanda.Instrument) (Quote, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.market.quote(string(instrument))
+}
+
+func (s *Server) advance(instrument string) bool {
+	script := s.market.scripts[instrument]
+	if len(script) == 0 {
+		return false
+	}
+	s.market.scripts[instrument] = script[1:]
+	s.setQuote(instrument, script[0])
+	return true
+}
+
+func (s *Server) setQuote(instrument string, q Quote) {
+	now := s.now().UTC()
+	if q.Time.IsZero() {
+		q.Time = now
+	}
+	s.market.quotes[instrument] = q
+	for _, id := range s.accountIDs {
+		a := s.accounts[id]
+		a.batchID = ""
+		a.evaluate(now, s.market)
+	}
+}
+
+// AddCandles adds candles of instrument in granularity, e.g. "M1" or "H1".
+func (s *Server) AddCandles(instrument oanda.Instrument, granularity string, candles ...Candle) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byGranularity, ok := s.market.candles[string(instrument)]
+	if !ok {
+		byGranularity = map[string][]Candle{}
+		s.market.candles[string(instrument)] = byGranularity
+	}
+	cs := append(byGranularity[granularity], candles...)
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Time.Before(cs[j].Time) })
+	byGranularity[granularity] = cs
+}
+
+// AddOrderBook adds a snapshot of an order book.
+// A request for a time gets the latest snapshot at or before the time.
+func (s *Server) AddOrderBook(book oanda.OrderBook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	books := append(s.market.books[string(book.Instrument)], book)
+	sort.SliceStable(books, func(i, j int) bool { return books[i].Time.Before(books[j].Time) })
+	s.market.books[string(book.Instrument)] = books
+}
+
+// pricing serves the prices of the instruments in the query, advancing their scripts,
+// and the conversion factors of their currencies to currency when includeHomeConversions is true.
+func (s *Server) pricing(q values, now time.Time, currency string) (int, interface{}) {
+	names := strings.Split(q.get("instruments"), ",")
+	var prices []map[string]interface{}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		s.advance(name)
+		quote, ok := s.market.quote(name)
+		if !ok {
+			return http.StatusBadRequest, errorBody("", "Invalid value specified for 'instruments': "+name)
+		}
+		prices = append(prices, priceObject(name, quote))
+	}
+	if len(prices) == 0 {
+		return http.StatusBadRequest, errorBody("", "Invalid value specified for 'instruments'")
+	}
+	body := map[string]interface{}{"prices": prices, "time": now}
+	if q.get("includeHomeConversions") == "true" {
+		body["homeConversions"] = s.market.homeConversions(names, currency)
+	}
+	return http.StatusOK, body
+}
+
+// homeConversions returns the conversion factors to home of the currencies of instruments.
+// A currency without a quote against home converts at 1.
+func (m *market) homeConversions(instruments []string, home string) []map[string]interface{} {
+	var conversions []map[string]interface{}
+	seen := map[string]bool{}
+	for _, name := range instruments {
+		for _, currency := range strings.SplitN(name, "_", 2) {
+			if currency == "" || seen[currency] {
+				continue
+			}
+			seen[currency] = true
+			gain, loss := 1.0, 1.0
+			if q, ok := m.quote(currency + "_" + home); ok {
+				gain, loss = float64(q.Bid), float64(q.Ask)
+			} else if q, ok := m.quote(home + "_" + currency); ok {
+				gain, loss = 1/float64(q.Ask), 1/float64(q.Bid)
+			}
+			conversions = append(conversions, map[string]interface{}{
+				"currency":      currency,
+				"accountGain":   strconv.FormatFloat(gain, 'f', -1, 64),
+				"accountLoss":   strconv.FormatFloat(loss, 'f', -1, 64),
+				"positionValue": strconv.FormatFloat((gain+loss)/2, 'f', -1, 64),
+			})
+		}
+	}
+	return conversions
+}
+
+// instruments serves the details of the instruments in the query, or of every quoted instrument.
+func (m *market) instruments(q values) (int, interface{}) {
+	var names []string
+	if q.get("instruments") != "" {
+		names = strings.Split(q.get("instruments"), ",")
+	} else {
+		for name := range m.quotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	var instruments []map[string]interface{}
+	for _, name := range names {
+		pipLocation, precision := -4, 5
+		if strings.HasSuffix(name, "_JPY") {
+			pipLocation, precision = -2, 3
+		}
+		instruments = append(instruments, map[string]interface{}{
+			"name":                        name,
+			"type":                        "CURRENCY",
+			"displayName":                 strings.Replace(name, "_", "/", 1),
+			"pipLocation":                 pipLocation,
+			"displayPrecision":            precision,
+			"tradeUnitsPrecision":         0,
+			"minimumTradeSize":            "1",
+			"maximumTrailingStopDistance": "1.00000",
+			"minimumTrailingStopDistance": "0.00050",
+			"maximumPositionSize":         "0",
+			"maximumOrderUnits":           "100000000",
+			"marginRate":                  "0.04",
+		})
+	}
+	return http.StatusOK, map[string]interface{}{"instruments": instruments}
+}
+
+func priceObject(instrument string, quote Quote) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "PRICE",
+		"instrument":  instrument,
+		"time":        quote.Time,
+		"tradeable":   true,
+		"status":      "tradeable",
+		"bids":        []map[string]interface{}{{"price": formatPrice(instrument, float64(quote.Bid)), "liquidity": 10000000}},
+		"asks":        []map[string]interface{}{{"price": formatPrice(instrument, float64(quote.Ask)), "liquidity": 10000000}},
+		"closeoutBid": formatPrice(instrument, float64(quote.Bid)),
+		"closeoutAsk": formatPrice(instrument, float64(quote.Ask)),
+	}
+}
+
+func (m *market) serveCandles(instrument string, q values) (int, interface{}) {
+	granularity := q.get("granularity")
+	if granularity == "" {
+		granularity = "S5"
+	}
+	components := q.get("price")
+	if components == "" {
+		components = "M"
+	}
+	from, err := parseTimeParam(q.get("from"))
+	if err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid value specified for 'from'")
+	}
+	to, err := parseTimeParam(q.get("to"))
+	if err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid value specified for 'to'")
+	}
+	count := defaultCandleCount
+	if c := q.get("count"); c != "" {
+		if from != nil && to != nil {
+			return http.StatusBadRequest, errorBody("", "Cannot specify 'count' with both 'from' and 'to'")
+		}
+		count, err = strconv.Atoi(c)
+		if err != nil || count <= 0 || count > maxCandleCount {
+			return http.StatusBadRequest, errorBody("", "Invalid value specified for 'count'")
+		}
+	}
+
+	var selected []Candle
+	for _, c := range m.candles[instrument][granularity] {
+		if (from == nil || !c.Time.Before(*from)) && (to == nil || c.Time.Before(*to)) {
+			selected = append(selected, c)
+		}
+	}
+	if from == nil || to == nil {
+		if len(selected) > count {
+			if from != nil {
+				selected = selected[:count]
+			} else {
+				selected = selected[len(selected)-count:]
+			}
+		}
+	}
+
+	candles := make([]map[string]interface{}, 0, len(selected))
+	for _, c := range selected {
+		candle := map[string]interface{}{"time": c.Time.UTC(), "volume": c.Volume, "complete": c.Complete}
+		for _, component := range components {
+			var key string
+			var ohlc *OHLC
+			switch component {
+			case 'M':
+				key, ohlc = "mid", c.midOHLC()
+			case 'B':
+				key, ohlc = "bid", c.Bid
+			case 'A':
+				key, ohlc = "ask", c.Ask
+			default:
+				return http.StatusBadRequest, errorBody("", "Invalid value specified for 'price'")
+			}
+			if ohlc == nil {
+				ohlc = c.midOHLC()
+			}
+			if ohlc != nil {
+				candle[key] = map[string]string{
+					"o": formatPrice(instrument, float64(ohlc.O)),
+					"h": formatPrice(instrument, float64(ohlc.H)),
+					"l": formatPrice(instrument, float64(ohlc.L)),
+					"c": formatPrice(instrument, float64(ohlc.C)),
+				}
+			}
+		}
+		candles = append(candles, candle)
+	}
+	return http.StatusOK, map[string]interface{}{"instrument": instrument, "granularity": granularity, "candles": candles}
+}
+
+func (c *Candle) midOHLC() *OHLC {
+	if c.Mid != nil {
+		return c.Mid
+	}
+	if c.Bid == nil || c.Ask == nil {
+		return nil
+	}
+	return &OHLC{
+		O: (c.Bid.O + c.Ask.O) / 2,
+		H: (c.Bid.H + c.Ask.H) / 2,
+		L: (c.Bid.L + c.Ask.L) / 2,
+		C: (c.Bid.C + c.Ask.C) / 2,
+	}
+}
+
+func (m *market) serveOrderBook(instrument string, q values) (int, interface{}) {
+	at, err := parseTimeParam(q.get("time"))
+	if err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid value specified for 'time'")
+	}
+	books := m.books[instrument]
+	for i := len(books) - 1; i >= 0; i-- {
+		if at != nil && books[i].Time.After(*at) {
+			continue
+		}
+		b, err := json.Marshal(&books[i])
+		if err != nil {
+			return http.StatusInternalServerError, errorBody("", err.Error())
+		}
+		return http.StatusOK, map[string]json.RawMessage{"orderBook": b}
+	}
+	return http.StatusNotFound, errorBody("", "No order book found for "+instrument)
+}
+
+// parseTimeParam parses a time in RFC3339 or UNIX format. It returns nil for an empty string.
+func parseTimeParam(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return &t, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(0, int64(f*float64(time.Second))).UTC()
+	return &t, nil
+}
+
+// formatPrice formats a price with the precision of instrument.
+func formatPrice(instrument string, p float64) string {
+	precision := 5
+	if strings.HasSuffix(instrument, "_JPY") {
+		precision = 3
+	}
+	return strconv.FormatFloat(p, 'f', precision, 64)
+}
+
+func formatAmount(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
+
+func formatUnits(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,289 @@
+// Package oandatest provides an in-memory fake of OANDA v3 REST API for tests.
+//
+// The fake keeps accounts, orders, trades, positions and transactions in memory and
+// moves them through the same states OANDA does: market orders fill at the current price,
+// pending orders fill when the price scripted with SetPrice or ScriptPrices reaches them,
+// fills open trades or reduce opposite ones first in first out, and dependent orders
+// (take profit, stop loss and trailing stop loss) close their trades.
+// Profit and loss is calculated in the quote currency and treated as the account currency.
+//
+//	s := oandatest.NewServer()
+//	defer s.Close()
+//	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+//	client := s.NewClient()
+package oandatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+const (
+	// DefaultAPIKey is the API key the server accepts unless WithAPIKey is given.
+	DefaultAPIKey = "oandatest-api-key"
+	// DefaultAccountID is the id of the account NewServer creates.
+	DefaultAccountID = "101-009-0000000-001"
+	// DefaultBalance is the balance of the account NewServer creates.
+	DefaultBalance = 1000000
+	// DefaultCurrency is the currency of the account NewServer creates.
+	DefaultCurrency = "JPY"
+	// DefaultMarginRate is the margin rate of accounts.
+	DefaultMarginRate = 0.04
+)
+
+// Server is a fake OANDA v3 REST API server.
+// All methods are safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	apiKey string
+	now    func() time.Time
+
+	mu         sync.Mutex
+	accounts   map[string]*account
+	accountIDs []string
+	market     *market
+	faults     []*Fault
+	requests   []Request
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAPIKey makes the server accept apiKey instead of DefaultAPIKey.
+func WithAPIKey(apiKey string) Option {
+	return func(s *Server) {
+		s.apiKey = apiKey
+	}
+}
+
+// WithClock makes the server use now as the clock of transactions, prices and GTD expiry.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// Request is a request the server received.
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Body   []byte
+}
+
+// NewServer starts a server with an account of DefaultAccountID.
+// The caller should call Close when finished.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		apiKey:   DefaultAPIKey,
+		now:      time.Now,
+		accounts: map[string]*account{},
+		market:   newMarket(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.AddAccount(DefaultAccountID, DefaultCurrency, DefaultBalance)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewClient returns a client of the account of DefaultAccountID which sends requests to the server.
+func (s *Server) NewClient(opts ...oanda.Option) *oanda.Client {
+	return s.NewClientFor(DefaultAccountID, opts...)
+}
+
+// NewClientFor returns a client of the account which sends requests to the server.
+func (s *Server) NewClientFor(accountID string, opts ...oanda.Option) *oanda.Client {
+	opts = append([]oanda.Option{oanda.WithEndpoint(s.URL)}, opts...)
+	return oanda.NewClient(accountID, s.apiKey, "Practice", opts...)
+}
+
+// AddAccount adds an account, or resets the account when it exists.
+func (s *Server) AddAccount(id, currency string, balance float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.accounts[id]; !ok {
+		s.accountIDs = append(s.accountIDs, id)
+	}
+	s.accounts[id] = newAccount(id, currency, balance, s.now().UTC())
+}
+
+// Requests returns the requests the server received in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorBody("", "failed to read request body"))
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Body: body})
+	fault := s.takeFault(r)
+	s.mu.Unlock()
+
+	if fault != nil {
+		if fault.Latency > 0 {
+			select {
+			case <-time.After(fault.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault.Status != 0 {
+			if fault.Status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeJSON(w, fault.Status, errorBody("", http.StatusText(fault.Status)))
+			return
+		}
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.apiKey {
+		writeJSON(w, http.StatusUnauthorized, errorBody("", "Insufficient authorization to perform request."))
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/stream") && r.Method == http.MethodGet {
+		s.serveStream(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	status, resp := s.route(r.Method, r.URL.Path, r.URL.Query(), body)
+	s.mu.Unlock()
+
+	if fault != nil && fault.Malformed {
+		rec := httptest.NewRecorder()
+		writeJSON(rec, status, resp)
+		b := rec.Body.Bytes()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(b[:len(b)/2])
+		return
+	}
+	writeJSON(w, status, resp)
+}
+
+// route dispatches a request to its handler. It is called with s.mu held.
+func (s *Server) route(method, path string, query map[string][]string, body []byte) (int, interface{}) {
+	q := values(query)
+	seg := strings.Split(strings.Trim(path, "/"), "/")
+	if len(seg) < 2 || seg[0] != "v3" {
+		return notFound()
+	}
+	switch seg[1] {
+	case "instruments":
+		if len(seg) != 4 || method != http.MethodGet {
+			return notFound()
+		}
+		switch seg[3] {
+		case "candles":
+			return s.market.serveCandles(seg[2], q)
+		case "orderBook":
+			return s.market.serveOrderBook(seg[2], q)
+		}
+		return notFound()
+	case "accounts":
+		if len(seg) == 2 {
+			if method != http.MethodGet {
+				return notFound()
+			}
+			return s.listAccounts()
+		}
+	default:
+		return notFound()
+	}
+
+	a, ok := s.accounts[seg[2]]
+	if !ok {
+		return http.StatusBadRequest, errorBody("", "Invalid value specified for 'accountID'")
+	}
+	a.batchID = ""
+	a.evaluate(s.now().UTC(), s.market)
+	a.batchID = ""
+	rest := seg[3:]
+	if len(rest) == 0 {
+		if method != http.MethodGet {
+			return notFound()
+		}
+		return http.StatusOK, map[string]interface{}{"account": a.details(s.market), "lastTransactionID": a.lastTransactionID()}
+	}
+
+	e := &exchange{a: a, m: s.market, now: s.now().UTC()}
+	switch {
+	case method == http.MethodGet && len(rest) == 1 && rest[0] == "summary":
+		return http.StatusOK, map[string]interface{}{"account": a.summary(s.market), "lastTransactionID": a.lastTransactionID()}
+	case method == http.MethodGet && len(rest) == 1 && rest[0] == "pricing":
+		return s.pricing(q, e.now, a.currency)
+	case method == http.MethodGet && len(rest) == 1 && rest[0] == "instruments":
+		return s.market.instruments(q)
+	case rest[0] == "orders" || rest[0] == "pendingOrders":
+		return e.routeOrders(method, rest, q, body)
+	case rest[0] == "trades" || rest[0] == "openTrades":
+		return e.routeTrades(method, rest, q, body)
+	case rest[0] == "positions" || rest[0] == "openPositions":
+		return e.routePositions(method, rest, body)
+	case rest[0] == "transactions" && method == http.MethodGet:
+		return a.routeTransactions(rest, q, s.URL+path)
+	case rest[0] == "instruments" && len(rest) == 3 && rest[2] == "candles" && method == http.MethodGet:
+		return s.market.serveCandles(rest[1], q)
+	}
+	return notFound()
+}
+
+func (s *Server) listAccounts() (int, interface{}) {
+	var accounts []map[string]interface{}
+	for _, id := range s.accountIDs {
+		accounts = append(accounts, map[string]interface{}{"id": id, "tags": []string{}})
+	}
+	return http.StatusOK, map[string]interface{}{"accounts": accounts}
+}
+
+func notFound() (int, interface{}) {
+	return http.StatusNotFound, errorBody("", "The requested resource could not be found.")
+}
+
+func errorBody(code, message string) map[string]interface{} {
+	body := map[string]interface{}{"errorMessage": message}
+	if code != "" {
+		body["errorCode"] = code
+	}
+	return body
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		status = http.StatusInternalServerError
+		buf.Reset()
+		buf.WriteString(`{"errorMessage":"failed to encode response"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// values is url.Values without the import of net/url in handlers.
+type values map[string][]string
+
+func (v values) get(key string) string {
+	if vs := v[key]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+package oandatest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+func newServer(t *testing.T) (*oandatest.Server, *oanda.Client) {
+	t.Helper()
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	return s, s.NewClient()
+}
+
+func marketOrder(units oanda.Unit) oanda.Order {
+	return oanda.Order{
+		Type:        oanda.OrderTypeMarket,
+		Instrument:  oanda.InstrumentUSDJPY,
+		Units:       units,
+		TimeInForce: oanda.TimeInForceFOK,
+	}
+}
+
+func TestMarketOrderFillsAtCurrentPrice(t *testing.T) {
+	_, client := newServer(t)
+	resp, err := client.SubmitOrder(marketOrder(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Fill == nil || resp.Fill.Price != 105.009 {
+		t.Fatalf("fill = %+v, want a fill at the ask", resp.Fill)
+	}
+	trades, err := client.FetchOpenTrades()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].CurrentUnits != 100 || trades[0].Price != 105.009 {
+		t.Errorf("trades = %+v", trades)
+	}
+}
+
+func TestPendingOrderFillsOnScriptedPrices(t *testing.T) {
+	s, client := newServer(t)
+	err := client.CreateOrder(oanda.Order{
+		Type:             oanda.OrderTypeLimit,
+		Instrument:       oanda.InstrumentUSDJPY,
+		Units:            100,
+		Price:            104.5,
+		TimeInForce:      oanda.TimeInForceGTC,
+		TakeProfitOnFill: &oanda.OnFill{Price: 105.5, TimeInForce: oanda.TimeInForceGTC},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := client.FetchOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].State != "PENDING" {
+		t.Fatalf("orders = %+v, want the pending limit order", orders)
+	}
+
+	s.ScriptPrices(oanda.InstrumentUSDJPY,
+		oandatest.Quote{Bid: 104.8, Ask: 104.808},
+		oandatest.Quote{Bid: 104.49, Ask: 104.498},
+		oandatest.Quote{Bid: 105.6, Ask: 105.608},
+	)
+	s.Step()
+	if trades, _ := client.FetchOpenTrades(); len(trades) != 0 {
+		t.Fatalf("limit order filled above its price: %+v", trades)
+	}
+	s.Step()
+	trades, err := client.FetchOpenTrades()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].TakeProfitOrderID == "" {
+		t.Fatalf("trades = %+v, want a trade with a take profit", trades)
+	}
+	if orders, _ := client.FetchOrders(); len(orders) != 1 || orders[0].Type != oanda.OrderTypeTakeProfit {
+		t.Fatalf("orders = %+v, want the take profit", orders)
+	}
+	s.Step()
+	if trades, _ := client.FetchOpenTrades(); len(trades) != 0 {
+		t.Errorf("take profit did not close the trade: %+v", trades)
+	}
+	if s.Step() {
+		t.Error("Step advances after the script ran out")
+	}
+}
+
+func TestClosePosition(t *testing.T) {
+	s, client := newServer(t)
+	for _, units := range []oanda.Unit{100, 50} {
+		if err := client.CreateOrder(marketOrder(units)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.101, 105.109)
+
+	fills, err := client.ClosePosition(oanda.InstrumentUSDJPY, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills) != 1 || fills[0].Units != -150 || fills[0].Price != 105.101 {
+		t.Fatalf("fills = %+v, want a fill of -150 at the bid", fills)
+	}
+	if want := 150 * (105.101 - 105.009); fills[0].PL < want-1e-6 || fills[0].PL > want+1e-6 {
+		t.Errorf("PL = %v, want %v", fills[0].PL, want)
+	}
+	if positions, _ := client.FetchOpenPositions(); len(positions) != 0 {
+		t.Errorf("positions = %+v, want none", positions)
+	}
+	if _, err := client.ClosePosition(oanda.InstrumentUSDJPY, true, false); err == nil {
+		t.Error("closing a closed position succeeds")
+	}
+}
+
+func TestInjectStatus(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		s, client := newServer(t)
+		s.Inject(oandatest.Fault{Method: http.MethodPost, Path: "/orders", Status: status, Times: 1})
+
+		err := client.CreateOrder(marketOrder(100))
+		var apiErr *oanda.APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != status {
+			t.Fatalf("err = %v, want HTTP %d", err, status)
+		}
+		if trades, _ := client.FetchOpenTrades(); len(trades) != 0 {
+			t.Fatalf("failed request opened trades: %+v", trades)
+		}
+		// the fault applies once.
+		if err := client.CreateOrder(marketOrder(100)); err != nil {
+			t.Fatalf("second request failed: %v", err)
+		}
+	}
+}
+
+func TestInjectMalformed(t *testing.T) {
+	s, client := newServer(t)
+	if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentUSDJPY, Units: 100, Price: 104, TimeInForce: oanda.TimeInForceGTC}); err != nil {
+		t.Fatal(err)
+	}
+	s.Inject(oandatest.Fault{Method: http.MethodGet, Path: "/orders", Malformed: true, Times: 1})
+	if _, err := client.FetchOrders(); err == nil {
+		t.Fatal("truncated response is decoded")
+	}
+	orders, err := client.FetchOrders()
+	if err != nil || len(orders) != 1 {
+		t.Errorf("orders after the fault = %+v, %v", orders, err)
+	}
+}
+
+func TestInjectLatency(t *testing.T) {
+	s := oandatest.NewServer()
+	defer s.Close()
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	s.Inject(oandatest.Fault{Path: "/orders", Latency: 200 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := s.NewClient().FetchOrders(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("responded in %s, want after the latency", elapsed)
+	}
+
+	impatient := s.NewClient(oanda.WithHTTPClient(&http.Client{Timeout: 50 * time.Millisecond}))
+	if _, err := impatient.FetchOrders(); err == nil {
+		t.Error("request succeeds although the latency exceeds the timeout")
+	}
+	s.ClearFaults()
+	if _, err := impatient.FetchOrders(); err != nil {
+		t.Errorf("request after ClearFaults failed: %v", err)
+	}
+}
+
+func TestRejectsUnknownAPIKey(t *testing.T) {
+	s, _ := newServer(t)
+	client := oanda.NewClient(oandatest.DefaultAccountID, "wrong", "Practice", oanda.WithEndpoint(s.URL))
+	var apiErr *oanda.APIError
+	if _, err := client.FetchOrders(); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("err = %v, want HTTP 401", err)
+	}
+}
@@ -0,0 +1,100 @@
+package oandatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	streamPollInterval = 10 * time.Millisecond
+	heartbeatInterval  = 5 * time.Second
+)
+
+// serveStream serves the pricing and transaction streams of an account as JSON lines
+// until the client disconnects. Prices are sent when they change and transactions as they are made,
+// with a heartbeat every 5 seconds.
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request) {
+	seg := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(seg) != 5 || seg[0] != "v3" || seg[1] != "accounts" || (seg[3] != "pricing" && seg[3] != "transactions") {
+		writeJSON(w, http.StatusNotFound, errorBody("", "The requested resource does not exist"))
+		return
+	}
+	s.mu.Lock()
+	a, ok := s.accounts[seg[2]]
+	var sent int
+	if ok {
+		sent = a.lastID
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, errorBody("", "Invalid value specified for 'accountID'"))
+		return
+	}
+	var instruments []string
+	if seg[3] == "pricing" {
+		for _, name := range strings.Split(r.URL.Query().Get("instruments"), ",") {
+			if name != "" {
+				instruments = append(instruments, name)
+			}
+		}
+		if len(instruments) == 0 {
+			writeJSON(w, http.StatusBadRequest, errorBody("", "Invalid value specified for 'instruments'"))
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	quotes := map[string]Quote{}
+	poll := time.NewTicker(streamPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		var lines []interface{}
+		s.mu.Lock()
+		for _, name := range instruments {
+			if q, ok := s.market.quote(name); ok && q != quotes[name] {
+				quotes[name] = q
+				lines = append(lines, priceObject(name, q))
+			}
+		}
+		if seg[3] == "transactions" {
+			for ; sent < a.lastID; sent++ {
+				lines = append(lines, a.transactions[sent])
+			}
+		}
+		s.mu.Unlock()
+		for _, line := range lines {
+			if enc.Encode(line) != nil {
+				return
+			}
+		}
+		if len(lines) > 0 && flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-poll.C:
+		case <-heartbeat.C:
+			s.mu.Lock()
+			line := map[string]interface{}{"type": "HEARTBEAT", "time": s.now().UTC()}
+			if seg[3] == "transactions" {
+				line["lastTransactionID"] = a.lastTransactionID()
+			}
+			s.mu.Unlock()
+			if enc.Encode(line) != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package oandatest
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTransactionPageSize = 100
+
+func (a *account) routeTransactions(rest []string, q values, baseURL string) (int, interface{}) {
+	switch {
+	case len(rest) == 1:
+		return a.transactionPages(q, baseURL)
+	case len(rest) == 2 && rest[1] == "idrange":
+		from, to := mustAtoi(q.get("from")), mustAtoi(q.get("to"))
+		if from <= 0 || to < from {
+			return http.StatusBadRequest, errorBody("", "Invalid value specified for 'from' or 'to'")
+		}
+		return a.transactionList(from, to, q.get("type"))
+	case len(rest) == 2 && rest[1] == "sinceid":
+		id, err := strconv.Atoi(q.get("id"))
+		if err != nil || id < 0 {
+			return http.StatusBadRequest, errorBody("", "Invalid value specified for 'id'")
+		}
+		return a.transactionList(id+1, a.lastID, q.get("type"))
+	case len(rest) == 2:
+		id := mustAtoi(rest[1])
+		if id <= 0 || id > a.lastID {
+			return http.StatusNotFound, errorBody("TRANSACTION_DOESNT_EXIST", "The transaction "+rest[1]+" does not exist")
+		}
+		return http.StatusOK, map[string]interface{}{"transaction": a.transactions[id-1], "lastTransactionID": a.lastTransactionID()}
+	}
+	return notFound()
+}
+
+// transactionPages returns the pages of the transactions in the time range of the query,
+// which are URLs of idrange.
+func (a *account) transactionPages(q values, baseURL string) (int, interface{}) {
+	from, err := parseTimeParam(q.get("from"))
+	if err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid value specified for 'from'")
+	}
+	to, err := parseTimeParam(q.get("to"))
+	if err != nil {
+		return http.StatusBadRequest, errorBody("", "Invalid value specified for 'to'")
+	}
+	pageSize := defaultTransactionPageSize
+	if s := q.get("pageSize"); s != "" {
+		pageSize, err = strconv.Atoi(s)
+		if err != nil || pageSize <= 0 || pageSize > 1000 {
+			return http.StatusBadRequest, errorBody("", "Invalid value specified for 'pageSize'")
+		}
+	}
+	types := typeFilter(q.get("type"))
+	var ids []int
+	for i, t := range a.transactions {
+		at := t["time"].(time.Time)
+		if (from != nil && at.Before(*from)) || (to != nil && at.After(*to)) {
+			continue
+		}
+		if types != nil && !types[t["type"].(string)] {
+			continue
+		}
+		ids = append(ids, i+1)
+	}
+	var pages []string
+	for i := 0; i < len(ids); i += pageSize {
+		end := i + pageSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		query := url.Values{"from": {strconv.Itoa(ids[i])}, "to": {strconv.Itoa(ids[end-1])}}
+		if q.get("type") != "" {
+			query.Set("type", q.get("type"))
+		}
+		pages = append(pages, baseURL+"/idrange?"+query.Encode())
+	}
+	resp := map[string]interface{}{
+		"pageSize":          pageSize,
+		"count":             len(ids),
+		"pages":             append([]string{}, pages...),
+		"lastTransactionID": a.lastTransactionID(),
+	}
+	if from != nil {
+		resp["from"] = from.UTC()
+	} else {
+		resp["from"] = a.createdTime
+	}
+	if to != nil {
+		resp["to"] = to.UTC()
+	}
+	return http.StatusOK, resp
+}
+
+func (a *account) transactionList(from, to int, typ string) (int, interface{}) {
+	types := typeFilter(typ)
+	transactions := []transaction{}
+	for id := from; id <= to && id <= a.lastID; id++ {
+		t := a.transactions[id-1]
+		if types == nil || types[t["type"].(string)] {
+			transactions = append(transactions, t)
+		}
+	}
+	return http.StatusOK, map[string]interface{}{"transactions": transactions, "lastTransactionID": a.lastTransactionID()}
+}
+
+// typeFilter returns the set of transaction types of a comma separated list, or nil for all types.
+// ORDER matches every type of order creation and FUNDING matches TRANSFER_FUNDS.
+func typeFilter(s string) map[string]bool {
+	if s == "" {
+		return nil
+	}
+	types := map[string]bool{}
+	for _, t := range strings.Split(s, ",") {
+		switch t {
+		case "ORDER":
+			for typ := range orderTypes {
+				types[typ+"_ORDER"] = true
+			}
+		case "FUNDING":
+			types["TRANSFER_FUNDS"] = true
+		default:
+			types[t] = true
+		}
+	}
+	return types
+}
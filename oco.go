@@ -0,0 +1,254 @@
+package oanda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+// OCO places groups of orders of which the first to fill cancels the others ("one cancels the other").
+// Orders of a group are identified by their client extensions ids, and Run watches the transaction stream
+// for their fills. Fills while the stream is broken are found by fetching the orders when it is reopened.
+// Orders which fail to be cancelled are retried every ReconnectInterval while Run runs.
+type OCO struct {
+	Client *Client
+	// ReconnectInterval is how long to wait before reopening a broken stream or retrying failed cancels.
+	ReconnectInterval time.Duration
+
+	mu         sync.Mutex
+	seq        int
+	groups     map[string][]string // client order ids of the pending orders of a group
+	members    map[string]string   // group of a client order id
+	cancelling map[string]string   // group of a client order id which failed to be cancelled
+}
+
+// NewOCO constructs an OCO with DefaultReconnectInterval.
+func NewOCO(client *Client) *OCO {
+	return &OCO{
+		Client:            client,
+		ReconnectInterval: DefaultReconnectInterval,
+		groups:            map[string][]string{},
+		members:           map[string]string{},
+		cancelling:        map[string]string{},
+	}
+}
+
+// Place creates orders as a group and returns the id of the group. The client extensions ids of the orders
+// are replaced by ids of the group. When an order cannot be created, or one of the group fills before the
+// rest are created, the orders already created are cancelled.
+func (o *OCO) Place(orders ...Order) (string, error) {
+	if len(orders) < 2 {
+		return "", errors.New("an OCO group needs at least 2 orders")
+	}
+	group, ids := o.newGroup(len(orders))
+	for i, order := range orders {
+		ext := ClientExtensions{}
+		if order.ClientExtensions != nil {
+			ext = *order.ClientExtensions
+		}
+		ext.ID = ids[i]
+		order.ClientExtensions = &ext
+		if err := o.Client.CreateOrder(order); err != nil {
+			o.cancel(group, ids[:i])
+			return "", fmt.Errorf("failed to create order %d of OCO group %s: %w", i+1, group, err)
+		}
+		if !o.pending(group) {
+			// the others were cancelled when one filled, possibly before this one was created.
+			o.cancel(group, ids[i:i+1])
+			return group, nil
+		}
+	}
+	return group, nil
+}
+
+func (o *OCO) newGroup(n int) (string, []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seq++
+	group := "oco-" + strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(o.seq)
+	var ids []string
+	for i := 1; i <= n; i++ {
+		id := group + "-" + strconv.Itoa(i)
+		ids = append(ids, id)
+		o.members[id] = group
+	}
+	o.groups[group] = ids
+	return group, ids
+}
+
+func (o *OCO) pending(group string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.groups[group]
+	return ok
+}
+
+// Run watches the transaction stream and cancels the other orders of a group when one fills,
+// until ctx is done.
+func (o *OCO) Run(ctx context.Context) error {
+	go func() {
+		ticker := time.NewTicker(o.ReconnectInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				o.retryCancels()
+			}
+		}
+	}()
+	for {
+		o.reconcile()
+		err := o.Client.StreamTransactions(ctx, func(t Transaction) error {
+			switch t.Type {
+			case "ORDER_FILL":
+				o.filled(t.ClientOrderID)
+			case "ORDER_CANCEL":
+				o.remove(t.ClientOrderID)
+			}
+			return nil
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("transaction stream broke, reconnecting in %s: %v", o.ReconnectInterval, err)
+		if err := util.SleepContext(ctx, o.ReconnectInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// reconcile fetches the orders of the groups and handles the ones which filled or were cancelled,
+// and retries the cancels which failed.
+func (o *OCO) reconcile() {
+	defer o.retryCancels()
+	o.mu.Lock()
+	var ids []string
+	for id := range o.members {
+		ids = append(ids, id)
+	}
+	o.mu.Unlock()
+	for _, id := range ids {
+		order, err := o.Client.FetchOrder(OrderID("@" + id))
+		if err != nil {
+			log.Printf("failed to fetch order @%s: %v", id, err)
+			continue
+		}
+		switch order.State {
+		case "FILLED":
+			o.filled(id)
+		case "CANCELLED":
+			o.remove(id)
+		}
+	}
+}
+
+// retryCancels cancels the orders which failed to be cancelled again, unless they are no longer pending.
+func (o *OCO) retryCancels() {
+	o.mu.Lock()
+	cancelling := map[string]string{}
+	for id, group := range o.cancelling {
+		cancelling[id] = group
+	}
+	o.mu.Unlock()
+	for id, group := range cancelling {
+		order, err := o.Client.FetchOrder(OrderID("@" + id))
+		if err != nil {
+			log.Printf("failed to fetch order @%s of OCO group %s: %v", id, group, err)
+			continue
+		}
+		if order.State == "PENDING" {
+			if err := o.Client.CancelOrder(OrderID("@" + id)); err != nil {
+				log.Printf("failed to cancel order @%s of OCO group %s again: %v", id, group, err)
+				continue
+			}
+		} else if order.State == "FILLED" {
+			log.Printf("order @%s of OCO group %s filled before it was cancelled", id, group)
+		}
+		o.mu.Lock()
+		delete(o.cancelling, id)
+		o.mu.Unlock()
+	}
+}
+
+// Cancelling returns the client order ids of the orders which failed to be cancelled and are retried.
+func (o *OCO) Cancelling() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var ids []string
+	for id := range o.cancelling {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// filled cancels the other orders of the group of the order id.
+func (o *OCO) filled(id string) {
+	o.mu.Lock()
+	if group, ok := o.cancelling[id]; ok {
+		log.Printf("order @%s of OCO group %s filled before it was cancelled", id, group)
+		delete(o.cancelling, id)
+	}
+	group, ok := o.members[id]
+	var others []string
+	for _, m := range o.groups[group] {
+		if m != id {
+			others = append(others, m)
+		}
+	}
+	o.mu.Unlock()
+	if ok {
+		o.cancel(group, others)
+	}
+}
+
+// remove removes the order id from its group.
+func (o *OCO) remove(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.cancelling, id)
+	group, ok := o.members[id]
+	if !ok {
+		return
+	}
+	delete(o.members, id)
+	var rest []string
+	for _, m := range o.groups[group] {
+		if m != id {
+			rest = append(rest, m)
+		}
+	}
+	if len(rest) == 0 {
+		delete(o.groups, group)
+		return
+	}
+	o.groups[group] = rest
+}
+
+// cancel cancels the orders ids of group and forgets the group. The orders which fail to be cancelled
+// are kept to be retried.
+func (o *OCO) cancel(group string, ids []string) {
+	o.mu.Lock()
+	for _, m := range o.groups[group] {
+		delete(o.members, m)
+	}
+	delete(o.groups, group)
+	o.mu.Unlock()
+	for _, id := range ids {
+		if err := o.Client.CancelOrder(OrderID("@" + id)); err != nil {
+			log.Printf("failed to cancel order @%s of OCO group %s, retrying later: %v", id, group, err)
+			o.mu.Lock()
+			o.cancelling[id] = group
+			o.mu.Unlock()
+		}
+	}
+}
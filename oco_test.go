@@ -0,0 +1,120 @@
+package oanda_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+func limitOrder(units oanda.Unit, price oanda.Price) oanda.Order {
+	return oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentUSDJPY, Units: units, Price: price, TimeInForce: oanda.TimeInForceGTC}
+}
+
+// startOCO runs oco until the test ends.
+func startOCO(t *testing.T, oco *oanda.OCO) {
+	t.Helper()
+	oco.ReconnectInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = oco.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+}
+
+// waitOrders waits until the orders of client satisfy done.
+func waitOrders(t *testing.T, client *oanda.Client, done func([]oanda.Order) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		orders, err := client.FetchOrders()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if done(orders) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("orders = %+v", orders)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOCOCancelsTheOthersOnFill(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	oco := oanda.NewOCO(client)
+	startOCO(t, oco)
+	if _, err := oco.Place(limitOrder(100, 104.5), limitOrder(-100, 105.5)); err != nil {
+		t.Fatal(err)
+	}
+	waitOrders(t, client, func(orders []oanda.Order) bool { return len(orders) == 2 })
+
+	s.SetPrice(oanda.InstrumentUSDJPY, 104.491, 104.499)
+	waitOrders(t, client, func(orders []oanda.Order) bool { return len(orders) == 0 })
+	if trades, _ := client.FetchOpenTrades(); len(trades) != 1 || trades[0].CurrentUnits != 100 {
+		t.Errorf("trades = %+v, want only the buy", trades)
+	}
+}
+
+func TestOCORetriesFailedCancels(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	oco := oanda.NewOCO(client)
+	if _, err := oco.Place(limitOrder(100, 104.5), limitOrder(-100, 105.5)); err != nil {
+		t.Fatal(err)
+	}
+	// the first cancel fails, and the sibling is left pending until it is retried.
+	s.Inject(oandatest.Fault{Method: http.MethodPut, Path: "/cancel", Status: http.StatusServiceUnavailable, Times: 1})
+	s.SetPrice(oanda.InstrumentUSDJPY, 104.491, 104.499)
+	startOCO(t, oco)
+
+	waitOrders(t, client, func(orders []oanda.Order) bool { return len(orders) == 0 })
+	deadline := time.Now().Add(5 * time.Second)
+	for len(oco.Cancelling()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("cancels still retried: %v", oco.Cancelling())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancels := 0
+	for _, r := range s.Requests() {
+		if r.Method == http.MethodPut {
+			cancels++
+		}
+	}
+	if cancels != 2 {
+		t.Errorf("sent %d cancel requests, want the failed one and its retry", cancels)
+	}
+}
+
+func TestOCOPlaceCancelsCreatedOrdersOnFailure(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	oco := oanda.NewOCO(client)
+	invalid := limitOrder(-100, 105.5)
+	invalid.Instrument = "NOT_AN_INSTRUMENT"
+	if _, err := oco.Place(limitOrder(100, 104.5), invalid); err == nil {
+		t.Fatal("placing a group with an invalid order succeeds")
+	}
+	if orders, _ := client.FetchOrders(); len(orders) != 0 {
+		t.Errorf("orders = %+v, want the created order cancelled", orders)
+	}
+}
@@ -3,12 +3,12 @@ package oanda
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"time"
 )
 
-
-
 type book struct {
 	Instrument  string    `json:"instrument"`
 	Time        time.Time `json:"time"`
@@ -18,7 +18,7 @@ type book struct {
 }
 
 type retrievedBook struct {
-	Book book `json:"orderBook"`
+	Book json.RawMessage `json:"orderBook"`
 }
 
 type bucket struct {
@@ -27,10 +27,16 @@ type bucket struct {
 	ShortCountPercent string `json:"shortCountPercent"`
 }
 type OrderBook struct {
-	Instrument instrument
-	Time       time.Time
-	Price      Price
-	Buckets    []OrderBookBucket
+	Instrument  Instrument
+	Time        time.Time
+	Price       Price
+	BucketWidth Decimal
+	Buckets     []OrderBookBucket
+	// Raw is the order book object exactly as OANDA API sent it.
+	// It is nil for books which were not decoded from OANDA API's JSON.
+	Raw json.RawMessage
+
+	decoded *OrderBook // the fields as decoded from Raw, to tell whether they have changed since
 }
 type OrderBookBucket struct {
 	Price             Price
@@ -41,21 +47,25 @@ type OrderBookBucket struct {
 func (b *book) toOrderBook() (*OrderBook, error) {
 	price, err := strconv.ParseFloat(b.Price, 64)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse price to float64: %v", err)
+		return nil, fmt.Errorf("failed to parse price to float64: %w", err)
+	}
+	width, err := ParseDecimal(b.BucketWidth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bucket width to decimal: %w", err)
 	}
 	var buckets []OrderBookBucket
 	for _, bu := range b.Buckets {
 		p, err := strconv.ParseFloat(bu.Price, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse bucket price to type of float64: %v", err)
+			return nil, fmt.Errorf("failed to parse bucket price to type of float64: %w", err)
 		}
 		l, err := strconv.ParseFloat(bu.LongCountPercent, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse long count percent to float64: %v", err)
+			return nil, fmt.Errorf("failed to parse long count percent to float64: %w", err)
 		}
 		s, err := strconv.ParseFloat(bu.ShortCountPercent, 64)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse short count percent to float64: %v", err)
+			return nil, fmt.Errorf("failed to parse short count percent to float64: %w", err)
 		}
 		buckets = append(buckets, OrderBookBucket{
 			Price(p),
@@ -64,51 +74,146 @@ func (b *book) toOrderBook() (*OrderBook, error) {
 		})
 	}
 	return &OrderBook{
-		instrument(b.Instrument),
-		b.Time,
-		Price(price),
-		buckets,
+		Instrument:  Instrument(b.Instrument),
+		Time:        b.Time,
+		Price:       Price(price),
+		BucketWidth: width,
+		Buckets:     buckets,
 	}, nil
 }
 
-func (o *OrderBook) ExtractBucketVicinityOfPrice(price Price, n int) (short, long []OrderBookBucket, err error) {
-	var lowerBuckets []OrderBookBucket
-	var higherBuckets []OrderBookBucket
-	for i, b := range o.Buckets {
-		if b.Price > price {
-			lowerBuckets = o.Buckets[:i-1]
-			higherBuckets = o.Buckets[i-1:]
-			break
+func (o *OrderBook) toBook() book {
+	b := book{
+		Instrument:  string(o.Instrument),
+		Time:        o.Time,
+		Price:       formatDecimal(o.Price),
+		BucketWidth: string(o.BucketWidth),
+	}
+	for _, bu := range o.Buckets {
+		b.Buckets = append(b.Buckets, bucket{
+			Price:             formatDecimal(bu.Price),
+			LongCountPercent:  strconv.FormatFloat(bu.LongCountPercent, 'f', -1, 64),
+			ShortCountPercent: strconv.FormatFloat(bu.ShortCountPercent, 'f', -1, 64),
+		})
+	}
+	return b
+}
+
+// MarshalJSON encodes the order book in the format of OANDA API.
+// A book decoded from OANDA API is encoded to exactly the bytes it was decoded from unless it has been changed.
+func (o OrderBook) MarshalJSON() ([]byte, error) {
+	if o.Raw != nil && !o.changed() {
+		return o.Raw, nil
+	}
+	return json.Marshal(o.toBook())
+}
+
+// changed reports whether the fields differ from the ones decoded from Raw.
+func (o *OrderBook) changed() bool {
+	d := o.decoded
+	if d == nil || d.Instrument != o.Instrument || !d.Time.Equal(o.Time) || d.Price != o.Price ||
+		d.BucketWidth != o.BucketWidth || len(d.Buckets) != len(o.Buckets) {
+		return true
+	}
+	for i := range o.Buckets {
+		if o.Buckets[i] != d.Buckets[i] {
+			return true
 		}
 	}
+	return false
+}
+
+// UnmarshalJSON decodes an order book object of OANDA API and keeps it in Raw.
+func (o *OrderBook) UnmarshalJSON(data []byte) error {
+	var b book
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	ob, err := b.toOrderBook()
+	if err != nil {
+		return err
+	}
+	decoded := *ob
+	decoded.Buckets = append([]OrderBookBucket(nil), ob.Buckets...)
+	*o = *ob
+	o.Raw = append(json.RawMessage(nil), data...)
+	o.decoded = &decoded
+	return nil
+}
+
+// BucketIndex returns the index of the bucket which contains price.
+// The index is computed from the first bucket and BucketWidth,
+// so it is negative or not less than len(Buckets) when price is outside of the book.
+func (o *OrderBook) BucketIndex(price Price) (int, error) {
+	if err := o.validateGrid(); err != nil {
+		return 0, err
+	}
+	return int(math.Floor(float64(price-o.Buckets[0].Price)/float64(o.BucketWidth.Price()) + 1e-9)), nil
+}
+
+// BucketPrice returns the lower bound price of the bucket at index i, the inverse of BucketIndex.
+func (o *OrderBook) BucketPrice(i int) (Price, error) {
+	if err := o.validateGrid(); err != nil {
+		return 0, err
+	}
+	p := float64(o.Buckets[0].Price) + float64(i)*float64(o.BucketWidth.Price())
+	return Price(math.Round(p*1e9) / 1e9), nil
+}
+
+func (o *OrderBook) validateGrid() error {
+	if len(o.Buckets) == 0 {
+		return fmt.Errorf("order book has no buckets")
+	}
+	if !(o.BucketWidth.Price() > 0) {
+		return fmt.Errorf("bucket width must be positive: %q", o.BucketWidth)
+	}
+	return nil
+}
+
+func formatDecimal(p Price) string {
+	return strconv.FormatFloat(float64(p), 'f', -1, 64)
+}
+
+func (o *OrderBook) ExtractBucketVicinityOfPrice(price Price, n int) (short, long []OrderBookBucket, err error) {
+	if n <= 0 {
+		return nil, nil, fmt.Errorf("number of buckets must be positive: %d", n)
+	}
+	i := sort.Search(len(o.Buckets), func(i int) bool { return o.Buckets[i].Price > price })
+	if i == 0 {
+		return nil, nil, fmt.Errorf("price is too low: no bucket contains %s", price.String())
+	}
+	// copy lower buckets so that reversing them does not reorder the book itself.
+	lowerBuckets := make([]OrderBookBucket, i-1)
+	copy(lowerBuckets, o.Buckets[:i-1])
+	higherBuckets := o.Buckets[i-1:]
 	for i, j := 0, len(lowerBuckets)-1; i < j; i, j = i+1, j-1 {
 		lowerBuckets[i], lowerBuckets[j] = lowerBuckets[j], lowerBuckets[i]
 	}
-	if len(lowerBuckets[:n]) < n {
+	if len(lowerBuckets) < n {
 		return nil, nil, fmt.Errorf("price is too low: lowerBuckets[%d] is not exist", n-1)
 	}
-	if len(higherBuckets[:n]) < n {
+	if len(higherBuckets) < n {
 		return nil, nil, fmt.Errorf("price is too high: higherBuckets[%d] is not exist", n-1)
 	}
 	return lowerBuckets[:n], higherBuckets[:n], nil
 }
 
-func (c *Client) FetchOrderBook(instrument instrument, dateTime *time.Time) (*OrderBook, error) {
+func (c *Client) FetchOrderBook(instrument Instrument, dateTime *time.Time) (*OrderBook, error) {
 	body, err := c.fetchOrderBook(instrument, dateTime)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch order book: %v", err)
+		return nil, fmt.Errorf("failed to fetch order book: %w", err)
 	}
 	var rb retrievedBook
 	if err := json.Unmarshal(body, &rb); err != nil {
-		return nil, fmt.Errorf("failed to json unmarshal: %v", err)
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
 	}
-	ob, err := rb.Book.toOrderBook()
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert book to order book: %v", err)
+	var ob OrderBook
+	if err := json.Unmarshal(rb.Book, &ob); err != nil {
+		return nil, fmt.Errorf("failed to convert book to order book: %w", err)
 	}
-	return ob, nil
+	return &ob, nil
 }
 
-func (c *Client) FetchOrderBookJSON(instrument instrument, dateTime *time.Time) ([]byte, error) {
+func (c *Client) FetchOrderBookJSON(instrument Instrument, dateTime *time.Time) ([]byte, error) {
 	return c.fetchOrderBook(instrument, dateTime)
 }
@@ -0,0 +1,112 @@
+package oanda
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExtractBucketVicinityOfPrice(t *testing.T) {
+	book := &OrderBook{Buckets: []OrderBookBucket{
+		{Price: 104.9}, {Price: 104.95}, {Price: 105}, {Price: 105.05}, {Price: 105.1},
+	}}
+	short, long, err := book.ExtractBucketVicinityOfPrice(105.01, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short[0].Price != 104.95 || short[1].Price != 104.9 {
+		t.Errorf("short = %v", short)
+	}
+	if long[0].Price != 105 || long[1].Price != 105.05 {
+		t.Errorf("long = %v", long)
+	}
+	if book.Buckets[0].Price != 104.9 {
+		t.Errorf("book is reordered: %v", book.Buckets)
+	}
+	for _, n := range []int{0, -1, 3} {
+		if _, _, err := book.ExtractBucketVicinityOfPrice(105.01, n); err == nil {
+			t.Errorf("n = %d is accepted", n)
+		}
+	}
+	if _, _, err := book.ExtractBucketVicinityOfPrice(100, 1); err == nil {
+		t.Error("price below the book is accepted")
+	}
+}
+
+const orderBookJSON = `{"instrument":"USD_JPY","time":"2020-09-18T10:20:00Z","price":"104.680","bucketWidth":"0.050",` +
+	`"buckets":[{"price":"104.600","longCountPercent":"0.1000","shortCountPercent":"0.2000"},` +
+	`{"price":"104.650","longCountPercent":"0.3000","shortCountPercent":"0.0000"},` +
+	`{"price":"104.700","longCountPercent":"0.0500","shortCountPercent":"0.4500"}]}`
+
+func TestOrderBookJSONRoundTrip(t *testing.T) {
+	var book OrderBook
+	if err := json.Unmarshal([]byte(orderBookJSON), &book); err != nil {
+		t.Fatal(err)
+	}
+	if book.BucketWidth != "0.050" || book.BucketWidth.Price() != 0.05 {
+		t.Errorf("bucket width = %q", book.BucketWidth)
+	}
+	b, err := json.Marshal(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != orderBookJSON {
+		t.Errorf("re-marshaled book = %s, want %s", b, orderBookJSON)
+	}
+
+	book.Buckets[1].LongCountPercent = 0.9
+	b, err = json.Marshal(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var changed OrderBook
+	if err := json.Unmarshal(b, &changed); err != nil {
+		t.Fatal(err)
+	}
+	if changed.Buckets[1].LongCountPercent != 0.9 {
+		t.Errorf("change of a decoded book is lost on re-marshal: %s", b)
+	}
+	if changed.BucketWidth != "0.050" || changed.Time != book.Time || len(changed.Buckets) != 3 {
+		t.Errorf("re-marshaled book = %+v", changed)
+	}
+}
+
+func TestBucketIndex(t *testing.T) {
+	var book OrderBook
+	if err := json.Unmarshal([]byte(orderBookJSON), &book); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		price Price
+		index int
+	}{
+		{104.6, 0},
+		{104.649, 0},
+		{104.65, 1},
+		{104.7, 2},
+		{104.749, 2},
+		{104.75, 3},
+		{104.55, -1},
+		{104.599, -1},
+	}
+	for _, tt := range tests {
+		i, err := book.BucketIndex(tt.price)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i != tt.index {
+			t.Errorf("BucketIndex(%v) = %d, want %d", tt.price, i, tt.index)
+		}
+	}
+	for i := -3; i < 10; i++ {
+		p, err := book.BucketPrice(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := book.BucketIndex(p); got != i {
+			t.Errorf("BucketIndex(BucketPrice(%d) = %v) = %d", i, p, got)
+		}
+	}
+	if _, err := (&OrderBook{Buckets: book.Buckets}).BucketIndex(104.6); err == nil {
+		t.Error("unknown bucket width is accepted")
+	}
+}
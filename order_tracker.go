@@ -0,0 +1,377 @@
+package oanda
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+// OrderState is the state of an order in its lifecycle.
+type OrderState string
+
+const (
+	OrderStatePending   OrderState = "PENDING"
+	OrderStateFilled    OrderState = "FILLED"
+	OrderStateTriggered OrderState = "TRIGGERED"
+	OrderStateCancelled OrderState = "CANCELLED"
+	// OrderStateRejected is the state of an order OANDA API rejected, which has no id.
+	OrderStateRejected OrderState = "REJECTED"
+)
+
+// Final reports whether s is an end of the lifecycle. A pending order moves to one of the final states and no further.
+func (s OrderState) Final() bool {
+	return s == OrderStateFilled || s == OrderStateTriggered || s == OrderStateCancelled || s == OrderStateRejected
+}
+
+// DefaultOrderPollInterval is how often OrderTracker.Poll fetches the tracked orders unless PollInterval is set.
+const DefaultOrderPollInterval = 5 * time.Second
+
+// triggeredFillReasons are the reasons of the fills of orders which were triggered by the price.
+var triggeredFillReasons = map[string]bool{
+	"LIMIT_ORDER":                true,
+	"STOP_ORDER":                 true,
+	"MARKET_IF_TOUCHED_ORDER":    true,
+	"TAKE_PROFIT_ORDER":          true,
+	"STOP_LOSS_ORDER":            true,
+	"GUARANTEED_STOP_LOSS_ORDER": true,
+	"TRAILING_STOP_LOSS_ORDER":   true,
+}
+
+// TrackedOrder is the state of an order followed by an OrderTracker.
+type TrackedOrder struct {
+	ID    OrderID
+	Order Order // as submitted, without the fields OANDA API sets
+	State OrderState
+	// Triggered reports whether the order was triggered by the price, i.e. it is TRIGGERED,
+	// or it passed through TRIGGERED to FILLED.
+	Triggered bool
+	// Reason is the reason of the cancellation or the rejection of the order.
+	Reason string
+	// TradeOpened, TradeReduced and TradesClosed are the trades the fill of the order changed.
+	TradeOpened  TradeID
+	TradeReduced TradeID
+	TradesClosed []TradeID
+	// ReplacedBy is the order which replaced a cancelled order.
+	ReplacedBy OrderID
+	// TransactionID is the last transaction which changed the state of the order.
+	TransactionID TransactionID
+	Updated       time.Time
+}
+
+// OrderTracker follows orders from their creation through PENDING to FILLED, TRIGGERED or CANCELLED.
+// It learns the outcome of an order from the response of its creation, and afterwards from the transaction
+// stream while Run is running or from fetching the orders while Poll is running.
+type OrderTracker struct {
+	Client *Client
+	// PollInterval is how often Poll fetches the tracked orders which are not final.
+	PollInterval time.Duration
+	// ReconnectInterval is how long Run waits before reopening a broken stream.
+	ReconnectInterval time.Duration
+
+	mu      sync.Mutex
+	orders  map[OrderID]*TrackedOrder
+	early   map[OrderID][]Transaction // transactions of orders not tracked yet, which may be being submitted
+	changed chan struct{}             // closed and replaced on every change
+}
+
+const maxEarlyTransactions = 1000
+
+// NewOrderTracker constructs an OrderTracker with the default intervals.
+func NewOrderTracker(client *Client) *OrderTracker {
+	return &OrderTracker{
+		Client:            client,
+		PollInterval:      DefaultOrderPollInterval,
+		ReconnectInterval: DefaultReconnectInterval,
+		orders:            map[OrderID]*TrackedOrder{},
+		early:             map[OrderID][]Transaction{},
+		changed:           make(chan struct{}),
+	}
+}
+
+// Submit creates order and tracks it. A rejected order is returned in OrderStateRejected with the error.
+func (t *OrderTracker) Submit(order Order) (TrackedOrder, error) {
+	resp, err := t.Client.SubmitOrder(order)
+	if resp == nil {
+		return TrackedOrder{}, err
+	}
+	if resp.Reject != nil {
+		return TrackedOrder{Order: order, State: OrderStateRejected, Reason: resp.Reject.Reason, TransactionID: resp.Reject.ID, Updated: resp.Reject.Time}, err
+	}
+	return t.record(order, resp), err
+}
+
+// Replace replaces the pending order of order.ID with order and tracks the replacement.
+// The replaced order becomes CANCELLED with ReplacedBy set.
+func (t *OrderTracker) Replace(order Order) (TrackedOrder, error) {
+	resp, err := t.Client.ReplaceOrder(order)
+	if resp == nil {
+		return TrackedOrder{}, err
+	}
+	if resp.Reject != nil {
+		return TrackedOrder{Order: order, State: OrderStateRejected, Reason: resp.Reject.Reason, TransactionID: resp.Reject.ID, Updated: resp.Reject.Time}, err
+	}
+	if resp.Cancel != nil {
+		t.apply(*resp.Cancel)
+		t.mu.Lock()
+		if o, ok := t.orders[resp.Cancel.OrderID]; ok {
+			o.ReplacedBy = resp.OrderID()
+		}
+		t.mu.Unlock()
+	}
+	order.ID = ""
+	tracked := t.record(order, resp)
+	if resp.ReplacingCancel != nil {
+		t.apply(*resp.ReplacingCancel)
+		tracked, _ = t.Order(tracked.ID)
+	}
+	return tracked, err
+}
+
+// Cancel cancels the pending order of id. The order becomes CANCELLED when the cancellation succeeds.
+func (t *OrderTracker) Cancel(id OrderID) error {
+	if err := t.Client.CancelOrder(id); err != nil {
+		return err
+	}
+	t.update(id, func(o *TrackedOrder) {
+		o.State = OrderStateCancelled
+		o.Reason = "CLIENT_REQUEST"
+	})
+	return nil
+}
+
+// Track starts tracking an order created elsewhere, e.g. before a restart, in the PENDING state.
+func (t *OrderTracker) Track(id OrderID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.orders[id]; !ok {
+		t.orders[id] = &TrackedOrder{ID: id, State: OrderStatePending, Updated: time.Now()}
+		t.notify()
+	}
+}
+
+// Order returns the state of the order of id.
+func (t *OrderTracker) Order(id OrderID) (TrackedOrder, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.orders[id]
+	if !ok {
+		return TrackedOrder{}, false
+	}
+	return o.copy(), true
+}
+
+// Orders returns the states of the tracked orders.
+func (t *OrderTracker) Orders() []TrackedOrder {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var orders []TrackedOrder
+	for _, o := range t.orders {
+		orders = append(orders, o.copy())
+	}
+	return orders
+}
+
+// Wait waits until the order of id is in one of states, or in any final state when none is given, and returns it.
+// A triggered order which filled is in OrderStateTriggered as well as OrderStateFilled.
+// It fails when the order reaches a final state which is not one of states, or ctx is done.
+func (t *OrderTracker) Wait(ctx context.Context, id OrderID, states ...OrderState) (TrackedOrder, error) {
+	for {
+		t.mu.Lock()
+		o, ok := t.orders[id]
+		var current TrackedOrder
+		if ok {
+			current = o.copy()
+		}
+		changed := t.changed
+		t.mu.Unlock()
+		if !ok {
+			return TrackedOrder{}, fmt.Errorf("order %s is not tracked", id)
+		}
+		if len(states) == 0 && current.State.Final() {
+			return current, nil
+		}
+		for _, s := range states {
+			if current.State == s || (s == OrderStateTriggered && current.Triggered) {
+				return current, nil
+			}
+		}
+		if current.State.Final() {
+			return current, fmt.Errorf("order %s is %s: %s", id, current.State, current.Reason)
+		}
+		select {
+		case <-ctx.Done():
+			return current, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Run follows the tracked orders on the transaction stream until ctx is done.
+// The orders are fetched whenever the stream is opened, so that changes while it was broken are not missed.
+func (t *OrderTracker) Run(ctx context.Context) error {
+	for {
+		t.refresh()
+		err := t.Client.StreamTransactions(ctx, func(tx Transaction) error {
+			t.apply(tx)
+			return nil
+		})
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("transaction stream broke, reconnecting in %s: %v", t.ReconnectInterval, err)
+		if err := util.SleepContext(ctx, t.ReconnectInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// Poll follows the tracked orders by fetching the ones which are not final every PollInterval until ctx is done.
+func (t *OrderTracker) Poll(ctx context.Context) error {
+	interval := t.PollInterval
+	if interval == 0 {
+		interval = DefaultOrderPollInterval
+	}
+	for {
+		t.refresh()
+		if err := util.SleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// record tracks the order created in resp.
+func (t *OrderTracker) record(order Order, resp *OrderResponse) TrackedOrder {
+	id := resp.OrderID()
+	if id == "" {
+		return TrackedOrder{Order: order}
+	}
+	t.mu.Lock()
+	if _, ok := t.orders[id]; !ok {
+		t.orders[id] = &TrackedOrder{ID: id, Order: order, State: OrderStatePending, TransactionID: resp.Create.ID, Updated: resp.Create.Time}
+		t.notify()
+	} else {
+		t.orders[id].Order = order
+	}
+	early := t.early[id]
+	delete(t.early, id)
+	t.mu.Unlock()
+	if resp.Fill != nil {
+		t.apply(*resp.Fill)
+	}
+	if resp.Cancel != nil && resp.Cancel.OrderID == id {
+		t.apply(*resp.Cancel)
+	}
+	for _, tx := range early {
+		t.apply(tx)
+	}
+	tracked, _ := t.Order(id)
+	return tracked
+}
+
+// apply moves a tracked order according to tx. A transaction of an order which is not tracked
+// is kept until the order is, since the stream may deliver it before the response of the creation.
+func (t *OrderTracker) apply(tx Transaction) {
+	if tx.Type != "ORDER_FILL" && tx.Type != "ORDER_CANCEL" {
+		return
+	}
+	t.mu.Lock()
+	if _, ok := t.orders[tx.OrderID]; !ok {
+		if len(t.early) >= maxEarlyTransactions {
+			t.early = map[OrderID][]Transaction{}
+		}
+		t.early[tx.OrderID] = append(t.early[tx.OrderID], tx)
+		t.mu.Unlock()
+		return
+	}
+	t.mu.Unlock()
+	switch tx.Type {
+	case "ORDER_FILL":
+		t.update(tx.OrderID, func(o *TrackedOrder) {
+			o.State = OrderStateFilled
+			o.Triggered = triggeredFillReasons[tx.Reason]
+			if tx.TradeOpened != nil {
+				o.TradeOpened = tx.TradeOpened.TradeID
+			}
+			if tx.TradeReduced != nil {
+				o.TradeReduced = tx.TradeReduced.TradeID
+			}
+			for _, c := range tx.TradesClosed {
+				o.TradesClosed = append(o.TradesClosed, c.TradeID)
+			}
+			o.TransactionID, o.Updated = tx.ID, tx.Time
+		})
+	case "ORDER_CANCEL":
+		t.update(tx.OrderID, func(o *TrackedOrder) {
+			o.State = OrderStateCancelled
+			o.Reason = tx.Reason
+			o.TransactionID, o.Updated = tx.ID, tx.Time
+		})
+	}
+}
+
+// refresh fetches the tracked orders which are not final and moves them to their current states.
+func (t *OrderTracker) refresh() {
+	t.mu.Lock()
+	var ids []OrderID
+	for id, o := range t.orders {
+		if !o.State.Final() {
+			ids = append(ids, id)
+		}
+	}
+	t.mu.Unlock()
+	for _, id := range ids {
+		order, err := t.Client.FetchOrder(id)
+		if err != nil {
+			log.Printf("failed to fetch order %s: %v", id, err)
+			continue
+		}
+		t.update(id, func(o *TrackedOrder) {
+			o.State = OrderState(order.State)
+			o.Triggered = o.State == OrderStateTriggered || (o.State == OrderStateFilled && order.Type != OrderTypeMarket)
+			o.TradeOpened, o.TradeReduced, o.TradesClosed = order.TradeOpenedID, order.TradeReducedID, order.TradeClosedIDs
+			o.ReplacedBy = order.ReplacedByOrderID
+			switch {
+			case order.FillingTransactionID != "":
+				o.TransactionID = order.FillingTransactionID
+			case order.CancellingTransactionID != "":
+				o.TransactionID = order.CancellingTransactionID
+			}
+			switch {
+			case order.FilledTime != nil:
+				o.Updated = *order.FilledTime
+			case order.CancelledTime != nil:
+				o.Updated = *order.CancelledTime
+			}
+		})
+	}
+}
+
+// update applies fn to the tracked order of id unless it is unknown or final.
+func (t *OrderTracker) update(id OrderID, fn func(o *TrackedOrder)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	o, ok := t.orders[id]
+	if !ok || o.State.Final() {
+		return
+	}
+	before := o.State
+	fn(o)
+	if o.State != before {
+		t.notify()
+	}
+}
+
+func (t *OrderTracker) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+func (o *TrackedOrder) copy() TrackedOrder {
+	c := *o
+	c.TradesClosed = append([]TradeID(nil), o.TradesClosed...)
+	return c
+}
@@ -0,0 +1,82 @@
+package oanda
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func fillTransaction(id TransactionID, order OrderID, reason string) Transaction {
+	return Transaction{ID: id, Type: "ORDER_FILL", OrderID: order, Reason: reason, Time: time.Now(), TradeOpened: &TradeChange{TradeID: "100"}}
+}
+
+func TestOrderTrackerAppliesEarlyTransactions(t *testing.T) {
+	tracker := NewOrderTracker(nil)
+	// the stream delivers the fill before the response of the creation.
+	tracker.apply(fillTransaction("11", "10", "LIMIT_ORDER"))
+	if _, ok := tracker.Order("10"); ok {
+		t.Fatal("order is tracked before it is recorded")
+	}
+	tracked := tracker.record(Order{Type: OrderTypeLimit}, &OrderResponse{Create: &Transaction{ID: "10"}})
+	if tracked.State != OrderStateFilled || !tracked.Triggered || tracked.TradeOpened != "100" || tracked.TransactionID != "11" {
+		t.Errorf("tracked = %+v, want filled by the early transaction", tracked)
+	}
+	if n := len(tracker.early); n != 0 {
+		t.Errorf("%d orders left in the early buffer", n)
+	}
+}
+
+func TestOrderTrackerBoundsEarlyTransactions(t *testing.T) {
+	tracker := NewOrderTracker(nil)
+	for i := 0; i <= maxEarlyTransactions; i++ {
+		tracker.apply(fillTransaction(TransactionID(strconv.Itoa(i)), OrderID("order-"+strconv.Itoa(i)), "LIMIT_ORDER"))
+	}
+	if n := len(tracker.early); n > maxEarlyTransactions {
+		t.Errorf("%d orders in the early buffer, want at most %d", n, maxEarlyTransactions)
+	}
+	// transactions which do not change orders are not kept.
+	tracker.early = map[OrderID][]Transaction{}
+	tracker.apply(Transaction{ID: "1", Type: "DAILY_FINANCING", OrderID: "10"})
+	if n := len(tracker.early); n != 0 {
+		t.Errorf("%d orders in the early buffer, want none", n)
+	}
+}
+
+func TestOrderTrackerStateMachine(t *testing.T) {
+	tracker := NewOrderTracker(nil)
+	tracker.record(Order{Type: OrderTypeMarket}, &OrderResponse{Create: &Transaction{ID: "1"}})
+	tracker.record(Order{Type: OrderTypeStop}, &OrderResponse{Create: &Transaction{ID: "2"}})
+	tracker.record(Order{Type: OrderTypeLimit}, &OrderResponse{Create: &Transaction{ID: "3"}})
+
+	tracker.apply(fillTransaction("4", "1", "MARKET_ORDER"))
+	tracker.apply(fillTransaction("5", "2", "STOP_ORDER"))
+	tracker.apply(Transaction{ID: "6", Type: "ORDER_CANCEL", OrderID: "3", Reason: "TIME_IN_FORCE_EXPIRED"})
+	// final states do not change.
+	tracker.apply(Transaction{ID: "7", Type: "ORDER_CANCEL", OrderID: "1", Reason: "CLIENT_REQUEST"})
+	tracker.apply(fillTransaction("8", "3", "LIMIT_ORDER"))
+
+	tests := []struct {
+		id        OrderID
+		state     OrderState
+		triggered bool
+		reason    string
+	}{
+		{"1", OrderStateFilled, false, ""},
+		{"2", OrderStateFilled, true, ""},
+		{"3", OrderStateCancelled, false, "TIME_IN_FORCE_EXPIRED"},
+	}
+	for _, tt := range tests {
+		o, _ := tracker.Order(tt.id)
+		if o.State != tt.state || o.Triggered != tt.triggered || o.Reason != tt.reason {
+			t.Errorf("order %s = %+v, want %s, triggered %t and reason %q", tt.id, o, tt.state, tt.triggered, tt.reason)
+		}
+	}
+	for _, s := range []OrderState{OrderStateFilled, OrderStateTriggered, OrderStateCancelled, OrderStateRejected} {
+		if !s.Final() {
+			t.Errorf("%s is not final", s)
+		}
+	}
+	if OrderStatePending.Final() {
+		t.Error("PENDING is final")
+	}
+}
@@ -0,0 +1,148 @@
+package oanda_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+func newTracker(t *testing.T) (*oandatest.Server, *oanda.OrderTracker) {
+	t.Helper()
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	tracker := oanda.NewOrderTracker(s.NewClient())
+	tracker.PollInterval = 10 * time.Millisecond
+	tracker.ReconnectInterval = 10 * time.Millisecond
+	return s, tracker
+}
+
+// follow runs follow, e.g. Run or Poll of a tracker, until the test ends.
+func follow(t *testing.T, follow func(ctx context.Context) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = follow(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+}
+
+func waitContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestOrderTrackerMarketOrder(t *testing.T) {
+	_, tracker := newTracker(t)
+	tracked, err := tracker.Submit(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 100, TimeInForce: oanda.TimeInForceFOK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracked.State != oanda.OrderStateFilled || tracked.Triggered || tracked.TradeOpened == "" {
+		t.Fatalf("tracked = %+v, want filled without a trigger and with the opened trade", tracked)
+	}
+	if _, err := tracker.Wait(waitContext(t), tracked.ID, oanda.OrderStateTriggered); err == nil {
+		t.Error("waiting for a filled market order to be triggered succeeds")
+	}
+
+	rejected, err := tracker.Submit(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: "NOT_AN_INSTRUMENT", Units: 100, TimeInForce: oanda.TimeInForceFOK})
+	if err == nil || rejected.State != oanda.OrderStateRejected || rejected.Reason == "" {
+		t.Errorf("rejected = %+v, %v, want a rejection with its reason", rejected, err)
+	}
+}
+
+func TestOrderTrackerTriggeredOnStream(t *testing.T) {
+	s, tracker := newTracker(t)
+	follow(t, tracker.Run)
+	tracked, err := tracker.Submit(oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentUSDJPY, Units: 100, Price: 104.5, TimeInForce: oanda.TimeInForceGTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracked.State != oanda.OrderStatePending {
+		t.Fatalf("state = %s, want PENDING", tracked.State)
+	}
+	done := make(chan struct{})
+	var triggered oanda.TrackedOrder
+	var waitErr error
+	go func() {
+		defer close(done)
+		triggered, waitErr = tracker.Wait(waitContext(t), tracked.ID, oanda.OrderStateTriggered)
+	}()
+	s.SetPrice(oanda.InstrumentUSDJPY, 104.491, 104.499)
+	<-done
+	if waitErr != nil {
+		t.Fatal(waitErr)
+	}
+	if !triggered.Triggered || triggered.State != oanda.OrderStateFilled || triggered.TradeOpened == "" {
+		t.Errorf("tracked = %+v, want triggered and filled with the opened trade", triggered)
+	}
+	if _, err := tracker.Wait(waitContext(t), tracked.ID, oanda.OrderStateFilled); err != nil {
+		t.Errorf("triggered order is not filled: %v", err)
+	}
+}
+
+func TestOrderTrackerTriggeredOnPoll(t *testing.T) {
+	s, tracker := newTracker(t)
+	client := s.NewClient()
+	if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeStop, Instrument: oanda.InstrumentUSDJPY, Units: -100, Price: 104.5, TimeInForce: oanda.TimeInForceGTC}); err != nil {
+		t.Fatal(err)
+	}
+	orders, err := client.FetchOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an order created elsewhere.
+	tracker.Track(orders[0].ID)
+	follow(t, tracker.Poll)
+	s.SetPrice(oanda.InstrumentUSDJPY, 104.491, 104.499)
+	tracked, err := tracker.Wait(waitContext(t), orders[0].ID, oanda.OrderStateTriggered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracked.State != oanda.OrderStateFilled || tracked.TradeOpened == "" {
+		t.Errorf("tracked = %+v, want filled with the opened trade", tracked)
+	}
+}
+
+func TestOrderTrackerReplaceAndCancel(t *testing.T) {
+	_, tracker := newTracker(t)
+	limit := oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentUSDJPY, Units: 100, Price: 104.5, TimeInForce: oanda.TimeInForceGTC}
+	original, err := tracker.Submit(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit.ID = original.ID
+	limit.Price = 104.4
+	replacement, err := tracker.Replace(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replacement.State != oanda.OrderStatePending || replacement.ID == original.ID {
+		t.Fatalf("replacement = %+v, want a new pending order", replacement)
+	}
+	replaced, _ := tracker.Order(original.ID)
+	if replaced.State != oanda.OrderStateCancelled || replaced.ReplacedBy != replacement.ID {
+		t.Errorf("replaced = %+v, want cancelled and replaced by %s", replaced, replacement.ID)
+	}
+
+	if err := tracker.Cancel(replacement.ID); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, err := tracker.Wait(waitContext(t), replacement.ID, oanda.OrderStateFilled)
+	if err == nil || cancelled.State != oanda.OrderStateCancelled || cancelled.Reason != "CLIENT_REQUEST" {
+		t.Errorf("cancelled = %+v, %v, want an error for waiting a cancelled order to fill", cancelled, err)
+	}
+	if n := len(tracker.Orders()); n != 2 {
+		t.Errorf("%d orders tracked, want 2", n)
+	}
+}
@@ -0,0 +1,186 @@
+// Package orderbook implements analytics over order books fetched by oanda.Client.
+//
+// Every function assumes that buckets are sorted by price in ascending order,
+// which is how OANDA API returns them, and never panics on an empty book or
+// on a price outside of the book.
+package orderbook
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// ErrEmptyBook is returned when an order book has no buckets.
+var ErrEmptyBook = errors.New("order book has no buckets")
+
+const (
+	SideLong  = "long"
+	SideShort = "short"
+)
+
+// DepthLevel is the cumulative long and short percent from the price of interest up to Price.
+type DepthLevel struct {
+	Price oanda.Price
+	Long  float64
+	Short float64
+}
+
+// Wall is a bucket whose long or short percent exceeds a percentile threshold.
+type Wall struct {
+	Bucket  oanda.OrderBookBucket
+	Side    string // SideLong or SideShort
+	Percent float64
+}
+
+// Nearest returns the bucket whose price is nearest to price and its index.
+// When price is equidistant from two buckets the lower one is returned.
+func Nearest(book *oanda.OrderBook, price oanda.Price) (oanda.OrderBookBucket, int, error) {
+	if book == nil || len(book.Buckets) == 0 {
+		return oanda.OrderBookBucket{}, -1, ErrEmptyBook
+	}
+	buckets := book.Buckets
+	i := sort.Search(len(buckets), func(i int) bool { return buckets[i].Price >= price })
+	switch {
+	case i == 0:
+	case i == len(buckets):
+		i = len(buckets) - 1
+	case price-buckets[i-1].Price <= buckets[i].Price-price:
+		i--
+	}
+	return buckets[i], i, nil
+}
+
+// Window returns the nearest bucket to price and up to n buckets on each side of it.
+// The window is truncated at the edges of the book.
+func Window(book *oanda.OrderBook, price oanda.Price, n int) ([]oanda.OrderBookBucket, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("window size must not be negative: %d", n)
+	}
+	_, i, err := Nearest(book, price)
+	if err != nil {
+		return nil, err
+	}
+	lo := i - n
+	if lo < 0 {
+		lo = 0
+	}
+	hi := i + n + 1
+	if hi > len(book.Buckets) {
+		hi = len(book.Buckets)
+	}
+	return book.Buckets[lo:hi], nil
+}
+
+// CumulativeDepth returns the running totals of long and short percent walking away from price.
+// Buckets priced above price are accumulated into above in ascending order,
+// and the others into below in descending order, so both start next to price.
+func CumulativeDepth(book *oanda.OrderBook, price oanda.Price) (above, below []DepthLevel) {
+	if book == nil {
+		return nil, nil
+	}
+	buckets := book.Buckets
+	i := sort.Search(len(buckets), func(i int) bool { return buckets[i].Price > price })
+	var long, short float64
+	for _, b := range buckets[i:] {
+		long += b.LongCountPercent
+		short += b.ShortCountPercent
+		above = append(above, DepthLevel{b.Price, long, short})
+	}
+	long, short = 0, 0
+	for j := i - 1; j >= 0; j-- {
+		long += buckets[j].LongCountPercent
+		short += buckets[j].ShortCountPercent
+		below = append(below, DepthLevel{buckets[j].Price, long, short})
+	}
+	return above, below
+}
+
+// Imbalance returns (long - short) / (long + short) over buckets.
+// It ranges from -1 (short only) to 1 (long only) and is 0 when buckets are empty.
+func Imbalance(buckets []oanda.OrderBookBucket) float64 {
+	var long, short float64
+	for _, b := range buckets {
+		long += b.LongCountPercent
+		short += b.ShortCountPercent
+	}
+	if long+short == 0 {
+		return 0
+	}
+	return (long - short) / (long + short)
+}
+
+// Walls returns buckets whose long or short percent is strictly greater than
+// the given percentile (0-100) of that side over the whole book, in ascending price order.
+func Walls(book *oanda.OrderBook, percentile float64) ([]Wall, error) {
+	if percentile < 0 || percentile > 100 || math.IsNaN(percentile) {
+		return nil, fmt.Errorf("percentile must be within [0, 100]: %v", percentile)
+	}
+	if book == nil || len(book.Buckets) == 0 {
+		return nil, ErrEmptyBook
+	}
+	longs := make([]float64, len(book.Buckets))
+	shorts := make([]float64, len(book.Buckets))
+	for i, b := range book.Buckets {
+		longs[i] = b.LongCountPercent
+		shorts[i] = b.ShortCountPercent
+	}
+	longThreshold := percentileOf(longs, percentile)
+	shortThreshold := percentileOf(shorts, percentile)
+	var walls []Wall
+	for _, b := range book.Buckets {
+		if b.LongCountPercent > longThreshold {
+			walls = append(walls, Wall{b, SideLong, b.LongCountPercent})
+		}
+		if b.ShortCountPercent > shortThreshold {
+			walls = append(walls, Wall{b, SideShort, b.ShortCountPercent})
+		}
+	}
+	return walls, nil
+}
+
+// Rebucket aggregates the buckets of book into buckets of the given width.
+// Each new bucket is priced at the lower bound of its range, a multiple of width.
+func Rebucket(book *oanda.OrderBook, width oanda.Price) (*oanda.OrderBook, error) {
+	if !(width > 0) {
+		return nil, fmt.Errorf("bucket width must be positive: %v", width)
+	}
+	if book == nil {
+		return nil, ErrEmptyBook
+	}
+	rebucketed := *book
+	rebucketed.BucketWidth = oanda.NewDecimal(width)
+	rebucketed.Buckets = nil
+	rebucketed.Raw = nil
+	for _, b := range book.Buckets {
+		p := floorToWidth(b.Price, width)
+		n := len(rebucketed.Buckets)
+		if n == 0 || rebucketed.Buckets[n-1].Price != p {
+			rebucketed.Buckets = append(rebucketed.Buckets, oanda.OrderBookBucket{Price: p})
+			n++
+		}
+		rebucketed.Buckets[n-1].LongCountPercent += b.LongCountPercent
+		rebucketed.Buckets[n-1].ShortCountPercent += b.ShortCountPercent
+	}
+	return &rebucketed, nil
+}
+
+// floorToWidth rounds price down to a multiple of width, tolerating the float error of decimal prices.
+func floorToWidth(price, width oanda.Price) oanda.Price {
+	n := math.Floor(float64(price)/float64(width) + 1e-9)
+	return oanda.Price(math.Round(n*float64(width)*1e9) / 1e9)
+}
+
+// percentileOf returns the p-th percentile of values by linear interpolation.
+func percentileOf(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
@@ -0,0 +1,238 @@
+package orderbook
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+const iterations = 500
+
+// randomBook returns a book of up to 200 buckets of a random width in ascending price order,
+// whose percents are random and possibly zero.
+func randomBook(r *rand.Rand) *oanda.OrderBook {
+	widths := []oanda.Price{0.05, 0.0005, 0.25, 1}
+	width := widths[r.Intn(len(widths))]
+	start := math.Round(r.Float64()*1000/float64(width)) * float64(width)
+	book := &oanda.OrderBook{
+		Instrument:  oanda.InstrumentUSDJPY,
+		BucketWidth: oanda.NewDecimal(width),
+	}
+	n := r.Intn(200)
+	for i := 0; i < n; i++ {
+		b := oanda.OrderBookBucket{Price: oanda.Price(start + float64(i)*float64(width))}
+		if r.Intn(4) > 0 {
+			b.LongCountPercent = math.Round(r.Float64()*5*10000) / 10000
+			b.ShortCountPercent = math.Round(r.Float64()*5*10000) / 10000
+		}
+		book.Buckets = append(book.Buckets, b)
+	}
+	if n > 0 {
+		book.Price = randomPrice(r, book)
+	}
+	return book
+}
+
+// randomPrice returns a price within or slightly outside of the buckets of book.
+func randomPrice(r *rand.Rand, book *oanda.OrderBook) oanda.Price {
+	width := float64(book.BucketWidth.Price())
+	lo := float64(book.Buckets[0].Price) - 5*width
+	hi := float64(book.Buckets[len(book.Buckets)-1].Price) + 5*width
+	return oanda.Price(lo + r.Float64()*(hi-lo))
+}
+
+func totals(buckets []oanda.OrderBookBucket) (long, short float64) {
+	for _, b := range buckets {
+		long += b.LongCountPercent
+		short += b.ShortCountPercent
+	}
+	return long, short
+}
+
+func TestCumulativeDepthIsMonotonic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for it := 0; it < iterations; it++ {
+		book := randomBook(r)
+		if len(book.Buckets) == 0 {
+			continue
+		}
+		price := randomPrice(r, book)
+		above, below := CumulativeDepth(book, price)
+		if len(above)+len(below) != len(book.Buckets) {
+			t.Fatalf("depth has %d levels, book has %d buckets", len(above)+len(below), len(book.Buckets))
+		}
+		for _, side := range [][]DepthLevel{above, below} {
+			for i := 1; i < len(side); i++ {
+				if side[i].Long < side[i-1].Long || side[i].Short < side[i-1].Short {
+					t.Fatalf("depth decreased from %+v to %+v", side[i-1], side[i])
+				}
+			}
+		}
+		for i := range above {
+			if above[i].Price <= price || (i > 0 && above[i].Price <= above[i-1].Price) {
+				t.Fatalf("above level %+v is out of order at price %v", above[i], price)
+			}
+		}
+		for i := range below {
+			if below[i].Price > price || (i > 0 && below[i].Price >= below[i-1].Price) {
+				t.Fatalf("below level %+v is out of order at price %v", below[i], price)
+			}
+		}
+		long, short := totals(book.Buckets)
+		var gotLong, gotShort float64
+		if len(above) > 0 {
+			gotLong, gotShort = above[len(above)-1].Long, above[len(above)-1].Short
+		}
+		if len(below) > 0 {
+			gotLong += below[len(below)-1].Long
+			gotShort += below[len(below)-1].Short
+		}
+		if math.Abs(gotLong-long) > 1e-9 || math.Abs(gotShort-short) > 1e-9 {
+			t.Fatalf("depth totals (%v, %v), want (%v, %v)", gotLong, gotShort, long, short)
+		}
+	}
+}
+
+func TestRebucketConservesPercent(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for it := 0; it < iterations; it++ {
+		book := randomBook(r)
+		width := book.BucketWidth.Price() * oanda.Price(1+r.Intn(10))
+		rebucketed, err := Rebucket(book, width)
+		if err != nil {
+			t.Fatal(err)
+		}
+		long, short := totals(book.Buckets)
+		gotLong, gotShort := totals(rebucketed.Buckets)
+		if math.Abs(gotLong-long) > 1e-9 || math.Abs(gotShort-short) > 1e-9 {
+			t.Fatalf("rebucketed totals (%v, %v), want (%v, %v)", gotLong, gotShort, long, short)
+		}
+		if len(rebucketed.Buckets) > len(book.Buckets) {
+			t.Fatalf("rebucketed into %d buckets from %d", len(rebucketed.Buckets), len(book.Buckets))
+		}
+		for i := 1; i < len(rebucketed.Buckets); i++ {
+			if rebucketed.Buckets[i].Price <= rebucketed.Buckets[i-1].Price {
+				t.Fatalf("rebucketed prices are not ascending: %v", rebucketed.Buckets)
+			}
+		}
+		for _, b := range rebucketed.Buckets {
+			if n := float64(b.Price) / float64(width); math.Abs(n-math.Round(n)) > 1e-6 {
+				t.Fatalf("bucket price %v is not a multiple of %v", b.Price, width)
+			}
+		}
+	}
+}
+
+func TestWindowStaysInBounds(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for it := 0; it < iterations; it++ {
+		book := randomBook(r)
+		n := r.Intn(20)
+		if len(book.Buckets) == 0 {
+			if _, err := Window(book, 100, n); err != ErrEmptyBook {
+				t.Fatalf("err = %v, want ErrEmptyBook", err)
+			}
+			continue
+		}
+		price := randomPrice(r, book)
+		window, err := Window(book, price, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(window) == 0 || len(window) > 2*n+1 {
+			t.Fatalf("window of %d buckets for n = %d", len(window), n)
+		}
+		nearest, i, err := Nearest(book, price)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, b := range window {
+			found = found || b == nearest
+		}
+		if !found {
+			t.Fatalf("window %v does not contain the nearest bucket %+v", window, nearest)
+		}
+		want := 1 + min(n, i) + min(n, len(book.Buckets)-1-i)
+		if len(window) != want {
+			t.Fatalf("window of %d buckets, want %d", len(window), want)
+		}
+	}
+	if _, err := Window(randomBook(r), 100, -1); err == nil {
+		t.Error("negative window size is accepted")
+	}
+}
+
+func TestNearest(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	for it := 0; it < iterations; it++ {
+		book := randomBook(r)
+		if len(book.Buckets) == 0 {
+			continue
+		}
+		price := randomPrice(r, book)
+		nearest, _, err := Nearest(book, price)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := math.Abs(float64(nearest.Price - price))
+		for _, b := range book.Buckets {
+			if math.Abs(float64(b.Price-price)) < d {
+				t.Fatalf("bucket %v is nearer to %v than %v", b.Price, price, nearest.Price)
+			}
+		}
+	}
+}
+
+func TestImbalanceRange(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for it := 0; it < iterations; it++ {
+		book := randomBook(r)
+		imbalance := Imbalance(book.Buckets)
+		if imbalance < -1 || imbalance > 1 || math.IsNaN(imbalance) {
+			t.Fatalf("imbalance %v is out of [-1, 1]", imbalance)
+		}
+	}
+	if got := Imbalance([]oanda.OrderBookBucket{{LongCountPercent: 1}}); got != 1 {
+		t.Errorf("imbalance of long only = %v, want 1", got)
+	}
+}
+
+func TestWallsExceedThreshold(t *testing.T) {
+	r := rand.New(rand.NewSource(6))
+	for it := 0; it < iterations; it++ {
+		book := randomBook(r)
+		if len(book.Buckets) == 0 {
+			continue
+		}
+		walls, err := Walls(book, 90)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(walls) > 2*len(book.Buckets) {
+			t.Fatalf("%d walls from %d buckets", len(walls), len(book.Buckets))
+		}
+		all, err := Walls(book, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 0 {
+			t.Fatalf("walls above the 100th percentile: %v", all)
+		}
+	}
+	if _, err := Walls(&oanda.OrderBook{}, 50); err != ErrEmptyBook {
+		t.Errorf("err = %v, want ErrEmptyBook", err)
+	}
+	if _, err := Walls(randomBook(r), math.NaN()); err == nil {
+		t.Error("NaN percentile is accepted")
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
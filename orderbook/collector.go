@@ -0,0 +1,124 @@
+package orderbook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+const (
+	// SnapshotInterval is how often OANDA publishes an order book snapshot.
+	SnapshotInterval = 20 * time.Minute
+	// DefaultRequestInterval is the default minimum interval between requests of Collector.
+	DefaultRequestInterval = 200 * time.Millisecond
+	// DefaultRetries is the default number of retries of a throttled or failed request.
+	DefaultRetries = 5
+
+	// unpublishedPeriod is how long a missing snapshot may still be published, during which it is not recorded as empty.
+	unpublishedPeriod = 2 * SnapshotInterval
+)
+
+// Fetcher fetches an order book snapshot. *oanda.Client implements it.
+type Fetcher interface {
+	FetchOrderBook(instrument oanda.Instrument, dateTime *time.Time) (*oanda.OrderBook, error)
+}
+
+// Collector backfills order book snapshots of OANDA API into a Store.
+//
+// Times already collected are never requested again, even when OANDA API returned a snapshot taken
+// at another time or none at all, so an interrupted backfill resumes where it stopped when it is run again.
+type Collector struct {
+	Fetcher Fetcher
+	Store   *Store
+	// RequestInterval is the minimum interval between two requests.
+	RequestInterval time.Duration
+	// Retries is the number of retries of a request throttled with HTTP 429 or failed with HTTP 5xx.
+	// Retries back off exponentially starting from RequestInterval.
+	Retries int
+}
+
+// NewCollector constructs a Collector with the default request interval and retries.
+func NewCollector(fetcher Fetcher, store *Store) *Collector {
+	return &Collector{
+		Fetcher:         fetcher,
+		Store:           store,
+		RequestInterval: DefaultRequestInterval,
+		Retries:         DefaultRetries,
+	}
+}
+
+// Backfill fetches the snapshots of instruments taken in [from, to) and stores them.
+// Times for which OANDA API has no snapshot, e.g. weekends, are skipped.
+func (c *Collector) Backfill(ctx context.Context, instruments []oanda.Instrument, from, to time.Time) error {
+	start := from.UTC().Truncate(SnapshotInterval)
+	if start.Before(from) {
+		start = start.Add(SnapshotInterval)
+	}
+	var last time.Time
+	for t := start; t.Before(to); t = t.Add(SnapshotInterval) {
+		for _, instrument := range instruments {
+			ok, err := c.Store.Collected(instrument, t)
+			if err != nil {
+				return err
+			}
+			if ok {
+				continue
+			}
+			book, err := c.fetch(ctx, instrument, t, &last)
+			if isStatus(err, http.StatusNotFound) {
+				log.Printf("no order book snapshot (instrument=%s, time=%s)", instrument, t.Format(time.RFC3339))
+				if time.Since(t) < unpublishedPeriod {
+					// the snapshot may not be published yet.
+					continue
+				}
+				book, err = nil, nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to fetch order book (instrument=%s, time=%s): %w", instrument, t.Format(time.RFC3339), err)
+			}
+			if err := c.Store.Record(instrument, t, book); err != nil {
+				return fmt.Errorf("failed to store order book: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// fetch fetches a snapshot, keeping RequestInterval since last and retrying throttled requests.
+func (c *Collector) fetch(ctx context.Context, instrument oanda.Instrument, t time.Time, last *time.Time) (*oanda.OrderBook, error) {
+	backoff := c.RequestInterval
+	for attempt := 0; ; attempt++ {
+		if err := util.SleepContext(ctx, time.Until(last.Add(c.RequestInterval))); err != nil {
+			return nil, err
+		}
+		*last = time.Now()
+		book, err := c.Fetcher.FetchOrderBook(instrument, &t)
+		if err == nil {
+			return book, nil
+		}
+		if attempt >= c.Retries || !isRetryable(err) {
+			return nil, err
+		}
+		log.Printf("retrying to fetch order book in %s: %v", backoff, err)
+		if err := util.SleepContext(ctx, backoff); err != nil {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+func isStatus(err error, status int) bool {
+	var apiErr *oanda.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+func isRetryable(err error) bool {
+	var apiErr *oanda.APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500)
+}
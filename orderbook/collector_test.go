@@ -0,0 +1,120 @@
+package orderbook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// fakeFetcher returns snapshots taken a minute after the requested time, and none on Saturdays.
+type fakeFetcher struct {
+	requests []time.Time
+}
+
+func (f *fakeFetcher) FetchOrderBook(instrument oanda.Instrument, dateTime *time.Time) (*oanda.OrderBook, error) {
+	f.requests = append(f.requests, *dateTime)
+	if dateTime.Weekday() == time.Saturday {
+		return nil, &oanda.APIError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}
+	}
+	return &oanda.OrderBook{
+		Instrument:  instrument,
+		Time:        dateTime.Add(time.Minute),
+		Price:       105,
+		BucketWidth: "0.05",
+		Buckets:     []oanda.OrderBookBucket{{Price: 105, LongCountPercent: 1, ShortCountPercent: 2}},
+	}, nil
+}
+
+func openTempStore(t *testing.T) *Store {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "orderbook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	store, err := OpenStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestBackfillResumesOnRequestedTimes(t *testing.T) {
+	store := openTempStore(t)
+	fetcher := &fakeFetcher{}
+	collector := NewCollector(fetcher, store)
+	collector.RequestInterval = 0
+	// Friday 22:00 to Saturday 02:00 UTC.
+	from := time.Date(2020, 9, 18, 22, 0, 0, 0, time.UTC)
+	to := from.Add(4 * time.Hour)
+	if err := collector.Backfill(context.Background(), []oanda.Instrument{oanda.InstrumentUSDJPY}, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if len(fetcher.requests) != 12 {
+		t.Fatalf("requested %d snapshots, want 12", len(fetcher.requests))
+	}
+
+	// a new store reads the recorded times from disk.
+	reopened, err := OpenStore(store.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher.requests = nil
+	collector.Store = reopened
+	if err := collector.Backfill(context.Background(), []oanda.Instrument{oanda.InstrumentUSDJPY}, from, to); err != nil {
+		t.Fatal(err)
+	}
+	if len(fetcher.requests) != 0 {
+		t.Errorf("refetched %v", fetcher.requests)
+	}
+
+	books, err := reopened.Range(oanda.InstrumentUSDJPY, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 6 {
+		t.Fatalf("stored %d snapshots, want 6", len(books))
+	}
+	if want := from.Add(time.Minute); !books[0].Time.Equal(want) {
+		t.Errorf("first snapshot at %s, want %s", books[0].Time, want)
+	}
+}
+
+func TestStorePutIsIdempotent(t *testing.T) {
+	store := openTempStore(t)
+	at := time.Date(2020, 9, 18, 10, 20, 0, 0, time.UTC)
+	book := &oanda.OrderBook{Instrument: oanda.InstrumentEURUSD, Time: at, Price: 1.18, BucketWidth: "0.0005"}
+	for i := 0; i < 3; i++ {
+		if err := store.Put(book); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, err := store.Has(oanda.InstrumentEURUSD, at); err != nil || !ok {
+		t.Fatalf("Has = %t, %v", ok, err)
+	}
+	if ok, _ := store.Has(oanda.InstrumentEURUSD, at.Add(SnapshotInterval)); ok {
+		t.Error("Has reports a snapshot which was not stored")
+	}
+	books, err := store.Range(oanda.InstrumentEURUSD, at, at.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(books) != 1 {
+		t.Errorf("stored %d snapshots, want 1", len(books))
+	}
+	latest, err := store.Latest(oanda.InstrumentEURUSD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.BucketWidth != "0.0005" {
+		t.Errorf("bucket width = %q", latest.BucketWidth)
+	}
+	if _, err := store.At(oanda.InstrumentEURUSD, at.Add(-time.Second)); err != ErrNotFound {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
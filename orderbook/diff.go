@@ -0,0 +1,197 @@
+package orderbook
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// BucketDelta is the change of a price level between two snapshots.
+type BucketDelta struct {
+	Price       oanda.Price
+	LongBefore  float64
+	LongAfter   float64
+	ShortBefore float64
+	ShortAfter  float64
+	// New is true when the level exists only in the later snapshot.
+	New bool
+	// Vanished is true when the level exists only in the earlier snapshot.
+	Vanished bool
+}
+
+// LongDelta returns the change of long count percent.
+func (d BucketDelta) LongDelta() float64 {
+	return d.LongAfter - d.LongBefore
+}
+
+// ShortDelta returns the change of short count percent.
+func (d BucketDelta) ShortDelta() float64 {
+	return d.ShortAfter - d.ShortBefore
+}
+
+// Mass is how one side of a snapshot is distributed around its mid price.
+type Mass struct {
+	Above float64 // total percent of buckets priced above the mid price
+	Below float64 // total percent of the other buckets
+	// Center is the percent weighted mean distance of the buckets from the mid price.
+	// It is positive when the mass sits above the mid price.
+	Center oanda.Price
+}
+
+// MassShift is how the long and short mass moved relative to the mid price between two snapshots.
+type MassShift struct {
+	LongBefore  Mass
+	LongAfter   Mass
+	ShortBefore Mass
+	ShortAfter  Mass
+}
+
+// BookDiff is the difference between two order book snapshots of the same instrument.
+type BookDiff struct {
+	Instrument oanda.Instrument
+	From       time.Time
+	To         time.Time
+	PriceFrom  oanda.Price
+	PriceTo    oanda.Price
+	// BucketWidth is the width both snapshots were aligned to.
+	BucketWidth oanda.Price
+	// Deltas has a delta for every price level of either snapshot in ascending price order.
+	Deltas []BucketDelta
+	Shift  MassShift
+}
+
+// NewLevels returns the deltas of levels which exist only in the later snapshot.
+func (d *BookDiff) NewLevels() []BucketDelta {
+	var levels []BucketDelta
+	for _, delta := range d.Deltas {
+		if delta.New {
+			levels = append(levels, delta)
+		}
+	}
+	return levels
+}
+
+// VanishedLevels returns the deltas of levels which exist only in the earlier snapshot.
+func (d *BookDiff) VanishedLevels() []BucketDelta {
+	var levels []BucketDelta
+	for _, delta := range d.Deltas {
+		if delta.Vanished {
+			levels = append(levels, delta)
+		}
+	}
+	return levels
+}
+
+// Diff compares snapshot a with the later snapshot b.
+// When their bucket edges differ, because the widths differ or the grids are offset, both are rebucketed
+// to the wider width before buckets are aligned by price.
+func Diff(a, b *oanda.OrderBook) (*BookDiff, error) {
+	if a == nil || b == nil {
+		return nil, ErrEmptyBook
+	}
+	if a.Instrument != b.Instrument {
+		return nil, fmt.Errorf("instruments differ: %s and %s", a.Instrument, b.Instrument)
+	}
+	width := bucketWidth(a)
+	if w := bucketWidth(b); w > width {
+		width = w
+	}
+	if width > 0 && (bucketWidth(a) != bucketWidth(b) || !samePrice(gridOffset(a, width), gridOffset(b, width))) {
+		var err error
+		if a, err = Rebucket(a, width); err != nil {
+			return nil, err
+		}
+		if b, err = Rebucket(b, width); err != nil {
+			return nil, err
+		}
+	}
+
+	diff := &BookDiff{
+		Instrument:  a.Instrument,
+		From:        a.Time,
+		To:          b.Time,
+		PriceFrom:   a.Price,
+		PriceTo:     b.Price,
+		BucketWidth: width,
+		Shift: MassShift{
+			LongBefore:  massOf(a, func(bu oanda.OrderBookBucket) float64 { return bu.LongCountPercent }),
+			LongAfter:   massOf(b, func(bu oanda.OrderBookBucket) float64 { return bu.LongCountPercent }),
+			ShortBefore: massOf(a, func(bu oanda.OrderBookBucket) float64 { return bu.ShortCountPercent }),
+			ShortAfter:  massOf(b, func(bu oanda.OrderBookBucket) float64 { return bu.ShortCountPercent }),
+		},
+	}
+	// merge both bucket lists, which are sorted by price.
+	i, j := 0, 0
+	for i < len(a.Buckets) || j < len(b.Buckets) {
+		switch {
+		case j == len(b.Buckets) || (i < len(a.Buckets) && a.Buckets[i].Price < b.Buckets[j].Price && !samePrice(a.Buckets[i].Price, b.Buckets[j].Price)):
+			diff.Deltas = append(diff.Deltas, BucketDelta{
+				Price:       a.Buckets[i].Price,
+				LongBefore:  a.Buckets[i].LongCountPercent,
+				ShortBefore: a.Buckets[i].ShortCountPercent,
+				Vanished:    true,
+			})
+			i++
+		case i == len(a.Buckets) || !samePrice(a.Buckets[i].Price, b.Buckets[j].Price):
+			diff.Deltas = append(diff.Deltas, BucketDelta{
+				Price:      b.Buckets[j].Price,
+				LongAfter:  b.Buckets[j].LongCountPercent,
+				ShortAfter: b.Buckets[j].ShortCountPercent,
+				New:        true,
+			})
+			j++
+		default:
+			diff.Deltas = append(diff.Deltas, BucketDelta{
+				Price:       b.Buckets[j].Price,
+				LongBefore:  a.Buckets[i].LongCountPercent,
+				LongAfter:   b.Buckets[j].LongCountPercent,
+				ShortBefore: a.Buckets[i].ShortCountPercent,
+				ShortAfter:  b.Buckets[j].ShortCountPercent,
+			})
+			i++
+			j++
+		}
+	}
+	return diff, nil
+}
+
+// bucketWidth returns BucketWidth of book, or the gap of its first two buckets when it is unknown.
+func bucketWidth(book *oanda.OrderBook) oanda.Price {
+	if width := book.BucketWidth.Price(); width > 0 || len(book.Buckets) < 2 {
+		return width
+	}
+	return book.Buckets[1].Price - book.Buckets[0].Price
+}
+
+// gridOffset returns how far the bucket edges of book are from the multiples of width.
+func gridOffset(book *oanda.OrderBook, width oanda.Price) oanda.Price {
+	if len(book.Buckets) == 0 {
+		return 0
+	}
+	p := book.Buckets[0].Price
+	return p - floorToWidth(p, width)
+}
+
+func massOf(book *oanda.OrderBook, percent func(oanda.OrderBookBucket) float64) Mass {
+	var m Mass
+	var weighted float64
+	for _, bu := range book.Buckets {
+		p := percent(bu)
+		if bu.Price > book.Price {
+			m.Above += p
+		} else {
+			m.Below += p
+		}
+		weighted += p * float64(bu.Price-book.Price)
+	}
+	if total := m.Above + m.Below; total > 0 {
+		m.Center = oanda.Price(weighted / total)
+	}
+	return m
+}
+
+func samePrice(a, b oanda.Price) bool {
+	return math.Abs(float64(a-b)) < 1e-9
+}
@@ -0,0 +1,149 @@
+package orderbook
+
+import (
+	"math"
+	"testing"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// book returns a USD_JPY book at price of the given width whose buckets are {price, long, short}.
+func book(price oanda.Price, width string, buckets ...[3]float64) *oanda.OrderBook {
+	b := &oanda.OrderBook{Instrument: oanda.InstrumentUSDJPY, Price: price, BucketWidth: oanda.Decimal(width)}
+	for _, bu := range buckets {
+		b.Buckets = append(b.Buckets, oanda.OrderBookBucket{Price: oanda.Price(bu[0]), LongCountPercent: bu[1], ShortCountPercent: bu[2]})
+	}
+	return b
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func checkDeltas(t *testing.T, name string, got, want []BucketDelta) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: deltas = %+v, want %+v", name, got, want)
+		return
+	}
+	for i := range got {
+		g, w := got[i], want[i]
+		if !samePrice(g.Price, w.Price) || !closeTo(g.LongBefore, w.LongBefore) || !closeTo(g.LongAfter, w.LongAfter) ||
+			!closeTo(g.ShortBefore, w.ShortBefore) || !closeTo(g.ShortAfter, w.ShortAfter) || g.New != w.New || g.Vanished != w.Vanished {
+			t.Errorf("%s: delta %d = %+v, want %+v", name, i, g, w)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  *oanda.OrderBook
+		width oanda.Price
+		want  []BucketDelta
+	}{
+		{
+			name:  "same width",
+			a:     book(105, "0.05", [3]float64{104.95, 1, 2}, [3]float64{105, 3, 4}, [3]float64{105.05, 1, 1}),
+			b:     book(105, "0.05", [3]float64{105, 2, 4}, [3]float64{105.05, 1, 3}, [3]float64{105.1, 0.5, 0}),
+			width: 0.05,
+			want: []BucketDelta{
+				{Price: 104.95, LongBefore: 1, ShortBefore: 2, Vanished: true},
+				{Price: 105, LongBefore: 3, LongAfter: 2, ShortBefore: 4, ShortAfter: 4},
+				{Price: 105.05, LongBefore: 1, LongAfter: 1, ShortBefore: 1, ShortAfter: 3},
+				{Price: 105.1, LongAfter: 0.5, New: true},
+			},
+		},
+		{
+			name:  "different widths",
+			a:     book(105, "0.05", [3]float64{105, 1, 2}, [3]float64{105.05, 3, 4}, [3]float64{105.1, 1, 1}),
+			b:     book(105, "0.1", [3]float64{105, 2, 2}, [3]float64{105.1, 1, 0}, [3]float64{105.2, 1, 1}),
+			width: 0.1,
+			want: []BucketDelta{
+				{Price: 105, LongBefore: 4, LongAfter: 2, ShortBefore: 6, ShortAfter: 2},
+				{Price: 105.1, LongBefore: 1, LongAfter: 1, ShortBefore: 1, ShortAfter: 0},
+				{Price: 105.2, LongAfter: 1, ShortAfter: 1, New: true},
+			},
+		},
+		{
+			name:  "same width on offset grids",
+			a:     book(105, "0.05", [3]float64{105, 1, 2}, [3]float64{105.05, 3, 4}),
+			b:     book(105, "0.05", [3]float64{105.025, 2, 2}, [3]float64{105.075, 1, 0}),
+			width: 0.05,
+			want: []BucketDelta{
+				{Price: 105, LongBefore: 1, LongAfter: 2, ShortBefore: 2, ShortAfter: 2},
+				{Price: 105.05, LongBefore: 3, LongAfter: 1, ShortBefore: 4, ShortAfter: 0},
+			},
+		},
+		{
+			name:  "every level new",
+			a:     book(105, "0.05"),
+			b:     book(105, "0.05", [3]float64{105, 1, 2}),
+			width: 0.05,
+			want:  []BucketDelta{{Price: 105, LongAfter: 1, ShortAfter: 2, New: true}},
+		},
+	}
+	for _, tt := range tests {
+		diff, err := Diff(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !samePrice(diff.BucketWidth, tt.width) {
+			t.Errorf("%s: bucket width = %v, want %v", tt.name, diff.BucketWidth, tt.width)
+		}
+		checkDeltas(t, tt.name, diff.Deltas, tt.want)
+		var newLevels, vanished int
+		for _, d := range tt.want {
+			if d.New {
+				newLevels++
+			}
+			if d.Vanished {
+				vanished++
+			}
+		}
+		if n := len(diff.NewLevels()); n != newLevels {
+			t.Errorf("%s: %d new levels, want %d", tt.name, n, newLevels)
+		}
+		if n := len(diff.VanishedLevels()); n != vanished {
+			t.Errorf("%s: %d vanished levels, want %d", tt.name, n, vanished)
+		}
+	}
+}
+
+func TestDiffMassShift(t *testing.T) {
+	a := book(105.02, "0.05", [3]float64{105, 2, 1}, [3]float64{105.05, 2, 3})
+	b := book(105.02, "0.05", [3]float64{104.95, 0, 4}, [3]float64{105.05, 4, 0})
+	diff, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		got          Mass
+		above, below float64
+		center       oanda.Price
+	}{
+		{"long before", diff.Shift.LongBefore, 2, 2, 0.005},
+		{"long after", diff.Shift.LongAfter, 4, 0, 0.03},
+		{"short before", diff.Shift.ShortBefore, 3, 1, 0.0175},
+		{"short after", diff.Shift.ShortAfter, 0, 4, -0.07},
+	}
+	for _, tt := range tests {
+		if !closeTo(tt.got.Above, tt.above) || !closeTo(tt.got.Below, tt.below) || !samePrice(tt.got.Center, tt.center) {
+			t.Errorf("%s = %+v, want above %v, below %v and center %v", tt.name, tt.got, tt.above, tt.below, tt.center)
+		}
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	a := book(105, "0.05")
+	if _, err := Diff(a, nil); err != ErrEmptyBook {
+		t.Errorf("err = %v, want ErrEmptyBook", err)
+	}
+	b := book(1.18, "0.0005")
+	b.Instrument = oanda.InstrumentEURUSD
+	if _, err := Diff(a, b); err == nil {
+		t.Error("diff of different instruments succeeds")
+	}
+}
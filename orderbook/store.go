@@ -0,0 +1,317 @@
+package orderbook
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+// ErrNotFound is returned when the store has no snapshot for a query.
+var ErrNotFound = errors.New("order book snapshot not found")
+
+const (
+	slotFileSuffix = ".slots"
+)
+
+// Store keeps order book snapshots on local disk.
+//
+// Snapshots are stored as one gzip compressed JSON Lines file per instrument and UTC day:
+//
+//	<dir>/<INSTRUMENT>/<YYYY-MM-DD>.jsonl.gz
+//
+// Each line is the order book object exactly as OANDA API sent it. The times a Collector requested
+// are recorded next to them in <YYYY-MM-DD>.slots, one RFC 3339 time per line.
+//
+// The times of the snapshots of a day are read once and kept in memory, so a Store assumes
+// it is the only writer of its directory.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+	// index is the times of the snapshots and the requested times of a day file by its path.
+	index map[string]*dayIndex
+}
+
+type dayIndex struct {
+	snapshots map[int64]bool
+	slots     map[int64]bool
+}
+
+// OpenStore opens the store in dir, creating the directory if it does not exist.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+	return &Store{dir: dir, index: map[string]*dayIndex{}}, nil
+}
+
+// Put stores book. A snapshot already stored for the same instrument and time is left as is.
+func (s *Store) Put(book *oanda.OrderBook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.put(book)
+}
+
+// Record stores book, which was fetched for the requested time, and records that the time was collected
+// even when book was taken at another time. A nil book records that OANDA API had no snapshot for it.
+func (s *Store) Record(instrument oanda.Instrument, requested time.Time, book *oanda.OrderBook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if book != nil {
+		if err := s.put(book); err != nil {
+			return err
+		}
+	}
+	index, err := s.dayIndex(instrument, requested)
+	if err != nil {
+		return err
+	}
+	if index.slots[requested.UnixNano()] {
+		return nil
+	}
+	path := s.slotPath(instrument, requested)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create instrument directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := fmt.Fprintln(f, requested.UTC().Format(time.RFC3339Nano)); err != nil {
+		util.SafeClose(f)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	index.slots[requested.UnixNano()] = true
+	return nil
+}
+
+// Collected reports whether t was recorded by Record or a snapshot of instrument at exactly t is stored.
+func (s *Store) Collected(instrument oanda.Instrument, t time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.dayIndex(instrument, t)
+	if err != nil {
+		return false, err
+	}
+	return index.slots[t.UnixNano()] || index.snapshots[t.UnixNano()], nil
+}
+
+func (s *Store) put(book *oanda.OrderBook) error {
+	index, err := s.dayIndex(book.Instrument, book.Time)
+	if err != nil {
+		return err
+	}
+	if index.snapshots[book.Time.UnixNano()] {
+		return nil
+	}
+	line, err := json.Marshal(book)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order book: %w", err)
+	}
+	path := s.dayPath(book.Instrument, book.Time)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create instrument directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	// every Put appends a gzip member; gzip.Reader reads concatenated members as one stream.
+	zw := gzip.NewWriter(f)
+	if _, err := zw.Write(append(line, '\n')); err != nil {
+		util.SafeClose(f)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := zw.Close(); err != nil {
+		util.SafeClose(f)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	index.snapshots[book.Time.UnixNano()] = true
+	return nil
+}
+
+// Has reports whether a snapshot of instrument at exactly t is stored.
+func (s *Store) Has(instrument oanda.Instrument, t time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	index, err := s.dayIndex(instrument, t)
+	if err != nil {
+		return false, err
+	}
+	return index.snapshots[t.UnixNano()], nil
+}
+
+// dayIndex returns the index of the day of t, reading the files of the day the first time.
+func (s *Store) dayIndex(instrument oanda.Instrument, t time.Time) (*dayIndex, error) {
+	path := s.dayPath(instrument, t)
+	if index, ok := s.index[path]; ok {
+		return index, nil
+	}
+	books, err := s.readDay(instrument, t)
+	if err != nil {
+		return nil, err
+	}
+	index := &dayIndex{snapshots: map[int64]bool{}, slots: map[int64]bool{}}
+	for _, b := range books {
+		index.snapshots[b.Time.UnixNano()] = true
+	}
+	slotPath := s.slotPath(instrument, t)
+	b, err := ioutil.ReadFile(slotPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", slotPath, err)
+	}
+	for _, line := range strings.Fields(string(b)) {
+		slot, err := time.Parse(time.RFC3339Nano, line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse a line of %s: %w", slotPath, err)
+		}
+		index.slots[slot.UnixNano()] = true
+	}
+	s.index[path] = index
+	return index, nil
+}
+
+// Range returns the snapshots of instrument in [from, to) in chronological order.
+func (s *Store) Range(instrument oanda.Instrument, from, to time.Time) ([]*oanda.OrderBook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var books []*oanda.OrderBook
+	for day := util.TruncateDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		bs, err := s.readDay(instrument, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bs {
+			if !b.Time.Before(from) && b.Time.Before(to) {
+				books = append(books, b)
+			}
+		}
+	}
+	return books, nil
+}
+
+// At returns the latest snapshot of instrument taken at or before t.
+func (s *Store) At(instrument oanda.Instrument, t time.Time) (*oanda.OrderBook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	days, err := s.days(instrument)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(days) - 1; i >= 0; i-- {
+		if days[i].After(t) {
+			continue
+		}
+		books, err := s.readDay(instrument, days[i])
+		if err != nil {
+			return nil, err
+		}
+		for j := len(books) - 1; j >= 0; j-- {
+			if !books[j].Time.After(t) {
+				return books[j], nil
+			}
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Latest returns the newest snapshot of instrument.
+func (s *Store) Latest(instrument oanda.Instrument) (*oanda.OrderBook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	days, err := s.days(instrument)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(days) - 1; i >= 0; i-- {
+		books, err := s.readDay(instrument, days[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(books) > 0 {
+			return books[len(books)-1], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *Store) dayPath(instrument oanda.Instrument, t time.Time) string {
+	return filepath.Join(s.dir, string(instrument), t.UTC().Format("2006-01-02")+util.DayFileSuffix)
+}
+
+func (s *Store) slotPath(instrument oanda.Instrument, t time.Time) string {
+	return filepath.Join(s.dir, string(instrument), t.UTC().Format("2006-01-02")+slotFileSuffix)
+}
+
+// days returns the days for which instrument has a file, in ascending order.
+func (s *Store) days(instrument oanda.Instrument) ([]time.Time, error) {
+	files, err := ioutil.ReadDir(filepath.Join(s.dir, string(instrument)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots of %s: %w", instrument, err)
+	}
+	var days []time.Time
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), util.DayFileSuffix) {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", strings.TrimSuffix(f.Name(), util.DayFileSuffix))
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+// readDay returns the snapshots of instrument on the UTC day of t in chronological order.
+func (s *Store) readDay(instrument oanda.Instrument, t time.Time) ([]*oanda.OrderBook, error) {
+	path := s.dayPath(instrument, t)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer util.SafeClose(f)
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var books []*oanda.OrderBook
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var b oanda.OrderBook
+		if err := json.Unmarshal(scanner.Bytes(), &b); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal a line of %s: %w", path, err)
+		}
+		books = append(books, &b)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	sort.Slice(books, func(i, j int) bool { return books[i].Time.Before(books[j].Time) })
+	return books, nil
+}
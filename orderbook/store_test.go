@@ -0,0 +1,114 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+)
+
+// putBooks stores a snapshot of USD_JPY at each of times.
+func putBooks(t *testing.T, store *Store, times ...time.Time) {
+	t.Helper()
+	for _, at := range times {
+		if err := store.Put(&oanda.OrderBook{Instrument: oanda.InstrumentUSDJPY, Time: at, Price: 105, BucketWidth: "0.05"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func bookTimes(books []*oanda.OrderBook) []time.Time {
+	var times []time.Time
+	for _, b := range books {
+		times = append(times, b.Time)
+	}
+	return times
+}
+
+func TestStoreRangeAcrossDays(t *testing.T) {
+	store := openTempStore(t)
+	friday := time.Date(2020, 9, 18, 23, 40, 0, 0, time.UTC)
+	saturday := time.Date(2020, 9, 19, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2020, 9, 21, 0, 20, 0, 0, time.UTC)
+	// stored out of order; Range sorts them.
+	putBooks(t, store, monday, saturday.Add(SnapshotInterval), friday, saturday)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []time.Time
+	}{
+		{"from is inclusive and to exclusive", friday, saturday.Add(SnapshotInterval), []time.Time{friday, saturday}},
+		{"over a day without a file", saturday.Add(time.Minute), monday.Add(time.Minute), []time.Time{saturday.Add(SnapshotInterval), monday}},
+		{"every day", friday.Add(-time.Hour), monday.Add(time.Hour), []time.Time{friday, saturday, saturday.Add(SnapshotInterval), monday}},
+		{"between snapshots", friday.Add(time.Minute), saturday, nil},
+		{"to before from", monday, friday, nil},
+	}
+	for _, tt := range tests {
+		books, err := store.Range(oanda.InstrumentUSDJPY, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := bookTimes(books)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+	if books, err := store.Range(oanda.InstrumentEURUSD, friday, monday); err != nil || len(books) != 0 {
+		t.Errorf("range of another instrument = %v, %v, want none", bookTimes(books), err)
+	}
+}
+
+func TestStoreAtAndLatest(t *testing.T) {
+	store := openTempStore(t)
+	if _, err := store.Latest(oanda.InstrumentUSDJPY); err != ErrNotFound {
+		t.Errorf("latest of an empty store: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.At(oanda.InstrumentUSDJPY, time.Now()); err != ErrNotFound {
+		t.Errorf("at of an empty store: err = %v, want ErrNotFound", err)
+	}
+
+	friday := time.Date(2020, 9, 18, 23, 40, 0, 0, time.UTC)
+	monday := time.Date(2020, 9, 21, 0, 20, 0, 0, time.UTC)
+	putBooks(t, store, friday, monday)
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"exactly at a snapshot", friday, friday},
+		{"between snapshots of the same day", friday.Add(time.Minute), friday},
+		{"over days without a file", monday.Add(-time.Hour), friday},
+		{"after the last snapshot", monday.Add(48 * time.Hour), monday},
+	}
+	for _, tt := range tests {
+		book, err := store.At(oanda.InstrumentUSDJPY, tt.at)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !book.Time.Equal(tt.want) {
+			t.Errorf("%s: snapshot at %s, want %s", tt.name, book.Time, tt.want)
+		}
+	}
+	if _, err := store.At(oanda.InstrumentUSDJPY, friday.Add(-time.Second)); err != ErrNotFound {
+		t.Errorf("at before the first snapshot: err = %v, want ErrNotFound", err)
+	}
+	latest, err := store.Latest(oanda.InstrumentUSDJPY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.Time.Equal(monday) {
+		t.Errorf("latest at %s, want %s", latest.Time, monday)
+	}
+	if _, err := store.Latest(oanda.InstrumentEURUSD); err != ErrNotFound {
+		t.Errorf("latest of another instrument: err = %v, want ErrNotFound", err)
+	}
+}
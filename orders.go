@@ -1,43 +1,68 @@
 package oanda
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
+type takeProfitDetails struct {
+	Price       string     `json:"price"`
+	TimeInForce string     `json:"timeInForce"`
+	GtdTime     *time.Time `json:"gtdTime"`
+}
+
+type stopLossDetails struct {
+	Price       string     `json:"price"`
+	Distance    string     `json:"distance"`
+	TimeInForce string     `json:"timeInForce"`
+	GtdTime     *time.Time `json:"gtdTime"`
+}
+
+type trailingStopLossDetails struct {
+	Distance    string     `json:"distance"`
+	TimeInForce string     `json:"timeInForce"`
+	GtdTime     *time.Time `json:"gtdTime"`
+}
+
+// orderInfo is the union of the fields of every order type defined by OANDA API.
+// Which of them are set depends on Type.
 type orderInfo struct {
-	ClientExtensions *struct {
-		Comment string `json:"comment,omitempty"`
-		ID      string `json:"id,omitempty"`
-		Tag     string `json:"tag,omitempty"`
-	} `json:"clientExtensions,omitempty"`
-	TakeProfitOnFill *struct {
-		Price       string `json:"price"`
-		TimeInForce string `json:"timeInForce"`
-	} `json:"takeProfitOnFill"`
-	StopLossOnFill *struct {
-		Price       string `json:"price"`
-		TimeInForce string `json:"timeInForce"`
-	} `json:"stopLossOnFill"`
-	TrailingStopLossOnFill *struct {
-		Distance    string `json:"distance"`
-		TimeInForce string `json:"timeInForce"`
-	} `json:"trailingStopLossOnFill"`
-	CreateTime       *time.Time `json:"createTime"`
-	ID               string     `json:"id"`
-	Instrument       string     `json:"instrument,omitempty"`
-	PartialFill      string     `json:"partialFill"`
-	PositionFill     string     `json:"positionFill"`
-	Price            string     `json:"price"`
-	ReplacesOrderID  string     `json:"replacesOrderID,omitempty"`
-	State            string     `json:"state"`
-	TimeInForce      string     `json:"timeInForce"`
-	GtdTime          *time.Time `json:"gtdTime"`
-	TriggerCondition string     `json:"triggerCondition"`
-	Type             string     `json:"type"`
-	Units            string     `json:"units,omitempty"`
+	ClientExtensions        *ClientExtensions        `json:"clientExtensions,omitempty"`
+	TradeClientExtensions   *ClientExtensions        `json:"tradeClientExtensions,omitempty"`
+	TakeProfitOnFill        *takeProfitDetails       `json:"takeProfitOnFill"`
+	StopLossOnFill          *stopLossDetails         `json:"stopLossOnFill"`
+	TrailingStopLossOnFill  *trailingStopLossDetails `json:"trailingStopLossOnFill"`
+	CreateTime              *time.Time               `json:"createTime"`
+	ID                      string                   `json:"id"`
+	Instrument              string                   `json:"instrument,omitempty"`
+	PartialFill             string                   `json:"partialFill"`
+	PositionFill            string                   `json:"positionFill"`
+	Price                   string                   `json:"price"`
+	PriceBound              string                   `json:"priceBound"`
+	Distance                string                   `json:"distance"`
+	TrailingStopValue       string                   `json:"trailingStopValue"`
+	TradeID                 string                   `json:"tradeID"`
+	ClientTradeID           string                   `json:"clientTradeID"`
+	ReplacesOrderID         string                   `json:"replacesOrderID,omitempty"`
+	ReplacedByOrderID       string                   `json:"replacedByOrderID,omitempty"`
+	State                   string                   `json:"state"`
+	TimeInForce             string                   `json:"timeInForce"`
+	GtdTime                 *time.Time               `json:"gtdTime"`
+	TriggerCondition        string                   `json:"triggerCondition"`
+	Type                    string                   `json:"type"`
+	Units                   string                   `json:"units,omitempty"`
+	FillingTransactionID    string                   `json:"fillingTransactionID"`
+	FilledTime              *time.Time               `json:"filledTime"`
+	TradeOpenedID           string                   `json:"tradeOpenedID"`
+	TradeReducedID          string                   `json:"tradeReducedID"`
+	TradeClosedIDs          []string                 `json:"tradeClosedIDs"`
+	CancellingTransactionID string                   `json:"cancellingTransactionID"`
+	CancelledTime           *time.Time               `json:"cancelledTime"`
 }
 
 type retrievedOrders struct {
@@ -46,185 +71,485 @@ type retrievedOrders struct {
 }
 
 type OrderPayloadBody struct {
-	Units            int        `json:"units"`
-	Instrument       string     `json:"instrument"`
-	TimeInForce      string     `json:"timeInForce"`
-	GtdTime          *time.Time `json:"gtdTime"`
-	Type             string     `json:"type"`
-	PositionFill     string     `json:"positionFill,omitempty"`
-	Price            string     `json:"price,omitempty"`
-	TakeProfitOnFill *onFillStr `json:"takeProfitOnFill,omitempty"`
-	StopLossOnFill   *onFillStr `json:"stopLossOnFill,omitempty"`
+	Units                  int               `json:"units,omitempty"`
+	Instrument             string            `json:"instrument,omitempty"`
+	TradeID                string            `json:"tradeID,omitempty"`
+	TimeInForce            string            `json:"timeInForce,omitempty"`
+	GtdTime                *time.Time        `json:"gtdTime,omitempty"`
+	Type                   string            `json:"type"`
+	PositionFill           string            `json:"positionFill,omitempty"`
+	TriggerCondition       string            `json:"triggerCondition,omitempty"`
+	Price                  string            `json:"price,omitempty"`
+	PriceBound             string            `json:"priceBound,omitempty"`
+	Distance               string            `json:"distance,omitempty"`
+	ClientExtensions       *ClientExtensions `json:"clientExtensions,omitempty"`
+	TradeClientExtensions  *ClientExtensions `json:"tradeClientExtensions,omitempty"`
+	TakeProfitOnFill       *onFillStr        `json:"takeProfitOnFill,omitempty"`
+	StopLossOnFill         *onFillStr        `json:"stopLossOnFill,omitempty"`
+	TrailingStopLossOnFill *onFillStr        `json:"trailingStopLossOnFill,omitempty"`
 }
 
 type OrderPayload struct {
 	Order OrderPayloadBody `json:"order"`
 }
 
+// ClientExtensions is the client-defined id, tag and comment attached to an order or trade.
+type ClientExtensions struct {
+	Comment string `json:"comment,omitempty"`
+	ID      string `json:"id,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+}
+
 type Order struct {
-	TakeProfitOnFill       *onFill
-	StopLossOnFill         *onFill
-	TrailingStopLossOnFill *onFill
-	CreateTime             *time.Time
-	ID                     orderID
-	Instrument             instrument
-	PartialFill            string
-	PositionFill           string
-	Price                  Price
-	State                  string
-	TimeInForce            timeInForce
-	GtdTime                *time.Time
-	TriggerCondition       string
-	Type                   orderType
-	Units                  Unit
-}
-
-type onFill struct {
-	Price       Price
-	TimeInForce timeInForce
+	ClientExtensions        *ClientExtensions `json:"clientExtensions,omitempty"`
+	TradeClientExtensions   *ClientExtensions `json:"tradeClientExtensions,omitempty"` // attached to the trade the order opens
+	TakeProfitOnFill        *OnFill           `json:"takeProfitOnFill,omitempty"`
+	StopLossOnFill          *OnFill           `json:"stopLossOnFill,omitempty"`
+	TrailingStopLossOnFill  *OnFill           `json:"trailingStopLossOnFill,omitempty"`
+	CreateTime              *time.Time        `json:"createTime,omitempty"`
+	ID                      OrderID           `json:"id,omitempty"`
+	Instrument              Instrument        `json:"instrument,omitempty"`
+	PartialFill             string            `json:"partialFill,omitempty"`
+	PositionFill            string            `json:"positionFill,omitempty"`
+	Price                   Price             `json:"price,omitempty"`
+	PriceBound              Price             `json:"priceBound,omitempty"`
+	Distance                Price             `json:"distance,omitempty"` // set for STOP_LOSS, GUARANTEED_STOP_LOSS and TRAILING_STOP_LOSS orders
+	TrailingStopValue       Price             `json:"trailingStopValue,omitempty"`
+	TradeID                 TradeID           `json:"tradeID,omitempty"` // set for orders attached to a trade, e.g. TAKE_PROFIT
+	ClientTradeID           string            `json:"clientTradeID,omitempty"`
+	ReplacesOrderID         OrderID           `json:"replacesOrderID,omitempty"`
+	ReplacedByOrderID       OrderID           `json:"replacedByOrderID,omitempty"`
+	State                   string            `json:"state,omitempty"`
+	TimeInForce             TimeInForce       `json:"timeInForce,omitempty"`
+	GtdTime                 *time.Time        `json:"gtdTime,omitempty"`
+	TriggerCondition        string            `json:"triggerCondition,omitempty"`
+	Type                    OrderType         `json:"type,omitempty"`
+	Units                   Unit              `json:"units,omitempty"`
+	FillingTransactionID    TransactionID     `json:"fillingTransactionID,omitempty"`
+	FilledTime              *time.Time        `json:"filledTime,omitempty"`
+	TradeOpenedID           TradeID           `json:"tradeOpenedID,omitempty"`
+	TradeReducedID          TradeID           `json:"tradeReducedID,omitempty"`
+	TradeClosedIDs          []TradeID         `json:"tradeClosedIDs,omitempty"`
+	CancellingTransactionID TransactionID     `json:"cancellingTransactionID,omitempty"`
+	CancelledTime           *time.Time        `json:"cancelledTime,omitempty"`
+}
+
+// OnFill is the details of a take profit or stop loss order created when an order is filled.
+// Either Price or Distance from the fill price is set.
+type OnFill struct {
+	Price       Price       `json:"price,omitempty"`
+	Distance    Price       `json:"distance,omitempty"`
+	TimeInForce TimeInForce `json:"timeInForce,omitempty"`
+	GtdTime     *time.Time  `json:"gtdTime,omitempty"`
 }
 
 type onFillStr struct {
-	TimeInForce string `json:"timeInForce,omitempty"`
-	Price       string `json:"price,omitempty"` // must be a string for float precision
+	TimeInForce string     `json:"timeInForce,omitempty"`
+	GtdTime     *time.Time `json:"gtdTime,omitempty"`
+	Price       string     `json:"price,omitempty"` // must be a string for float precision
+	Distance    string     `json:"distance,omitempty"`
 }
 
-func (o *onFill) ToOnFillStr() *onFillStr {
+// requiredOrderFields lists the fields which each known order type must have.
+var requiredOrderFields = map[OrderType][]string{
+	OrderTypeMarket:             {"instrument", "units"},
+	OrderTypeLimit:              {"instrument", "units", "price"},
+	OrderTypeStop:               {"instrument", "units", "price"},
+	OrderTypeMarketIfTouched:    {"instrument", "units", "price"},
+	OrderFixedPrice:             {"instrument", "units", "price"},
+	OrderTypeTakeProfit:         {"tradeID", "price"},
+	OrderTypeStopLoss:           {"tradeID"},
+	OrderTypeGuaranteedStopLoss: {"tradeID"},
+	OrderTypeTrailingStopLoss:   {"tradeID", "distance"},
+}
+
+func (o *OnFill) ToOnFillStr() *onFillStr {
 	if o == nil {
 		return nil
 	}
 	return &onFillStr{
-		Price:       strconv.FormatFloat(float64(o.Price), 'f', 7, 64),
+		Price:       formatPayloadPrice(o.Price),
+		Distance:    formatPayloadPrice(o.Distance),
 		TimeInForce: string(o.TimeInForce),
+		GtdTime:     o.GtdTime,
 	}
 }
 
 func (o *Order) toOrderPayload() OrderPayload {
 	return OrderPayload{
 		OrderPayloadBody{
-			Units:            int(o.Units),
-			Instrument:       string(o.Instrument),
-			TimeInForce:      string(o.TimeInForce),
-			GtdTime:          o.GtdTime,
-			Type:             string(o.Type),
-			PositionFill:     o.PositionFill,
-			Price:            o.Price.String(),
-			TakeProfitOnFill: o.TakeProfitOnFill.ToOnFillStr(),
-			StopLossOnFill:   o.StopLossOnFill.ToOnFillStr(),
+			Units:                  int(o.Units),
+			Instrument:             string(o.Instrument),
+			TradeID:                string(o.TradeID),
+			TimeInForce:            string(o.TimeInForce),
+			GtdTime:                o.GtdTime,
+			Type:                   string(o.Type),
+			PositionFill:           o.PositionFill,
+			TriggerCondition:       o.TriggerCondition,
+			Price:                  formatPayloadPrice(o.Price),
+			PriceBound:             formatPayloadPrice(o.PriceBound),
+			Distance:               formatPayloadPrice(o.Distance),
+			ClientExtensions:       o.ClientExtensions,
+			TradeClientExtensions:  o.TradeClientExtensions,
+			TakeProfitOnFill:       o.TakeProfitOnFill.ToOnFillStr(),
+			StopLossOnFill:         o.StopLossOnFill.ToOnFillStr(),
+			TrailingStopLossOnFill: o.TrailingStopLossOnFill.ToOnFillStr(),
 		},
 	}
 }
 
-func (r *retrievedOrders) toOrders() ([]Order, error) {
-	var orders []Order
-	for _, o := range r.Orders {
-		var tpOnFill onFill
-		var slOnFill onFill
-		var trOnFill onFill
-		if o.TakeProfitOnFill != nil {
-			p, err := strconv.ParseFloat(o.TakeProfitOnFill.Price, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse take plofit on fill price to float64: %v", err)
-			}
-			tpOnFill = onFill{
-				Price(p),
-				timeInForce(o.TakeProfitOnFill.TimeInForce),
-			}
+// formatPayloadPrice formats p for a payload, in which an unset price must be omitted.
+func formatPayloadPrice(p Price) string {
+	if p == 0 {
+		return ""
+	}
+	return p.String()
+}
+
+// fieldParser parses optional numeric fields of OANDA API objects and keeps the first error.
+type fieldParser struct {
+	err error
+}
+
+func (p *fieldParser) price(name, s string) Price {
+	if s == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("failed to parse %s to float64: %v", name, err)
+	}
+	return Price(f)
+}
+
+func (p *fieldParser) units(name, s string) Unit {
+	if s == "" {
+		return 0
+	}
+	u, err := strconv.Atoi(s)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("failed to parse %s to int: %v", name, err)
+	}
+	return Unit(u)
+}
+
+func (p *fieldParser) amount(name, s string) float64 {
+	if s == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && p.err == nil {
+		p.err = fmt.Errorf("failed to parse %s to float64: %v", name, err)
+	}
+	return f
+}
+
+func (o *orderInfo) toOrder() (Order, error) {
+	var p fieldParser
+	order := Order{
+		ClientExtensions:        o.ClientExtensions,
+		TradeClientExtensions:   o.TradeClientExtensions,
+		CreateTime:              o.CreateTime,
+		ID:                      OrderID(o.ID),
+		Instrument:              Instrument(o.Instrument),
+		PartialFill:             o.PartialFill,
+		PositionFill:            o.PositionFill,
+		Price:                   p.price("price", o.Price),
+		PriceBound:              p.price("price bound", o.PriceBound),
+		Distance:                p.price("distance", o.Distance),
+		TrailingStopValue:       p.price("trailing stop value", o.TrailingStopValue),
+		TradeID:                 TradeID(o.TradeID),
+		ClientTradeID:           o.ClientTradeID,
+		ReplacesOrderID:         OrderID(o.ReplacesOrderID),
+		ReplacedByOrderID:       OrderID(o.ReplacedByOrderID),
+		State:                   o.State,
+		TimeInForce:             TimeInForce(o.TimeInForce),
+		GtdTime:                 o.GtdTime,
+		TriggerCondition:        o.TriggerCondition,
+		Type:                    OrderType(o.Type),
+		Units:                   p.units("units", o.Units),
+		FillingTransactionID:    TransactionID(o.FillingTransactionID),
+		FilledTime:              o.FilledTime,
+		TradeOpenedID:           TradeID(o.TradeOpenedID),
+		TradeReducedID:          TradeID(o.TradeReducedID),
+		CancellingTransactionID: TransactionID(o.CancellingTransactionID),
+		CancelledTime:           o.CancelledTime,
+	}
+	for _, id := range o.TradeClosedIDs {
+		order.TradeClosedIDs = append(order.TradeClosedIDs, TradeID(id))
+	}
+	if o.TakeProfitOnFill != nil {
+		order.TakeProfitOnFill = &OnFill{
+			Price:       p.price("take profit on fill price", o.TakeProfitOnFill.Price),
+			TimeInForce: TimeInForce(o.TakeProfitOnFill.TimeInForce),
+			GtdTime:     o.TakeProfitOnFill.GtdTime,
 		}
-		if o.StopLossOnFill != nil {
-			p, err := strconv.ParseFloat(o.StopLossOnFill.Price, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse take stop loss on fill price to float64: %v", err)
-			}
-			slOnFill = onFill{
-				Price(p),
-				timeInForce(o.StopLossOnFill.TimeInForce),
-			}
+	}
+	if o.StopLossOnFill != nil {
+		order.StopLossOnFill = &OnFill{
+			Price:       p.price("stop loss on fill price", o.StopLossOnFill.Price),
+			Distance:    p.price("stop loss on fill distance", o.StopLossOnFill.Distance),
+			TimeInForce: TimeInForce(o.StopLossOnFill.TimeInForce),
+			GtdTime:     o.StopLossOnFill.GtdTime,
 		}
-		if o.TrailingStopLossOnFill != nil {
-			dp, err := strconv.ParseFloat(o.TrailingStopLossOnFill.Distance, 64)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse trailing stop loss on fill distance to float64: %v", err)
-			}
-			trOnFill = onFill{
-				Price(dp),
-				timeInForce(o.TrailingStopLossOnFill.TimeInForce),
-			}
+	}
+	if o.TrailingStopLossOnFill != nil {
+		order.TrailingStopLossOnFill = &OnFill{
+			Distance:    p.price("trailing stop loss on fill distance", o.TrailingStopLossOnFill.Distance),
+			TimeInForce: TimeInForce(o.TrailingStopLossOnFill.TimeInForce),
+			GtdTime:     o.TrailingStopLossOnFill.GtdTime,
 		}
-		p, err := strconv.ParseFloat(o.Price, 64)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse order price to float64: %v", err)
+	}
+
+	required, known := requiredOrderFields[order.Type]
+	if !known {
+		// fields of an unknown order type are decoded on a best-effort basis.
+		return order, nil
+	}
+	if p.err != nil {
+		return order, p.err
+	}
+	present := map[string]bool{
+		"instrument": o.Instrument != "",
+		"units":      o.Units != "",
+		"price":      o.Price != "",
+		"tradeID":    o.TradeID != "",
+		"distance":   o.Distance != "",
+	}
+	for _, f := range required {
+		if !present[f] {
+			return order, fmt.Errorf("%s order has no %s", order.Type, f)
 		}
-		u, err := strconv.Atoi(o.Units)
+	}
+	return order, nil
+}
+
+// OrderDecodeError is an order OANDA API returned which could not be decoded.
+// Order holds the fields which could be, e.g. ID, Type and Instrument.
+type OrderDecodeError struct {
+	Order Order
+	Err   error
+}
+
+func (e *OrderDecodeError) Error() string {
+	return fmt.Sprintf("failed to convert order (id=%s): %v", e.Order.ID, e.Err)
+}
+
+func (e *OrderDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// OrderDecodeErrors is returned by FetchOrders alongside the orders which could be decoded,
+// so that a malformed order does not hide the others.
+type OrderDecodeErrors []*OrderDecodeError
+
+func (e OrderDecodeErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d orders could not be decoded: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Orders returns the partly decoded orders.
+func (e OrderDecodeErrors) Orders() []Order {
+	var orders []Order
+	for _, err := range e {
+		orders = append(orders, err.Order)
+	}
+	return orders
+}
+
+// partlyDecodedOrders returns the orders of err when it is OrderDecodeErrors, and err otherwise.
+func partlyDecodedOrders(err error) ([]Order, error) {
+	var decodeErrs OrderDecodeErrors
+	if errors.As(err, &decodeErrs) {
+		return decodeErrs.Orders(), nil
+	}
+	return nil, err
+}
+
+func (r *retrievedOrders) toOrders() ([]Order, error) {
+	var orders []Order
+	var errs OrderDecodeErrors
+	for _, o := range r.Orders {
+		order, err := o.toOrder()
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse units to int")
+			errs = append(errs, &OrderDecodeError{Order: order, Err: err})
+			continue
 		}
-		orders = append(orders, Order{
-			&tpOnFill,
-			&slOnFill,
-			&trOnFill,
-			o.CreateTime,
-			orderID(o.ID),
-			instrument(o.Instrument),
-			o.PartialFill,
-			o.PositionFill,
-			Price(p),
-			o.State,
-			timeInForce(o.TimeInForce),
-			o.GtdTime,
-			o.TriggerCondition,
-			orderType(o.Type),
-			Unit(u),
-		})
+		orders = append(orders, order)
+	}
+	if len(errs) > 0 {
+		return orders, errs
 	}
 	return orders, nil
 }
 
+// FetchOrders fetches the pending orders. Orders which cannot be decoded are reported in OrderDecodeErrors
+// along with the others.
 func (c *Client) FetchOrders() ([]Order, error) {
 	body, err := c.fetchOrders()
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch orders: %v", err)
+		return nil, fmt.Errorf("failed to fetch orders: %w", err)
 	}
 	var ro retrievedOrders
 	if err := json.Unmarshal(body, &ro); err != nil {
-		return nil, fmt.Errorf("failed to json unmarshal: %v", err)
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
 	}
-	o, err := ro.toOrders()
+	return ro.toOrders()
+}
+
+// FetchOrder fetches an order of any state by id.
+func (c *Client) FetchOrder(id OrderID) (*Order, error) {
+	body, err := c.fetchOrder(id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert retrieved orders to type of Orders")
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
 	}
-	return o, nil
+	var ro struct {
+		Order orderInfo `json:"order"`
+	}
+	if err := json.Unmarshal(body, &ro); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	o, err := ro.Order.toOrder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert order (id=%s): %w", id, err)
+	}
+	return &o, nil
 }
 
 func (c *Client) FetchOrdersJSON() ([]byte, error) {
 	return c.fetchOrders()
 }
 
-func (c *Client) UpdateOrder(order Order) error {
+// OrderResponse is the transactions OANDA API responds to an order creation or replacement with.
+// Transactions which did not occur are nil.
+type OrderResponse struct {
+	// Create is the creation of the order, whose id is the id of the order.
+	Create *Transaction
+	Fill   *Transaction
+	// Cancel is the cancellation of the order replaced by a replacement, or of a created order which could not be filled.
+	Cancel *Transaction
+	// ReplacingCancel is the cancellation of a replacing order which could not be filled.
+	ReplacingCancel *Transaction
+	// Reject is the rejection of the request, in which case no order was created.
+	Reject                *Transaction
+	RelatedTransactionIDs []TransactionID
+	LastTransactionID     TransactionID
+}
+
+// OrderID returns the id of the order created, or "" when none was created.
+func (r *OrderResponse) OrderID() OrderID {
+	if r.Create == nil {
+		return ""
+	}
+	return OrderID(r.Create.ID)
+}
+
+func parseOrderResponse(body []byte) (*OrderResponse, error) {
+	var rr struct {
+		OrderCreateTransaction          json.RawMessage `json:"orderCreateTransaction"`
+		OrderFillTransaction            json.RawMessage `json:"orderFillTransaction"`
+		OrderCancelTransaction          json.RawMessage `json:"orderCancelTransaction"`
+		ReplacingOrderCancelTransaction json.RawMessage `json:"replacingOrderCancelTransaction"`
+		OrderRejectTransaction          json.RawMessage `json:"orderRejectTransaction"`
+		RelatedTransactionIDs           []string        `json:"relatedTransactionIDs"`
+		LastTransactionID               string          `json:"lastTransactionID"`
+	}
+	if err := json.Unmarshal(body, &rr); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	resp := &OrderResponse{LastTransactionID: TransactionID(rr.LastTransactionID)}
+	for _, id := range rr.RelatedTransactionIDs {
+		resp.RelatedTransactionIDs = append(resp.RelatedTransactionIDs, TransactionID(id))
+	}
+	for _, f := range []struct {
+		raw json.RawMessage
+		t   **Transaction
+	}{
+		{rr.OrderCreateTransaction, &resp.Create},
+		{rr.OrderFillTransaction, &resp.Fill},
+		{rr.OrderCancelTransaction, &resp.Cancel},
+		{rr.ReplacingOrderCancelTransaction, &resp.ReplacingCancel},
+		{rr.OrderRejectTransaction, &resp.Reject},
+	} {
+		if len(f.raw) == 0 || string(f.raw) == "null" {
+			continue
+		}
+		var info transactionInfo
+		if err := json.Unmarshal(f.raw, &info); err != nil {
+			return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+		}
+		t, err := info.toTransaction(f.raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert transaction %s: %w", info.ID, err)
+		}
+		*f.t = &t
+	}
+	return resp, nil
+}
+
+// orderResponse parses the response of an order mutation. A rejection is returned with err.
+func orderResponse(body []byte, err error) (*OrderResponse, error) {
+	var apiErr *APIError
+	if err != nil {
+		if !errors.As(err, &apiErr) {
+			return nil, err
+		}
+		if resp, perr := parseOrderResponse(apiErr.Body); perr == nil && resp.Reject != nil {
+			return resp, err
+		}
+		return nil, err
+	}
+	return parseOrderResponse(body)
+}
+
+// ReplaceOrder replaces the pending order of order.ID with order and returns the transactions of the replacement.
+// When OANDA API rejects the replacement, the response with the rejection is returned along with the error.
+func (c *Client) ReplaceOrder(order Order) (*OrderResponse, error) {
 	body, err := json.Marshal(order.toOrderPayload())
 	if err != nil {
-		return fmt.Errorf("failed to marshal order payload to json: %v", err)
+		return nil, fmt.Errorf("failed to marshal order payload to json: %w", err)
 	}
-	if err = c.updateOrder(order.ID, body); err != nil {
-		return fmt.Errorf("failed to update order: %v", err)
+	resp, err := orderResponse(c.updateOrder(order.ID, body))
+	if err != nil {
+		return resp, fmt.Errorf("failed to update order: %w", err)
 	}
-	return nil
+	return resp, nil
 }
 
-func (c *Client) CreateOrder(order Order) error {
+func (c *Client) UpdateOrder(order Order) error {
+	_, err := c.ReplaceOrder(order)
+	return err
+}
+
+// SubmitOrder creates order and returns the transactions of the creation.
+// When OANDA API rejects the order, the response with the rejection is returned along with the error.
+func (c *Client) SubmitOrder(order Order) (*OrderResponse, error) {
 	body, err := json.Marshal(order.toOrderPayload())
 	if err != nil {
-		return fmt.Errorf("failed to marshal order payload to json: %v", err)
+		return nil, fmt.Errorf("failed to marshal order payload to json: %w", err)
 	}
-	if err = c.createOrder(body); err != nil {
-		return fmt.Errorf("failed to create order: %v", err)
+	resp, err := orderResponse(c.createOrder(body))
+	if err != nil {
+		return resp, fmt.Errorf("failed to create order: %w", err)
 	}
-	return nil
+	return resp, nil
+}
+
+func (c *Client) CreateOrder(order Order) error {
+	_, err := c.SubmitOrder(order)
+	return err
+}
+
+func (c *Client) CancelOrder(orderID OrderID) error {
+	return c.cancelOrderContext(context.Background(), orderID)
 }
 
-func (c *Client) CancelOrder(orderID orderID) error {
-	if err := c.cancelOrder(orderID); err != nil {
-		return fmt.Errorf("failed to cancel order: %v", err)
+func (c *Client) cancelOrderContext(ctx context.Context, orderID OrderID) error {
+	if err := c.cancelOrder(ctx, orderID); err != nil {
+		return fmt.Errorf("failed to cancel order: %w", err)
 	}
 	return nil
 }
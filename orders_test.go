@@ -0,0 +1,118 @@
+package oanda
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const ordersJSON = `{
+  "lastTransactionID": "9",
+  "orders": [
+    {"id": "1", "type": "LIMIT", "instrument": "USD_JPY", "units": "100", "price": "105.000", "timeInForce": "GTC", "state": "PENDING"},
+    {"id": "2", "type": "TAKE_PROFIT", "tradeID": "7", "price": "106.000", "timeInForce": "GTC", "state": "PENDING"},
+    {"id": "3", "type": "SOME_FUTURE_TYPE", "units": "not a number", "state": "PENDING"},
+    {"id": "4", "type": "LIMIT", "instrument": "EUR_USD", "units": "100", "timeInForce": "GTC", "state": "PENDING"},
+    {"id": "5", "type": "STOP", "instrument": "EUR_JPY", "units": "-100", "price": "12x.5", "state": "PENDING"},
+    {"id": "6", "type": "TRAILING_STOP_LOSS", "tradeID": "7", "distance": "0.050", "state": "PENDING"}
+  ]
+}`
+
+func fetchOrdersFrom(t *testing.T, body string) ([]Order, error) {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer s.Close()
+	return NewClient("101-001", "key", "Practice", WithEndpoint(s.URL)).FetchOrders()
+}
+
+func TestFetchOrdersKeepsDecodableOrders(t *testing.T) {
+	orders, err := fetchOrdersFrom(t, ordersJSON)
+
+	var decodeErrs OrderDecodeErrors
+	if !errors.As(err, &decodeErrs) {
+		t.Fatalf("err = %v, want OrderDecodeErrors", err)
+	}
+	var ids []OrderID
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+	if want := []OrderID{"1", "2", "3", "6"}; !equalOrderIDs(ids, want) {
+		t.Errorf("decoded orders = %v, want %v", ids, want)
+	}
+	var failed []OrderID
+	for _, e := range decodeErrs {
+		failed = append(failed, e.Order.ID)
+	}
+	if want := []OrderID{"4", "5"}; !equalOrderIDs(failed, want) {
+		t.Errorf("failed orders = %v, want %v", failed, want)
+	}
+	if got := decodeErrs[0].Order.Instrument; got != InstrumentEURUSD {
+		t.Errorf("partly decoded instrument = %s, want %s", got, InstrumentEURUSD)
+	}
+}
+
+func TestFetchOrdersDecodesEveryType(t *testing.T) {
+	orders, _ := fetchOrdersFrom(t, ordersJSON)
+	byID := map[OrderID]Order{}
+	for _, o := range orders {
+		byID[o.ID] = o
+	}
+	if o := byID["1"]; o.Units != 100 || o.Price != 105 || o.Instrument != InstrumentUSDJPY {
+		t.Errorf("LIMIT order = %+v", o)
+	}
+	if o := byID["2"]; o.Units != 0 || o.TradeID != "7" || o.Price != 106 {
+		t.Errorf("TAKE_PROFIT order = %+v", o)
+	}
+	if o := byID["3"]; o.Type != "SOME_FUTURE_TYPE" || o.Units != 0 {
+		t.Errorf("unknown order = %+v", o)
+	}
+	if o := byID["6"]; o.Distance != 0.05 {
+		t.Errorf("TRAILING_STOP_LOSS order = %+v", o)
+	}
+}
+
+func TestFetchOrdersWithoutBadOrders(t *testing.T) {
+	orders, err := fetchOrdersFrom(t, `{"orders": [{"id": "1", "type": "MARKET", "instrument": "USD_JPY", "units": "1"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Errorf("got %d orders, want 1", len(orders))
+	}
+}
+
+func TestOrderDecodeErrorsRequiredFields(t *testing.T) {
+	tests := []struct {
+		info    orderInfo
+		wantErr bool
+	}{
+		{orderInfo{Type: "MARKET", Instrument: "USD_JPY", Units: "1"}, false},
+		{orderInfo{Type: "MARKET", Instrument: "USD_JPY"}, true},
+		{orderInfo{Type: "LIMIT", Instrument: "USD_JPY", Units: "1"}, true},
+		{orderInfo{Type: "TAKE_PROFIT", Price: "1.0"}, true},
+		{orderInfo{Type: "TRAILING_STOP_LOSS", TradeID: "1"}, true},
+		{orderInfo{Type: "STOP_LOSS", TradeID: "1", Distance: "x"}, true},
+		{orderInfo{Type: "UNKNOWN", Price: "x"}, false},
+	}
+	for _, tt := range tests {
+		_, err := tt.info.toOrder()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, want error %t", tt.info, err, tt.wantErr)
+		}
+	}
+}
+
+func equalOrderIDs(a, b []OrderID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
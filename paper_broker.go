@@ -0,0 +1,798 @@
+package oanda
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPaperMarginRate is the margin rate of PaperBroker unless PaperConfig.MarginRate is set.
+const DefaultPaperMarginRate = 0.04
+
+// ErrPaperRejected is returned by PaperBroker for a request OANDA would reject.
+var ErrPaperRejected = errors.New("paper broker rejected the request")
+
+// PaperConfig configures the simulated account of a PaperBroker.
+type PaperConfig struct {
+	Balance    float64
+	MarginRate float64
+	// Spread widens the spread of the price feed, half on each side.
+	Spread Price
+	// Slippage worsens the price of market, stop, stop loss and trailing stop loss fills.
+	Slippage Price
+	// FinancingRate is the annual rate charged on the notional value of open trades, accrued as the clock advances.
+	FinancingRate float64
+}
+
+// PaperBroker is a Broker which simulates an account against a price feed given to Update.
+//
+// Market orders fill at the current price, pending orders fill when the price reaches them,
+// GTD orders expire on the clock of the feed, fills reduce opposite trades first in first out,
+// and take profit, stop loss and trailing stop loss orders close their trades.
+// Profit and loss is calculated in the quote currency and treated as the account currency.
+type PaperBroker struct {
+	mu        sync.Mutex
+	config    PaperConfig
+	now       time.Time
+	balance   float64
+	pl        float64
+	financing float64
+	lastID    int
+	prices    map[Instrument]ClientPrice
+	orders    []*paperOrder
+	trades    []*Trade
+	fills     []OrderFill
+}
+
+type paperOrder struct {
+	Order
+	// touchAbove is whether a MARKET_IF_TOUCHED order triggers when the price rises to its price.
+	touchAbove bool
+}
+
+// NewPaperBroker constructs a PaperBroker.
+func NewPaperBroker(config PaperConfig) *PaperBroker {
+	if config.MarginRate == 0 {
+		config.MarginRate = DefaultPaperMarginRate
+	}
+	return &PaperBroker{
+		config:  config,
+		balance: config.Balance,
+		prices:  map[Instrument]ClientPrice{},
+	}
+}
+
+// Update feeds a price. It advances the clock to the time of the price, accrues financing,
+// expires GTD orders and fills the orders the price triggers.
+func (b *PaperBroker) Update(price ClientPrice) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(price.Time)
+	price.Bid -= b.config.Spread / 2
+	price.Ask += b.config.Spread / 2
+	b.prices[price.Instrument] = price
+	b.evaluate()
+}
+
+// Advance advances the clock to t, accrues financing and expires GTD orders.
+func (b *PaperBroker) Advance(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(t)
+	b.evaluate()
+}
+
+func (b *PaperBroker) advance(t time.Time) {
+	if !t.After(b.now) {
+		return
+	}
+	if !b.now.IsZero() && b.config.FinancingRate != 0 {
+		years := t.Sub(b.now).Hours() / (365 * 24)
+		for _, trade := range b.openTrades() {
+			p := b.prices[trade.Instrument]
+			cost := math.Abs(float64(trade.CurrentUnits)) * float64(p.Mid()) * b.config.FinancingRate * years
+			trade.Financing -= cost
+			b.financing -= cost
+			b.balance -= cost
+		}
+	}
+	b.now = t
+}
+
+// Now returns the clock of the broker, the time of the latest price.
+func (b *PaperBroker) Now() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.now
+}
+
+// Price returns the current price of instrument, including the configured spread.
+func (b *PaperBroker) Price(instrument Instrument) (ClientPrice, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.prices[instrument]
+	return p, ok
+}
+
+// FetchPricing returns the current prices of instruments, including the configured spread.
+func (b *PaperBroker) FetchPricing(instruments ...Instrument) ([]ClientPrice, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var prices []ClientPrice
+	for _, i := range instruments {
+		p, ok := b.prices[i]
+		if !ok {
+			return nil, fmt.Errorf("%w: no price of %s", ErrPaperRejected, i)
+		}
+		prices = append(prices, p)
+	}
+	return prices, nil
+}
+
+// FetchOrders returns the pending orders, the newest first.
+func (b *PaperBroker) FetchOrders() ([]Order, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var orders []Order
+	for i := len(b.orders) - 1; i >= 0; i-- {
+		if b.orders[i].State == "PENDING" {
+			orders = append(orders, b.orders[i].Order)
+		}
+	}
+	return orders, nil
+}
+
+// FetchOpenTrades returns the open trades, the newest first.
+func (b *PaperBroker) FetchOpenTrades() ([]Trade, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var trades []Trade
+	for i := len(b.trades) - 1; i >= 0; i-- {
+		if t := b.trades[i]; t.State == "OPEN" {
+			trade := *t
+			trade.UnrealizedPL = b.unrealizedPL(t)
+			trades = append(trades, trade)
+		}
+	}
+	return trades, nil
+}
+
+// Trades returns all trades including closed ones, in the order opened.
+func (b *PaperBroker) Trades() []Trade {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	trades := make([]Trade, len(b.trades))
+	for i, t := range b.trades {
+		trades[i] = *t
+		trades[i].UnrealizedPL = b.unrealizedPL(t)
+	}
+	return trades
+}
+
+// Fills returns all fills in order.
+func (b *PaperBroker) Fills() []OrderFill {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]OrderFill(nil), b.fills...)
+}
+
+// FetchOpenPositions returns the positions of the open trades by instrument.
+func (b *PaperBroker) FetchOpenPositions() ([]Position, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var positions []Position
+	index := map[Instrument]int{}
+	for _, t := range b.trades {
+		i, ok := index[t.Instrument]
+		if !ok {
+			i = len(positions)
+			index[t.Instrument] = i
+			positions = append(positions, Position{Instrument: t.Instrument})
+		}
+		p := &positions[i]
+		side := &p.Long
+		if t.Side() == SideSell {
+			side = &p.Short
+		}
+		side.PL += t.RealizedPL
+		p.PL += t.RealizedPL
+		if t.State != "OPEN" {
+			continue
+		}
+		upl := b.unrealizedPL(t)
+		side.AveragePrice = (side.AveragePrice*Price(side.Units) + t.Price*Price(t.CurrentUnits)) / Price(side.Units+t.CurrentUnits)
+		side.Units += t.CurrentUnits
+		side.TradeIDs = append(side.TradeIDs, t.ID)
+		side.UnrealizedPL += upl
+		p.UnrealizedPL += upl
+	}
+	var open []Position
+	for _, p := range positions {
+		if p.Long.Units != 0 || p.Short.Units != 0 {
+			open = append(open, p)
+		}
+	}
+	return open, nil
+}
+
+// FetchAccountSummary returns the summary of the simulated account.
+func (b *PaperBroker) FetchAccountSummary() (*AccountSummary, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	unrealizedPL, marginUsed := b.unrealized()
+	positions := map[Instrument]bool{}
+	for _, t := range b.openTrades() {
+		positions[t.Instrument] = true
+	}
+	pending := 0
+	for _, o := range b.orders {
+		if o.State == "PENDING" {
+			pending++
+		}
+	}
+	return &AccountSummary{
+		ID:                "paper",
+		Alias:             "paper",
+		Balance:           b.balance,
+		NAV:               b.balance + unrealizedPL,
+		UnrealizedPL:      unrealizedPL,
+		PL:                b.pl,
+		Financing:         b.financing,
+		MarginUsed:        marginUsed,
+		MarginAvailable:   math.Max(0, b.balance+unrealizedPL-marginUsed),
+		MarginRate:        b.config.MarginRate,
+		OpenTradeCount:    len(b.openTrades()),
+		OpenPositionCount: len(positions),
+		PendingOrderCount: pending,
+		LastTransactionID: TransactionID(strconv.Itoa(b.lastID)),
+	}, nil
+}
+
+// CreateOrder creates an order. As on OANDA, an order which cannot be filled, e.g. for insufficient margin,
+// is cancelled without an error.
+func (b *PaperBroker) CreateOrder(order Order) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o, err := b.newOrder(order, nil)
+	if err != nil {
+		return err
+	}
+	b.submit(o)
+	b.evaluate()
+	return nil
+}
+
+// UpdateOrder replaces the pending order of order.ID with order.
+func (b *PaperBroker) UpdateOrder(order Order) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	old := b.pendingOrder(order.ID)
+	if old == nil {
+		return fmt.Errorf("%w: order %s is not pending", ErrPaperRejected, order.ID)
+	}
+	o, err := b.newOrder(order, old)
+	if err != nil {
+		return err
+	}
+	if o.Type != old.Type {
+		return fmt.Errorf("%w: order %s of %s cannot be replaced with %s", ErrPaperRejected, old.ID, old.Type, o.Type)
+	}
+	b.cancel(old)
+	o.ReplacesOrderID = old.ID
+	b.submit(o)
+	old.ReplacedByOrderID = o.ID
+	b.evaluate()
+	return nil
+}
+
+// CancelOrder cancels a pending order.
+func (b *PaperBroker) CancelOrder(orderID OrderID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	o := b.pendingOrder(orderID)
+	if o == nil {
+		return fmt.Errorf("%w: order %s is not pending", ErrPaperRejected, orderID)
+	}
+	b.cancel(o)
+	return nil
+}
+
+// CloseOpenTrade closes all units of an open trade at the current price.
+func (b *PaperBroker) CloseOpenTrade(id TradeID) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.trade(id)
+	if t == nil || t.State != "OPEN" {
+		return fmt.Errorf("%w: trade %s is not open", ErrPaperRejected, id)
+	}
+	o := &paperOrder{Order: Order{
+		Type:         OrderTypeMarket,
+		Instrument:   t.Instrument,
+		Units:        -t.CurrentUnits,
+		TimeInForce:  TimeInForceFOK,
+		PositionFill: "REDUCE_ONLY",
+		TradeID:      t.ID,
+	}}
+	b.create(o)
+	b.fill(o, o.Units, []*Trade{t})
+	b.evaluate()
+	return nil
+}
+
+func (b *PaperBroker) nextID() string {
+	b.lastID++
+	return strconv.Itoa(b.lastID)
+}
+
+func (b *PaperBroker) pendingOrder(id OrderID) *paperOrder {
+	for _, o := range b.orders {
+		if o.ID == id && o.State == "PENDING" {
+			return o
+		}
+	}
+	return nil
+}
+
+func (b *PaperBroker) trade(id TradeID) *Trade {
+	for _, t := range b.trades {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (b *PaperBroker) openTrades() []*Trade {
+	var trades []*Trade
+	for _, t := range b.trades {
+		if t.State == "OPEN" {
+			trades = append(trades, t)
+		}
+	}
+	return trades
+}
+
+// closePrice returns the price t closes at: the bid for a long trade and the ask for a short one.
+func (b *PaperBroker) closePrice(t *Trade) Price {
+	p := b.prices[t.Instrument]
+	if t.Side() == SideBuy {
+		return p.Bid
+	}
+	return p.Ask
+}
+
+func (b *PaperBroker) unrealizedPL(t *Trade) float64 {
+	if t.State != "OPEN" {
+		return 0
+	}
+	return float64(b.closePrice(t)-t.Price) * float64(t.CurrentUnits)
+}
+
+func (b *PaperBroker) unrealized() (pl, marginUsed float64) {
+	for _, t := range b.openTrades() {
+		p := b.prices[t.Instrument]
+		pl += b.unrealizedPL(t)
+		marginUsed += math.Abs(float64(t.CurrentUnits)) * float64(p.Mid()) * b.config.MarginRate
+	}
+	return pl, marginUsed
+}
+
+func (b *PaperBroker) dependentOrder(t *Trade, typ OrderType) OrderID {
+	switch typ {
+	case OrderTypeTakeProfit:
+		return t.TakeProfitOrderID
+	case OrderTypeStopLoss:
+		return t.StopLossOrderID
+	case OrderTypeTrailingStopLoss:
+		return t.TrailingStopLossOrderID
+	}
+	return ""
+}
+
+func (b *PaperBroker) setDependentOrder(t *Trade, typ OrderType, id OrderID) {
+	switch typ {
+	case OrderTypeTakeProfit:
+		t.TakeProfitOrderID = id
+	case OrderTypeStopLoss:
+		t.StopLossOrderID = id
+	case OrderTypeTrailingStopLoss:
+		t.TrailingStopLossOrderID = id
+	}
+}
+
+func dependentOrderType(typ OrderType) bool {
+	return typ == OrderTypeTakeProfit || typ == OrderTypeStopLoss || typ == OrderTypeTrailingStopLoss
+}
+
+// newOrder validates order and returns the order to submit. replacing is the order it replaces, if any.
+func (b *PaperBroker) newOrder(order Order, replacing *paperOrder) (*paperOrder, error) {
+	reject := func(format string, args ...interface{}) (*paperOrder, error) {
+		return nil, fmt.Errorf("%w: %s", ErrPaperRejected, fmt.Sprintf(format, args...))
+	}
+	o := &paperOrder{Order: Order{
+		Type:                   order.Type,
+		Instrument:             order.Instrument,
+		Units:                  order.Units,
+		Price:                  order.Price,
+		PriceBound:             order.PriceBound,
+		Distance:               order.Distance,
+		TradeID:                order.TradeID,
+		TimeInForce:            order.TimeInForce,
+		GtdTime:                order.GtdTime,
+		PositionFill:           order.PositionFill,
+		TriggerCondition:       order.TriggerCondition,
+		ClientExtensions:       order.ClientExtensions,
+		TradeClientExtensions:  order.TradeClientExtensions,
+		TakeProfitOnFill:       order.TakeProfitOnFill,
+		StopLossOnFill:         order.StopLossOnFill,
+		TrailingStopLossOnFill: order.TrailingStopLossOnFill,
+	}}
+	if o.PositionFill == "" {
+		o.PositionFill = "DEFAULT"
+	}
+	if o.TriggerCondition == "" {
+		o.TriggerCondition = "DEFAULT"
+	}
+	if o.TimeInForce == "" {
+		o.TimeInForce = TimeInForceGTC
+		if o.Type == OrderTypeMarket {
+			o.TimeInForce = TimeInForceFOK
+		}
+	}
+	switch {
+	case o.Type == OrderTypeMarket && o.TimeInForce != TimeInForceFOK && o.TimeInForce != TimeInForceIOC:
+		return reject("time in force %s is invalid for a market order", o.TimeInForce)
+	case o.TimeInForce == TimeInForceGTD && (o.GtdTime == nil || !o.GtdTime.After(b.now)):
+		return reject("GTD time is missing or in the past")
+	}
+
+	switch o.Type {
+	case OrderTypeTakeProfit, OrderTypeStopLoss, OrderTypeTrailingStopLoss:
+		t := b.trade(o.TradeID)
+		if t == nil || t.State != "OPEN" {
+			return reject("trade %s does not exist", o.TradeID)
+		}
+		if id := b.dependentOrder(t, o.Type); id != "" && (replacing == nil || replacing.ID != id) {
+			return reject("%s order already exists for trade %s", o.Type, t.ID)
+		}
+		o.Instrument = t.Instrument
+		switch {
+		case o.Type == OrderTypeTakeProfit && o.Price <= 0:
+			return reject("take profit order has no price")
+		case o.Type == OrderTypeStopLoss && o.Price <= 0 && o.Distance <= 0:
+			return reject("stop loss order has neither price nor distance")
+		case o.Type == OrderTypeTrailingStopLoss && o.Distance <= 0:
+			return reject("trailing stop loss order has no distance")
+		}
+		if o.Type == OrderTypeStopLoss && o.Price <= 0 {
+			o.Price = b.closePrice(t) - Price(math.Copysign(float64(o.Distance), float64(t.CurrentUnits)))
+		}
+		return o, nil
+	case OrderTypeMarket, OrderTypeLimit, OrderTypeStop, OrderTypeMarketIfTouched:
+	default:
+		return reject("order type %s is not supported", o.Type)
+	}
+	if _, ok := b.prices[o.Instrument]; !ok {
+		return reject("no price of %s", o.Instrument)
+	}
+	if o.Units == 0 {
+		return reject("units are missing")
+	}
+	if o.Type != OrderTypeMarket && o.Price <= 0 {
+		return reject("price is missing")
+	}
+	switch o.PositionFill {
+	case "DEFAULT", "OPEN_ONLY", "REDUCE_FIRST", "REDUCE_ONLY":
+	default:
+		return reject("position fill %s is invalid", o.PositionFill)
+	}
+	if o.Type == OrderTypeMarketIfTouched {
+		o.touchAbove = o.Price > b.triggerPrice(o.Instrument, o.Units > 0, o.TriggerCondition)
+	}
+	return o, nil
+}
+
+// triggerPrice returns the price an order of the side triggers on: the ask for buying and the bid for selling,
+// unless triggerCondition says otherwise.
+func (b *PaperBroker) triggerPrice(instrument Instrument, buy bool, triggerCondition string) Price {
+	p := b.prices[instrument]
+	switch triggerCondition {
+	case "MID":
+		return p.Mid()
+	case "BID":
+		return p.Bid
+	case "ASK":
+		return p.Ask
+	case "INVERSE":
+		buy = !buy
+	}
+	if buy {
+		return p.Ask
+	}
+	return p.Bid
+}
+
+func (b *PaperBroker) create(o *paperOrder) {
+	now := b.now
+	o.ID = OrderID(b.nextID())
+	o.State = "PENDING"
+	o.CreateTime = &now
+	b.orders = append(b.orders, o)
+}
+
+// submit creates the order, attaches it to its trade if it depends on one and fills it if it is a market order.
+func (b *PaperBroker) submit(o *paperOrder) {
+	b.create(o)
+	if dependentOrderType(o.Type) {
+		t := b.trade(o.TradeID)
+		b.setDependentOrder(t, o.Type, o.ID)
+		if o.Type == OrderTypeTrailingStopLoss {
+			o.TrailingStopValue = b.closePrice(t) - Price(math.Copysign(float64(o.Distance), float64(t.CurrentUnits)))
+		}
+	}
+	if o.Type == OrderTypeMarket {
+		b.execute(o)
+	}
+}
+
+// evaluate expires, triggers and fills the pending orders until nothing changes.
+func (b *PaperBroker) evaluate() {
+	for pass := 0; pass < 10; pass++ {
+		changed := false
+		for _, o := range b.orders {
+			if o.State != "PENDING" {
+				continue
+			}
+			if o.TimeInForce == TimeInForceGTD && o.GtdTime != nil && !b.now.Before(*o.GtdTime) {
+				b.cancel(o)
+				changed = true
+				continue
+			}
+			b.trail(o)
+			if b.triggered(o) {
+				b.execute(o)
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// trail moves the trailing stop value of a trailing stop loss order as the price moves in favor of its trade.
+func (b *PaperBroker) trail(o *paperOrder) {
+	if o.Type != OrderTypeTrailingStopLoss {
+		return
+	}
+	t := b.trade(o.TradeID)
+	if t == nil || t.State != "OPEN" {
+		return
+	}
+	long := t.CurrentUnits > 0
+	p := b.triggerPrice(o.Instrument, !long, o.TriggerCondition)
+	if long {
+		o.TrailingStopValue = Price(math.Max(float64(o.TrailingStopValue), float64(p-o.Distance)))
+	} else {
+		o.TrailingStopValue = Price(math.Min(float64(o.TrailingStopValue), float64(p+o.Distance)))
+	}
+}
+
+// triggered reports whether the current price triggers o.
+func (b *PaperBroker) triggered(o *paperOrder) bool {
+	if dependentOrderType(o.Type) {
+		t := b.trade(o.TradeID)
+		if t == nil || t.State != "OPEN" {
+			return false
+		}
+		long := t.CurrentUnits > 0
+		p := b.triggerPrice(o.Instrument, !long, o.TriggerCondition)
+		switch o.Type {
+		case OrderTypeTakeProfit:
+			return (long && p >= o.Price) || (!long && p <= o.Price)
+		case OrderTypeStopLoss:
+			return (long && p <= o.Price) || (!long && p >= o.Price)
+		case OrderTypeTrailingStopLoss:
+			return (long && p <= o.TrailingStopValue) || (!long && p >= o.TrailingStopValue)
+		}
+		return false
+	}
+	buy := o.Units > 0
+	p := b.triggerPrice(o.Instrument, buy, o.TriggerCondition)
+	switch o.Type {
+	case OrderTypeLimit:
+		return (buy && p <= o.Price) || (!buy && p >= o.Price)
+	case OrderTypeStop:
+		return (buy && p >= o.Price) || (!buy && p <= o.Price)
+	case OrderTypeMarketIfTouched:
+		return (o.touchAbove && p >= o.Price) || (!o.touchAbove && p <= o.Price)
+	}
+	return false
+}
+
+func (b *PaperBroker) execute(o *paperOrder) {
+	if dependentOrderType(o.Type) {
+		t := b.trade(o.TradeID)
+		b.fill(o, -t.CurrentUnits, []*Trade{t})
+		return
+	}
+	var targets []*Trade
+	if o.PositionFill != "OPEN_ONLY" {
+		for _, t := range b.openTrades() {
+			if t.Instrument == o.Instrument && (t.CurrentUnits > 0) != (o.Units > 0) {
+				targets = append(targets, t)
+			}
+		}
+	}
+	if o.PositionFill == "REDUCE_ONLY" && len(targets) == 0 {
+		b.cancel(o)
+		return
+	}
+	b.fill(o, o.Units, targets)
+}
+
+// fill fills units of the order at the current price. It closes or reduces targets first in first out
+// and opens a trade of the remaining units unless the order only reduces.
+func (b *PaperBroker) fill(o *paperOrder, units Unit, targets []*Trade) {
+	p := b.prices[o.Instrument]
+	buy := units > 0
+	price := p.Bid
+	if buy {
+		price = p.Ask
+	}
+	switch o.Type {
+	case OrderTypeMarket, OrderTypeStop, OrderTypeStopLoss, OrderTypeTrailingStopLoss:
+		if buy {
+			price += b.config.Slippage
+		} else {
+			price -= b.config.Slippage
+		}
+	}
+	if o.PriceBound != 0 && ((buy && price > o.PriceBound) || (!buy && price < o.PriceBound)) {
+		b.cancel(o)
+		return
+	}
+
+	type closing struct {
+		t     *Trade
+		units Unit
+	}
+	var closings []closing
+	remaining := units
+	for _, t := range targets {
+		if remaining == 0 {
+			break
+		}
+		u := t.CurrentUnits
+		if abs(remaining) < abs(u) {
+			u = -remaining
+		}
+		closings = append(closings, closing{t, -u})
+		remaining += u
+	}
+	if dependentOrderType(o.Type) || o.PositionFill == "REDUCE_ONLY" {
+		units -= remaining
+		remaining = 0
+	}
+	if remaining != 0 {
+		upl, marginUsed := b.unrealized()
+		if math.Abs(float64(remaining))*float64(price)*b.config.MarginRate > b.balance+upl-marginUsed {
+			b.cancel(o)
+			return
+		}
+	}
+
+	id := b.nextID()
+	now := b.now
+	var pl float64
+	for _, c := range closings {
+		t := c.t
+		tradePL := float64(price-t.Price) * float64(-c.units)
+		pl += tradePL
+		t.CurrentUnits += c.units
+		t.RealizedPL += tradePL
+		if t.CurrentUnits == 0 {
+			t.State = "CLOSED"
+			o.TradeClosedIDs = append(o.TradeClosedIDs, t.ID)
+			for _, typ := range []OrderType{OrderTypeTakeProfit, OrderTypeStopLoss, OrderTypeTrailingStopLoss} {
+				if dep := b.pendingOrder(b.dependentOrder(t, typ)); dep != nil && dep != o {
+					b.cancel(dep)
+				}
+			}
+		} else {
+			o.TradeReducedID = t.ID
+		}
+	}
+	b.balance += pl
+	b.pl += pl
+	o.State = "FILLED"
+	o.FillingTransactionID = TransactionID(id)
+	o.FilledTime = &now
+	b.fills = append(b.fills, OrderFill{
+		TransactionID: TransactionID(id),
+		Time:          now,
+		Instrument:    o.Instrument,
+		Units:         units,
+		Price:         price,
+		PL:            pl,
+	})
+
+	if remaining == 0 {
+		return
+	}
+	t := &Trade{
+		ID:               TradeID(id),
+		OpenTime:         &now,
+		Instrument:       o.Instrument,
+		Price:            price,
+		State:            "OPEN",
+		InitialUnits:     remaining,
+		CurrentUnits:     remaining,
+		ClientExtensions: o.TradeClientExtensions,
+	}
+	b.trades = append(b.trades, t)
+	o.TradeOpenedID = t.ID
+	b.attachOnFill(&o.Order, t)
+}
+
+// attachOnFill creates the dependent orders o specifies for the trade it opened.
+func (b *PaperBroker) attachOnFill(o *Order, t *Trade) {
+	direction := Price(1)
+	if t.CurrentUnits < 0 {
+		direction = -1
+	}
+	for _, d := range []struct {
+		typ    OrderType
+		onFill *OnFill
+	}{
+		{OrderTypeTakeProfit, o.TakeProfitOnFill},
+		{OrderTypeStopLoss, o.StopLossOnFill},
+		{OrderTypeTrailingStopLoss, o.TrailingStopLossOnFill},
+	} {
+		if d.onFill == nil {
+			continue
+		}
+		dependent := &paperOrder{Order: Order{
+			Type:             d.typ,
+			Instrument:       t.Instrument,
+			TradeID:          t.ID,
+			Price:            d.onFill.Price,
+			Distance:         d.onFill.Distance,
+			TimeInForce:      d.onFill.TimeInForce,
+			GtdTime:          d.onFill.GtdTime,
+			TriggerCondition: "DEFAULT",
+		}}
+		if dependent.TimeInForce == "" {
+			dependent.TimeInForce = TimeInForceGTC
+		}
+		if dependent.Price == 0 && d.typ == OrderTypeTakeProfit {
+			dependent.Price = t.Price + direction*dependent.Distance
+		}
+		if dependent.Price == 0 && d.typ == OrderTypeStopLoss {
+			dependent.Price = t.Price - direction*dependent.Distance
+		}
+		b.submit(dependent)
+	}
+}
+
+func (b *PaperBroker) cancel(o *paperOrder) {
+	now := b.now
+	o.State = "CANCELLED"
+	o.CancellingTransactionID = TransactionID(b.nextID())
+	o.CancelledTime = &now
+	if dependentOrderType(o.Type) {
+		if t := b.trade(o.TradeID); t != nil && b.dependentOrder(t, o.Type) == o.ID {
+			b.setDependentOrder(t, o.Type, "")
+		}
+	}
+}
+
+func abs(u Unit) Unit {
+	if u < 0 {
+		return -u
+	}
+	return u
+}
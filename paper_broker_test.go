@@ -0,0 +1,236 @@
+package oanda
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+var paperStart = time.Date(2020, 9, 18, 0, 0, 0, 0, time.UTC)
+
+func quote(b *PaperBroker, after time.Duration, bid, ask Price) {
+	b.Update(ClientPrice{Instrument: InstrumentUSDJPY, Time: paperStart.Add(after), Bid: bid, Ask: ask})
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func mustCreate(t *testing.T, b *PaperBroker, o Order) {
+	t.Helper()
+	if o.Instrument == "" && o.TradeID == "" {
+		o.Instrument = InstrumentUSDJPY
+	}
+	if err := b.CreateOrder(o); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func openTrades(t *testing.T, b *PaperBroker) []Trade {
+	t.Helper()
+	trades, err := b.FetchOpenTrades()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trades
+}
+
+func TestPaperFillsWithSpreadAndSlippage(t *testing.T) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000000, Spread: 0.002, Slippage: 0.001})
+	quote(b, 0, 105.000, 105.004)
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: 1000})
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: -400})
+
+	fills := b.Fills()
+	if len(fills) != 2 {
+		t.Fatalf("fills = %+v", fills)
+	}
+	if !near(float64(fills[0].Price), 105.006) {
+		t.Errorf("buy filled at %v, want the ask with spread and slippage 105.006", fills[0].Price)
+	}
+	if !near(float64(fills[1].Price), 104.998) {
+		t.Errorf("sell filled at %v, want 104.998", fills[1].Price)
+	}
+	if want := (104.998 - 105.006) * 400; !near(fills[1].PL, want) {
+		t.Errorf("PL of the reduction = %v, want %v", fills[1].PL, want)
+	}
+	trades := openTrades(t, b)
+	if len(trades) != 1 || trades[0].CurrentUnits != 600 || trades[0].InitialUnits != 1000 {
+		t.Errorf("trades = %+v, want the buy reduced to 600", trades)
+	}
+	summary, err := b.FetchAccountSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(summary.Balance, 1000000+fills[1].PL) {
+		t.Errorf("balance = %v", summary.Balance)
+	}
+}
+
+func TestPaperPendingOrdersFillWhenTouched(t *testing.T) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000000})
+	quote(b, 0, 105.000, 105.004)
+	mustCreate(t, b, Order{Type: OrderTypeLimit, Units: 100, Price: 104.9})
+	mustCreate(t, b, Order{Type: OrderTypeStop, Units: -100, Price: 104.8})
+	mustCreate(t, b, Order{Type: OrderTypeMarketIfTouched, Units: -100, Price: 105.2})
+
+	quote(b, time.Minute, 104.95, 104.954)
+	if n := len(openTrades(t, b)); n != 0 {
+		t.Fatalf("%d trades opened before any price was touched", n)
+	}
+	quote(b, 2*time.Minute, 104.896, 104.9)
+	if trades := openTrades(t, b); len(trades) != 1 || trades[0].Price != 104.9 {
+		t.Fatalf("trades = %+v, want the limit order filled at 104.9", trades)
+	}
+	quote(b, 3*time.Minute, 105.2, 105.204)
+	if trades := openTrades(t, b); len(trades) != 0 {
+		t.Fatalf("trades = %+v, want the buy closed by the market if touched order", trades)
+	}
+	orders, _ := b.FetchOrders()
+	if len(orders) != 1 || orders[0].Type != OrderTypeStop {
+		t.Errorf("pending orders = %+v, want the stop order", orders)
+	}
+}
+
+func TestPaperTakeProfitAndStopLoss(t *testing.T) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000000})
+	quote(b, 0, 105.000, 105.004)
+	mustCreate(t, b, Order{
+		Type:             OrderTypeMarket,
+		Units:            100,
+		TakeProfitOnFill: &OnFill{Price: 105.5},
+		StopLossOnFill:   &OnFill{Distance: 0.3},
+	})
+	trades := openTrades(t, b)
+	if len(trades) != 1 || trades[0].TakeProfitOrderID == "" || trades[0].StopLossOrderID == "" {
+		t.Fatalf("trades = %+v, want a trade with a take profit and a stop loss", trades)
+	}
+	orders, _ := b.FetchOrders()
+	for _, o := range orders {
+		if o.Type == OrderTypeStopLoss && !near(float64(o.Price), 104.704) {
+			t.Errorf("stop loss at %v, want the distance from the fill price 104.704", o.Price)
+		}
+	}
+
+	quote(b, time.Minute, 105.5, 105.504)
+	if n := len(openTrades(t, b)); n != 0 {
+		t.Fatalf("%d trades left after the take profit", n)
+	}
+	if orders, _ := b.FetchOrders(); len(orders) != 0 {
+		t.Errorf("orders = %+v, want the stop loss cancelled with the trade", orders)
+	}
+	closed := b.Trades()[0]
+	if want := (105.5 - 105.004) * 100; !near(closed.RealizedPL, want) || closed.State != "CLOSED" {
+		t.Errorf("closed trade = %+v, want PL %v", closed, want)
+	}
+
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: -100, StopLossOnFill: &OnFill{Price: 105.8}})
+	quote(b, 2*time.Minute, 105.79, 105.799)
+	if n := len(openTrades(t, b)); n != 1 {
+		t.Fatalf("%d trades open, want the short trade below its stop loss", n)
+	}
+	quote(b, 3*time.Minute, 105.8, 105.804)
+	if n := len(openTrades(t, b)); n != 0 {
+		t.Errorf("%d trades open, want the short trade stopped out", n)
+	}
+}
+
+func TestPaperTrailingStopLoss(t *testing.T) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000000})
+	quote(b, 0, 105.000, 105.004)
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: 100, TrailingStopLossOnFill: &OnFill{Distance: 0.1}})
+
+	trailingStop := func() Price {
+		orders, _ := b.FetchOrders()
+		if len(orders) != 1 || orders[0].Type != OrderTypeTrailingStopLoss {
+			t.Fatalf("orders = %+v, want the trailing stop loss", orders)
+		}
+		return orders[0].TrailingStopValue
+	}
+	if v := trailingStop(); !near(float64(v), 104.9) {
+		t.Errorf("trailing stop value = %v, want 104.9", v)
+	}
+	quote(b, time.Minute, 105.3, 105.304)
+	if v := trailingStop(); !near(float64(v), 105.2) {
+		t.Errorf("trailing stop value = %v, want it moved up to 105.2", v)
+	}
+	quote(b, 2*time.Minute, 105.25, 105.254)
+	if v := trailingStop(); !near(float64(v), 105.2) {
+		t.Errorf("trailing stop value = %v, want it kept at 105.2 as the price falls", v)
+	}
+	quote(b, 3*time.Minute, 105.2, 105.204)
+	if n := len(openTrades(t, b)); n != 0 {
+		t.Errorf("%d trades open, want the trade stopped out at 105.2", n)
+	}
+}
+
+func TestPaperGTDExpiry(t *testing.T) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000000})
+	quote(b, 0, 105.000, 105.004)
+	gtd := paperStart.Add(time.Hour)
+	mustCreate(t, b, Order{Type: OrderTypeLimit, Units: 100, Price: 104, TimeInForce: TimeInForceGTD, GtdTime: &gtd})
+
+	b.Advance(paperStart.Add(59 * time.Minute))
+	if orders, _ := b.FetchOrders(); len(orders) != 1 {
+		t.Fatalf("orders = %+v, want the order pending before its GTD time", orders)
+	}
+	b.Advance(gtd)
+	if orders, _ := b.FetchOrders(); len(orders) != 0 {
+		t.Errorf("orders = %+v, want the order expired", orders)
+	}
+
+	past := paperStart
+	err := b.CreateOrder(Order{Type: OrderTypeLimit, Instrument: InstrumentUSDJPY, Units: 100, Price: 104, TimeInForce: TimeInForceGTD, GtdTime: &past})
+	if !errors.Is(err, ErrPaperRejected) {
+		t.Errorf("err = %v, want ErrPaperRejected for a GTD time in the past", err)
+	}
+}
+
+func TestPaperMargin(t *testing.T) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000})
+	quote(b, 0, 105.000, 105.004)
+	// 1000 units need 1000 * 105.004 * 0.04 = 4200.16 of margin.
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: 1000})
+	if n := len(openTrades(t, b)); n != 0 {
+		t.Fatalf("%d trades opened without enough margin", n)
+	}
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: 200})
+	if n := len(openTrades(t, b)); n != 1 {
+		t.Fatalf("%d trades opened, want 1", n)
+	}
+	summary, err := b.FetchAccountSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 200 * 105.002 * DefaultPaperMarginRate; !near(summary.MarginUsed, want) {
+		t.Errorf("margin used = %v, want %v", summary.MarginUsed, want)
+	}
+	if want := summary.NAV - summary.MarginUsed; !near(summary.MarginAvailable, want) {
+		t.Errorf("margin available = %v, want %v", summary.MarginAvailable, want)
+	}
+	// the remaining margin does not cover another 200 units.
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: 200})
+	if n := len(openTrades(t, b)); n != 1 {
+		t.Errorf("%d trades opened, want the second order cancelled", n)
+	}
+}
+
+func TestPaperFinancing(t *testing.T) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000000, FinancingRate: 0.0365})
+	quote(b, 0, 105.000, 105.004)
+	mustCreate(t, b, Order{Type: OrderTypeMarket, Units: -1000})
+
+	b.Advance(paperStart.Add(24 * time.Hour))
+	want := 1000 * 105.002 * 0.0365 / 365
+	summary, err := b.FetchAccountSummary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(summary.Financing, -want) || !near(summary.Balance, 1000000-want) {
+		t.Errorf("financing = %v and balance = %v, want %v charged", summary.Financing, summary.Balance, want)
+	}
+	if trades := openTrades(t, b); !near(trades[0].Financing, -want) {
+		t.Errorf("financing of the trade = %v, want %v", trades[0].Financing, -want)
+	}
+}
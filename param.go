@@ -1,14 +1,12 @@
 package oanda
 
 import (
-	"log"
+	"errors"
 	"os"
 	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
 // Param implements parameter store access method.
@@ -22,43 +20,53 @@ const (
 var (
 	// ParamOandaAPIKey defines api key of OANDA API.
 	ParamOandaAPIKey = Param(prefix + "/APIKey")
-	//ParamOandaAccountID defines oanda account.
+	// ParamOandaAccountID defines oanda account.
 	ParamOandaAccountID = Param(prefix + "/AccountID")
 	// ParamOandaUSDJPYUnits defines USD/JPY Units.
+	//
+	// Deprecated: compute units with Sizer, or configure Instruments/USD_JPY/DefaultUnits and use LoadInstrumentSettings.
 	ParamOandaUSDJPYUnits = Param(prefix + "/Units/USD_JPY")
 	// ParamOandaEURUSDUnits defines EUR/USD Units.
+	//
+	// Deprecated: compute units with Sizer, or configure Instruments/EUR_USD/DefaultUnits and use LoadInstrumentSettings.
 	ParamOandaEURUSDUnits = Param(prefix + "/Units/EUR_USD")
 	// ParamOandaEURJPYUnits defines EUR/JPY Units.
+	//
+	// Deprecated: compute units with Sizer, or configure Instruments/EUR_JPY/DefaultUnits and use LoadInstrumentSettings.
 	ParamOandaEURJPYUnits = Param(prefix + "/Units/EUR_JPY")
 )
 
 var (
-	ssmClient     *ssm.SSM
-	ssmClientOnce sync.Once
-	env           = os.Getenv("ENVIRONMENT") // Practice or Trade
+	ssmProvider     *SSMProvider
+	ssmProviderErr  error
+	ssmProviderOnce sync.Once
+	env             = os.Getenv("ENVIRONMENT") // Practice or Trade
 )
 
-func initClient() {
-	s := session.Must(session.NewSession(&aws.Config{
-		Region: aws.String(os.Getenv("AWS_DEFAULT_REGION")),
-	}))
-	ssmClient = ssm.New(s)
+// Key returns the key of p relative to the environment, e.g. "APIKey".
+func (p Param) Key() string {
+	return strings.TrimPrefix(string(p), prefix+"/")
 }
 
-func (p Param) FetchValue() string {
+// ValueFrom returns the value of p provided by provider.
+func (p Param) ValueFrom(provider ConfigProvider) (string, error) {
+	return provider.Value(p.Key())
+}
+
+// FetchValue returns the value of p in AWS SSM parameter store under the environment set by ENVIRONMENT.
+//
+// Deprecated: use ValueFrom with a ConfigProvider, e.g. from DefaultConfigProvider.
+func (p Param) FetchValue() (string, error) {
 	if len(env) == 0 {
-		return ""
-	}
-	resolved := strings.Replace(string(p), envPlaceholder, env, 1)
-	ssmClientOnce.Do(initClient)
-	output, err := ssmClient.GetParameter(&ssm.GetParameterInput{Name: aws.String(resolved)})
-	if err != nil {
-		log.Fatalf("failed to get parameter (key=%s): %v", resolved, err)
-		return ""
+		return "", errors.New("ENVIRONMENT is not set")
 	}
-	if output.Parameter.Value == nil {
-		log.Fatalf("failed to get parameter (key=%s): empty", resolved)
-		return ""
+	ssmProviderOnce.Do(func() {
+		ssmProvider, ssmProviderErr = NewSSMProvider(env, &aws.Config{
+			Region: aws.String(os.Getenv("AWS_DEFAULT_REGION")),
+		})
+	})
+	if ssmProviderErr != nil {
+		return "", ssmProviderErr
 	}
-	return *output.Parameter.Value
+	return p.ValueFrom(ssmProvider)
 }
@@ -0,0 +1,204 @@
+package oanda
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type positionSideInfo struct {
+	Units        string   `json:"units"`
+	AveragePrice string   `json:"averagePrice"`
+	TradeIDs     []string `json:"tradeIDs"`
+	PL           string   `json:"pl"`
+	UnrealizedPL string   `json:"unrealizedPL"`
+}
+
+type positionInfo struct {
+	Instrument   string           `json:"instrument"`
+	PL           string           `json:"pl"`
+	UnrealizedPL string           `json:"unrealizedPL"`
+	Long         positionSideInfo `json:"long"`
+	Short        positionSideInfo `json:"short"`
+}
+
+type receivedPositions struct {
+	Positions []positionInfo `json:"positions"`
+}
+
+// PositionSide is the long or short side of a position.
+type PositionSide struct {
+	Units        Unit // negative for the short side
+	AveragePrice Price
+	TradeIDs     []TradeID
+	PL           float64
+	UnrealizedPL float64
+}
+
+// Position is the position of an account in an instrument.
+type Position struct {
+	Instrument   Instrument
+	PL           float64
+	UnrealizedPL float64
+	Long         PositionSide
+	Short        PositionSide
+}
+
+// NetUnits returns the sum of long and short units.
+func (p *Position) NetUnits() Unit {
+	return p.Long.Units + p.Short.Units
+}
+
+func (s *positionSideInfo) toPositionSide(p *fieldParser) PositionSide {
+	side := PositionSide{
+		Units:        p.units("units", s.Units),
+		AveragePrice: p.price("average price", s.AveragePrice),
+		PL:           p.amount("pl", s.PL),
+		UnrealizedPL: p.amount("unrealized pl", s.UnrealizedPL),
+	}
+	for _, id := range s.TradeIDs {
+		side.TradeIDs = append(side.TradeIDs, TradeID(id))
+	}
+	return side
+}
+
+func (i *positionInfo) toPosition() (Position, error) {
+	var p fieldParser
+	position := Position{
+		Instrument:   Instrument(i.Instrument),
+		PL:           p.amount("pl", i.PL),
+		UnrealizedPL: p.amount("unrealized pl", i.UnrealizedPL),
+		Long:         i.Long.toPositionSide(&p),
+		Short:        i.Short.toPositionSide(&p),
+	}
+	return position, p.err
+}
+
+// FetchOpenPositions fetches the positions which have open trades.
+func (c *Client) FetchOpenPositions() ([]Position, error) {
+	body, err := c.fetchOpenPositions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch open positions: %w", err)
+	}
+	var rp receivedPositions
+	if err := json.Unmarshal(body, &rp); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	var positions []Position
+	for _, i := range rp.Positions {
+		p, err := i.toPosition()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert position of %s: %w", i.Instrument, err)
+		}
+		positions = append(positions, p)
+	}
+	return positions, nil
+}
+
+func (c *Client) FetchOpenPositionsJSON() ([]byte, error) {
+	return c.fetchOpenPositions()
+}
+
+type orderFillInfo struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Instrument string    `json:"instrument"`
+	Units      string    `json:"units"`
+	Price      string    `json:"price"`
+	PL         string    `json:"pl"`
+	Financing  string    `json:"financing"`
+}
+
+type orderCancelInfo struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type positionCloseResponse struct {
+	LongOrderFillTransaction    *orderFillInfo   `json:"longOrderFillTransaction"`
+	LongOrderCancelTransaction  *orderCancelInfo `json:"longOrderCancelTransaction"`
+	ShortOrderFillTransaction   *orderFillInfo   `json:"shortOrderFillTransaction"`
+	ShortOrderCancelTransaction *orderCancelInfo `json:"shortOrderCancelTransaction"`
+}
+
+// OrderFill is the fill of an order which closed a position or a trade.
+type OrderFill struct {
+	TransactionID TransactionID
+	Time          time.Time
+	Instrument    Instrument
+	Units         Unit
+	Price         Price
+	PL            float64 // realized profit and loss in the account currency
+	Financing     float64
+}
+
+func (i *orderFillInfo) toOrderFill() (OrderFill, error) {
+	var p fieldParser
+	fill := OrderFill{
+		TransactionID: TransactionID(i.ID),
+		Time:          i.Time,
+		Instrument:    Instrument(i.Instrument),
+		Units:         p.units("units", i.Units),
+		Price:         p.price("price", i.Price),
+		PL:            p.amount("pl", i.PL),
+		Financing:     p.amount("financing", i.Financing),
+	}
+	return fill, p.err
+}
+
+// ClosePosition closes all units of the long and/or the short side of the position in instrument
+// with market orders and returns their fills.
+// When one side is closed and the other is not, the fill of the closed side is returned with the error.
+func (c *Client) ClosePosition(instrument Instrument, long, short bool) ([]OrderFill, error) {
+	payload := struct {
+		LongUnits  string `json:"longUnits"`
+		ShortUnits string `json:"shortUnits"`
+	}{"NONE", "NONE"}
+	if long {
+		payload.LongUnits = "ALL"
+	}
+	if short {
+		payload.ShortUnits = "ALL"
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal: %w", err)
+	}
+	resp, err := c.closePosition(instrument, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to close position of %s: %w", instrument, err)
+	}
+	var r positionCloseResponse
+	if err := json.Unmarshal(resp, &r); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	var fills []OrderFill
+	var msgs []string
+	for _, side := range []struct {
+		name   string
+		fill   *orderFillInfo
+		cancel *orderCancelInfo
+	}{
+		{"long", r.LongOrderFillTransaction, r.LongOrderCancelTransaction},
+		{"short", r.ShortOrderFillTransaction, r.ShortOrderCancelTransaction},
+	} {
+		if side.cancel != nil {
+			msgs = append(msgs, fmt.Sprintf("order closing %s position of %s was canceled: %s", side.name, instrument, side.cancel.Reason))
+		}
+		if side.fill == nil {
+			continue
+		}
+		fill, err := side.fill.toOrderFill()
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("failed to convert order fill of %s position of %s: %v", side.name, instrument, err))
+			continue
+		}
+		fills = append(fills, fill)
+	}
+	if len(msgs) > 0 {
+		return fills, errors.New(strings.Join(msgs, "; "))
+	}
+	return fills, nil
+}
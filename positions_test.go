@@ -0,0 +1,71 @@
+package oanda
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func closePositionWith(t *testing.T, body string) ([]OrderFill, error) {
+	t.Helper()
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer s.Close()
+	return NewClient("101-001", "key", "Practice", WithEndpoint(s.URL)).ClosePosition(InstrumentUSDJPY, true, true)
+}
+
+func TestClosePositionReturnsFillsWithError(t *testing.T) {
+	fills, err := closePositionWith(t, `{
+  "longOrderFillTransaction": {"id": "10", "time": "2020-09-18T10:20:00Z", "instrument": "USD_JPY", "units": "-100", "price": "105.000", "pl": "120.5", "financing": "-1.2"},
+  "shortOrderCancelTransaction": {"id": "11", "reason": "MARKET_HALTED"}
+}`)
+	if err == nil || !strings.Contains(err.Error(), "MARKET_HALTED") {
+		t.Errorf("err = %v, want the cancel of the short side", err)
+	}
+	if len(fills) != 1 || fills[0].TransactionID != "10" || fills[0].PL != 120.5 {
+		t.Errorf("fills = %+v, want the fill of the long side", fills)
+	}
+
+	fills, err = closePositionWith(t, `{
+  "longOrderFillTransaction": {"id": "10", "time": "2020-09-18T10:20:00Z", "instrument": "USD_JPY", "units": "-100", "price": "105.000", "pl": "120.5", "financing": "0"},
+  "shortOrderFillTransaction": {"id": "11", "time": "2020-09-18T10:20:00Z", "instrument": "USD_JPY", "units": "50", "price": "105.010", "pl": "-3", "financing": "0"}
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills) != 2 {
+		t.Errorf("fills = %+v, want both sides", fills)
+	}
+}
+
+func TestKillSwitchKeepsFillsOfPartlyClosedPositions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/accounts/101-001/orders", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"orders": []}`))
+	})
+	mux.HandleFunc("/v3/accounts/101-001/openPositions", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"positions": [{"instrument": "USD_JPY", "pl": "0", "unrealizedPL": "0",
+  "long": {"units": "100", "averagePrice": "104.000", "pl": "0", "unrealizedPL": "100"},
+  "short": {"units": "-50", "averagePrice": "105.500", "pl": "0", "unrealizedPL": "25"}}]}`))
+	})
+	mux.HandleFunc("/v3/accounts/101-001/positions/USD_JPY/close", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+  "longOrderFillTransaction": {"id": "10", "time": "2020-09-18T10:20:00Z", "instrument": "USD_JPY", "units": "-100", "price": "105.000", "pl": "100", "financing": "0"},
+  "shortOrderCancelTransaction": {"id": "11", "reason": "MARKET_HALTED"}
+}`))
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	k := &KillSwitch{Client: NewClient("101-001", "key", "Practice", WithEndpoint(s.URL))}
+	report := &PanicReport{}
+	if err := k.round(context.Background(), report, false); err == nil {
+		t.Error("round succeeds although the short side was not closed")
+	}
+	if len(report.ClosedPositions) != 1 || report.RealizedPL() != 100 {
+		t.Errorf("closed positions = %+v, want the fill of the long side", report.ClosedPositions)
+	}
+}
@@ -0,0 +1,90 @@
+package oanda
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type priceBucket struct {
+	Price     string `json:"price"`
+	Liquidity int    `json:"liquidity"`
+}
+
+type priceInfo struct {
+	Instrument  string        `json:"instrument"`
+	Time        time.Time     `json:"time"`
+	Tradeable   bool          `json:"tradeable"`
+	Bids        []priceBucket `json:"bids"`
+	Asks        []priceBucket `json:"asks"`
+	CloseoutBid string        `json:"closeoutBid"`
+	CloseoutAsk string        `json:"closeoutAsk"`
+}
+
+type receivedPricing struct {
+	Prices []priceInfo `json:"prices"`
+}
+
+// ClientPrice is the price of an instrument available to the account.
+type ClientPrice struct {
+	Instrument  Instrument
+	Time        time.Time
+	Tradeable   bool
+	Bid         Price // best bid
+	Ask         Price // best ask
+	CloseoutBid Price
+	CloseoutAsk Price
+}
+
+// Mid returns the middle of the best bid and ask.
+func (p *ClientPrice) Mid() Price {
+	return (p.Bid + p.Ask) / 2
+}
+
+// Spread returns the best ask minus the best bid.
+func (p *ClientPrice) Spread() Price {
+	return p.Ask - p.Bid
+}
+
+func (i *priceInfo) toClientPrice() (ClientPrice, error) {
+	var p fieldParser
+	price := ClientPrice{
+		Instrument:  Instrument(i.Instrument),
+		Time:        i.Time,
+		Tradeable:   i.Tradeable,
+		CloseoutBid: p.price("closeout bid", i.CloseoutBid),
+		CloseoutAsk: p.price("closeout ask", i.CloseoutAsk),
+	}
+	if len(i.Bids) > 0 {
+		price.Bid = p.price("bid", i.Bids[0].Price)
+	}
+	if len(i.Asks) > 0 {
+		price.Ask = p.price("ask", i.Asks[0].Price)
+	}
+	return price, p.err
+}
+
+// FetchPricing fetches the current prices of instruments.
+func (c *Client) FetchPricing(instruments ...Instrument) ([]ClientPrice, error) {
+	body, err := c.fetchPricing(instruments, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pricing: %w", err)
+	}
+	var rp receivedPricing
+	if err := json.Unmarshal(body, &rp); err != nil {
+		return nil, fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	var prices []ClientPrice
+	for _, i := range rp.Prices {
+		p, err := i.toClientPrice()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert price of %s: %w", i.Instrument, err)
+		}
+		prices = append(prices, p)
+	}
+	return prices, nil
+}
+
+func (c *Client) FetchPricingJSON(instruments ...Instrument) ([]byte, error) {
+	return c.fetchPricing(instruments, false)
+}
@@ -0,0 +1,117 @@
+package oanda_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/cassette"
+)
+
+var (
+	record = flag.Bool("record", false, "record the cassettes in testdata/cassettes against OANDA API of the default configuration")
+	update = flag.Bool("update", false, "update the golden files in testdata/golden")
+)
+
+// replayClient returns a client which replays testdata/cassettes/<name>.json,
+// or records it with the default configuration when -record is given.
+func replayClient(t *testing.T, name string) *oanda.Client {
+	t.Helper()
+	path := filepath.Join("testdata", "cassettes", name+".json")
+	if !*record {
+		rec, err := cassette.New(path, cassette.ModeReplay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if unused := rec.Unused(); len(unused) > 0 {
+				t.Errorf("%d interactions of %s were not replayed", len(unused), path)
+			}
+		})
+		return oanda.NewClient(cassette.RedactedAccountID, "replayed", oanda.EnvironmentPractice, oanda.WithHTTPClient(rec.Client()))
+	}
+
+	provider, err := oanda.DefaultConfigProvider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey, err := provider.Value(oanda.ConfigKeyAPIKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := cassette.New(path, cassette.ModeRecord, cassette.WithRedaction(apiKey, "REDACTED"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := rec.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+	client, err := oanda.NewClientFromConfig(provider, oanda.WithHTTPClient(rec.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// assertGolden compares the JSON of v with testdata/golden/<name>.json, or writes it when -update is given.
+func assertGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\n%s", name, got)
+	}
+}
+
+func TestReplayFetchOrders(t *testing.T) {
+	client := replayClient(t, "orders")
+	orders, err := client.FetchOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "orders", orders)
+}
+
+func TestReplayFetchOrderBook(t *testing.T) {
+	client := replayClient(t, "order_book")
+	latest, err := client.FetchOrderBook(oanda.InstrumentUSDJPY, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2020, 9, 18, 10, 0, 0, 0, time.UTC)
+	past, err := client.FetchOrderBook(oanda.InstrumentUSDJPY, &at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "order_books", []*oanda.OrderBook{latest, past})
+
+	missing := at.Add(24 * time.Hour)
+	_, err = client.FetchOrderBook(oanda.InstrumentUSDJPY, &missing)
+	var apiErr *oanda.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %v, want HTTP 404", err)
+	}
+}
@@ -0,0 +1,445 @@
+package oanda
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RiskRule names the limit of RiskLimits which rejected an order.
+type RiskRule string
+
+const (
+	RiskRuleMaxUnits           = RiskRule("MAX_UNITS")
+	RiskRuleMaxNotional        = RiskRule("MAX_NOTIONAL")
+	RiskRuleMaxOpenTrades      = RiskRule("MAX_OPEN_TRADES")
+	RiskRuleMaxOrdersPerMinute = RiskRule("MAX_ORDERS_PER_MINUTE")
+	RiskRuleDailyLoss          = RiskRule("DAILY_LOSS_LIMIT")
+	RiskRuleStopLossRequired   = RiskRule("STOP_LOSS_REQUIRED")
+	RiskRulePriceSanity        = RiskRule("PRICE_SANITY")
+	RiskRuleNotTradeable       = RiskRule("INSTRUMENT_NOT_TRADEABLE")
+)
+
+// ErrRiskRejected matches every *RiskRejection with errors.Is.
+var ErrRiskRejected = errors.New("rejected by risk manager")
+
+// RiskRejection is the error of an order which RiskManager rejected.
+type RiskRejection struct {
+	Rule       RiskRule
+	Instrument Instrument
+	// Limit is the configured limit and Value the value the order would have reached.
+	// Both are zero for rules without a quantity.
+	Limit   float64
+	Value   float64
+	Message string
+}
+
+func (r *RiskRejection) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrRiskRejected, r.Rule, r.Message)
+}
+
+// Is reports whether target is ErrRiskRejected.
+func (r *RiskRejection) Is(target error) bool {
+	return target == ErrRiskRejected
+}
+
+// RiskLimits are the limits RiskManager enforces. Zero values disable a limit.
+type RiskLimits struct {
+	// MaxUnits limits the absolute units of an order and of the net position it results in, by instrument.
+	MaxUnits map[Instrument]Unit
+	// DefaultMaxUnits is MaxUnits of the instruments which are not in MaxUnits.
+	DefaultMaxUnits Unit
+	// MaxNotional limits the sum of units times the mid price of the open positions an order results in.
+	// It is in the quote currency of each instrument, so it should be used with instruments of the same quote currency.
+	MaxNotional float64
+	// MaxOpenTrades limits the number of open trades when an order may open another.
+	MaxOpenTrades int
+	// MaxOrdersPerMinute limits the number of orders created or replaced in any minute.
+	MaxOrdersPerMinute int
+	// DailyLossLimit rejects entries once the P/L of the account, including unrealized P/L,
+	// has fallen by this amount since the first order checked on the UTC day.
+	DailyLossLimit float64
+	// RequireStopLoss requires every entry order to have a stop loss or a trailing stop loss on fill.
+	RequireStopLoss bool
+	// MaxPriceDeviation limits how far the price, price bound and stop loss of an order may be
+	// from the current mid price, as a ratio, e.g. 0.05 for 5%.
+	MaxPriceDeviation float64
+}
+
+// RiskBroker is a Broker which provides the pricing and the account summary RiskManager checks orders against.
+// *Client and *PaperBroker implement it.
+type RiskBroker interface {
+	Broker
+	FetchPricing(instruments ...Instrument) ([]ClientPrice, error)
+	FetchAccountSummary() (*AccountSummary, error)
+}
+
+// RiskManager is a Broker which checks every order against RiskLimits before passing it to the underlying broker.
+// Rejected orders are never sent and fail with a *RiskRejection.
+type RiskManager struct {
+	Broker RiskBroker
+	Limits RiskLimits
+	// StatePath is the file the P/L at the start of the day is persisted to, so that DailyLossLimit
+	// holds across restarts. Empty keeps it in memory only.
+	StatePath string
+
+	now        func() time.Time
+	mu         sync.Mutex
+	sent       []time.Time
+	day        time.Time
+	dayStartPL float64
+	loaded     bool
+}
+
+// riskState is the content of RiskManager.StatePath.
+type riskState struct {
+	AccountID  string    `json:"accountID"`
+	Day        time.Time `json:"day"`
+	DayStartPL float64   `json:"dayStartPL"`
+}
+
+// NewRiskManager constructs a RiskManager in front of broker.
+func NewRiskManager(broker RiskBroker, limits RiskLimits) *RiskManager {
+	return &RiskManager{Broker: broker, Limits: limits, now: time.Now}
+}
+
+// CreateOrder checks order and creates it.
+func (m *RiskManager) CreateOrder(order Order) error {
+	return m.send(order, m.Broker.CreateOrder)
+}
+
+// UpdateOrder checks order and replaces the order of order.ID with it.
+func (m *RiskManager) UpdateOrder(order Order) error {
+	return m.send(order, m.Broker.UpdateOrder)
+}
+
+// send checks order and sends it with request. The order counts towards MaxOrdersPerMinute
+// from the check on, so that concurrent orders cannot exceed it, but not when request fails.
+func (m *RiskManager) send(order Order, request func(Order) error) error {
+	m.mu.Lock()
+	sentAt, err := m.check(order)
+	if err == nil {
+		m.sent = append(m.sent, sentAt)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := request(order); err != nil {
+		m.mu.Lock()
+		for i, t := range m.sent {
+			if t.Equal(sentAt) {
+				m.sent = append(m.sent[:i], m.sent[i+1:]...)
+				break
+			}
+		}
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (m *RiskManager) FetchOrders() ([]Order, error)           { return m.Broker.FetchOrders() }
+func (m *RiskManager) CancelOrder(orderID OrderID) error       { return m.Broker.CancelOrder(orderID) }
+func (m *RiskManager) FetchOpenTrades() ([]Trade, error)       { return m.Broker.FetchOpenTrades() }
+func (m *RiskManager) CloseOpenTrade(id TradeID) error         { return m.Broker.CloseOpenTrade(id) }
+func (m *RiskManager) FetchOpenPositions() ([]Position, error) { return m.Broker.FetchOpenPositions() }
+
+// Check checks order against the limits without sending it or counting it as sent.
+// It returns a *RiskRejection for an order which breaks a limit.
+func (m *RiskManager) Check(order Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := m.check(order)
+	return err
+}
+
+// check checks order and returns the time it was checked at. m.mu must be held.
+func (m *RiskManager) check(order Order) (time.Time, error) {
+	now := m.now()
+	if err := m.checkRate(now); err != nil {
+		return now, err
+	}
+
+	instrument := order.Instrument
+	// orders attached to a trade only reduce it.
+	entry := order.TradeID == ""
+	if !entry && instrument == "" {
+		trades, err := m.Broker.FetchOpenTrades()
+		if err != nil {
+			return now, fmt.Errorf("failed to fetch open trades: %w", err)
+		}
+		for _, t := range trades {
+			if t.ID == order.TradeID {
+				instrument = t.Instrument
+			}
+		}
+	}
+	if instrument == "" {
+		return now, fmt.Errorf("failed to check order: instrument of trade %s is unknown", order.TradeID)
+	}
+	prices, err := m.Broker.FetchPricing(instrument)
+	if err != nil {
+		return now, fmt.Errorf("failed to fetch pricing: %w", err)
+	}
+	if len(prices) == 0 {
+		return now, fmt.Errorf("failed to check order: no price of %s", instrument)
+	}
+	price := prices[0]
+	if err := m.checkPrices(instrument, order, price); err != nil {
+		return now, err
+	}
+	if entry {
+		if err := m.checkEntry(instrument, order, price, now); err != nil {
+			return now, err
+		}
+	}
+	return now, nil
+}
+
+func (m *RiskManager) checkRate(now time.Time) error {
+	limit := m.Limits.MaxOrdersPerMinute
+	if limit <= 0 {
+		return nil
+	}
+	i := 0
+	for i < len(m.sent) && !m.sent[i].After(now.Add(-time.Minute)) {
+		i++
+	}
+	m.sent = m.sent[i:]
+	if len(m.sent) >= limit {
+		return &RiskRejection{
+			Rule:    RiskRuleMaxOrdersPerMinute,
+			Limit:   float64(limit),
+			Value:   float64(len(m.sent) + 1),
+			Message: fmt.Sprintf("%d orders were already sent in the last minute", len(m.sent)),
+		}
+	}
+	return nil
+}
+
+// checkPrices checks that the instrument is tradeable and the prices of order are near the current price.
+func (m *RiskManager) checkPrices(instrument Instrument, order Order, price ClientPrice) error {
+	if !price.Tradeable {
+		return &RiskRejection{Rule: RiskRuleNotTradeable, Instrument: instrument, Message: fmt.Sprintf("%s is not tradeable", instrument)}
+	}
+	mid := float64(price.Mid())
+	if m.Limits.MaxPriceDeviation > 0 && mid > 0 {
+		check := func(name string, p Price) error {
+			if p == 0 {
+				return nil
+			}
+			deviation := math.Abs(float64(p)-mid) / mid
+			if deviation <= m.Limits.MaxPriceDeviation {
+				return nil
+			}
+			return &RiskRejection{
+				Rule:       RiskRulePriceSanity,
+				Instrument: instrument,
+				Limit:      m.Limits.MaxPriceDeviation,
+				Value:      deviation,
+				Message:    fmt.Sprintf("%s %v is %.2f%% away from the current price %v", name, float64(p), deviation*100, mid),
+			}
+		}
+		if err := check("price", order.Price); err != nil {
+			return err
+		}
+		if err := check("price bound", order.PriceBound); err != nil {
+			return err
+		}
+		if order.StopLossOnFill != nil {
+			if err := check("stop loss", order.StopLossOnFill.Price); err != nil {
+				return err
+			}
+		}
+		if order.TakeProfitOnFill != nil {
+			if err := check("take profit", order.TakeProfitOnFill.Price); err != nil {
+				return err
+			}
+		}
+	}
+	// a stop loss on the wrong side of the entry would close the trade at once.
+	if sl := order.StopLossOnFill; sl != nil && sl.Price != 0 && order.Units != 0 {
+		entryPrice := order.Price
+		if entryPrice == 0 {
+			entryPrice = price.Ask
+			if order.Units < 0 {
+				entryPrice = price.Bid
+			}
+		}
+		if (order.Units > 0 && sl.Price >= entryPrice) || (order.Units < 0 && sl.Price <= entryPrice) {
+			return &RiskRejection{
+				Rule:       RiskRulePriceSanity,
+				Instrument: instrument,
+				Message:    fmt.Sprintf("stop loss %v is on the wrong side of the entry %v", float64(sl.Price), float64(entryPrice)),
+			}
+		}
+	}
+	return nil
+}
+
+// checkEntry checks the limits on the exposure and loss of the account for an order which may open a trade.
+func (m *RiskManager) checkEntry(instrument Instrument, order Order, price ClientPrice, now time.Time) error {
+	l := m.Limits
+	if l.RequireStopLoss && order.StopLossOnFill == nil && order.TrailingStopLossOnFill == nil && order.PositionFill != "REDUCE_ONLY" {
+		return &RiskRejection{Rule: RiskRuleStopLossRequired, Instrument: instrument, Message: "entry order has no stop loss on fill"}
+	}
+
+	maxUnits, ok := l.MaxUnits[instrument]
+	if !ok {
+		maxUnits = l.DefaultMaxUnits
+	}
+	var positions []Position
+	if maxUnits > 0 || l.MaxNotional > 0 {
+		var err error
+		positions, err = m.Broker.FetchOpenPositions()
+		if err != nil {
+			return fmt.Errorf("failed to fetch open positions: %w", err)
+		}
+	}
+	if maxUnits > 0 {
+		resulting := abs(order.Units)
+		for _, p := range positions {
+			if p.Instrument == instrument {
+				resulting = exposure(p, order)
+			}
+		}
+		for _, u := range []Unit{order.Units, resulting} {
+			if abs(u) > maxUnits {
+				return &RiskRejection{
+					Rule:       RiskRuleMaxUnits,
+					Instrument: instrument,
+					Limit:      float64(maxUnits),
+					Value:      float64(abs(u)),
+					Message:    fmt.Sprintf("%d units of %s exceed the limit of %d", abs(u), instrument, maxUnits),
+				}
+			}
+		}
+	}
+	if l.MaxNotional > 0 {
+		notional := math.Abs(float64(order.Units)) * float64(price.Mid())
+		var others []Instrument
+		for _, p := range positions {
+			if p.Instrument == instrument {
+				notional = float64(exposure(p, order)) * float64(price.Mid())
+			} else {
+				others = append(others, p.Instrument)
+			}
+		}
+		if len(others) > 0 {
+			prices, err := m.Broker.FetchPricing(others...)
+			if err != nil {
+				return fmt.Errorf("failed to fetch pricing: %w", err)
+			}
+			mids := map[Instrument]float64{}
+			for _, p := range prices {
+				mids[p.Instrument] = float64(p.Mid())
+			}
+			for _, p := range positions {
+				if p.Instrument != instrument {
+					notional += float64(p.Long.Units-p.Short.Units) * mids[p.Instrument]
+				}
+			}
+		}
+		if notional > l.MaxNotional {
+			return &RiskRejection{
+				Rule:       RiskRuleMaxNotional,
+				Instrument: instrument,
+				Limit:      l.MaxNotional,
+				Value:      notional,
+				Message:    fmt.Sprintf("notional %.0f exceeds the limit of %.0f", notional, l.MaxNotional),
+			}
+		}
+	}
+
+	if l.MaxOpenTrades <= 0 && l.DailyLossLimit <= 0 {
+		return nil
+	}
+	summary, err := m.Broker.FetchAccountSummary()
+	if err != nil {
+		return fmt.Errorf("failed to fetch account summary: %w", err)
+	}
+	if l.MaxOpenTrades > 0 && order.PositionFill != "REDUCE_ONLY" && summary.OpenTradeCount >= l.MaxOpenTrades {
+		return &RiskRejection{
+			Rule:       RiskRuleMaxOpenTrades,
+			Instrument: instrument,
+			Limit:      float64(l.MaxOpenTrades),
+			Value:      float64(summary.OpenTradeCount + 1),
+			Message:    fmt.Sprintf("%d trades are already open", summary.OpenTradeCount),
+		}
+	}
+	if l.DailyLossLimit > 0 {
+		pl := summary.PL + summary.UnrealizedPL
+		if err := m.startDay(summary.ID, now.UTC().Truncate(24*time.Hour), pl); err != nil {
+			return err
+		}
+		if loss := m.dayStartPL - pl; loss >= l.DailyLossLimit && order.PositionFill != "REDUCE_ONLY" {
+			return &RiskRejection{
+				Rule:       RiskRuleDailyLoss,
+				Instrument: instrument,
+				Limit:      l.DailyLossLimit,
+				Value:      loss,
+				Message:    fmt.Sprintf("the account lost %.2f today, reaching the limit of %.2f", loss, l.DailyLossLimit),
+			}
+		}
+	}
+	return nil
+}
+
+// startDay sets the P/L at the start of day to pl unless it is already set, in memory or in StatePath.
+func (m *RiskManager) startDay(accountID string, day time.Time, pl float64) error {
+	if !m.loaded && m.StatePath != "" {
+		b, err := ioutil.ReadFile(m.StatePath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read risk state: %w", err)
+		}
+		if err == nil {
+			var state riskState
+			if err := json.Unmarshal(b, &state); err != nil {
+				return fmt.Errorf("failed to unmarshal risk state %s: %w", m.StatePath, err)
+			}
+			if state.AccountID != accountID {
+				return fmt.Errorf("risk state %s is of account %s", m.StatePath, state.AccountID)
+			}
+			m.day, m.dayStartPL = state.Day, state.DayStartPL
+		}
+	}
+	m.loaded = true
+	if m.day.Equal(day) {
+		return nil
+	}
+	m.day, m.dayStartPL = day, pl
+	if m.StatePath == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(riskState{AccountID: accountID, Day: day, DayStartPL: pl}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal risk state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.StatePath), 0700); err != nil {
+		return fmt.Errorf("failed to create risk state directory: %w", err)
+	}
+	tmp := m.StatePath + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return fmt.Errorf("failed to write risk state: %w", err)
+	}
+	if err := os.Rename(tmp, m.StatePath); err != nil {
+		return fmt.Errorf("failed to write risk state: %w", err)
+	}
+	return nil
+}
+
+// exposure returns the absolute units of position p after order is filled.
+// An OPEN_ONLY order adds to the exposure even when it is opposite to the position.
+func exposure(p Position, order Order) Unit {
+	if order.PositionFill == "OPEN_ONLY" {
+		return p.Long.Units - p.Short.Units + abs(order.Units)
+	}
+	return abs(p.NetUnits() + order.Units)
+}
@@ -0,0 +1,173 @@
+package oanda
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// failingBroker fails every order it is given.
+type failingBroker struct {
+	RiskBroker
+}
+
+func (failingBroker) CreateOrder(Order) error {
+	return errors.New("unavailable")
+}
+
+func newRiskManager(limits RiskLimits) (*RiskManager, *PaperBroker, *time.Time) {
+	b := NewPaperBroker(PaperConfig{Balance: 1000000})
+	b.Update(ClientPrice{Instrument: InstrumentUSDJPY, Time: paperStart, Tradeable: true, Bid: 105.000, Ask: 105.004})
+	b.Update(ClientPrice{Instrument: InstrumentEURJPY, Time: paperStart, Tradeable: true, Bid: 124.000, Ask: 124.004})
+	now := paperStart
+	m := NewRiskManager(b, limits)
+	m.now = func() time.Time { return now }
+	return m, b, &now
+}
+
+func market(instrument Instrument, units Unit) Order {
+	return Order{Type: OrderTypeMarket, Instrument: instrument, Units: units, TimeInForce: TimeInForceFOK}
+}
+
+func assertRejected(t *testing.T, err error, rule RiskRule) {
+	t.Helper()
+	var rejection *RiskRejection
+	if !errors.As(err, &rejection) || rejection.Rule != rule || !errors.Is(err, ErrRiskRejected) {
+		t.Errorf("err = %v, want a rejection by %s", err, rule)
+	}
+}
+
+func TestRiskMaxUnits(t *testing.T) {
+	m, _, _ := newRiskManager(RiskLimits{MaxUnits: map[Instrument]Unit{InstrumentUSDJPY: 1000}, DefaultMaxUnits: 100})
+	assertRejected(t, m.CreateOrder(market(InstrumentUSDJPY, -1001)), RiskRuleMaxUnits)
+	assertRejected(t, m.CreateOrder(market(InstrumentEURJPY, 101)), RiskRuleMaxUnits)
+	if err := m.CreateOrder(market(InstrumentUSDJPY, 600)); err != nil {
+		t.Fatal(err)
+	}
+	// the resulting position would exceed the limit.
+	assertRejected(t, m.CreateOrder(market(InstrumentUSDJPY, 600)), RiskRuleMaxUnits)
+	if err := m.CreateOrder(market(InstrumentUSDJPY, -1000)); err != nil {
+		t.Errorf("order reducing the position to -400 is rejected: %v", err)
+	}
+}
+
+func TestRiskMaxNotional(t *testing.T) {
+	m, _, _ := newRiskManager(RiskLimits{MaxNotional: 200000})
+	if err := m.CreateOrder(market(InstrumentUSDJPY, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	// 1000 * 105.002 + 1000 * 124.002 exceeds the limit.
+	assertRejected(t, m.CreateOrder(market(InstrumentEURJPY, -1000)), RiskRuleMaxNotional)
+	if err := m.CreateOrder(market(InstrumentEURJPY, 500)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRiskMaxOpenTrades(t *testing.T) {
+	m, _, _ := newRiskManager(RiskLimits{MaxOpenTrades: 1})
+	if err := m.CreateOrder(market(InstrumentUSDJPY, 100)); err != nil {
+		t.Fatal(err)
+	}
+	assertRejected(t, m.CreateOrder(market(InstrumentEURJPY, 100)), RiskRuleMaxOpenTrades)
+	reduce := market(InstrumentUSDJPY, -100)
+	reduce.PositionFill = "REDUCE_ONLY"
+	if err := m.CreateOrder(reduce); err != nil {
+		t.Errorf("reduce only order is rejected: %v", err)
+	}
+}
+
+func TestRiskMaxOrdersPerMinute(t *testing.T) {
+	m, b, now := newRiskManager(RiskLimits{MaxOrdersPerMinute: 2})
+	m.Broker = failingBroker{b}
+	if err := m.CreateOrder(market(InstrumentUSDJPY, 100)); err == nil {
+		t.Fatal("failing broker created an order")
+	}
+	m.Broker = b
+	for i := 0; i < 2; i++ {
+		if err := m.Check(market(InstrumentUSDJPY, 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// neither the failed order nor the checks count.
+	for i := 0; i < 2; i++ {
+		if err := m.CreateOrder(market(InstrumentUSDJPY, 100)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertRejected(t, m.CreateOrder(market(InstrumentUSDJPY, 100)), RiskRuleMaxOrdersPerMinute)
+	*now = now.Add(time.Minute + time.Second)
+	if err := m.CreateOrder(market(InstrumentUSDJPY, 100)); err != nil {
+		t.Errorf("order a minute later is rejected: %v", err)
+	}
+}
+
+func TestRiskDailyLossLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "risk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "risk", "state.json")
+	m, b, now := newRiskManager(RiskLimits{DailyLossLimit: 50})
+	m.StatePath = path
+	if err := m.CreateOrder(market(InstrumentUSDJPY, 1000)); err != nil {
+		t.Fatal(err)
+	}
+	// the unrealized loss of 1000 * 0.054 exceeds the limit.
+	b.Update(ClientPrice{Instrument: InstrumentUSDJPY, Time: paperStart.Add(time.Hour), Tradeable: true, Bid: 104.950, Ask: 104.954})
+	assertRejected(t, m.CreateOrder(market(InstrumentUSDJPY, 100)), RiskRuleDailyLoss)
+
+	// a restarted risk manager keeps the P/L at the start of the day.
+	restarted := NewRiskManager(b, m.Limits)
+	restarted.StatePath = path
+	restarted.now = m.now
+	assertRejected(t, restarted.CreateOrder(market(InstrumentUSDJPY, 100)), RiskRuleDailyLoss)
+	reduce := market(InstrumentUSDJPY, -100)
+	reduce.PositionFill = "REDUCE_ONLY"
+	if err := restarted.CreateOrder(reduce); err != nil {
+		t.Errorf("reduce only order is rejected: %v", err)
+	}
+
+	*now = paperStart.Add(24 * time.Hour)
+	if err := restarted.CreateOrder(market(InstrumentUSDJPY, 100)); err != nil {
+		t.Errorf("order of the next day is rejected: %v", err)
+	}
+}
+
+func TestRiskStopLossRequired(t *testing.T) {
+	m, _, _ := newRiskManager(RiskLimits{RequireStopLoss: true})
+	assertRejected(t, m.CreateOrder(market(InstrumentUSDJPY, 100)), RiskRuleStopLossRequired)
+	o := market(InstrumentUSDJPY, 100)
+	o.StopLossOnFill = &OnFill{Distance: 0.2}
+	if err := m.CreateOrder(o); err != nil {
+		t.Fatal(err)
+	}
+	trades, _ := m.FetchOpenTrades()
+	// orders attached to a trade are not entries.
+	tp := Order{Type: OrderTypeTakeProfit, TradeID: trades[0].ID, Price: 105.5, TimeInForce: TimeInForceGTC}
+	if err := m.CreateOrder(tp); err != nil {
+		t.Errorf("take profit is rejected: %v", err)
+	}
+}
+
+func TestRiskPriceSanity(t *testing.T) {
+	m, b, _ := newRiskManager(RiskLimits{MaxPriceDeviation: 0.05})
+	for _, o := range []Order{
+		{Type: OrderTypeLimit, Instrument: InstrumentUSDJPY, Units: 100, Price: 10.5, TimeInForce: TimeInForceGTC},
+		{Type: OrderTypeMarket, Instrument: InstrumentUSDJPY, Units: 100, StopLossOnFill: &OnFill{Price: 105.1}},
+		{Type: OrderTypeMarket, Instrument: InstrumentUSDJPY, Units: 100, TakeProfitOnFill: &OnFill{Price: 150}},
+	} {
+		assertRejected(t, m.CreateOrder(o), RiskRulePriceSanity)
+	}
+	if err := m.CreateOrder(Order{Type: OrderTypeLimit, Instrument: InstrumentUSDJPY, Units: 100, Price: 104, TimeInForce: TimeInForceGTC}); err != nil {
+		t.Error(err)
+	}
+	b.Update(ClientPrice{Instrument: InstrumentUSDJPY, Time: paperStart, Bid: 105.000, Ask: 105.004})
+	assertRejected(t, m.CreateOrder(market(InstrumentUSDJPY, 100)), RiskRuleNotTradeable)
+	if orders, _ := b.FetchOrders(); len(orders) != 1 {
+		t.Errorf("orders = %+v, want only the accepted limit order", orders)
+	}
+}
@@ -0,0 +1,163 @@
+package oanda
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrBelowMinimumSize is returned when a computed size rounds below the minimum trade size of the instrument.
+var ErrBelowMinimumSize = errors.New("size is below the minimum trade size")
+
+// ErrAboveMaximumSize is returned when a computed size exceeds the units an order can have,
+// which happens for a tiny stop distance of an instrument without MaximumOrderUnits.
+var ErrAboveMaximumSize = errors.New("size is above the maximum order units")
+
+// FixedFractionalUnits returns the units whose loss at a stop distance away is risk.
+// lossConversion converts an amount in the quote currency of the instrument to the account currency,
+// i.e. HomeConversion.AccountLoss of the quote currency.
+func FixedFractionalUnits(details InstrumentDetails, risk float64, distance Price, lossConversion float64) (Unit, error) {
+	if risk <= 0 || distance <= 0 || lossConversion <= 0 {
+		return 0, fmt.Errorf("invalid risk %g, stop distance %g or conversion %g", risk, distance, lossConversion)
+	}
+	return details.RoundUnits(risk / (float64(distance) * lossConversion))
+}
+
+// FixedNotionalUnits returns the units whose value is notional in the account currency.
+// valueConversion converts an amount in the base currency of the instrument to the account currency,
+// i.e. HomeConversion.PositionValue of the base currency.
+func FixedNotionalUnits(details InstrumentDetails, notional float64, valueConversion float64) (Unit, error) {
+	if notional <= 0 || valueConversion <= 0 {
+		return 0, fmt.Errorf("invalid notional %g or conversion %g", notional, valueConversion)
+	}
+	return details.RoundUnits(notional / valueConversion)
+}
+
+// AverageTrueRange returns the average true range of candles over period with Wilder's smoothing.
+// Candles must be in chronological order and number more than period; the mid prices are used,
+// or the average of bid and ask when a candle has no mid.
+func AverageTrueRange(candles []Candle, period int) (Price, error) {
+	if period <= 0 {
+		return 0, fmt.Errorf("invalid period %d", period)
+	}
+	if len(candles) <= period {
+		return 0, fmt.Errorf("%d candles are not enough for a period of %d", len(candles), period)
+	}
+	var atr float64
+	prev, ok := candleMid(candles[0])
+	if !ok {
+		return 0, fmt.Errorf("candle of %s has no prices", candles[0].Time)
+	}
+	for i, c := range candles[1:] {
+		mid, ok := candleMid(c)
+		if !ok {
+			return 0, fmt.Errorf("candle of %s has no prices", c.Time)
+		}
+		tr := math.Max(float64(mid.H-mid.L), math.Max(math.Abs(float64(mid.H-prev.C)), math.Abs(float64(mid.L-prev.C))))
+		if i < period {
+			atr += tr / float64(period)
+		} else {
+			atr = (atr*float64(period-1) + tr) / float64(period)
+		}
+		prev = mid
+	}
+	return Price(atr), nil
+}
+
+func candleMid(c Candle) (OHLC, bool) {
+	switch {
+	case c.Mid != nil:
+		return *c.Mid, true
+	case c.Bid != nil && c.Ask != nil:
+		return OHLC{
+			O: (c.Bid.O + c.Ask.O) / 2,
+			H: (c.Bid.H + c.Ask.H) / 2,
+			L: (c.Bid.L + c.Ask.L) / 2,
+			C: (c.Bid.C + c.Ask.C) / 2,
+		}, true
+	}
+	return OHLC{}, false
+}
+
+// Sizer computes order sizes from the account, instrument and pricing data of a client.
+// The NAV and conversion factors are fetched on every call. Sizes are positive; negate them for short orders.
+type Sizer struct {
+	Client *Client
+}
+
+// NewSizer constructs a Sizer.
+func NewSizer(client *Client) *Sizer {
+	return &Sizer{Client: client}
+}
+
+// FixedFractional returns the units which lose riskPercent percent of the NAV when the stop, pips away, is hit.
+func (s *Sizer) FixedFractional(instrument Instrument, riskPercent float64, stop Pips) (Unit, error) {
+	details, err := s.Client.LookupInstrument(instrument)
+	if err != nil {
+		return 0, err
+	}
+	return s.riskUnits(details, riskPercent, details.PipsToPrice(stop))
+}
+
+// FixedNotional returns the units whose value is notional in the account currency.
+func (s *Sizer) FixedNotional(instrument Instrument, notional float64) (Unit, error) {
+	details, err := s.Client.LookupInstrument(instrument)
+	if err != nil {
+		return 0, err
+	}
+	base, _ := details.Currencies()
+	conversion, err := s.conversion(instrument, base)
+	if err != nil {
+		return 0, err
+	}
+	return FixedNotionalUnits(details, notional, conversion.PositionValue)
+}
+
+// Volatility returns the units which lose riskPercent percent of the NAV when the stop is hit,
+// where the stop is multiplier times the average true range of the last period complete candles of granularity.
+func (s *Sizer) Volatility(instrument Instrument, riskPercent float64, granularity string, period int, multiplier float64) (Unit, error) {
+	details, err := s.Client.LookupInstrument(instrument)
+	if err != nil {
+		return 0, err
+	}
+	candles, err := s.Client.FetchCandles(instrument, CandlesQuery{Granularity: granularity, Price: "M", Count: period + 2})
+	if err != nil {
+		return 0, err
+	}
+	var complete []Candle
+	for _, c := range candles {
+		if c.Complete {
+			complete = append(complete, c)
+		}
+	}
+	atr, err := AverageTrueRange(complete, period)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute average true range of %s: %w", instrument, err)
+	}
+	return s.riskUnits(details, riskPercent, Price(float64(atr)*multiplier))
+}
+
+func (s *Sizer) riskUnits(details InstrumentDetails, riskPercent float64, distance Price) (Unit, error) {
+	summary, err := s.Client.FetchAccountSummary()
+	if err != nil {
+		return 0, err
+	}
+	_, quote := details.Currencies()
+	conversion, err := s.conversion(details.Name, quote)
+	if err != nil {
+		return 0, err
+	}
+	return FixedFractionalUnits(details, summary.NAV*riskPercent/100, distance, conversion.AccountLoss)
+}
+
+func (s *Sizer) conversion(instrument Instrument, currency string) (HomeConversion, error) {
+	conversions, err := s.Client.FetchHomeConversions(instrument)
+	if err != nil {
+		return HomeConversion{}, err
+	}
+	conversion, ok := conversions[currency]
+	if !ok {
+		return HomeConversion{}, fmt.Errorf("no home conversion of %s for %s", currency, instrument)
+	}
+	return conversion, nil
+}
@@ -0,0 +1,133 @@
+package oanda_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+func TestRoundUnits(t *testing.T) {
+	details := oanda.InstrumentDetails{Name: oanda.InstrumentUSDJPY, MinimumTradeSize: 1, MaximumOrderUnits: 100000000}
+	tests := []struct {
+		units float64
+		want  oanda.Unit
+		err   error
+	}{
+		{1234.9, 1234, nil},
+		{-1234.9, -1234, nil},
+		{0.9, 0, oanda.ErrBelowMinimumSize},
+		{1e12, 100000000, nil},
+		{-1e12, -100000000, nil},
+	}
+	for _, tt := range tests {
+		got, err := details.RoundUnits(tt.units)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("RoundUnits(%g) = %d, %v, want %d, %v", tt.units, got, err, tt.want, tt.err)
+		}
+	}
+
+	coarse := details
+	coarse.TradeUnitsPrecision = -2
+	if got, err := coarse.RoundUnits(1299); got != 1200 || err != nil {
+		t.Errorf("RoundUnits(1299) in hundreds = %d, %v, want 1200", got, err)
+	}
+
+	unlimited := details
+	unlimited.MaximumOrderUnits = 0
+	for _, units := range []float64{1e30, -1e30, math.Inf(1)} {
+		if got, err := unlimited.RoundUnits(units); !errors.Is(err, oanda.ErrAboveMaximumSize) {
+			t.Errorf("RoundUnits(%g) without a maximum = %d, %v, want ErrAboveMaximumSize", units, got, err)
+		}
+	}
+	if _, err := unlimited.RoundUnits(math.NaN()); err == nil {
+		t.Error("RoundUnits(NaN) succeeds")
+	}
+	// a tiny stop must not overflow the units.
+	if got, err := oanda.FixedFractionalUnits(unlimited, 10000, 1e-12, 1); !errors.Is(err, oanda.ErrAboveMaximumSize) {
+		t.Errorf("FixedFractionalUnits with a tiny stop = %d, %v, want ErrAboveMaximumSize", got, err)
+	}
+}
+
+func ohlc(o, h, l, c oanda.Price) *oanda.OHLC {
+	return &oanda.OHLC{O: o, H: h, L: l, C: c}
+}
+
+func TestAverageTrueRange(t *testing.T) {
+	candles := []oanda.Candle{
+		{Mid: ohlc(100, 100, 100, 100)},
+		{Mid: ohlc(100, 101, 99.5, 100.5)},
+		{Mid: ohlc(100.5, 100.8, 100.2, 100.4)},
+		// a candle without mid uses the average of bid and ask.
+		{Bid: ohlc(100.3, 101.9, 100.3, 101.4), Ask: ohlc(100.5, 102.1, 100.5, 101.6)},
+	}
+	// true ranges 1.5, 0.6 and 1.6: the average of the first two, then Wilder's smoothing.
+	atr, err := oanda.AverageTrueRange(candles, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ((1.5+0.6)/2 + 1.6) / 2; math.Abs(float64(atr)-want) > 1e-9 {
+		t.Errorf("ATR = %v, want %v", atr, want)
+	}
+	for _, period := range []int{0, 4} {
+		if _, err := oanda.AverageTrueRange(candles, period); err == nil {
+			t.Errorf("ATR of %d candles over %d succeeds", len(candles), period)
+		}
+	}
+	if _, err := oanda.AverageTrueRange(append(candles, oanda.Candle{}), 2); err == nil {
+		t.Error("ATR of a candle without prices succeeds")
+	}
+}
+
+func TestSizer(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	s.SetPrice(oanda.InstrumentEURUSD, 1.18001, 1.18009)
+	start := time.Date(2020, 9, 18, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		s.AddCandles(oanda.InstrumentUSDJPY, "H1", oandatest.Candle{
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			Complete: i < 4,
+			Mid:      &oandatest.OHLC{O: 105, H: 105.1, L: 104.9, C: 105},
+		})
+	}
+	sizer := oanda.NewSizer(s.NewClient())
+	// 1% of the NAV of 1000000 JPY is 10000 JPY.
+	risk := float64(oandatest.DefaultBalance) / 100
+
+	tests := []struct {
+		name string
+		size func() (oanda.Unit, error)
+		want float64
+	}{
+		{"fixed fractional in the account currency", func() (oanda.Unit, error) {
+			return sizer.FixedFractional(oanda.InstrumentUSDJPY, 1, 20)
+		}, risk / 0.2},
+		{"fixed fractional converted at the ask", func() (oanda.Unit, error) {
+			return sizer.FixedFractional(oanda.InstrumentEURUSD, 1, 20)
+		}, risk / (0.002 * 105.009)},
+		{"fixed notional converted at the mid", func() (oanda.Unit, error) {
+			return sizer.FixedNotional(oanda.InstrumentUSDJPY, 1000000)
+		}, 1000000 / 105.005},
+		{"volatility of complete candles", func() (oanda.Unit, error) {
+			return sizer.Volatility(oanda.InstrumentUSDJPY, 1, "H1", 3, 1.5)
+		}, risk / (0.2 * 1.5)},
+	}
+	for _, tt := range tests {
+		got, err := tt.size()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := oanda.Unit(math.Trunc(tt.want + 1e-6)); got != want {
+			t.Errorf("%s: units = %d, want %d", tt.name, got, want)
+		}
+	}
+	if _, err := sizer.Volatility(oanda.InstrumentUSDJPY, 1, "H1", 4, 1); err == nil {
+		t.Error("volatility over more complete candles than there are succeeds")
+	}
+}
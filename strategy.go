@@ -0,0 +1,231 @@
+package oanda
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Strategy is a trading bot run by a Runtime.
+// The hooks of a strategy are called one at a time, never concurrently.
+// An error returned by a hook is logged; a panic stops the strategy.
+type Strategy interface {
+	// OnStart is called once before any other hook with the broker the strategy trades through.
+	// The strategy is not run when it returns an error.
+	OnStart(broker Broker) error
+	// OnPrice is called with every streamed price of the instruments of the strategy.
+	OnPrice(price ClientPrice) error
+	// OnOrderBook is called with every new order book snapshot of the instruments of the strategy.
+	OnOrderBook(book *OrderBook) error
+	// OnFill is called with every ORDER_FILL transaction in the instruments of the strategy.
+	OnFill(fill Transaction) error
+	// OnTradeClosed is called for every trade in the instruments of the strategy which an order fill closed.
+	OnTradeClosed(trade TradeClose) error
+	// OnStop is called once when the runtime stops or a hook panicked, unless OnStart failed.
+	OnStop()
+}
+
+// BaseStrategy implements every hook of Strategy doing nothing.
+// Embed it in a strategy to implement only the hooks it needs.
+type BaseStrategy struct{}
+
+func (BaseStrategy) OnStart(Broker) error           { return nil }
+func (BaseStrategy) OnPrice(ClientPrice) error      { return nil }
+func (BaseStrategy) OnOrderBook(*OrderBook) error   { return nil }
+func (BaseStrategy) OnFill(Transaction) error       { return nil }
+func (BaseStrategy) OnTradeClosed(TradeClose) error { return nil }
+func (BaseStrategy) OnStop()                        {}
+
+// TradeClose is a trade closed by an order fill.
+type TradeClose struct {
+	TradeChange
+	Instrument Instrument
+	Time       time.Time
+	// Reason is the reason of the fill, e.g. TAKE_PROFIT_ORDER or MARKET_ORDER.
+	Reason string
+}
+
+// StrategyConfig is the configuration of a strategy in a Runtime.
+type StrategyConfig struct {
+	// Name identifies the strategy in logs and is the tag of the client extensions of its orders.
+	Name string
+	// Instruments are the instruments the strategy receives events of and may trade.
+	Instruments []Instrument
+	// MaxUnits limits the absolute units of an order of the strategy. Zero means no limit.
+	MaxUnits Unit
+}
+
+// ErrNotPermitted is returned by the broker of a strategy for an operation outside of its configuration.
+var ErrNotPermitted = errors.New("not permitted to the strategy")
+
+// strategyBroker is the Broker given to a strategy. It only shows and operates on
+// orders, trades and positions in the instruments of the strategy.
+type strategyBroker struct {
+	broker      Broker
+	config      StrategyConfig
+	instruments map[Instrument]bool
+}
+
+func newStrategyBroker(broker Broker, config StrategyConfig) *strategyBroker {
+	instruments := map[Instrument]bool{}
+	for _, i := range config.Instruments {
+		instruments[i] = true
+	}
+	return &strategyBroker{broker: broker, config: config, instruments: instruments}
+}
+
+func (b *strategyBroker) FetchOrders() ([]Order, error) {
+	orders, err := b.broker.FetchOrders()
+	var decodeErrs OrderDecodeErrors
+	if err != nil && !errors.As(err, &decodeErrs) {
+		return nil, err
+	}
+	trades, err := b.tradeInstruments(append(orders, decodeErrs.Orders()...))
+	if err != nil {
+		return nil, err
+	}
+	var own []Order
+	for _, o := range orders {
+		if b.instruments[o.Instrument] || b.instruments[trades[o.TradeID]] {
+			own = append(own, o)
+		}
+	}
+	var ownErrs OrderDecodeErrors
+	for _, e := range decodeErrs {
+		if b.instruments[e.Order.Instrument] || b.instruments[trades[e.Order.TradeID]] {
+			ownErrs = append(ownErrs, e)
+		}
+	}
+	if len(ownErrs) > 0 {
+		return own, ownErrs
+	}
+	return own, nil
+}
+
+// tradeInstruments returns the instruments of the trades when an order depends on a trade,
+// since OANDA API omits the instrument of dependent orders.
+func (b *strategyBroker) tradeInstruments(orders []Order) (map[TradeID]Instrument, error) {
+	trades := map[TradeID]Instrument{}
+	for _, o := range orders {
+		if o.Instrument != "" || o.TradeID == "" {
+			continue
+		}
+		open, err := b.broker.FetchOpenTrades()
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range open {
+			trades[t.ID] = t.Instrument
+		}
+		break
+	}
+	return trades, nil
+}
+
+func (b *strategyBroker) CreateOrder(order Order) error {
+	if err := b.permit(&order); err != nil {
+		return err
+	}
+	return b.broker.CreateOrder(order)
+}
+
+func (b *strategyBroker) UpdateOrder(order Order) error {
+	if err := b.ownOrder(order.ID); err != nil {
+		return err
+	}
+	if err := b.permit(&order); err != nil {
+		return err
+	}
+	return b.broker.UpdateOrder(order)
+}
+
+func (b *strategyBroker) CancelOrder(orderID OrderID) error {
+	if err := b.ownOrder(orderID); err != nil {
+		return err
+	}
+	return b.broker.CancelOrder(orderID)
+}
+
+func (b *strategyBroker) FetchOpenTrades() ([]Trade, error) {
+	trades, err := b.broker.FetchOpenTrades()
+	if err != nil {
+		return nil, err
+	}
+	var own []Trade
+	for _, t := range trades {
+		if b.instruments[t.Instrument] {
+			own = append(own, t)
+		}
+	}
+	return own, nil
+}
+
+func (b *strategyBroker) CloseOpenTrade(id TradeID) error {
+	trades, err := b.FetchOpenTrades()
+	if err != nil {
+		return err
+	}
+	for _, t := range trades {
+		if t.ID == id {
+			return b.broker.CloseOpenTrade(id)
+		}
+	}
+	return fmt.Errorf("%w: trade %s", ErrNotPermitted, id)
+}
+
+func (b *strategyBroker) FetchOpenPositions() ([]Position, error) {
+	positions, err := b.broker.FetchOpenPositions()
+	if err != nil {
+		return nil, err
+	}
+	var own []Position
+	for _, p := range positions {
+		if b.instruments[p.Instrument] {
+			own = append(own, p)
+		}
+	}
+	return own, nil
+}
+
+// permit checks the instrument and units of order and tags it and the trade it opens with the name of the strategy.
+func (b *strategyBroker) permit(order *Order) error {
+	instrument := order.Instrument
+	if instrument == "" && order.TradeID != "" {
+		trades, err := b.FetchOpenTrades()
+		if err != nil {
+			return err
+		}
+		for _, t := range trades {
+			if t.ID == order.TradeID {
+				instrument = t.Instrument
+			}
+		}
+	}
+	if !b.instruments[instrument] {
+		return fmt.Errorf("%w: instrument %q", ErrNotPermitted, instrument)
+	}
+	if b.config.MaxUnits != 0 && (order.Units > b.config.MaxUnits || order.Units < -b.config.MaxUnits) {
+		return fmt.Errorf("%w: %d units exceed %d", ErrNotPermitted, order.Units, b.config.MaxUnits)
+	}
+	if order.ClientExtensions == nil && b.config.Name != "" {
+		order.ClientExtensions = &ClientExtensions{Tag: b.config.Name}
+	}
+	if order.TradeClientExtensions == nil && order.TradeID == "" && b.config.Name != "" {
+		order.TradeClientExtensions = &ClientExtensions{Tag: b.config.Name}
+	}
+	return nil
+}
+
+func (b *strategyBroker) ownOrder(id OrderID) error {
+	orders, err := b.FetchOrders()
+	partial, err := partlyDecodedOrders(err)
+	if err != nil {
+		return err
+	}
+	for _, o := range append(orders, partial...) {
+		if o.ID == id {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: order %s", ErrNotPermitted, id)
+}
@@ -0,0 +1,342 @@
+package oanda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+const (
+	// DefaultOrderBookInterval is how often Runtime fetches order books unless OrderBookInterval is set.
+	// OANDA publishes a snapshot every 20 minutes.
+	DefaultOrderBookInterval = time.Minute
+	// DefaultReconnectInterval is how long Runtime waits before reopening a broken stream.
+	DefaultReconnectInterval = 5 * time.Second
+
+	strategyQueueSize = 256
+)
+
+// Runtime runs strategies on the pricing stream, the transaction stream and periodic order book fetches.
+//
+// Every strategy runs in its own goroutine with its own queue of events, so a slow strategy drops
+// its own prices and order books without delaying others, and a strategy which panics is stopped
+// without affecting others. Fills and closed trades are never dropped; they are queued without bound
+// ahead of prices and order books, so a slow strategy never blocks the streams. Strategies trade
+// through a Broker restricted to their instruments.
+type Runtime struct {
+	// Client provides the streams and the order books.
+	Client *Client
+	// Broker is what the strategies trade through, e.g. a RiskManager in front of Client. Nil means Client.
+	Broker Broker
+	// OrderBookInterval is how often the order books of the instruments are fetched. Negative disables fetching.
+	OrderBookInterval time.Duration
+	// ReconnectInterval is how long to wait before reopening a broken stream.
+	ReconnectInterval time.Duration
+
+	strategies []*runningStrategy
+}
+
+type runningStrategy struct {
+	strategy    Strategy
+	config      StrategyConfig
+	instruments map[Instrument]bool
+	// events are the prices and order books, dropped when the queue is full.
+	events chan strategyEvent
+	done   chan struct{}
+
+	mu sync.Mutex
+	// critical are the fills and closed trades, which are never dropped.
+	critical []strategyEvent
+	// wake is signaled when an event is added to critical.
+	wake chan struct{}
+}
+
+// NewRuntime constructs a Runtime with the default intervals.
+func NewRuntime(client *Client) *Runtime {
+	return &Runtime{
+		Client:            client,
+		OrderBookInterval: DefaultOrderBookInterval,
+		ReconnectInterval: DefaultReconnectInterval,
+	}
+}
+
+// Add adds a strategy to run. It must be called before Run.
+func (r *Runtime) Add(strategy Strategy, config StrategyConfig) {
+	instruments := map[Instrument]bool{}
+	for _, i := range config.Instruments {
+		instruments[i] = true
+	}
+	r.strategies = append(r.strategies, &runningStrategy{
+		strategy:    strategy,
+		config:      config,
+		instruments: instruments,
+		events:      make(chan strategyEvent, strategyQueueSize),
+		done:        make(chan struct{}),
+		wake:        make(chan struct{}, 1),
+	})
+}
+
+// Run starts the strategies and dispatches events to them until ctx is done or every strategy has stopped.
+// It calls OnStop of the running strategies before it returns.
+func (r *Runtime) Run(ctx context.Context) error {
+	if len(r.strategies) == 0 {
+		return errors.New("no strategy to run")
+	}
+	var instruments []Instrument
+	seen := map[Instrument]bool{}
+	for _, s := range r.strategies {
+		for _, i := range s.config.Instruments {
+			if !seen[i] {
+				seen[i] = true
+				instruments = append(instruments, i)
+			}
+		}
+	}
+
+	var broker Broker = r.Client
+	if r.Broker != nil {
+		broker = r.Broker
+	}
+	var strategies sync.WaitGroup
+	stopped := make(chan struct{})
+	for _, s := range r.strategies {
+		strategies.Add(1)
+		go func(s *runningStrategy) {
+			defer strategies.Done()
+			s.run(newStrategyBroker(broker, s.config))
+		}(s)
+	}
+	go func() {
+		for _, s := range r.strategies {
+			<-s.done
+		}
+		close(stopped)
+	}()
+
+	sourceCtx, cancel := context.WithCancel(ctx)
+	var sources sync.WaitGroup
+	start := func(source func(context.Context)) {
+		sources.Add(1)
+		go func() {
+			defer sources.Done()
+			source(sourceCtx)
+		}()
+	}
+	if len(instruments) > 0 {
+		start(func(ctx context.Context) { r.streamPricing(ctx, instruments) })
+	}
+	start(r.streamTransactions)
+	if r.OrderBookInterval >= 0 {
+		for _, i := range instruments {
+			i := i
+			start(func(ctx context.Context) { r.pollOrderBook(ctx, i) })
+		}
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-stopped:
+		err = errors.New("every strategy has stopped")
+	}
+	cancel()
+	sources.Wait()
+	for _, s := range r.strategies {
+		close(s.events)
+	}
+	strategies.Wait()
+	return err
+}
+
+// reconnect runs open until ctx is done, waiting ReconnectInterval after every failure.
+func (r *Runtime) reconnect(ctx context.Context, name string, open func(context.Context) error) {
+	for {
+		err := open(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("%s broke, reconnecting in %s: %v", name, r.ReconnectInterval, err)
+		if util.SleepContext(ctx, r.ReconnectInterval) != nil {
+			return
+		}
+	}
+}
+
+func (r *Runtime) streamPricing(ctx context.Context, instruments []Instrument) {
+	r.reconnect(ctx, "pricing stream", func(ctx context.Context) error {
+		return r.Client.StreamPricing(ctx, instruments, func(price ClientPrice) error {
+			r.dispatch(price.Instrument, "OnPrice", true, func(s Strategy) error { return s.OnPrice(price) })
+			return nil
+		})
+	})
+}
+
+func (r *Runtime) streamTransactions(ctx context.Context) {
+	r.reconnect(ctx, "transaction stream", func(ctx context.Context) error {
+		return r.Client.StreamTransactions(ctx, func(t Transaction) error {
+			if t.Type != "ORDER_FILL" {
+				return nil
+			}
+			r.dispatch(t.Instrument, "OnFill", false, func(s Strategy) error { return s.OnFill(t) })
+			for _, c := range t.TradesClosed {
+				closed := TradeClose{TradeChange: c, Instrument: t.Instrument, Time: t.Time, Reason: t.Reason}
+				r.dispatch(t.Instrument, "OnTradeClosed", false, func(s Strategy) error { return s.OnTradeClosed(closed) })
+			}
+			return nil
+		})
+	})
+}
+
+// pollOrderBook fetches the order book of instrument every OrderBookInterval
+// and dispatches the snapshots which are newer than the last one.
+func (r *Runtime) pollOrderBook(ctx context.Context, instrument Instrument) {
+	interval := r.OrderBookInterval
+	if interval == 0 {
+		interval = DefaultOrderBookInterval
+	}
+	var last time.Time
+	for {
+		book, err := r.Client.FetchOrderBook(instrument, nil)
+		if err != nil {
+			log.Printf("failed to fetch order book of %s: %v", instrument, err)
+		} else if book.Time.After(last) {
+			last = book.Time
+			r.dispatch(instrument, "OnOrderBook", true, func(s Strategy) error { return s.OnOrderBook(book) })
+		}
+		if util.SleepContext(ctx, interval) != nil {
+			return
+		}
+	}
+}
+
+// dispatch queues hook for the strategies of instrument without blocking. When droppable, the event
+// is dropped for a strategy whose queue is full; otherwise it is queued as a critical event.
+func (r *Runtime) dispatch(instrument Instrument, hook string, droppable bool, fn func(Strategy) error) {
+	for _, s := range r.strategies {
+		if !s.instruments[instrument] {
+			continue
+		}
+		s := s
+		event := strategyEvent{hook, func() error { return fn(s.strategy) }}
+		if !droppable {
+			s.queueCritical(event)
+			continue
+		}
+		select {
+		case <-s.done:
+		case s.events <- event:
+		default:
+			log.Printf("strategy %s: queue is full, dropped %s of %s", s.config.Name, hook, instrument)
+		}
+	}
+}
+
+// queueCritical queues event unless the strategy has stopped.
+func (s *runningStrategy) queueCritical(event strategyEvent) {
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	s.mu.Lock()
+	s.critical = append(s.critical, event)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextCritical removes and returns the oldest critical event.
+func (s *runningStrategy) nextCritical() (strategyEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.critical) == 0 {
+		return strategyEvent{}, false
+	}
+	event := s.critical[0]
+	s.critical[0] = strategyEvent{}
+	s.critical = s.critical[1:]
+	return event, true
+}
+
+// next returns the next event, critical ones first. It returns false when the events are closed
+// and no critical event is left.
+func (s *runningStrategy) next() (strategyEvent, bool) {
+	for {
+		if event, ok := s.nextCritical(); ok {
+			return event, true
+		}
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				return s.nextCritical()
+			}
+			return event, true
+		case <-s.wake:
+		}
+	}
+}
+
+// run calls OnStart and then the queued hooks until the queues are closed and drained or a hook panics,
+// and calls OnStop at the end.
+func (s *runningStrategy) run(broker Broker) {
+	defer close(s.done)
+	if err := s.call("OnStart", func() error { return s.strategy.OnStart(broker) }); err != nil {
+		log.Printf("strategy %s: failed to start: %v", s.config.Name, err)
+		return
+	}
+	defer func() {
+		_ = s.call("OnStop", func() error {
+			s.strategy.OnStop()
+			return nil
+		})
+	}()
+	for {
+		event, ok := s.next()
+		if !ok {
+			return
+		}
+		err := s.call(event.hook, event.fn)
+		var p *strategyPanic
+		if errors.As(err, &p) {
+			log.Printf("strategy %s: stopped: %v\n%s", s.config.Name, err, p.stack)
+			return
+		}
+		if err != nil {
+			log.Printf("strategy %s: %v", s.config.Name, err)
+		}
+	}
+}
+
+type strategyEvent struct {
+	hook string
+	fn   func() error
+}
+
+type strategyPanic struct {
+	hook  string
+	value interface{}
+	stack []byte
+}
+
+func (p *strategyPanic) Error() string {
+	return fmt.Sprintf("%s panicked: %v", p.hook, p.value)
+}
+
+// call calls fn and turns a panic into a *strategyPanic.
+func (s *runningStrategy) call(hook string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &strategyPanic{hook: hook, value: v, stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,269 @@
+package oanda_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client"
+	"github.com/yuki-inoue-eng/oanda-api-client/oandatest"
+)
+
+// recorder is a strategy which sends its broker and events to channels.
+type recorder struct {
+	oanda.BaseStrategy
+	broker  chan oanda.Broker
+	prices  chan oanda.ClientPrice
+	fills   chan oanda.Transaction
+	stopped chan struct{}
+	// panics makes OnPrice panic.
+	panics bool
+	// block blocks OnFill until it is closed.
+	block chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		broker:  make(chan oanda.Broker, 1),
+		prices:  make(chan oanda.ClientPrice, 1000),
+		fills:   make(chan oanda.Transaction, 1000),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (r *recorder) OnStart(broker oanda.Broker) error {
+	r.broker <- broker
+	return nil
+}
+
+func (r *recorder) OnPrice(price oanda.ClientPrice) error {
+	r.prices <- price
+	if r.panics {
+		panic("broken strategy")
+	}
+	return nil
+}
+
+func (r *recorder) OnFill(fill oanda.Transaction) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.fills <- fill
+	return nil
+}
+
+func (r *recorder) OnStop() {
+	close(r.stopped)
+}
+
+// startRuntime runs the strategies until the test ends.
+func startRuntime(t *testing.T, client *oanda.Client, add func(r *oanda.Runtime)) {
+	t.Helper()
+	runtime := oanda.NewRuntime(client)
+	runtime.OrderBookInterval = -1
+	runtime.ReconnectInterval = 10 * time.Millisecond
+	add(runtime)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = runtime.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+}
+
+func receive(t *testing.T, ch interface{}) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	switch ch := ch.(type) {
+	case chan oanda.ClientPrice:
+		select {
+		case <-ch:
+		case <-timeout:
+			t.Fatal("no price received")
+		}
+	case chan oanda.Transaction:
+		select {
+		case <-ch:
+		case <-timeout:
+			t.Fatal("no fill received")
+		}
+	case chan struct{}:
+		select {
+		case <-ch:
+		case <-timeout:
+			t.Fatal("channel is not closed")
+		}
+	}
+}
+
+func TestRuntimeIsolatesPanics(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	healthy, broken := newRecorder(), newRecorder()
+	broken.panics = true
+	startRuntime(t, s.NewClient(), func(r *oanda.Runtime) {
+		r.Add(healthy, oanda.StrategyConfig{Name: "healthy", Instruments: []oanda.Instrument{oanda.InstrumentUSDJPY}})
+		r.Add(broken, oanda.StrategyConfig{Name: "broken", Instruments: []oanda.Instrument{oanda.InstrumentUSDJPY}})
+	})
+
+	receive(t, healthy.prices)
+	receive(t, broken.prices)
+	receive(t, broken.stopped)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.101, 105.109)
+	receive(t, healthy.prices)
+	select {
+	case <-healthy.stopped:
+		t.Fatal("healthy strategy stopped with the broken one")
+	default:
+	}
+	if n := len(broken.prices); n != 0 {
+		t.Errorf("stopped strategy received %d more prices", n)
+	}
+}
+
+func TestRuntimeNeverDropsFills(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	fast, slow := newRecorder(), newRecorder()
+	slow.block = make(chan struct{})
+	startRuntime(t, client, func(r *oanda.Runtime) {
+		r.Add(fast, oanda.StrategyConfig{Name: "fast", Instruments: []oanda.Instrument{oanda.InstrumentUSDJPY}})
+		r.Add(slow, oanda.StrategyConfig{Name: "slow", Instruments: []oanda.Instrument{oanda.InstrumentUSDJPY}})
+	})
+	receive(t, fast.prices)
+	receive(t, slow.prices)
+
+	// more fills than the queue of a strategy holds, while the slow strategy is blocked.
+	const n = 300
+	for i := 0; i < n; i++ {
+		if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 1, TimeInForce: oanda.TimeInForceFOK}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < n; i++ {
+		receive(t, fast.fills)
+	}
+	close(slow.block)
+	for i := 0; i < n; i++ {
+		receive(t, slow.fills)
+	}
+}
+
+func TestStrategyBrokerRestrictions(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	s.SetPrice(oanda.InstrumentEURUSD, 1.18001, 1.18009)
+	client := s.NewClient()
+	// a trade and an order of another instrument.
+	if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentEURUSD, Units: 100, TimeInForce: oanda.TimeInForceFOK}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.CreateOrder(oanda.Order{Type: oanda.OrderTypeLimit, Instrument: oanda.InstrumentEURUSD, Units: 100, Price: 1.17, TimeInForce: oanda.TimeInForceGTC}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := client.FetchOpenTrades()
+	if err != nil {
+		t.Fatal(err)
+	}
+	others, err := client.FetchOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	strategy := newRecorder()
+	startRuntime(t, client, func(r *oanda.Runtime) {
+		r.Add(strategy, oanda.StrategyConfig{Name: "scalp", Instruments: []oanda.Instrument{oanda.InstrumentUSDJPY}, MaxUnits: 1000})
+	})
+	var broker oanda.Broker
+	select {
+	case broker = <-strategy.broker:
+	case <-time.After(5 * time.Second):
+		t.Fatal("strategy did not start")
+	}
+
+	for _, o := range []oanda.Order{
+		{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentEURUSD, Units: 100, TimeInForce: oanda.TimeInForceFOK},
+		{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: -1001, TimeInForce: oanda.TimeInForceFOK},
+		{Type: oanda.OrderTypeTakeProfit, TradeID: other[0].ID, Price: 1.2, TimeInForce: oanda.TimeInForceGTC},
+	} {
+		if err := broker.CreateOrder(o); !errors.Is(err, oanda.ErrNotPermitted) {
+			t.Errorf("CreateOrder(%s %d %s) err = %v, want ErrNotPermitted", o.Type, o.Units, o.Instrument, err)
+		}
+	}
+	if err := broker.CancelOrder(others[0].ID); !errors.Is(err, oanda.ErrNotPermitted) {
+		t.Errorf("CancelOrder of another instrument err = %v, want ErrNotPermitted", err)
+	}
+	if err := broker.CloseOpenTrade(other[0].ID); !errors.Is(err, oanda.ErrNotPermitted) {
+		t.Errorf("CloseOpenTrade of another instrument err = %v, want ErrNotPermitted", err)
+	}
+
+	if err := broker.CreateOrder(oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 1000, TimeInForce: oanda.TimeInForceFOK}); err != nil {
+		t.Fatal(err)
+	}
+	trades, err := broker.FetchOpenTrades()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].Instrument != oanda.InstrumentUSDJPY {
+		t.Fatalf("trades = %+v, want only the USD_JPY trade", trades)
+	}
+	if trades[0].ClientExtensions == nil || trades[0].ClientExtensions.Tag != "scalp" {
+		t.Errorf("client extensions = %+v, want the tag of the strategy", trades[0].ClientExtensions)
+	}
+	if err := broker.CreateOrder(oanda.Order{Type: oanda.OrderTypeTakeProfit, TradeID: trades[0].ID, Price: 106, TimeInForce: oanda.TimeInForceGTC}); err != nil {
+		t.Fatal(err)
+	}
+	orders, err := broker.FetchOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Type != oanda.OrderTypeTakeProfit {
+		t.Errorf("orders = %+v, want only the take profit of the own trade", orders)
+	}
+	positions, err := broker.FetchOpenPositions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 1 || positions[0].Instrument != oanda.InstrumentUSDJPY {
+		t.Errorf("positions = %+v, want only USD_JPY", positions)
+	}
+	if err := broker.CloseOpenTrade(trades[0].ID); err != nil {
+		t.Errorf("CloseOpenTrade of the own trade failed: %v", err)
+	}
+}
+
+func TestRuntimeTradesThroughBroker(t *testing.T) {
+	s := oandatest.NewServer()
+	t.Cleanup(s.Close)
+	s.SetPrice(oanda.InstrumentUSDJPY, 105.001, 105.009)
+	client := s.NewClient()
+	strategy := newRecorder()
+	startRuntime(t, client, func(r *oanda.Runtime) {
+		r.Broker = oanda.NewRiskManager(client, oanda.RiskLimits{DefaultMaxUnits: 100})
+		r.Add(strategy, oanda.StrategyConfig{Name: "scalp", Instruments: []oanda.Instrument{oanda.InstrumentUSDJPY}})
+	})
+	var broker oanda.Broker
+	select {
+	case broker = <-strategy.broker:
+	case <-time.After(5 * time.Second):
+		t.Fatal("strategy did not start")
+	}
+	order := oanda.Order{Type: oanda.OrderTypeMarket, Instrument: oanda.InstrumentUSDJPY, Units: 101, TimeInForce: oanda.TimeInForceFOK}
+	if err := broker.CreateOrder(order); !errors.Is(err, oanda.ErrRiskRejected) {
+		t.Errorf("err = %v, want ErrRiskRejected", err)
+	}
+	if trades, _ := client.FetchOpenTrades(); len(trades) != 0 {
+		t.Errorf("trades = %+v, want the order rejected before it is sent", trades)
+	}
+}
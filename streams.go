@@ -0,0 +1,223 @@
+package oanda
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/yuki-inoue-eng/oanda-api-client/internal/util"
+)
+
+// HeartbeatTimeout is how long a stream may be silent before it is considered dead.
+// OANDA API sends a heartbeat every 5 seconds.
+const HeartbeatTimeout = 20 * time.Second
+
+type streamLine struct {
+	Type string `json:"type"`
+}
+
+type tradeChangeInfo struct {
+	TradeID    string `json:"tradeID"`
+	Units      string `json:"units"`
+	Price      string `json:"price"`
+	RealizedPL string `json:"realizedPL"`
+	Financing  string `json:"financing"`
+}
+
+type transactionInfo struct {
+	ID            string            `json:"id"`
+	Time          time.Time         `json:"time"`
+	Type          string            `json:"type"`
+	Instrument    string            `json:"instrument"`
+	Units         string            `json:"units"`
+	Price         string            `json:"price"`
+	PL            string            `json:"pl"`
+	Financing     string            `json:"financing"`
+	OrderID       string            `json:"orderID"`
+	ClientOrderID string            `json:"clientOrderID"`
+	TradeID       string            `json:"tradeID"`
+	Reason        string            `json:"reason"`
+	RejectReason  string            `json:"rejectReason"`
+	TradeOpened   *tradeChangeInfo  `json:"tradeOpened"`
+	TradesClosed  []tradeChangeInfo `json:"tradesClosed"`
+	TradeReduced  *tradeChangeInfo  `json:"tradeReduced"`
+}
+
+// TradeChange is a trade opened, closed or reduced by an order fill.
+type TradeChange struct {
+	TradeID    TradeID
+	Units      Unit
+	Price      Price
+	RealizedPL float64
+	Financing  float64
+}
+
+// Transaction is a transaction of an account. Fields which do not apply to Type are zero.
+type Transaction struct {
+	ID            TransactionID
+	Time          time.Time
+	Type          string
+	Instrument    Instrument
+	Units         Unit
+	Price         Price
+	PL            float64
+	Financing     float64
+	OrderID       OrderID
+	ClientOrderID string // client extensions id of the order
+	TradeID       TradeID
+	Reason        string // reason of the transaction, or the reject reason of a rejection
+	TradeOpened   *TradeChange
+	TradesClosed  []TradeChange
+	TradeReduced  *TradeChange
+	// Raw is the transaction object exactly as OANDA API sent it.
+	Raw json.RawMessage
+}
+
+// Fill returns the order fill of an ORDER_FILL transaction.
+func (t *Transaction) Fill() OrderFill {
+	return OrderFill{
+		TransactionID: t.ID,
+		Time:          t.Time,
+		Instrument:    t.Instrument,
+		Units:         t.Units,
+		Price:         t.Price,
+		PL:            t.PL,
+		Financing:     t.Financing,
+	}
+}
+
+func (i *tradeChangeInfo) toTradeChange(p *fieldParser) TradeChange {
+	return TradeChange{
+		TradeID:    TradeID(i.TradeID),
+		Units:      p.units("units", i.Units),
+		Price:      p.price("price", i.Price),
+		RealizedPL: p.amount("realized pl", i.RealizedPL),
+		Financing:  p.amount("financing", i.Financing),
+	}
+}
+
+func (i *transactionInfo) toTransaction(raw []byte) (Transaction, error) {
+	var p fieldParser
+	t := Transaction{
+		ID:            TransactionID(i.ID),
+		Time:          i.Time,
+		Type:          i.Type,
+		Instrument:    Instrument(i.Instrument),
+		Units:         p.units("units", i.Units),
+		Price:         p.price("price", i.Price),
+		PL:            p.amount("pl", i.PL),
+		Financing:     p.amount("financing", i.Financing),
+		OrderID:       OrderID(i.OrderID),
+		ClientOrderID: i.ClientOrderID,
+		TradeID:       TradeID(i.TradeID),
+		Reason:        i.Reason,
+		Raw:           append(json.RawMessage(nil), raw...),
+	}
+	if t.Reason == "" {
+		t.Reason = i.RejectReason
+	}
+	if i.TradeOpened != nil {
+		opened := i.TradeOpened.toTradeChange(&p)
+		t.TradeOpened = &opened
+	}
+	for _, c := range i.TradesClosed {
+		t.TradesClosed = append(t.TradesClosed, c.toTradeChange(&p))
+	}
+	if i.TradeReduced != nil {
+		reduced := i.TradeReduced.toTradeChange(&p)
+		t.TradeReduced = &reduced
+	}
+	return t, p.err
+}
+
+// StreamPricing streams the prices of instruments to handle until ctx is done, handle returns an error
+// or the stream breaks. It returns ctx.Err() when ctx is done.
+func (c *Client) StreamPricing(ctx context.Context, instruments []Instrument, handle func(ClientPrice) error) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := c.streamPricing(streamCtx, instruments)
+	if err != nil {
+		return fmt.Errorf("failed to open pricing stream: %w", err)
+	}
+	return readStream(ctx, cancel, resp, func(typ string, line []byte) error {
+		if typ != "PRICE" {
+			return nil
+		}
+		var info priceInfo
+		if err := json.Unmarshal(line, &info); err != nil {
+			return fmt.Errorf("failed to json unmarshal: %w", err)
+		}
+		price, err := info.toClientPrice()
+		if err != nil {
+			return fmt.Errorf("failed to convert price of %s: %w", info.Instrument, err)
+		}
+		return handle(price)
+	})
+}
+
+// StreamTransactions streams the transactions of the account to handle until ctx is done, handle returns an error
+// or the stream breaks. It returns ctx.Err() when ctx is done.
+func (c *Client) StreamTransactions(ctx context.Context, handle func(Transaction) error) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := c.streamTransactions(streamCtx)
+	if err != nil {
+		return fmt.Errorf("failed to open transaction stream: %w", err)
+	}
+	return readStream(ctx, cancel, resp, func(typ string, line []byte) error {
+		if typ == "HEARTBEAT" {
+			return nil
+		}
+		var info transactionInfo
+		if err := json.Unmarshal(line, &info); err != nil {
+			return fmt.Errorf("failed to json unmarshal: %w", err)
+		}
+		t, err := info.toTransaction(line)
+		if err != nil {
+			return fmt.Errorf("failed to convert transaction %s: %w", info.ID, err)
+		}
+		return handle(t)
+	})
+}
+
+// readStream passes every line of the stream to handle with its type.
+// It cancels the stream with cancel when no line arrives within HeartbeatTimeout.
+func readStream(ctx context.Context, cancel context.CancelFunc, resp *http.Response, handle func(typ string, line []byte) error) error {
+	defer util.SafeClose(resp.Body)
+	var timedOut int32
+	watchdog := time.AfterFunc(HeartbeatTimeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		cancel()
+	})
+	defer watchdog.Stop()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		watchdog.Reset(HeartbeatTimeout)
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var l streamLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return fmt.Errorf("failed to json unmarshal: %w", err)
+		}
+		if err := handle(l.Type, line); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if atomic.LoadInt32(&timedOut) == 1 {
+		return fmt.Errorf("no heartbeat for %s", HeartbeatTimeout)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return fmt.Errorf("stream closed")
+}
@@ -1,68 +1,132 @@
 package oanda
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
 )
 
+type tradeInfo struct {
+	ClientExtensions      *ClientExtensions    `json:"clientExtensions,omitempty"`
+	CurrentUnits          string               `json:"currentUnits"`
+	Financing             string               `json:"financing"`
+	ID                    string               `json:"id"`
+	InitialUnits          string               `json:"initialUnits"`
+	Instrument            string               `json:"instrument"`
+	OpenTime              time.Time            `json:"openTime"`
+	Price                 string               `json:"price"`
+	RealizedPL            string               `json:"realizedPL"`
+	State                 string               `json:"state"`
+	UnrealizedPL          string               `json:"unrealizedPL"`
+	TakeProfitOrder       *struct{ ID string } `json:"takeProfitOrder"`
+	StopLossOrder         *struct{ ID string } `json:"stopLossOrder"`
+	TrailingStopLossOrder *struct{ ID string } `json:"trailingStopLossOrder"`
+}
+
 type receivedTrades struct {
-	LastTransactionID string `json:"lastTransactionID"`
-	Trades            []struct {
-		CurrentUnits string    `json:"currentUnits"`
-		Financing    string    `json:"financing"`
-		ID           string    `json:"id"`
-		InitialUnits string    `json:"initialUnits"`
-		Instrument   string    `json:"instrument"`
-		OpenTime     time.Time `json:"openTime"`
-		Price        string    `json:"price"`
-		RealizedPL   string    `json:"realizedPL"`
-		State        string    `json:"state"`
-		UnrealizedPL string    `json:"unrealizedPL"`
-	} `json:"trades"`
+	LastTransactionID string      `json:"lastTransactionID"`
+	Trades            []tradeInfo `json:"trades"`
 }
 
 type Trade struct {
-	ID       tradeID
-	OpenTime *time.Time
+	ID                      TradeID           `json:"id,omitempty"`
+	OpenTime                *time.Time        `json:"openTime,omitempty"`
+	Instrument              Instrument        `json:"instrument,omitempty"`
+	Price                   Price             `json:"price,omitempty"`
+	State                   string            `json:"state,omitempty"`
+	InitialUnits            Unit              `json:"initialUnits"`
+	CurrentUnits            Unit              `json:"currentUnits"` // negative for a short trade
+	RealizedPL              float64           `json:"realizedPL"`
+	UnrealizedPL            float64           `json:"unrealizedPL"`
+	Financing               float64           `json:"financing"`
+	ClientExtensions        *ClientExtensions `json:"clientExtensions,omitempty"`
+	TakeProfitOrderID       OrderID           `json:"takeProfitOrderID,omitempty"`
+	StopLossOrderID         OrderID           `json:"stopLossOrderID,omitempty"`
+	TrailingStopLossOrderID OrderID           `json:"trailingStopLossOrderID,omitempty"`
+}
+
+func (t *tradeInfo) toTrade() (Trade, error) {
+	var p fieldParser
+	openTime := t.OpenTime
+	trade := Trade{
+		ID:               TradeID(t.ID),
+		OpenTime:         &openTime,
+		Instrument:       Instrument(t.Instrument),
+		Price:            p.price("price", t.Price),
+		State:            t.State,
+		InitialUnits:     p.units("initial units", t.InitialUnits),
+		CurrentUnits:     p.units("current units", t.CurrentUnits),
+		RealizedPL:       p.amount("realized pl", t.RealizedPL),
+		UnrealizedPL:     p.amount("unrealized pl", t.UnrealizedPL),
+		Financing:        p.amount("financing", t.Financing),
+		ClientExtensions: t.ClientExtensions,
+	}
+	if t.TakeProfitOrder != nil {
+		trade.TakeProfitOrderID = OrderID(t.TakeProfitOrder.ID)
+	}
+	if t.StopLossOrder != nil {
+		trade.StopLossOrderID = OrderID(t.StopLossOrder.ID)
+	}
+	if t.TrailingStopLossOrder != nil {
+		trade.TrailingStopLossOrderID = OrderID(t.TrailingStopLossOrder.ID)
+	}
+	return trade, p.err
 }
 
-func (r *receivedTrades) toTrades() []Trade {
+func (r *receivedTrades) toTrades() ([]Trade, error) {
 	var trades []Trade
 	for _, t := range r.Trades {
-		trades = append(trades, Trade{
-			ID:       tradeID(t.ID),
-			OpenTime: &t.OpenTime,
-		})
+		trade, err := t.toTrade()
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert trade (id=%s): %w", t.ID, err)
+		}
+		trades = append(trades, trade)
 	}
-	return trades
+	return trades, nil
+}
+
+// Side returns SideBuy for a long trade and SideSell for a short one.
+func (t *Trade) Side() Side {
+	if t.CurrentUnits < 0 || (t.CurrentUnits == 0 && t.InitialUnits < 0) {
+		return SideSell
+	}
+	return SideBuy
 }
 
 func (c *Client) FetchOpenTrades() ([]Trade, error) {
 	body, err := c.fetchOpenTrades()
 	if err != nil {
-		return nil, fmt.Errorf("fariled to fetch open trades: %v", err)
+		return nil, fmt.Errorf("fariled to fetch open trades: %w", err)
 	}
 	var rt receivedTrades
 	if err := json.Unmarshal(body, &rt); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal: %w", err)
 	}
-	return rt.toTrades(), nil
+	trades, err := rt.toTrades()
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert received trades to type of Trades: %w", err)
+	}
+	return trades, nil
 }
 
-func (c *Client) FetchOpenTradesJSON() ([]byte, error){
+func (c *Client) FetchOpenTradesJSON() ([]byte, error) {
 	return c.fetchOpenTrades()
 }
 
-func (c *Client) CloseOpenTrade(id tradeID) error {
+func (c *Client) CloseOpenTrade(id TradeID) error {
+	return c.closeOpenTrade(context.Background(), id)
+}
+
+func (c *Client) closeOpenTrade(ctx context.Context, id TradeID) error {
 	body, err := json.Marshal(struct {
 		Units string `json:"units"`
 	}{Units: "ALL"})
 	if err != nil {
-		return fmt.Errorf("failed to marshal: %v", err)
+		return fmt.Errorf("failed to marshal: %w", err)
 	}
-	if err := c.reduceTradeSize(id, body); err != nil {
-		return fmt.Errorf("failed to close trade (id=%s): %v", string(id), err)
+	if err := c.reduceTradeSize(ctx, id, body); err != nil {
+		return fmt.Errorf("failed to close trade (id=%s): %w", string(id), err)
 	}
 	return nil
 }
@@ -0,0 +1,41 @@
+package oanda
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type receivedTransactions struct {
+	Transactions      []json.RawMessage `json:"transactions"`
+	LastTransactionID string            `json:"lastTransactionID"`
+}
+
+// FetchTransactionsSince fetches the transactions after id in order, and the id of the last transaction of the account.
+// An id of "0" fetches every transaction.
+func (c *Client) FetchTransactionsSince(id TransactionID) ([]Transaction, TransactionID, error) {
+	body, err := c.fetchTransactionsSinceID(id)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	var rt receivedTransactions
+	if err := json.Unmarshal(body, &rt); err != nil {
+		return nil, "", fmt.Errorf("failed to json unmarshal: %w", err)
+	}
+	transactions := make([]Transaction, 0, len(rt.Transactions))
+	for _, raw := range rt.Transactions {
+		var info transactionInfo
+		if err := json.Unmarshal(raw, &info); err != nil {
+			return nil, "", fmt.Errorf("failed to json unmarshal: %w", err)
+		}
+		t, err := info.toTransaction(raw)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to convert transaction %s: %w", info.ID, err)
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, TransactionID(rt.LastTransactionID), nil
+}
+
+func (c *Client) FetchTransactionsSinceJSON(id TransactionID) ([]byte, error) {
+	return c.fetchTransactionsSinceID(id)
+}
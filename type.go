@@ -1,51 +1,94 @@
 package oanda
 
-import "strconv"
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
 
 const (
-	SideBuy                     = side("buy")
-	SideSell                    = side("sell")
-	OrderTypeMarket             = orderType("MARKET")
-	OrderTypeLimit              = orderType("LIMIT")
-	OrderTypeStop               = orderType("STOP")
-	OrderTypeMarketIfTouched    = orderType("MARKET_IF_TOUCHED")
-	OrderTypeTakeProfit         = orderType("TAKE_PROFIT")
-	OrderTypeStopLoss           = orderType("STOP_LOSS")
-	OrderTypeGuaranteedStopLoss = orderType("GUARANTEED_STOP_LOSS")
-	OrderTypeTrailingStopLoss   = orderType("TRAILING_STOP_LOSS")
-	OrderFixedPrice             = orderType("FIXED_PRICE")
-	TimeInForceGTC              = timeInForce("GTC")
-	TimeInForceGTD              = timeInForce("GTD")
-	TimeInForceGFD              = timeInForce("GFD")
-	TimeInForceFOK              = timeInForce("FOK")
-	TimeInForceIOC              = timeInForce("IOC")
-	InstrumentUSDJPY            = instrument("USD_JPY")
-	InstrumentEURJPY            = instrument("EUR_JPY")
-	InstrumentEURUSD            = instrument("EUR_USD")
+	SideBuy                     = Side("buy")
+	SideSell                    = Side("sell")
+	OrderTypeMarket             = OrderType("MARKET")
+	OrderTypeLimit              = OrderType("LIMIT")
+	OrderTypeStop               = OrderType("STOP")
+	OrderTypeMarketIfTouched    = OrderType("MARKET_IF_TOUCHED")
+	OrderTypeTakeProfit         = OrderType("TAKE_PROFIT")
+	OrderTypeStopLoss           = OrderType("STOP_LOSS")
+	OrderTypeGuaranteedStopLoss = OrderType("GUARANTEED_STOP_LOSS")
+	OrderTypeTrailingStopLoss   = OrderType("TRAILING_STOP_LOSS")
+	OrderFixedPrice             = OrderType("FIXED_PRICE")
+	TimeInForceGTC              = TimeInForce("GTC")
+	TimeInForceGTD              = TimeInForce("GTD")
+	TimeInForceGFD              = TimeInForce("GFD")
+	TimeInForceFOK              = TimeInForce("FOK")
+	TimeInForceIOC              = TimeInForce("IOC")
+	InstrumentUSDJPY            = Instrument("USD_JPY")
+	InstrumentEURJPY            = Instrument("EUR_JPY")
+	InstrumentEURUSD            = Instrument("EUR_USD")
 )
 
 type Price float64
-func (p *Price)String()string{
+
+func (p *Price) String() string {
 	return strconv.FormatFloat(float64(*p), 'f', 7, 64)
 }
 
 type Pips float64 // valid up to the first minority
 
-type side string
+// Decimal is a decimal number exactly as OANDA API sends it, e.g. "0.050". The empty Decimal is unknown.
+type Decimal string
 
-type orderType string
+// NewDecimal returns p as the shortest decimal which parses back to p.
+func NewDecimal(p Price) Decimal {
+	return Decimal(formatDecimal(p))
+}
 
-type timeInForce string
+// ParseDecimal validates s as a finite decimal number.
+func ParseDecimal(s string) (Decimal, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("%q is not a finite decimal", s)
+	}
+	return Decimal(s), nil
+}
 
-type orderID string
+// Price returns d as a Price, or 0 when d is unknown.
+func (d Decimal) Price() Price {
+	f, _ := strconv.ParseFloat(string(d), 64)
+	return Price(f)
+}
 
-type tradeID string
+// Side is the direction of an order or trade.
+type Side string
 
-type instrument string
+// OrderType is the type of an order, e.g. MARKET or TAKE_PROFIT.
+type OrderType string
+
+// TimeInForce specifies how long an order remains pending.
+type TimeInForce string
+
+// OrderID identifies an order within an account.
+type OrderID string
+
+// TradeID identifies a trade within an account.
+type TradeID string
+
+// TransactionID identifies a transaction within an account.
+type TransactionID string
+
+// Instrument is the name of an instrument, e.g. USD_JPY.
+type Instrument string
 
 type Unit int
 
-func (p *Pips)PipsToPrice(instrument string) Price {
+// PipsToPrice returns the price distance of p in USD_JPY, EUR_JPY or EUR_USD.
+//
+// Deprecated: use InstrumentDetails.PipsToPrice, which applies to every instrument.
+func (p *Pips) PipsToPrice(instrument string) Price {
 	if instrument == "USD_JPY" {
 		return Price(float64(*p) * 0.01)
 	}
@@ -56,4 +99,4 @@ func (p *Pips)PipsToPrice(instrument string) Price {
 		return Price(float64(*p) * 0.0001)
 	}
 	return 0
-}
\ No newline at end of file
+}